@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+//FindAllRateEndPoint is FindAllRateEndPoint
+func (m *DataObjectAccess) FindAllRateEndPoint(c echo.Context) (err error) {
+	return c.JSON(http.StatusOK, MapJSONRate(m.rates.Rates()))
+}
+
+//SetRatesEndPoint for replace every ExchangeRate with the list in body
+func (m *DataObjectAccess) SetRatesEndPoint(c echo.Context) (err error) {
+	var rates []model.ExchangeRate
+	if err := c.Bind(&rates); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	err = m.rates.SetRates(rates)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(rates)
+	return c.JSON(http.StatusOK, MapJSONRate(m.rates.Rates()))
+}
+
+//SetUserRolesEndPoint is SetUserRolesEndPoint, Roles of User are replaced by roles of body
+func (m *DataObjectAccess) SetUserRolesEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	r := new(model.RoleAssignment)
+	if err := c.Bind(r); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	version, err := ParseIfMatch(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	userResp, err := m.userService.SetRoles(user, r.Roles, version)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(model.NewUserResponse(*userResp))
+	c.Response().Header().Set(HeaderETag, ETag(userResp.Version))
+	return c.JSON(http.StatusOK, MapJSONUser(model.NewUserResponse(*userResp)))
+}
+
+//MapJSONRate for MapJSONRate
+func MapJSONRate(rates interface{}) interface{} {
+	dataJSON := map[string]interface{}{
+		"rates": rates,
+	}
+	return dataJSON
+}
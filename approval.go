@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+//ApprovalExpiryInterval is how often pending Approval past ExpiresAt are expired
+const ApprovalExpiryInterval = time.Minute
+
+var (
+	//ErrApprovalNotFound is returned when there is no Approval of ID
+	ErrApprovalNotFound = errors.New("Not Have ApprovalID")
+	//ErrApprovalSelf is returned when maker of Approval tries to approve or reject it
+	ErrApprovalSelf = errors.New("approval must be decided by someone other than who asked for it")
+	//ErrApprovalDecided is returned when Approval is not pending anymore
+	ErrApprovalDecided = errors.New("approval is not pending")
+	//ErrApprovalExpired is returned when Approval is decided after ExpiresAt
+	ErrApprovalExpired = errors.New("approval expired")
+	//ErrApprovalPending is returned when the same operation is already waiting for approval
+	ErrApprovalPending = errors.New("operation is already waiting for approval")
+	//ErrApprovalRequired is returned when Tranfer above approval_tranfer_threshold runs without Approval
+	ErrApprovalRequired = errors.New("tranfer above approval_tranfer_threshold must be approved first")
+)
+
+//ApprovalService is interface
+type ApprovalService interface {
+	NeedsApproval(tranfer *model.Tranfer, userFrom model.User) (bool, error)
+	RequestTranfer(tranfer *model.Tranfer, userFrom model.User, makerID string) (*model.Approval, error)
+	RequestDeleteBankAccount(user model.User, id string, makerID string) (*model.Approval, error)
+	RequestDeleteUser(user model.User, makerID string) (*model.Approval, error)
+	FindApprovals(states []model.ApprovalState) ([]model.Approval, error)
+	FindApproval(id string) (model.Approval, []model.AuditEntry, error)
+	Approve(id string, checkerID string) (*model.Approval, error)
+	Reject(id string, checkerID string, reason string) (*model.Approval, error)
+	ExpireApprovals(now time.Time) (int, error)
+	RecoverApprovals() error
+}
+
+//ApprovalServiceImplement is struct
+type ApprovalServiceImplement struct {
+	approvals          repository.ApprovalRepository
+	audit              repository.AuditRepository
+	userService        UserService
+	bankAccountService BankAccountService
+	tranfers           *TranferServiceImplement
+	//window is how long Approval waits for checker
+	window time.Duration
+}
+
+//NeedsApproval for check Tranfer is above approval_tranfer_threshold, Tranfer that can not be compared
+//does not need it since Tranfer rejects it anyway
+func (a *ApprovalServiceImplement) NeedsApproval(tranfer *model.Tranfer, userFrom model.User) (bool, error) {
+	bankAccountFrom, ok := findBankAccountByNumber(userFrom, tranfer.From)
+	if !ok {
+		return false, nil
+	}
+	amount, err := tranfer.Amount.In(bankAccountFrom.AccountCurrency())
+	if err != nil {
+		return false, nil
+	}
+	return a.tranfers.needsApproval(amount)
+}
+
+//RequestTranfer for Approval of tranfer from userFrom asked for by makerID
+func (a *ApprovalServiceImplement) RequestTranfer(tranfer *model.Tranfer, userFrom model.User, makerID string) (*model.Approval, error) {
+	bankAccountFrom, ok := findBankAccountByNumber(userFrom, tranfer.From)
+	if !ok {
+		return nil, errors.New("Not Have BankAccountID From")
+	}
+	amount, err := tranfer.Amount.In(bankAccountFrom.AccountCurrency())
+	if err != nil {
+		return nil, err
+	}
+	//Amount is kept in Currency of From so Approval shows what will leave it
+	approval := model.Approval{Kind: model.ApprovalTranfer, UserID: userFrom.ID, Tranfer: &model.Tranfer{Amount: amount, From: tranfer.From, To: tranfer.To}}
+	detail := fmt.Sprintf("tranfer %s %s from %s to %s", amount, amount.Currency, tranfer.From, tranfer.To)
+	return a.request(approval, makerID, detail)
+}
+
+//RequestDeleteBankAccount for Approval of DeleteBankAccount of id of user asked for by makerID, BankAccount
+//with money left is refused before anybody is asked
+func (a *ApprovalServiceImplement) RequestDeleteBankAccount(user model.User, id string, makerID string) (*model.Approval, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
+	}
+	bankAccount, ok := findBankAccount(user, bson.ObjectIdHex(id))
+	if !ok {
+		return nil, ErrBankAccountNotFound
+	}
+	if err := checkEmpty([]model.BankAccount{bankAccount}); err != nil {
+		return nil, err
+	}
+	approval := model.Approval{Kind: model.ApprovalDeleteBankAccount, UserID: user.ID, BankAccountID: bankAccount.ID}
+	return a.request(approval, makerID, "delete bank account "+bankAccount.AccountNumber)
+}
+
+//RequestDeleteUser for Approval of DeleteUser of user asked for by makerID, user with money left is refused
+//before anybody is asked
+func (a *ApprovalServiceImplement) RequestDeleteUser(user model.User, makerID string) (*model.Approval, error) {
+	if err := checkEmpty(user.UserBankAccount); err != nil {
+		return nil, err
+	}
+	approval := model.Approval{Kind: model.ApprovalDeleteUser, UserID: user.ID}
+	return a.request(approval, makerID, "delete user "+user.Username)
+}
+
+//request for store approval as pending until window is over and audit it, the same delete can wait only once
+func (a *ApprovalServiceImplement) request(approval model.Approval, makerID string, detail string) (*model.Approval, error) {
+	if approval.Kind != model.ApprovalTranfer {
+		pending, err := a.approvals.FindByState(model.ApprovalPending)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range pending {
+			if other.Kind == approval.Kind && other.UserID == approval.UserID && other.BankAccountID == approval.BankAccountID {
+				return nil, ErrApprovalPending
+			}
+		}
+	}
+	now := time.Now()
+	approval.ID = bson.NewObjectId()
+	approval.State = model.ApprovalPending
+	approval.MakerID = bson.ObjectIdHex(makerID)
+	approval.CreatedAt = now
+	approval.ExpiresAt = now.Add(a.window)
+	approval.LastModified = now
+	if err := a.approvals.Insert(&approval); err != nil {
+		return nil, err
+	}
+	if err := a.record(approval, model.AuditRequested, approval.MakerID, detail); err != nil {
+		return nil, committed(err)
+	}
+	return &approval, nil
+}
+
+//record for store AuditEntry of approval
+func (a *ApprovalServiceImplement) record(approval model.Approval, action model.AuditAction, actorID bson.ObjectId, detail string) error {
+	return a.audit.Insert(&model.AuditEntry{
+		ID:         bson.NewObjectId(),
+		ApprovalID: approval.ID,
+		Action:     action,
+		ActorID:    actorID,
+		Detail:     detail,
+		CreatedAt:  time.Now(),
+	})
+}
+
+//FindApprovals for Approval in any of states, oldest first
+func (a *ApprovalServiceImplement) FindApprovals(states []model.ApprovalState) ([]model.Approval, error) {
+	approvals, err := a.approvals.FindByState(states...)
+	if approvals == nil {
+		approvals = []model.Approval{}
+	}
+	return approvals, err
+}
+
+//FindApproval for Approval of id with its audit trail
+func (a *ApprovalServiceImplement) FindApproval(id string) (model.Approval, []model.AuditEntry, error) {
+	approval, err := a.find(id)
+	if err != nil {
+		return model.Approval{}, nil, err
+	}
+	entries, err := a.audit.FindByApproval(approval.ID)
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+	return approval, entries, err
+}
+
+func (a *ApprovalServiceImplement) find(id string) (model.Approval, error) {
+	if !bson.IsObjectIdHex(id) {
+		return model.Approval{}, ErrApprovalNotFound
+	}
+	approval, err := a.approvals.FindByID(bson.ObjectIdHex(id))
+	if err == repository.ErrNotFound {
+		return model.Approval{}, ErrApprovalNotFound
+	}
+	return approval, err
+}
+
+//decide for move pending Approval of id to state by checkerID, Approval past ExpiresAt is expired instead.
+//Tranfer to be executed gets ID of its TranferLog in the same update so RecoverApprovals can find it
+func (a *ApprovalServiceImplement) decide(id string, checkerID string, state model.ApprovalState, reason string) (model.Approval, error) {
+	approval, err := a.find(id)
+	if err != nil {
+		return approval, err
+	}
+	if approval.State != model.ApprovalPending {
+		return approval, ErrApprovalDecided
+	}
+	if approval.MakerID.Hex() == checkerID {
+		return approval, ErrApprovalSelf
+	}
+	now := time.Now()
+	if now.After(approval.ExpiresAt) {
+		if err := a.expire(approval, now); err != nil {
+			return approval, err
+		}
+		return approval, ErrApprovalExpired
+	}
+	approval.State = state
+	approval.CheckerID = bson.ObjectIdHex(checkerID)
+	approval.Reason = reason
+	approval.LastModified = now
+	if state == model.ApprovalExecuting && approval.Kind == model.ApprovalTranfer {
+		approval.TranferID = bson.NewObjectId()
+	}
+	err = a.approvals.SetState(approval, model.ApprovalPending)
+	if err == repository.ErrNotFound {
+		//someone else decided it first
+		return approval, ErrApprovalDecided
+	}
+	return approval, err
+}
+
+//Approve for approve Approval of id by checkerID and run its operation through the same service as without Approval.
+//Operation that fails leaves Approval failed with Error
+func (a *ApprovalServiceImplement) Approve(id string, checkerID string) (*model.Approval, error) {
+	approval, err := a.decide(id, checkerID, model.ApprovalExecuting, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.record(approval, model.AuditApproved, approval.CheckerID, ""); err != nil {
+		return nil, committed(err)
+	}
+	return a.finish(approval, a.execute(&approval))
+}
+
+//finish for move executing approval to done, or to failed when its operation returned err
+func (a *ApprovalServiceImplement) finish(approval model.Approval, err error) (*model.Approval, error) {
+	action, detail := model.AuditExecuted, ""
+	approval.State = model.ApprovalDone
+	if err != nil {
+		action, detail = model.AuditFailed, err.Error()
+		approval.State = model.ApprovalFailed
+		approval.Error = err.Error()
+	}
+	approval.LastModified = time.Now()
+	if err := a.approvals.SetState(approval, model.ApprovalExecuting); err != nil {
+		return nil, committed(err)
+	}
+	if err := a.record(approval, action, approval.CheckerID, detail); err != nil {
+		return nil, committed(err)
+	}
+	return &approval, nil
+}
+
+//execute for run operation of approval
+func (a *ApprovalServiceImplement) execute(approval *model.Approval) error {
+	user, err := a.userService.FindByIDUser(approval.UserID.Hex())
+	if err != nil {
+		return err
+	}
+	switch approval.Kind {
+	case model.ApprovalTranfer:
+		_, err := a.tranfers.tranfer(approval.Tranfer, user, approval.TranferID, true)
+		return err
+	case model.ApprovalDeleteBankAccount:
+		_, err = a.bankAccountService.DeleteBankAccount(user, approval.BankAccountID.Hex())
+		return err
+	case model.ApprovalDeleteUser:
+		_, err = a.userService.DeleteUser(user)
+		return err
+	}
+	return fmt.Errorf("approval %s has unknown kind %s", approval.ID.Hex(), approval.Kind)
+}
+
+//recoverApproval for finish executing approval, operation that took effect is finished as it went
+//and one that did not is run again
+func (a *ApprovalServiceImplement) recoverApproval(approval model.Approval) error {
+	var ran bool
+	var outcome error
+	switch approval.Kind {
+	case model.ApprovalTranfer:
+		tranferLog, err := a.tranfers.tranfers.FindByID(approval.TranferID)
+		if err != nil && err != repository.ErrNotFound {
+			return err
+		}
+		ran = err == nil
+		//RecoverTranfer already finished or rolled it back
+		if ran && tranferLog.State != model.TranferStateDone {
+			outcome = fmt.Errorf("tranfer %s is %s", tranferLog.ID.Hex(), tranferLog.State)
+		}
+	case model.ApprovalDeleteBankAccount, model.ApprovalDeleteUser:
+		user, err := a.userService.FindByIDUser(approval.UserID.Hex())
+		if err != nil && err != repository.ErrNotFound {
+			return err
+		}
+		_, ok := findBankAccount(user, approval.BankAccountID)
+		ran = err == repository.ErrNotFound || approval.Kind == model.ApprovalDeleteBankAccount && !ok
+	}
+	if !ran {
+		outcome = a.execute(&approval)
+	}
+	_, err := a.finish(approval, outcome)
+	return err
+}
+
+//RecoverApprovals for finish every Approval left executing when the server stopped, it must run after
+//RecoverTranfer. Operation that took effect is done, one that did not is run again since it was approved
+func (a *ApprovalServiceImplement) RecoverApprovals() error {
+	executing, err := a.approvals.FindByState(model.ApprovalExecuting)
+	if err != nil {
+		return err
+	}
+	for _, approval := range executing {
+		if err := a.recoverApproval(approval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Reject for reject Approval of id by checkerID, its operation never runs
+func (a *ApprovalServiceImplement) Reject(id string, checkerID string, reason string) (*model.Approval, error) {
+	approval, err := a.decide(id, checkerID, model.ApprovalRejected, reason)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.record(approval, model.AuditRejected, approval.CheckerID, reason); err != nil {
+		return nil, committed(err)
+	}
+	return &approval, nil
+}
+
+//expire for move pending approval to expired, it is left as it is when someone decided it first
+func (a *ApprovalServiceImplement) expire(approval model.Approval, now time.Time) error {
+	approval.State = model.ApprovalExpired
+	approval.LastModified = now
+	err := a.approvals.SetState(approval, model.ApprovalPending)
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return a.record(approval, model.AuditExpired, "", "")
+}
+
+//ExpireApprovals for expire every pending Approval past ExpiresAt at now and returns how many
+func (a *ApprovalServiceImplement) ExpireApprovals(now time.Time) (int, error) {
+	pending, err := a.approvals.FindByState(model.ApprovalPending)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, approval := range pending {
+		if !now.After(approval.ExpiresAt) {
+			continue
+		}
+		if err := a.expire(approval, now); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+//RunApprovalExpiry for ExpireApprovals every interval, it never returns
+func RunApprovalExpiry(approvalService ApprovalService, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := approvalService.ExpireApprovals(time.Now()); err != nil {
+			log.Println("approval expiry:", err)
+		}
+	}
+}
+
+//parseApprovalStates for states in query string separated by comma, it is pending when there is none
+func parseApprovalStates(value string) ([]model.ApprovalState, error) {
+	if value == "" {
+		return []model.ApprovalState{model.ApprovalPending}, nil
+	}
+	var states []model.ApprovalState
+	for _, name := range strings.Split(value, ",") {
+		state := model.ApprovalState(strings.TrimSpace(name))
+		known := false
+		for _, approvalState := range model.ApprovalStates {
+			known = known || state == approvalState
+		}
+		if !known {
+			return nil, fmt.Errorf("state must be some of %s separated by comma", joinApprovalStates())
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func joinApprovalStates() string {
+	var names []string
+	for _, state := range model.ApprovalStates {
+		names = append(names, string(state))
+	}
+	return strings.Join(names, ", ")
+}
+
+//FindAllApprovalEndPoint is FindAllApprovalEndPoint, state is ApprovalState to list and pending when it is left out
+func (m *DataObjectAccess) FindAllApprovalEndPoint(c echo.Context) (err error) {
+	states, err := parseApprovalStates(c.QueryParam("state"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	approvals, err := m.approvalService.FindApprovals(states)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(approvals)
+	return c.JSON(http.StatusOK, MapJSONApproval(approvals))
+}
+
+//FindApprovalEndPoint is FindApprovalEndPoint, Approval is sent with its audit trail
+func (m *DataObjectAccess) FindApprovalEndPoint(c echo.Context) (err error) {
+	approval, entries, err := m.approvalService.FindApproval(c.Param("idApproval"))
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(approval)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"approval": approval,
+		"audit":    entries,
+	})
+}
+
+//ApproveEndPoint is ApproveEndPoint
+func (m *DataObjectAccess) ApproveEndPoint(c echo.Context) (err error) {
+	approval, err := m.approvalService.Approve(c.Param("idApproval"), AuthUserID(c))
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(approval)
+	return c.JSON(http.StatusOK, MapJSONApproval(approval))
+}
+
+//RejectEndPoint is RejectEndPoint, reason of body is kept in Approval and audit trail
+func (m *DataObjectAccess) RejectEndPoint(c echo.Context) (err error) {
+	d := new(model.ApprovalDecision)
+	if err := c.Bind(d); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	approval, err := m.approvalService.Reject(c.Param("idApproval"), AuthUserID(c), d.Reason)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(approval)
+	return c.JSON(http.StatusOK, MapJSONApproval(approval))
+}
+
+//MapJSONApproval for MapJSONApproval
+func MapJSONApproval(approval interface{}) interface{} {
+	dataJSON := map[string]interface{}{
+		"approval": approval,
+	}
+	return dataJSON
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/pain"
+	"bankaccountapi/repository"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestApprovalThresholdHoldsForEveryCaller(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			d.tranferService.(*TranferServiceImplement).approvalThreshold = "100"
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			maker := createTestUser(t, d, "maker", model.RoleAdmin)
+			checker := createTestUser(t, d, "checker", model.RoleAdmin)
+			createTestBankAccount(t, d, &alice, "111-1", "1000")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+
+			if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "150"), From: "111-1", To: "222-2"}, alice); err != ErrApprovalRequired {
+				t.Fatalf("tranfer above threshold got %v, want %v", err, ErrApprovalRequired)
+			}
+
+			//standing order runs without anybody approving it
+			order, err := d.standingOrderService.CreateStandingOrder(&model.StandingOrder{
+				AccountNumberFrom: "111-1",
+				AccountNumberTo:   "222-2",
+				Amount:            testMoney(t, "150"),
+				Schedule:          model.Schedule{Kind: model.ScheduleWeekly, Weekday: "monday"},
+				Count:             1,
+			}, alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := d.standingOrderService.RunStandingOrders(order.NextRun); err != nil {
+				t.Fatal(err)
+			}
+			executions, err := store.StandingOrders.FindExecutions(order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(executions) != 1 || executions[0].Error != ErrApprovalRequired.Error() {
+				t.Fatalf("standing order executions %+v, want one refused by %v", executions, ErrApprovalRequired)
+			}
+
+			//so does bulk payment
+			bulkPayment, err := d.bulkPaymentService.CreateBulkPayment(pain.Pain001{
+				MessageID:            "MSG-1",
+				NumberOfTransactions: "1",
+				PaymentInformation: []pain.PaymentInformation{{
+					ID:            "PMT-1",
+					DebtorAccount: pain.Account{Other: "111-1"},
+					CreditTransfers: []pain.CreditTransfer{{
+						EndToEndID:      "E2E-1",
+						Amount:          pain.Amount{Currency: model.DefaultCurrency, Value: "150"},
+						CreditorAccount: pain.Account{Other: "222-2"},
+					}},
+				}},
+			}, alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := d.bulkPaymentService.RunBulkPayment(bulkPayment.ID); err != nil {
+				t.Fatal(err)
+			}
+			stored, err := d.bulkPaymentService.FindBulkPayment(alice, bulkPayment.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line := stored.Lines[0]; line.Status != model.BulkPaymentLineRejected || line.ReasonCode != ReasonNotAllowedAmount {
+				t.Fatalf("bulk payment line is %s with %s, want %s with %s", line.Status, line.ReasonCode, model.BulkPaymentLineRejected, ReasonNotAllowedAmount)
+			}
+			if balance := balanceOf(t, store, "222-2"); balance != "1.00" {
+				t.Fatalf("balance of 222-2 is %s before approval, want 1.00", balance)
+			}
+
+			//only Approve runs it
+			approval, err := d.approvalService.RequestTranfer(&model.Tranfer{Amount: testMoney(t, "150"), From: "111-1", To: "222-2"}, alice, maker.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			approval, err = d.approvalService.Approve(approval.ID.Hex(), checker.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval.State != model.ApprovalDone {
+				t.Fatalf("approval is %s with %q, want %s", approval.State, approval.Error, model.ApprovalDone)
+			}
+			if balance := balanceOf(t, store, "222-2"); balance != "151.00" {
+				t.Fatalf("balance of 222-2 is %s, want 151.00", balance)
+			}
+		})
+	}
+}
+
+func TestRecoverApprovalsFinishesExecuting(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			tranferService := d.tranferService.(*TranferServiceImplement)
+			tranferService.approvalThreshold = "100"
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			checker := createTestUser(t, d, "checker", model.RoleAdmin)
+			createTestBankAccount(t, d, &alice, "111-1", "1000")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+			spare := createTestBankAccount(t, d, &bob, "333-3", "1")
+
+			//server stopped after Approve stored them executing
+			executing := func(approval model.Approval) model.Approval {
+				approval.ID = bson.NewObjectId()
+				approval.State = model.ApprovalExecuting
+				approval.MakerID = bob.ID
+				approval.CheckerID = checker.ID
+				approval.CreatedAt = time.Now()
+				approval.ExpiresAt = approval.CreatedAt.Add(time.Hour)
+				approval.LastModified = approval.CreatedAt
+				if err := store.Approvals.Insert(&approval); err != nil {
+					t.Fatal(err)
+				}
+				return approval
+			}
+			tranfer := &model.Tranfer{Amount: testMoney(t, "150"), From: "111-1", To: "222-2"}
+			//one Tranfer never ran and one ran to the end
+			notRun := executing(model.Approval{Kind: model.ApprovalTranfer, UserID: alice.ID, Tranfer: tranfer, TranferID: bson.NewObjectId()})
+			ran := executing(model.Approval{Kind: model.ApprovalTranfer, UserID: alice.ID, Tranfer: tranfer, TranferID: bson.NewObjectId()})
+			if _, err := tranferService.tranfer(tranfer, alice, ran.TranferID, true); err != nil {
+				t.Fatal(err)
+			}
+			//delete ran to the end too, on BankAccount emptied first since one with money is never deleted
+			if _, err := d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "1")}, bob, spare.ID.Hex(), AnyVersion); err != nil {
+				t.Fatal(err)
+			}
+			bob = findTestUser(t, d, bob.ID.Hex())
+			deleted := executing(model.Approval{Kind: model.ApprovalDeleteBankAccount, UserID: bob.ID, BankAccountID: spare.ID})
+			if _, err := d.bankAccountService.DeleteBankAccount(bob, spare.ID.Hex()); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := d.approvalService.RecoverApprovals(); err != nil {
+				t.Fatal(err)
+			}
+			for _, approval := range []model.Approval{notRun, ran, deleted} {
+				stored, _, err := d.approvalService.FindApproval(approval.ID.Hex())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stored.State != model.ApprovalDone {
+					t.Fatalf("%s approval is %s with %q, want %s", stored.Kind, stored.State, stored.Error, model.ApprovalDone)
+				}
+			}
+			//each Tranfer moved money once
+			if balance := balanceOf(t, store, "222-2"); balance != "301.00" {
+				t.Fatalf("balance of 222-2 is %s, want 301.00", balance)
+			}
+		})
+	}
+}
+
+func TestDeleteBankAccountWithMoneyIsRefused(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			faulty := newFaultyBankAccounts(store)
+			d := newTestDAO(t, store)
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			maker := createTestUser(t, d, "maker", model.RoleAdmin)
+			bankAccount := createTestBankAccount(t, d, &alice, "111-1", "40")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+
+			if _, err := d.approvalService.RequestDeleteBankAccount(alice, bankAccount.ID.Hex(), maker.ID.Hex()); err != ErrBankAccountNotEmpty {
+				t.Fatalf("approval of delete of bank account with money got %v, want %v", err, ErrBankAccountNotEmpty)
+			}
+			if _, err := d.bankAccountService.DeleteBankAccount(alice, bankAccount.ID.Hex()); err != ErrBankAccountNotEmpty {
+				t.Fatalf("delete of bank account with money got %v, want %v", err, ErrBankAccountNotEmpty)
+			}
+
+			//Balance is zero but Tranfer out of it is still pending
+			faulty.fail(faulty.applyErr, "222-2", errCrash)
+			crash(t, func() {
+				d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "40"), From: "111-1", To: "222-2"}, alice)
+			})
+			faulty.fail(faulty.applyErr, "222-2", nil)
+			alice = findTestUser(t, d, alice.ID.Hex())
+			if _, err := d.bankAccountService.DeleteBankAccount(alice, bankAccount.ID.Hex()); err != ErrBankAccountNotEmpty {
+				t.Fatalf("delete of bank account with pending tranfer got %v, want %v", err, ErrBankAccountNotEmpty)
+			}
+			if err := d.tranferService.RecoverTranfer(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.bankAccountService.DeleteBankAccount(alice, bankAccount.ID.Hex()); err != nil {
+				t.Fatal(err)
+			}
+			if balance := balanceOf(t, store, "222-2"); balance != "41.00" {
+				t.Fatalf("balance of 222-2 is %s, want 41.00", balance)
+			}
+		})
+	}
+}
+
+//failingAudit is AuditRepository that fails Insert of action
+type failingAudit struct {
+	repository.AuditRepository
+	action model.AuditAction
+}
+
+func (f failingAudit) Insert(entry *model.AuditEntry) error {
+	if entry.Action == f.action {
+		return errCrash
+	}
+	return f.AuditRepository.Insert(entry)
+}
+
+func TestRejectAuditFailureIsCommitted(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	approvalService := d.approvalService.(*ApprovalServiceImplement)
+	approvalService.audit = failingAudit{AuditRepository: store.Audit, action: model.AuditRejected}
+	alice := createTestUser(t, d, "alice")
+	bob := createTestUser(t, d, "bob")
+	maker := createTestUser(t, d, "maker", model.RoleAdmin)
+	checker := createTestUser(t, d, "checker", model.RoleAdmin)
+	createTestBankAccount(t, d, &alice, "111-1", "1000")
+	createTestBankAccount(t, d, &bob, "222-2", "1")
+	approval, err := d.approvalService.RequestTranfer(&model.Tranfer{Amount: testMoney(t, "150"), From: "111-1", To: "222-2"}, alice, maker.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Approval is already rejected when its audit fails, so request must not run again
+	_, err = d.approvalService.Reject(approval.ID.Hex(), checker.ID.Hex(), "not expected")
+	if _, ok := err.(committedError); !ok {
+		t.Fatalf("reject with audit down got %v, want committedError", err)
+	}
+	stored, err := store.Approvals.FindByID(approval.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != model.ApprovalRejected {
+		t.Fatalf("approval is %s, want %s", stored.State, model.ApprovalRejected)
+	}
+}
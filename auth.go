@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+)
+
+const (
+	//ContextUserID is key of authenticated user ID in echo.Context
+	ContextUserID = "userID"
+	//ContextRoles is key of Roles of authenticated user in echo.Context
+	ContextRoles = "roles"
+	//contextToken is key of parsed jwt in echo.Context
+	contextToken = "token"
+)
+
+var (
+	//ErrInvalidCredentials is returned when username or password is wrong
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	//ErrInvalidRefreshToken is returned when refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+)
+
+//AuthService is interface
+type AuthService interface {
+	Login(login *model.Login) (*model.Token, error)
+	Refresh(refreshToken string) (*model.Token, error)
+	Logout(refreshToken string) error
+}
+
+//AuthServiceImplement is struct
+type AuthServiceImplement struct {
+	refreshTokens   repository.RefreshTokenRepository
+	userService     UserService
+	secret          []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+//Login for Login
+func (a *AuthServiceImplement) Login(login *model.Login) (*model.Token, error) {
+	if login.Username == "" || login.Password == "" {
+		return nil, errors.New("please require Username and Password")
+	}
+	user, err := a.userService.FindByUsername(login.Username)
+	if err == repository.ErrNotFound {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := a.userService.CheckPassword(user, login.Password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return a.issueToken(user.ID, bson.NewObjectId())
+}
+
+//Refresh for rotate refresh token, old refresh token can not be used again
+func (a *AuthServiceImplement) Refresh(refreshToken string) (*model.Token, error) {
+	stored, err := a.refreshTokens.FindByID(hashRefreshToken(refreshToken))
+	if err == repository.ErrNotFound {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.Revoked {
+		//refresh token was already rotated, someone else has it so end the whole login
+		if err := a.revokeFamily(stored.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	err = a.refreshTokens.Revoke(stored.ID)
+	if err == repository.ErrNotFound {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.issueToken(stored.UserID, stored.Family)
+}
+
+//Logout for revoke refresh token and every refresh token rotated from the same login
+func (a *AuthServiceImplement) Logout(refreshToken string) error {
+	stored, err := a.refreshTokens.FindByID(hashRefreshToken(refreshToken))
+	if err == repository.ErrNotFound {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	return a.revokeFamily(stored.Family)
+}
+
+func (a *AuthServiceImplement) revokeFamily(family bson.ObjectId) error {
+	return a.refreshTokens.RevokeFamily(family)
+}
+
+func (a *AuthServiceImplement) issueToken(userID bson.ObjectId, family bson.ObjectId) (*model.Token, error) {
+	now := time.Now()
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:   userID.Hex(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(a.accessTokenTTL).Unix(),
+	}).SignedString(a.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(b)
+	err = a.refreshTokens.Insert(&model.RefreshToken{
+		ID:        hashRefreshToken(refreshToken),
+		UserID:    userID,
+		Family:    family,
+		ExpiresAt: now.Add(a.refreshTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Token{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(a.accessTokenTTL / time.Second),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+//JWTAuth is middleware for check access token and put user ID in echo.Context
+func JWTAuth(secret []byte) echo.MiddlewareFunc {
+	jwtMiddleware := middleware.JWTWithConfig(middleware.JWTConfig{
+		SigningKey:    secret,
+		SigningMethod: middleware.AlgorithmHS256,
+		ContextKey:    contextToken,
+		Claims:        &jwt.StandardClaims{},
+	})
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return jwtMiddleware(func(c echo.Context) error {
+			token, ok := c.Get(contextToken).(*jwt.Token)
+			if !ok {
+				return middleware.ErrJWTInvalid
+			}
+			claims, ok := token.Claims.(*jwt.StandardClaims)
+			if !ok || !bson.IsObjectIdHex(claims.Subject) {
+				return middleware.ErrJWTInvalid
+			}
+			c.Set(ContextUserID, claims.Subject)
+			return next(c)
+		})
+	}
+}
+
+//LoadRoles is middleware for put Roles of authenticated user in echo.Context, it must be used after JWTAuth.
+//User in admins of config.toml always has RoleAdmin so there is someone to assign the first Roles
+func LoadRoles(userService UserService, admins []string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := userService.FindByIDUser(AuthUserID(c))
+			if err == repository.ErrNotFound {
+				return middleware.ErrJWTInvalid
+			}
+			if err != nil {
+				return MapHTTPError(err)
+			}
+			roles := user.UserRoles()
+			for _, admin := range admins {
+				if admin == user.ID.Hex() && !model.HasRole(roles, model.RoleAdmin) {
+					roles = append(roles, model.RoleAdmin)
+				}
+			}
+			c.Set(ContextRoles, roles)
+			return next(c)
+		}
+	}
+}
+
+//Allow is middleware for allow only user whose Roles have permission on user of the route, it must be used after LoadRoles.
+//ScopeOwn allows only when the route is for the authenticated user
+func Allow(permission model.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch model.ScopeOf(AuthRoles(c), permission) {
+			case model.ScopeAny:
+				return next(c)
+			case model.ScopeOwn:
+				if owner := routeOwner(c); owner != "" && owner == AuthUserID(c) {
+					return next(c)
+				}
+				return echo.NewHTTPError(http.StatusForbidden, "can not access other user")
+			}
+			return echo.NewHTTPError(http.StatusForbidden, "not allowed to "+string(permission))
+		}
+	}
+}
+
+//routeOwner for ID of user the route is for, it is :id or :idFrom of tranfers and empty when route is for no user
+func routeOwner(c echo.Context) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	return c.Param("idFrom")
+}
+
+//AuthUserID for get authenticated user ID from echo.Context
+func AuthUserID(c echo.Context) string {
+	userID, _ := c.Get(ContextUserID).(string)
+	return userID
+}
+
+//AuthRoles for get Roles of authenticated user from echo.Context, it is RoleCustomer when LoadRoles was not used
+func AuthRoles(c echo.Context) []model.Role {
+	if roles, ok := c.Get(ContextRoles).([]model.Role); ok {
+		return roles
+	}
+	return []model.Role{model.RoleCustomer}
+}
+
+//LoginEndPoint is LoginEndPoint
+func (m *DataObjectAccess) LoginEndPoint(c echo.Context) (err error) {
+	l := new(model.Login)
+	if err := c.Bind(l); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	tokenResp, err := m.authService.Login(l)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	return c.JSON(http.StatusOK, tokenResp)
+}
+
+//RefreshEndPoint is RefreshEndPoint
+func (m *DataObjectAccess) RefreshEndPoint(c echo.Context) (err error) {
+	r := new(model.RefreshTokenRequest)
+	if err := c.Bind(r); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	tokenResp, err := m.authService.Refresh(r.RefreshToken)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	return c.JSON(http.StatusOK, tokenResp)
+}
+
+//LogoutEndPoint is LogoutEndPoint
+func (m *DataObjectAccess) LogoutEndPoint(c echo.Context) (err error) {
+	r := new(model.RefreshTokenRequest)
+	if err := c.Bind(r); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	err = m.authService.Logout(r.RefreshToken)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"result": "Logout Success"})
+}
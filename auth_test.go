@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+//routeCase is route and whether each caller may use it on User bob, owner is bob himself
+type routeCase struct {
+	method, path                   string
+	owner, customer, teller, admin bool
+}
+
+//login for access token of username
+func login(t *testing.T, d *DataObjectAccess, username string) string {
+	t.Helper()
+	token, err := d.authService.Login(&model.Login{Username: username, Password: "password of " + username})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.AccessToken
+}
+
+func TestRoutePermissions(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	e := echo.New()
+	e.Logger.SetOutput(ioutil.Discard)
+	SetUpRoute(e, d, store)
+
+	bob := createTestUser(t, d, "bob")
+	bankAccount := createTestBankAccount(t, d, &bob, "222-2", "100")
+	createTestUser(t, d, "alice")
+	createTestUser(t, d, "teller", model.RoleTeller)
+	createTestUser(t, d, "admin", model.RoleAdmin)
+	tokens := map[string]string{}
+	for _, username := range []string{"bob", "alice", "teller", "admin"} {
+		tokens[username] = login(t, d, username)
+	}
+
+	user := "/v1/user/" + bob.ID.Hex()
+	account := user + "/bankAccount/" + bankAccount.ID.Hex()
+	missing := "/000000000000000000000000"
+	for _, rc := range []routeCase{
+		{"GET", "/v1/users", false, false, true, true},
+		{"GET", user, true, false, true, true},
+		{"PUT", user, true, false, false, true},
+		{"DELETE", user, false, false, false, true},
+		{"POST", user + "/bankAccount", true, false, true, true},
+		{"GET", user + "/bankAccount", true, false, true, true},
+		{"GET", account, true, false, true, true},
+		{"DELETE", account, true, false, false, true},
+		{"PUT", account + "/deposit", true, false, true, true},
+		{"PUT", account + "/withdraw", true, false, false, false},
+		{"GET", account + "/transactions", true, false, true, true},
+		{"GET", account + "/statement", true, false, true, true},
+		{"GET", account + "/interest", true, false, true, true},
+		{"GET", account + "/fee", true, false, true, true},
+		{"PUT", account + "/limits", true, false, false, true},
+		{"GET", user + "/limits", true, false, true, true},
+		{"PUT", user + "/limits", true, false, false, true},
+		{"POST", user + "/standingOrders", true, false, false, false},
+		{"GET", user + "/standingOrders", true, false, false, false},
+		{"PUT", user + "/standingOrders" + missing + "/pause", true, false, false, false},
+		{"PUT", user + "/standingOrders" + missing + "/resume", true, false, false, false},
+		{"PUT", user + "/standingOrders" + missing + "/cancel", true, false, false, false},
+		{"GET", user + "/standingOrders" + missing + "/executions", true, false, false, false},
+		{"POST", user + "/bulkPayments", true, false, false, false},
+		{"GET", user + "/bulkPayments", true, false, false, false},
+		{"GET", user + "/bulkPayments" + missing, true, false, false, false},
+		{"POST", "/tranfers/from/" + bob.ID.Hex(), true, false, false, false},
+		{"GET", "/v1/admin/rates", false, false, true, true},
+		{"PUT", "/v1/admin/rates", false, false, false, true},
+		{"POST", "/v1/admin/users/import", false, false, false, true},
+		{"PUT", "/v1/admin/users/" + bob.ID.Hex() + "/roles", false, false, false, true},
+		{"GET", "/v1/admin/search?q=bob", false, false, true, true},
+		{"GET", "/v1/admin/approvals", false, false, false, true},
+		{"GET", "/v1/admin/approvals" + missing, false, false, false, true},
+		{"PUT", "/v1/admin/approvals" + missing + "/approve", false, false, false, true},
+		{"PUT", "/v1/admin/approvals" + missing + "/reject", false, false, false, true},
+	} {
+		for username, allowed := range map[string]bool{"bob": rc.owner, "alice": rc.customer, "teller": rc.teller, "admin": rc.admin} {
+			req := httptest.NewRequest(rc.method, rc.path, strings.NewReader(`{}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokens[username])
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code == http.StatusUnauthorized {
+				t.Fatalf("%s %s by %s: 401 %s", rc.method, rc.path, username, rec.Body)
+			}
+			if forbidden := rec.Code == http.StatusForbidden; forbidden == allowed {
+				t.Errorf("%s %s by %s: %d, want allowed %v", rc.method, rc.path, username, rec.Code, allowed)
+			}
+		}
+		req := httptest.NewRequest(rc.method, rc.path, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest && rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without token: %d, want it refused", rc.method, rc.path, rec.Code)
+		}
+	}
+}
+
+func TestCrossUserForbidden(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	e := echo.New()
+	e.Logger.SetOutput(ioutil.Discard)
+	SetUpRoute(e, d, store)
+
+	alice := createTestUser(t, d, "alice")
+	bob := createTestUser(t, d, "bob")
+	createTestBankAccount(t, d, &alice, "111-1", "100")
+	bankAccount := createTestBankAccount(t, d, &bob, "222-2", "100")
+	token := login(t, d, "alice")
+
+	account := "/v1/user/" + bob.ID.Hex() + "/bankAccount/" + bankAccount.ID.Hex()
+	for _, rc := range []struct {
+		method, path, body string
+	}{
+		{"POST", "/tranfers/from/" + bob.ID.Hex(), `{"amount": "10", "from": "222-2", "to": "111-1"}`},
+		{"PUT", account + "/withdraw", `{"amount": "10"}`},
+		{"GET", account, ""},
+	} {
+		req := httptest.NewRequest(rc.method, rc.path, strings.NewReader(rc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s by alice: %d %s, want 403", rc.method, rc.path, rec.Code, rec.Body)
+		}
+	}
+
+	//account number of bob in body of tranfer from alice herself is not hers either
+	req := httptest.NewRequest("POST", "/tranfers/from/"+alice.ID.Hex(), strings.NewReader(`{"amount": "10", "from": "222-2", "to": "111-1"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK {
+		t.Errorf("tranfer of alice from 222-2 of bob: %d, want it refused", rec.Code)
+	}
+
+	if balance := balanceOf(t, store, "222-2"); balance != "100.00" {
+		t.Fatalf("balance of 222-2 is %s, want 100.00", balance)
+	}
+	if balance := balanceOf(t, store, "111-1"); balance != "100.00" {
+		t.Fatalf("balance of 111-1 is %s, want 100.00", balance)
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bankaccountapi/fx"
+	"bankaccountapi/model"
+	"bankaccountapi/pain"
+	"bankaccountapi/repository"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+const (
+	//BulkPaymentMaxLines is most credit transfers one pain.001 may have
+	BulkPaymentMaxLines = 10000
+	//BulkPaymentMaxSize is most bytes of pain.001 read from request
+	BulkPaymentMaxSize = 32 << 20
+	//endToEndNotProvided is EndToEndId ISO 20022 uses when it was not given
+	endToEndNotProvided = "NOTPROVIDED"
+)
+
+//ISO 20022 status reason codes of BulkPayment and BulkPaymentLine
+const (
+	ReasonIncorrectAccountNumber         = "AC01"
+	ReasonInvalidCreditorAccountNumber   = "AC03"
+	ReasonZeroAmount                     = "AM01"
+	ReasonNotAllowedAmount               = "AM02"
+	ReasonNotAllowedCurrency             = "AM03"
+	ReasonInsufficientFunds              = "AM04"
+	ReasonDuplication                    = "AM05"
+	ReasonInvalidControlSum              = "AM10"
+	ReasonInvalidAmount                  = "AM12"
+	ReasonAmountExceedsAgreedLimit       = "AM14"
+	ReasonInvalidNumberOfTransactions    = "AM18"
+	ReasonTransactionForbidden           = "AG01"
+	ReasonNotSpecifiedReasonAgentCreated = "MS03"
+	ReasonNarrative                      = "NARR"
+)
+
+var (
+	//ErrBulkPaymentEmpty is returned when pain.001 has no credit transfer
+	ErrBulkPaymentEmpty = errors.New("pain.001 has no CdtTrfTxInf")
+	//ErrBulkPaymentTooLarge is returned when pain.001 has more than BulkPaymentMaxLines credit transfers
+	ErrBulkPaymentTooLarge = errors.New("pain.001 has more than " + strconv.Itoa(BulkPaymentMaxLines) + " CdtTrfTxInf")
+	//ErrBulkPaymentDuplicate is returned when User already uploaded pain.001 with the same MsgId
+	ErrBulkPaymentDuplicate = errors.New("pain.001 MsgId was already uploaded")
+)
+
+//BulkPaymentService is interface
+type BulkPaymentService interface {
+	CreateBulkPayment(initiation pain.Pain001, user model.User) (*model.BulkPayment, error)
+	FindAllBulkPayment(user model.User) ([]model.BulkPayment, error)
+	FindBulkPayment(user model.User, id string) (model.BulkPayment, error)
+	RunBulkPayment(id bson.ObjectId) error
+	RecoverBulkPayment() error
+}
+
+//BulkPaymentServiceImplement is struct
+type BulkPaymentServiceImplement struct {
+	bulkPayments repository.BulkPaymentRepository
+	bankAccounts repository.BankAccountRepository
+	userService  UserService
+	tranfers     *TranferServiceImplement
+}
+
+//bulkPaymentReason for ISO 20022 status reason of error of Tranfer
+func bulkPaymentReason(err error) string {
+	switch err {
+	case ErrInsufficientFunds:
+		return ReasonInsufficientFunds
+	case ErrInvalidAmount, model.ErrMoneyFormat, model.ErrMoneyDecimals, model.ErrMoneyOverflow:
+		return ReasonInvalidAmount
+	case model.ErrCurrencyUnknown, model.ErrCurrencyMismatch, fx.ErrRateNotFound:
+		return ReasonNotAllowedCurrency
+	case ErrBankAccountToNotFound, ErrTranferSameAccount:
+		return ReasonInvalidCreditorAccountNumber
+	case ErrTranferDailyLimit:
+		return ReasonAmountExceedsAgreedLimit
+	case ErrTranferCountLimit:
+		return ReasonTransactionForbidden
+	case ErrApprovalRequired:
+		return ReasonNotAllowedAmount
+	}
+	return ReasonNotSpecifiedReasonAgentCreated
+}
+
+//reject for mark line rejected with reason, a line rejected before keeps its first reason
+func reject(line *model.BulkPaymentLine, code string, reason string) {
+	if line.Status == model.BulkPaymentLineRejected {
+		return
+	}
+	line.Status = model.BulkPaymentLineRejected
+	line.ReasonCode = code
+	line.Reason = reason
+}
+
+//checkCount for check NbOfTxs and CtrlSum of file or PmtInf against its lines, empty CtrlSum is not checked
+func checkCount(numberOfTransactions string, controlSum string, lines []model.BulkPaymentLine, amounts []string) (string, string) {
+	if numberOfTransactions != strconv.Itoa(len(lines)) {
+		return ReasonInvalidNumberOfTransactions, fmt.Sprintf("NbOfTxs is %q but there are %d CdtTrfTxInf", numberOfTransactions, len(lines))
+	}
+	if controlSum == "" {
+		return "", ""
+	}
+	expected, ok := new(big.Rat).SetString(controlSum)
+	if !ok {
+		return ReasonInvalidControlSum, fmt.Sprintf("CtrlSum %q is not a decimal number", controlSum)
+	}
+	sum := new(big.Rat)
+	for _, amount := range amounts {
+		value, ok := new(big.Rat).SetString(amount)
+		if !ok {
+			return ReasonInvalidControlSum, fmt.Sprintf("CtrlSum can not add InstdAmt %q", amount)
+		}
+		sum.Add(sum, value)
+	}
+	if sum.Cmp(expected) != 0 {
+		return ReasonInvalidControlSum, fmt.Sprintf("CtrlSum is %s but InstdAmt add up to %s", controlSum, strings.TrimRight(strings.TrimRight(sum.FloatString(5), "0"), "."))
+	}
+	return "", ""
+}
+
+//newBulkPaymentLine for BulkPaymentLine of credit transfer paid from bankAccountFrom, it is rejected when it can never be paid.
+//bankAccountFrom is empty when debtor account is not of User
+func (s *BulkPaymentServiceImplement) newBulkPaymentLine(creditTransfer pain.CreditTransfer, bankAccountFrom model.BankAccount) model.BulkPaymentLine {
+	line := model.BulkPaymentLine{
+		InstructionID:   creditTransfer.InstructionID,
+		EndToEndID:      creditTransfer.EndToEndID,
+		AccountNumberTo: creditTransfer.CreditorAccount.Number(),
+		Status:          model.BulkPaymentLinePending,
+	}
+	if line.EndToEndID == "" {
+		line.EndToEndID = endToEndNotProvided
+	}
+	if bankAccountFrom.ID == "" {
+		reject(&line, ReasonIncorrectAccountNumber, "Not Have BankAccountID From")
+	}
+	currency := creditTransfer.Amount.Currency
+	if currency == "" {
+		currency = bankAccountFrom.AccountCurrency()
+	}
+	amount, err := model.ParseMoney(creditTransfer.Amount.Value, currency)
+	switch {
+	case err == model.ErrCurrencyUnknown:
+		reject(&line, ReasonNotAllowedCurrency, err.Error())
+	case err != nil:
+		reject(&line, ReasonInvalidAmount, err.Error())
+	case amount.IsZero():
+		reject(&line, ReasonZeroAmount, "please require Amount")
+	case amount.IsNegative():
+		reject(&line, ReasonInvalidAmount, ErrInvalidAmount.Error())
+	case bankAccountFrom.ID != "" && currency != bankAccountFrom.AccountCurrency():
+		reject(&line, ReasonNotAllowedCurrency, model.ErrCurrencyMismatch.Error())
+	}
+	line.Amount = amount
+	if line.AccountNumberTo == "" {
+		reject(&line, ReasonInvalidCreditorAccountNumber, "please require AccountNumberTo")
+	} else if _, err := s.bankAccounts.FindByAccountNumber(line.AccountNumberTo); err == repository.ErrNotFound {
+		reject(&line, ReasonInvalidCreditorAccountNumber, ErrBankAccountToNotFound.Error())
+	}
+	return line
+}
+
+//CreateBulkPayment for validate every credit transfer of pain.001 and store it as BulkPayment of user to run later.
+//Lines that can never be paid are rejected at once, when NbOfTxs or CtrlSum do not match the whole file is rejected
+func (s *BulkPaymentServiceImplement) CreateBulkPayment(initiation pain.Pain001, user model.User) (*model.BulkPayment, error) {
+	now := time.Now()
+	bulkPayment := model.BulkPayment{
+		ID:                   bson.NewObjectId(),
+		UserID:               user.ID,
+		MessageID:            initiation.MessageID,
+		MessageCreatedAt:     initiation.CreatedAt,
+		NumberOfTransactions: initiation.NumberOfTransactions,
+		ControlSum:           initiation.ControlSum,
+		State:                model.BulkPaymentReceived,
+		CreatedAt:            now,
+		LastModified:         now,
+	}
+
+	var amounts []string
+	endToEndIDs := map[string]bool{}
+	for _, paymentInformation := range initiation.PaymentInformation {
+		first := len(bulkPayment.Lines)
+		accountNumberFrom := paymentInformation.DebtorAccount.Number()
+		bankAccountFrom, _ := findBankAccountByNumber(user, accountNumberFrom)
+		var paymentAmounts []string
+		for _, creditTransfer := range paymentInformation.CreditTransfers {
+			line := s.newBulkPaymentLine(creditTransfer, bankAccountFrom)
+			line.Line = len(bulkPayment.Lines) + 1
+			line.PaymentInformationID = paymentInformation.ID
+			line.AccountNumberFrom = accountNumberFrom
+			line.LastModified = now
+			if line.EndToEndID != endToEndNotProvided && endToEndIDs[line.EndToEndID] {
+				reject(&line, ReasonDuplication, "EndToEndId "+line.EndToEndID+" is already in this file")
+			}
+			endToEndIDs[line.EndToEndID] = true
+			bulkPayment.Lines = append(bulkPayment.Lines, line)
+			paymentAmounts = append(paymentAmounts, creditTransfer.Amount.Value)
+			if len(bulkPayment.Lines) > BulkPaymentMaxLines {
+				return nil, ErrBulkPaymentTooLarge
+			}
+		}
+		amounts = append(amounts, paymentAmounts...)
+		if paymentInformation.NumberOfTransactions == "" && paymentInformation.ControlSum == "" {
+			continue
+		}
+		numberOfTransactions := paymentInformation.NumberOfTransactions
+		if numberOfTransactions == "" {
+			numberOfTransactions = strconv.Itoa(len(paymentAmounts))
+		}
+		if code, reason := checkCount(numberOfTransactions, paymentInformation.ControlSum, bulkPayment.Lines[first:], paymentAmounts); code != "" {
+			for i := first; i < len(bulkPayment.Lines); i++ {
+				reject(&bulkPayment.Lines[i], code, "PmtInf "+paymentInformation.ID+": "+reason)
+			}
+		}
+	}
+	if len(bulkPayment.Lines) == 0 {
+		return nil, ErrBulkPaymentEmpty
+	}
+
+	bulkPayment.ReasonCode, bulkPayment.Reason = checkCount(initiation.NumberOfTransactions, initiation.ControlSum, bulkPayment.Lines, amounts)
+	for i := range bulkPayment.Lines {
+		if bulkPayment.ReasonCode != "" {
+			reject(&bulkPayment.Lines[i], bulkPayment.ReasonCode, bulkPayment.Reason)
+		}
+	}
+	if bulkPaymentState(bulkPayment.Lines, model.BulkPaymentLinePending) == model.BulkPaymentRejected {
+		bulkPayment.State = model.BulkPaymentRejected
+	}
+
+	err := s.bulkPayments.Insert(&bulkPayment)
+	if err == repository.ErrDuplicate {
+		return nil, ErrBulkPaymentDuplicate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bulkPayment, nil
+}
+
+//bulkPaymentState for state of BulkPayment when none of lines is left to run, it is rejected only when every line
+//is rejected and completed only when every line is status. Line with unknown outcome makes it partially completed
+func bulkPaymentState(lines []model.BulkPaymentLine, status model.BulkPaymentLineStatus) model.BulkPaymentState {
+	done, rejected := 0, 0
+	for _, line := range lines {
+		switch line.Status {
+		case status:
+			done++
+		case model.BulkPaymentLineRejected:
+			rejected++
+		}
+	}
+	switch {
+	case rejected == len(lines):
+		return model.BulkPaymentRejected
+	case done == len(lines):
+		return model.BulkPaymentCompleted
+	}
+	return model.BulkPaymentPartiallyCompleted
+}
+
+//FindAllBulkPayment for FindAllBulkPayment
+func (s *BulkPaymentServiceImplement) FindAllBulkPayment(user model.User) ([]model.BulkPayment, error) {
+	bulkPayments, err := s.bulkPayments.FindByUser(user.ID)
+	if bulkPayments == nil {
+		bulkPayments = []model.BulkPayment{}
+	}
+	return bulkPayments, err
+}
+
+//FindBulkPayment for BulkPayment id of user
+func (s *BulkPaymentServiceImplement) FindBulkPayment(user model.User, id string) (model.BulkPayment, error) {
+	if !bson.IsObjectIdHex(id) {
+		return model.BulkPayment{}, errors.New("Not Have BulkPaymentID")
+	}
+	bulkPayment, err := s.bulkPayments.FindByID(bson.ObjectIdHex(id))
+	if err == repository.ErrNotFound || err == nil && bulkPayment.UserID != user.ID {
+		return model.BulkPayment{}, errors.New("Not Have BulkPaymentID")
+	}
+	return bulkPayment, err
+}
+
+//RunBulkPayment for make Tranfer of every pending line of BulkPayment id in order of the file.
+//Each line is claimed as executing with the TranferID its Tranfer will have before the Tranfer runs
+//and stored with its result after it, so TranferLog of a line left executing says whether its money moved
+func (s *BulkPaymentServiceImplement) RunBulkPayment(id bson.ObjectId) error {
+	bulkPayment, err := s.bulkPayments.FindByID(id)
+	if err != nil {
+		return err
+	}
+	switch bulkPayment.State {
+	case model.BulkPaymentReceived:
+		err = s.bulkPayments.SetState(id, model.BulkPaymentReceived, model.BulkPaymentProcessing, time.Now())
+		if err == repository.ErrNotFound {
+			//run by someone else since it was found
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	case model.BulkPaymentProcessing:
+	default:
+		return nil
+	}
+
+	for _, line := range bulkPayment.Lines {
+		if line.Status != model.BulkPaymentLinePending {
+			continue
+		}
+		line.Status = model.BulkPaymentLineExecuting
+		line.TranferID = bson.NewObjectId()
+		line.LastModified = time.Now()
+		err = s.bulkPayments.SetLine(id, line, model.BulkPaymentLinePending)
+		if err == repository.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		//User is read again for every line, Balance of its bank accounts changes with each Tranfer
+		user, err := s.userService.FindByIDUser(bulkPayment.UserID.Hex())
+		if err == nil {
+			_, err = s.tranfers.tranfer(&model.Tranfer{
+				Amount: line.Amount,
+				From:   line.AccountNumberFrom,
+				To:     line.AccountNumberTo,
+			}, user, line.TranferID, false)
+		}
+		if _, ok := err.(committedError); ok {
+			//money may have moved, line stays executing until RecoverBulkPayment reads its TranferLog
+			return err
+		}
+		if err == nil {
+			line.Status = model.BulkPaymentLineDone
+		} else {
+			line.Status = model.BulkPaymentLineRejected
+			line.ReasonCode = bulkPaymentReason(err)
+			line.Reason = err.Error()
+		}
+		line.LastModified = time.Now()
+		if err := s.bulkPayments.SetLine(id, line, model.BulkPaymentLineExecuting); err != nil {
+			return err
+		}
+	}
+
+	bulkPayment, err = s.bulkPayments.FindByID(id)
+	if err != nil {
+		return err
+	}
+	for _, line := range bulkPayment.Lines {
+		if line.Status == model.BulkPaymentLinePending || line.Status == model.BulkPaymentLineExecuting {
+			//another run still has lines of it
+			return nil
+		}
+	}
+	err = s.bulkPayments.SetState(id, model.BulkPaymentProcessing, bulkPaymentState(bulkPayment.Lines, model.BulkPaymentLineDone), time.Now())
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+//resolveLine for status of line left executing by the state of TranferLog of its TranferID,
+//
+//line whose Tranfer never started is pending again
+func (s *BulkPaymentServiceImplement) resolveLine(line model.BulkPaymentLine) (model.BulkPaymentLine, error) {
+	line.LastModified = time.Now()
+	if line.TranferID == "" {
+		//claimed before lines were given TranferID, nothing says whether its money moved
+		line.Status = model.BulkPaymentLineUnknown
+		line.ReasonCode = ReasonNarrative
+		line.Reason = "server stopped during Tranfer, check ledger of " + line.AccountNumberFrom + " before paying it again"
+		return line, nil
+	}
+	tranferLog, err := s.tranfers.tranfers.FindByID(line.TranferID)
+	if err == repository.ErrNotFound {
+		line.Status = model.BulkPaymentLinePending
+		return line, nil
+	}
+	if err != nil {
+		return line, err
+	}
+	if tranferLog.State != model.TranferStateDone && tranferLog.State != model.TranferStateCancelled {
+		if err := s.tranfers.RecoverTranfer(); err != nil {
+			return line, err
+		}
+		tranferLog, err = s.tranfers.tranfers.FindByID(line.TranferID)
+		if err != nil {
+			return line, err
+		}
+	}
+	switch tranferLog.State {
+	case model.TranferStateDone:
+		line.Status = model.BulkPaymentLineDone
+	case model.TranferStateCancelled:
+		line.Status = model.BulkPaymentLineRejected
+		line.ReasonCode = ReasonNotSpecifiedReasonAgentCreated
+		line.Reason = "tranfer " + tranferLog.ID.Hex() + " was cancelled"
+	default:
+		return line, fmt.Errorf("tranfer %s is %s", tranferLog.ID.Hex(), tranferLog.State)
+	}
+	return line, nil
+}
+
+//RecoverBulkPayment for run every BulkPayment left behind when the server stopped, it must run after
+//RecoverTranfer and before any other RunBulkPayment. Line that was executing is done or rejected as
+//its TranferLog ended and is run again when its Tranfer never started
+func (s *BulkPaymentServiceImplement) RecoverBulkPayment() error {
+	bulkPayments, err := s.bulkPayments.FindByState(model.BulkPaymentReceived, model.BulkPaymentProcessing)
+	if err != nil {
+		return err
+	}
+	for _, bulkPayment := range bulkPayments {
+		for _, line := range bulkPayment.Lines {
+			if line.Status != model.BulkPaymentLineExecuting {
+				continue
+			}
+			line, err := s.resolveLine(line)
+			if err != nil {
+				return fmt.Errorf("recover bulk payment %s: %s", bulkPayment.ID.Hex(), err)
+			}
+			if err := s.bulkPayments.SetLine(bulkPayment.ID, line, model.BulkPaymentLineExecuting); err != nil && err != repository.ErrNotFound {
+				return err
+			}
+		}
+		if err := s.RunBulkPayment(bulkPayment.ID); err != nil {
+			return fmt.Errorf("recover bulk payment %s: %s", bulkPayment.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+//runBulkPayment for RunBulkPayment in background, error is logged and the rest of it is run by RecoverBulkPayment
+func runBulkPayment(bulkPaymentService BulkPaymentService, id bson.ObjectId) {
+	if err := bulkPaymentService.RunBulkPayment(id); err != nil {
+		log.Println("bulk payment "+id.Hex()+":", err)
+	}
+}
+
+//bulkPaymentReport for send pain.002 of bulkPayment
+func bulkPaymentReport(c echo.Context, status int, bulkPayment model.BulkPayment) error {
+	var body bytes.Buffer
+	if err := pain.WritePain002(&body, bulkPayment, time.Now().In(config.BusinessTimeZone.Location)); err != nil {
+		return MapHTTPError(err)
+	}
+	return c.Blob(status, echo.MIMEApplicationXMLCharsetUTF8, body.Bytes())
+}
+
+//CreateBulkPaymentEndPoint is CreateBulkPaymentEndPoint, it takes pain.001 and answers pain.002 while the lines run in background
+func (m *DataObjectAccess) CreateBulkPaymentEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	initiation, err := pain.ParsePain001(io.LimitReader(c.Request().Body, BulkPaymentMaxSize))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	bulkPaymentResp, err := m.bulkPaymentService.CreateBulkPayment(initiation, user)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(bulkPaymentResp)
+	if bulkPaymentResp.State == model.BulkPaymentReceived {
+		go runBulkPayment(m.bulkPaymentService, bulkPaymentResp.ID)
+	}
+	c.Response().Header().Set(echo.HeaderLocation, c.Request().URL.Path+"/"+bulkPaymentResp.ID.Hex())
+	return bulkPaymentReport(c, http.StatusAccepted, *bulkPaymentResp)
+}
+
+//FindAllBulkPaymentEndPoint is FindAllBulkPaymentEndPoint
+func (m *DataObjectAccess) FindAllBulkPaymentEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	bulkPayments, err := m.bulkPaymentService.FindAllBulkPayment(user)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(bulkPayments)
+	return c.JSON(http.StatusOK, MapJSONBulkPayment(bulkPayments))
+}
+
+//FindBulkPaymentEndPoint is FindBulkPaymentEndPoint, it answers pain.002 of BulkPayment as it is now
+func (m *DataObjectAccess) FindBulkPaymentEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	bulkPaymentResp, err := m.bulkPaymentService.FindBulkPayment(user, c.Param("idBulkPayment"))
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(bulkPaymentResp)
+	return bulkPaymentReport(c, http.StatusOK, bulkPaymentResp)
+}
+
+//MapJSONBulkPayment for MapJSONBulkPayment
+func MapJSONBulkPayment(bulkPayment interface{}) interface{} {
+	dataJSON := map[string]interface{}{
+		"bulk_payments": bulkPayment,
+	}
+	return dataJSON
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/pain"
+	"bankaccountapi/repository"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//testPain001 for pain.001 of alice paying amounts from 111-1 to 222-2, one CdtTrfTxInf for each
+func testPain001(amounts ...string) pain.Pain001 {
+	paymentInformation := pain.PaymentInformation{ID: "PMT-1", DebtorAccount: pain.Account{Other: "111-1"}}
+	for i, amount := range amounts {
+		paymentInformation.CreditTransfers = append(paymentInformation.CreditTransfers, pain.CreditTransfer{
+			EndToEndID:      "E2E-" + strconv.Itoa(i+1),
+			Amount:          pain.Amount{Currency: model.DefaultCurrency, Value: amount},
+			CreditorAccount: pain.Account{Other: "222-2"},
+		})
+	}
+	return pain.Pain001{
+		MessageID:            "MSG-1",
+		NumberOfTransactions: strconv.Itoa(len(amounts)),
+		PaymentInformation:   []pain.PaymentInformation{paymentInformation},
+	}
+}
+
+func TestRecoverBulkPaymentResolvesExecutingLines(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			faulty := newFaultyBankAccounts(store)
+			d := newTestDAO(t, store)
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			createTestBankAccount(t, d, &alice, "111-1", "100")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+
+			bulkPayment, err := d.bulkPaymentService.CreateBulkPayment(testPain001("40", "10", "5"), alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			//server stops between debit and credit of the first line
+			faulty.fail(faulty.applyErr, "222-2", errCrash)
+			crash(t, func() {
+				d.bulkPaymentService.RunBulkPayment(bulkPayment.ID)
+			})
+			faulty.fail(faulty.applyErr, "222-2", nil)
+
+			//second line was claimed and its Tranfer was cancelled, third was claimed and its Tranfer never started
+			stored, err := d.bulkPaymentService.FindBulkPayment(alice, bulkPayment.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line := stored.Lines[0]; line.Status != model.BulkPaymentLineExecuting || line.TranferID == "" {
+				t.Fatalf("first line is %s with TranferID %q, want %s with TranferID", line.Status, line.TranferID, model.BulkPaymentLineExecuting)
+			}
+			cancelled := model.TranferLog{
+				ID:                bson.NewObjectId(),
+				UserFrom:          alice.ID,
+				UserTo:            bob.ID,
+				AccountNumberFrom: "111-1",
+				AccountNumberTo:   "222-2",
+				Amount:            testMoney(t, "10"),
+				AmountTo:          testMoney(t, "10"),
+				State:             model.TranferStateCancelled,
+				LastModified:      time.Now(),
+			}
+			if err := store.Tranfers.Insert(&cancelled); err != nil {
+				t.Fatal(err)
+			}
+			for i, tranferID := range []bson.ObjectId{cancelled.ID, bson.NewObjectId()} {
+				line := stored.Lines[i+1]
+				line.Status = model.BulkPaymentLineExecuting
+				line.TranferID = tranferID
+				if err := store.BulkPayments.SetLine(bulkPayment.ID, line, model.BulkPaymentLinePending); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			//RecoverTranfer has not run, RecoverBulkPayment finishes first line itself
+			if err := d.bulkPaymentService.RecoverBulkPayment(); err != nil {
+				t.Fatal(err)
+			}
+			stored, err = d.bulkPaymentService.FindBulkPayment(alice, bulkPayment.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []model.BulkPaymentLineStatus{model.BulkPaymentLineDone, model.BulkPaymentLineRejected, model.BulkPaymentLineDone}
+			for i, line := range stored.Lines {
+				if line.Status != want[i] {
+					t.Fatalf("line %d is %s with %q, want %s", line.Line, line.Status, line.Reason, want[i])
+				}
+			}
+			if stored.State != model.BulkPaymentPartiallyCompleted {
+				t.Fatalf("bulk payment is %s, want %s", stored.State, model.BulkPaymentPartiallyCompleted)
+			}
+			if unfinished := unfinishedTranfers(t, store); len(unfinished) != 0 {
+				t.Fatalf("%d tranfers left unfinished after recovery, want 0", len(unfinished))
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "55.00" {
+				t.Fatalf("balance of 111-1 is %s, want 55.00", balance)
+			}
+			if balance := balanceOf(t, store, "222-2"); balance != "46.00" {
+				t.Fatalf("balance of 222-2 is %s, want 46.00", balance)
+			}
+		})
+	}
+}
+
+func TestCreateBulkPaymentRejectsLines(t *testing.T) {
+	d := newTestDAO(t, repository.NewMemoryStore())
+	alice := createTestUser(t, d, "alice")
+	bob := createTestUser(t, d, "bob")
+	createTestBankAccount(t, d, &alice, "111-1", "100")
+	createTestBankAccount(t, d, &bob, "222-2", "1")
+
+	initiation := testPain001("10", "0", "-1", "1.001", "10")
+	credit := initiation.PaymentInformation[0].CreditTransfers
+	credit[4].EndToEndID = credit[0].EndToEndID
+	initiation.PaymentInformation = append(initiation.PaymentInformation, pain.PaymentInformation{
+		ID:            "PMT-2",
+		DebtorAccount: pain.Account{Other: "222-2"},
+		CreditTransfers: []pain.CreditTransfer{
+			{EndToEndID: "E2E-6", Amount: pain.Amount{Currency: model.DefaultCurrency, Value: "1"}, CreditorAccount: pain.Account{Other: "111-1"}},
+		},
+	}, pain.PaymentInformation{
+		ID:            "PMT-3",
+		DebtorAccount: pain.Account{Other: "111-1"},
+		CreditTransfers: []pain.CreditTransfer{
+			{EndToEndID: "E2E-7", Amount: pain.Amount{Currency: model.DefaultCurrency, Value: "1"}, CreditorAccount: pain.Account{Other: "999-9"}},
+			{EndToEndID: "E2E-8", Amount: pain.Amount{Currency: "USD", Value: "1"}, CreditorAccount: pain.Account{Other: "222-2"}},
+		},
+	})
+	initiation.NumberOfTransactions = "8"
+	bulkPayment, err := d.bulkPaymentService.CreateBulkPayment(initiation, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"", ReasonZeroAmount, ReasonInvalidAmount, ReasonInvalidAmount, ReasonDuplication, ReasonIncorrectAccountNumber, ReasonInvalidCreditorAccountNumber, ReasonNotAllowedCurrency}
+	for i, line := range bulkPayment.Lines {
+		if line.ReasonCode != want[i] || (line.Status == model.BulkPaymentLineRejected) != (want[i] != "") {
+			t.Errorf("line %d is %s with %s, want reason %q", line.Line, line.Status, line.ReasonCode, want[i])
+		}
+	}
+	if bulkPayment.State != model.BulkPaymentReceived {
+		t.Fatalf("bulk payment is %s, want %s", bulkPayment.State, model.BulkPaymentReceived)
+	}
+
+	//NbOfTxs that does not match rejects the whole file
+	initiation.MessageID = "MSG-2"
+	initiation.NumberOfTransactions = "7"
+	bulkPayment, err = d.bulkPaymentService.CreateBulkPayment(initiation, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bulkPayment.State != model.BulkPaymentRejected || bulkPayment.ReasonCode != ReasonInvalidNumberOfTransactions {
+		t.Fatalf("bulk payment is %s with %s, want %s with %s", bulkPayment.State, bulkPayment.ReasonCode, model.BulkPaymentRejected, ReasonInvalidNumberOfTransactions)
+	}
+	if _, err := d.bulkPaymentService.CreateBulkPayment(initiation, alice); err != ErrBulkPaymentDuplicate {
+		t.Fatalf("second MSG-2 got %v, want %v", err, ErrBulkPaymentDuplicate)
+	}
+	if _, err := d.bulkPaymentService.CreateBulkPayment(pain.Pain001{MessageID: "MSG-3", NumberOfTransactions: "0"}, alice); err != ErrBulkPaymentEmpty {
+		t.Fatalf("pain.001 without CdtTrfTxInf got %v, want %v", err, ErrBulkPaymentEmpty)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"time"
+)
+
+//BusinessDay for business date t belongs to, as midnight of that date in business_time_zone.
+//Business date starts at business_day_start, so before it t still belongs to the date before
+func BusinessDay(t time.Time) time.Time {
+	location := config.BusinessTimeZone.Location
+	local := t.In(location).Add(-config.BusinessDayStart.Duration)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+}
+
+//businessDayStart for moment business date of day starts
+func businessDayStart(day time.Time) time.Time {
+	location := config.BusinessTimeZone.Location
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, location).Add(config.BusinessDayStart.Duration)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bankaccountapi/internal"
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	//CommandMigrateBankAccounts is command for move bank accounts out of users in mgo
+	CommandMigrateBankAccounts = "migrate-bank-accounts"
+	//CommandAccrueInterest is command for run interest accrual once, for today or date given after it
+	CommandAccrueInterest = "accrue-interest"
+	//CommandStatement is command for write statement files of format from date to date, of every bank account
+	//or of account numbers given after them
+	CommandStatement = "statement"
+	//CommandImportUsers is command for bulk import of users and bank accounts from CSV file,
+	//report of every row is written to standard output as CSV
+	CommandImportUsers = "import-users"
+)
+
+//RunCommand for run one-shot command given on command line instead of server
+func RunCommand(args []string) error {
+	switch args[0] {
+	case CommandMigrateBankAccounts:
+		return migrateBankAccounts()
+	case CommandAccrueInterest:
+		return accrueInterest(args[1:])
+	case CommandStatement:
+		return writeStatements(args[1:])
+	case CommandImportUsers:
+		return importUsers(args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+//migrateBankAccounts for split bank accounts embedded in users into their own collection,
+//SQL backends already keep them in their own table and are migrated when they are opened
+func migrateBankAccounts() error {
+	if config.Backend != internal.BackendMongo {
+		_, err := OpenStore(config)
+		if err == nil {
+			fmt.Println("nothing to migrate for backend", config.Backend)
+		}
+		return err
+	}
+	s.Server = config.Server
+	s.Database = config.Database
+	s.Connect()
+	migrated, err := repository.MigrateMongoBankAccounts(dbs)
+	fmt.Printf("migrated bank accounts of %d users\n", migrated)
+	return err
+}
+
+//accrueInterest for RunInterest once, args may have date YYYY-MM-DD to run it as if it was that day
+func accrueInterest(args []string) error {
+	today := BusinessDay(time.Now())
+	if len(args) > 0 {
+		var err error
+		today, err = time.ParseInLocation(BusinessDateFormat, args[0], config.BusinessTimeZone.Location)
+		if err != nil {
+			return errors.New("date must be YYYY-MM-DD")
+		}
+	}
+	store, err := OpenStore(config)
+	if err != nil {
+		return err
+	}
+	products, err := LoadProducts(config.Products)
+	if err != nil {
+		return err
+	}
+	dao := NewDataObjectAccess(store, nil, products, nil)
+	return dao.interestService.RunInterest(today)
+}
+
+//writeStatements for write statement of each bank account to file named by StatementFilename in working directory,
+//args are format, from, to and account numbers, every bank account is written when no account number is given
+func writeStatements(args []string) error {
+	if len(args) < 3 {
+		return errors.New("usage: statement FORMAT FROM TO [ACCOUNT_NUMBER...]")
+	}
+	statementFormat, ok := StatementFormats[args[0]]
+	if !ok {
+		return ErrStatementFormat
+	}
+	from, to, err := ParseStatementPeriod(args[1], args[2])
+	if err != nil {
+		return err
+	}
+	store, err := OpenStore(config)
+	if err != nil {
+		return err
+	}
+	dao := NewDataObjectAccess(store, nil, nil, nil)
+
+	var bankAccounts []model.BankAccount
+	if len(args) == 3 {
+		if bankAccounts, err = store.BankAccounts.FindAll(); err != nil {
+			return err
+		}
+	}
+	for _, accountNumber := range args[3:] {
+		bankAccount, err := store.BankAccounts.FindByAccountNumber(accountNumber)
+		if err == repository.ErrNotFound {
+			return fmt.Errorf("Not Have AccountNumber %s", accountNumber)
+		}
+		if err != nil {
+			return err
+		}
+		bankAccounts = append(bankAccounts, bankAccount)
+	}
+	for _, bankAccount := range bankAccounts {
+		user, err := dao.userService.FindByIDUser(bankAccount.UserID.Hex())
+		if err != nil {
+			return err
+		}
+		statementResp, err := dao.bankAccountService.Statement(user, bankAccount.ID.Hex(), from, to)
+		if err != nil {
+			return fmt.Errorf("%s: %v", bankAccount.AccountNumber, err)
+		}
+		filename := StatementFilename(statementResp, statementFormat)
+		if err := writeStatementFile(filename, statementFormat, statementResp); err != nil {
+			return err
+		}
+		fmt.Println(filename)
+	}
+	return nil
+}
+
+func writeStatementFile(filename string, statementFormat StatementFormat, statementResp model.Statement) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := statementFormat.Write(file, statementResp); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//importUsers for ImportUsers of CSV file, args are -dry-run to only check rows and file name.
+//Report of every row is written to standard output and the count of rows to standard error
+func importUsers(args []string) error {
+	dryRun := len(args) > 0 && args[0] == "-dry-run"
+	if dryRun {
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return errors.New("usage: import-users [-dry-run] FILE")
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	store, err := OpenStore(config)
+	if err != nil {
+		return err
+	}
+	products, err := LoadProducts(config.Products)
+	if err != nil {
+		return err
+	}
+	dao := NewDataObjectAccess(store, nil, products, nil)
+
+	report := csv.NewWriter(os.Stdout)
+	report.Write([]string{"row", "username", "account_number", "result", "error"})
+	summary, err := dao.importService.ImportUsers(file, dryRun, func(row model.ImportRow) error {
+		return report.Write([]string{strconv.Itoa(row.Row), row.Username, row.AccountNumber, string(row.Result), row.Error})
+	})
+	report.Flush()
+	fmt.Fprintf(os.Stderr, "rows %d, users %d, bank accounts %d, rejected %d, skipped %d, dry run %v\n",
+		summary.Rows, summary.Users, summary.BankAccounts, summary.Rejected, summary.Skipped, summary.DryRun)
+	if err != nil {
+		return err
+	}
+	return report.Error()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	//HeaderETag is header of Version sent back to client
+	HeaderETag = "ETag"
+	//HeaderIfMatch is header of Version client expects before it changes something
+	HeaderIfMatch = "If-Match"
+	//AnyVersion is version for accept any Version, it is used when request has no If-Match
+	AnyVersion int64 = -1
+	//MaxConflictRetry is how many times service reads again and retries update after ErrConflict
+	MaxConflictRetry = 5
+)
+
+//ErrPreconditionFailed is returned when Version is not Version from If-Match
+var ErrPreconditionFailed = errors.New("resource was changed, If-Match does not match")
+
+//ETag for ETag of Version, it is quoted Version
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+//ParseIfMatch for read Version from If-Match, AnyVersion when there is no If-Match or it is *
+func ParseIfMatch(c echo.Context) (int64, error) {
+	ifMatch := strings.TrimSpace(c.Request().Header.Get(HeaderIfMatch))
+	if ifMatch == "" || ifMatch == "*" {
+		return AnyVersion, nil
+	}
+	value, err := strconv.Unquote(ifMatch)
+	if err != nil {
+		return 0, errors.New("If-Match must be ETag like \"3\"")
+	}
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 0 {
+		return 0, errors.New("If-Match must be ETag like \"3\"")
+	}
+	return version, nil
+}
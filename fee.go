@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bankaccountapi/fee"
+	"bankaccountapi/fx"
+	"bankaccountapi/internal"
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+var (
+	//ErrFeeKindUnknown is returned when FeeKind is not withdraw or tranfer
+	ErrFeeKindUnknown = errors.New("kind must be withdraw or tranfer")
+	//ErrFeeIncomeAccount is returned when fee is charged but fee_income_account is not set
+	ErrFeeIncomeAccount = errors.New("fee_income_account is not set")
+)
+
+//feeTransactionType is TransactionType counted for FreePerMonth of each FeeKind
+var feeTransactionType = map[model.FeeKind]model.TransactionType{
+	model.FeeKindWithdraw: model.TransactionTypeWithdraw,
+	model.FeeKindTranfer:  model.TransactionTypeTranferOut,
+}
+
+//FeeService is interface
+type FeeService interface {
+	QuoteFee(user model.User, id string, kind model.FeeKind, amount model.Money) (*model.FeeQuote, error)
+}
+
+//FeeServiceImplement is struct
+type FeeServiceImplement struct {
+	transactions repository.TransactionRepository
+	fees         map[model.Product]model.ProductFees
+}
+
+//LoadFees for ProductFees of every Product in config.toml, Product without fees costs nothing
+func LoadFees(products map[string]internal.Product, feeIncomeAccount string) (map[model.Product]model.ProductFees, error) {
+	fees := map[model.Product]model.ProductFees{}
+	for name, product := range products {
+		productFees := model.ProductFees{
+			model.FeeKindWithdraw: product.WithdrawFee,
+			model.FeeKindTranfer:  product.TranferFee,
+		}
+		for kind, schedule := range productFees {
+			if schedule.Type == model.FeeTypeNone {
+				continue
+			}
+			if err := fee.Validate(schedule); err != nil {
+				return nil, fmt.Errorf("config.toml: %s fee of product %s: %s", kind, name, err)
+			}
+			if feeIncomeAccount == "" {
+				return nil, fmt.Errorf("config.toml: product %s has %s fee: %s", name, kind, ErrFeeIncomeAccount)
+			}
+		}
+		fees[model.Product(name)] = productFees
+	}
+	return fees, nil
+}
+
+//monthStart for start of the first business date of the month t belongs to, FreePerMonth is counted from it
+func monthStart(t time.Time) time.Time {
+	day := BusinessDay(t)
+	return businessDayStart(time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location()))
+}
+
+//quote for fee bankAccount pays for amount of kind, amount must be in Currency of bankAccount
+func (f *FeeServiceImplement) quote(bankAccount model.BankAccount, kind model.FeeKind, amount model.Money) (model.FeeQuote, error) {
+	transactionType, ok := feeTransactionType[kind]
+	if !ok {
+		return model.FeeQuote{}, ErrFeeKindUnknown
+	}
+	schedule := f.fees[bankAccount.AccountProduct()][kind]
+	used := 0
+	if schedule.FreePerMonth > 0 {
+		var err error
+		used, err = f.transactions.CountByType(bankAccount.ID, transactionType, monthStart(time.Now()))
+		if err != nil {
+			return model.FeeQuote{}, err
+		}
+	}
+	charge, err := fee.Calculate(schedule, amount, used)
+	if err != nil {
+		return model.FeeQuote{}, err
+	}
+	freeRemaining := schedule.FreePerMonth - used
+	if freeRemaining < 0 {
+		freeRemaining = 0
+	}
+	return model.FeeQuote{
+		BankAccountID: bankAccount.ID,
+		Kind:          kind,
+		Amount:        amount,
+		Fee:           charge,
+		FreeRemaining: freeRemaining,
+	}, nil
+}
+
+//QuoteFee for fee BankAccount of user would pay for amount of kind now
+func (f *FeeServiceImplement) QuoteFee(user model.User, id string, kind model.FeeKind, amount model.Money) (*model.FeeQuote, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
+	}
+	bankAccount, ok := findBankAccount(user, bson.ObjectIdHex(id))
+	if !ok {
+		return nil, ErrBankAccountNotFound
+	}
+	if amount.IsZero() {
+		return nil, errors.New("please require Amount")
+	}
+	if amount.IsNegative() {
+		return nil, ErrInvalidAmount
+	}
+	amount, err := amount.In(bankAccount.AccountCurrency())
+	if err != nil {
+		return nil, err
+	}
+	feeQuote, err := f.quote(bankAccount, kind, amount)
+	if err != nil {
+		return nil, err
+	}
+	return &feeQuote, nil
+}
+
+//addFee makes charge a leg of tranferLog that leaves AccountNumberFrom and arrives at fee_income_account,
+//so it moves in the same two-phase commit as what it is charged for
+func (t *TranferServiceImplement) addFee(tranferLog *model.TranferLog, charge model.Money) error {
+	if charge.IsZero() {
+		return nil
+	}
+	if t.feeIncomeAccount == "" {
+		return ErrFeeIncomeAccount
+	}
+	incomeAccount, err := t.bankAccounts.FindByAccountNumber(t.feeIncomeAccount)
+	if err != nil {
+		return fmt.Errorf("fee_income_account %s: %s", t.feeIncomeAccount, err)
+	}
+	exchangeRate, err := t.rates.Rate(charge.Currency, incomeAccount.AccountCurrency())
+	if err != nil {
+		return err
+	}
+	chargeTo, err := fx.Convert(charge, exchangeRate)
+	if err != nil {
+		return err
+	}
+	tranferLog.Fee = charge
+	tranferLog.FeeTo = chargeTo
+	tranferLog.FeeAccountNumber = incomeAccount.AccountNumber
+	tranferLog.FeeID = bson.NewObjectId()
+	return nil
+}
+
+//QuoteFeeEndPoint is QuoteFeeEndPoint
+func (m *DataObjectAccess) QuoteFeeEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	var amount model.Money
+	if err := amount.UnmarshalJSON([]byte(c.QueryParam("amount"))); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "amount must be decimal number like \"100.25\"")
+	}
+	feeQuote, err := m.feeService.QuoteFee(user, c.Param("idBankAccount"), model.FeeKind(c.QueryParam("kind")), amount)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(feeQuote)
+	return c.JSON(http.StatusOK, MapJSONFee(feeQuote))
+}
+
+//MapJSONFee for MapJSONFee
+func MapJSONFee(feeQuote interface{}) interface{} {
+	dataJSON := map[string]interface{}{
+		"fee": feeQuote,
+	}
+	return dataJSON
+}
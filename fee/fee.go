@@ -0,0 +1,169 @@
+package fee
+
+import (
+	"bankaccountapi/model"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+var (
+	//ErrPercentFormat is returned when Percent is not decimal percent
+	ErrPercentFormat = errors.New("fee percent must be decimal percent like \"0.25\"")
+	//ErrFeeType is returned when Type of FeeSchedule is not supported
+	ErrFeeType = errors.New("fee type must be flat, percent or tiered")
+	//ErrTiers is returned when FeeTier are not in order of UpTo or the last one has UpTo
+	ErrTiers = errors.New("fee tiers must go up by up_to and the last one must have no up_to")
+	//ErrNegative is returned when amount of FeeSchedule is below zero
+	ErrNegative = errors.New("fee amount must not be negative")
+)
+
+//Validate for check every amount and percent of schedule can be read
+func Validate(schedule model.FeeSchedule) error {
+	_, err := Calculate(schedule, model.Money{Currency: model.DefaultCurrency}, schedule.FreePerMonth)
+	if err != nil {
+		return err
+	}
+	if schedule.Type != model.FeeTypeTiered {
+		return nil
+	}
+	if len(schedule.Tiers) == 0 || schedule.Tiers[len(schedule.Tiers)-1].UpTo != "" {
+		return ErrTiers
+	}
+	var last model.Money
+	for i, tier := range schedule.Tiers {
+		if _, err := tierFee(tier, model.Money{Currency: model.DefaultCurrency}); err != nil {
+			return err
+		}
+		if tier.UpTo == "" {
+			if i != len(schedule.Tiers)-1 {
+				return ErrTiers
+			}
+			continue
+		}
+		upTo, err := model.ParseMoney(tier.UpTo, model.DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		if i > 0 && upTo.Amount <= last.Amount {
+			return ErrTiers
+		}
+		last = upTo
+	}
+	return nil
+}
+
+//Calculate for fee of amount under schedule, used is how many of the same FeeKind
+//BankAccount made this month before this one. Fee is rounded half to even
+func Calculate(schedule model.FeeSchedule, amount model.Money, used int) (model.Money, error) {
+	zero := model.Money{Currency: amount.Currency}
+	var fee model.Money
+	var err error
+	switch schedule.Type {
+	case model.FeeTypeNone:
+		return zero, nil
+	case model.FeeTypeFlat:
+		fee, err = parseAmount(schedule.Amount, amount.Currency)
+	case model.FeeTypePercent:
+		fee, err = percentOf(schedule.Percent, amount)
+		if err == nil {
+			fee, err = clamp(fee, schedule.Minimum, schedule.Maximum)
+		}
+	case model.FeeTypeTiered:
+		fee, err = tiered(schedule.Tiers, amount)
+	default:
+		return zero, ErrFeeType
+	}
+	if err != nil {
+		return zero, err
+	}
+	if used < schedule.FreePerMonth {
+		return zero, nil
+	}
+	return fee, nil
+}
+
+func tiered(tiers []model.FeeTier, amount model.Money) (model.Money, error) {
+	for _, tier := range tiers {
+		if tier.UpTo != "" {
+			upTo, err := parseAmount(tier.UpTo, amount.Currency)
+			if err != nil {
+				return model.Money{}, err
+			}
+			if amount.Amount > upTo.Amount {
+				continue
+			}
+		}
+		return tierFee(tier, amount)
+	}
+	return model.Money{Currency: amount.Currency}, nil
+}
+
+func tierFee(tier model.FeeTier, amount model.Money) (model.Money, error) {
+	flat, err := parseAmount(tier.Amount, amount.Currency)
+	if err != nil {
+		return model.Money{}, err
+	}
+	percent, err := percentOf(tier.Percent, amount)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return flat.Add(percent)
+}
+
+//parseAmount for Money of currency from value, empty value is zero
+func parseAmount(value string, currency string) (model.Money, error) {
+	if value == "" {
+		return model.Money{Currency: currency}, nil
+	}
+	money, err := model.ParseMoney(value, currency)
+	if err == nil && money.IsNegative() {
+		return model.Money{}, ErrNegative
+	}
+	return money, err
+}
+
+func percentOf(percent string, amount model.Money) (model.Money, error) {
+	zero := model.Money{Currency: amount.Currency}
+	percent = strings.TrimSpace(percent)
+	if percent == "" {
+		return zero, nil
+	}
+	if strings.Trim(percent, "0123456789.") != "" || strings.Count(percent, ".") > 1 {
+		return zero, ErrPercentFormat
+	}
+	rate, ok := new(big.Rat).SetString(percent)
+	if !ok {
+		return zero, ErrPercentFormat
+	}
+	value := new(big.Rat).SetInt64(amount.Amount)
+	value.Mul(value, rate)
+	value.Quo(value, big.NewRat(100, 1))
+	fee, err := model.RoundHalfEven(value)
+	if err != nil {
+		return zero, err
+	}
+	return model.Money{Amount: fee, Currency: amount.Currency}, nil
+}
+
+func clamp(fee model.Money, minimum string, maximum string) (model.Money, error) {
+	if minimum != "" {
+		min, err := parseAmount(minimum, fee.Currency)
+		if err != nil {
+			return model.Money{}, err
+		}
+		if fee.Amount < min.Amount {
+			fee.Amount = min.Amount
+		}
+	}
+	if maximum != "" {
+		max, err := parseAmount(maximum, fee.Currency)
+		if err != nil {
+			return model.Money{}, err
+		}
+		if fee.Amount > max.Amount {
+			fee.Amount = max.Amount
+		}
+	}
+	return fee, nil
+}
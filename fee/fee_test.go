@@ -0,0 +1,67 @@
+package fee
+
+import (
+	"bankaccountapi/model"
+	"testing"
+)
+
+func TestCalculate(t *testing.T) {
+	tiers := []model.FeeTier{
+		{UpTo: "1000", Amount: "10"},
+		{UpTo: "10000", Amount: "5", Percent: "0.1"},
+		{Percent: "0.05"},
+	}
+	for _, tc := range []struct {
+		name     string
+		schedule model.FeeSchedule
+		amount   string
+		used     int
+		want     string
+	}{
+		{"none", model.FeeSchedule{}, "500", 0, "0.00"},
+		{"flat", model.FeeSchedule{Type: model.FeeTypeFlat, Amount: "15"}, "500", 0, "15.00"},
+		{"percent", model.FeeSchedule{Type: model.FeeTypePercent, Percent: "0.25"}, "1000", 0, "2.50"},
+		//0.25% of 10.10 is 0.02525, half to even
+		{"percent rounded", model.FeeSchedule{Type: model.FeeTypePercent, Percent: "0.25"}, "10.10", 0, "0.03"},
+		{"percent below minimum", model.FeeSchedule{Type: model.FeeTypePercent, Percent: "0.25", Minimum: "5", Maximum: "20"}, "1000", 0, "5.00"},
+		{"percent above maximum", model.FeeSchedule{Type: model.FeeTypePercent, Percent: "0.25", Minimum: "5", Maximum: "20"}, "100000", 0, "20.00"},
+		{"percent between", model.FeeSchedule{Type: model.FeeTypePercent, Percent: "0.25", Minimum: "5", Maximum: "20"}, "4000", 0, "10.00"},
+		{"tiered first", model.FeeSchedule{Type: model.FeeTypeTiered, Tiers: tiers}, "1000", 0, "10.00"},
+		{"tiered second", model.FeeSchedule{Type: model.FeeTypeTiered, Tiers: tiers}, "2000", 0, "7.00"},
+		{"tiered last", model.FeeSchedule{Type: model.FeeTypeTiered, Tiers: tiers}, "20000", 0, "10.00"},
+		//the first FreePerMonth are free, the one after pays
+		{"free", model.FeeSchedule{Type: model.FeeTypeFlat, Amount: "15", FreePerMonth: 2}, "500", 1, "0.00"},
+		{"free used", model.FeeSchedule{Type: model.FeeTypeFlat, Amount: "15", FreePerMonth: 2}, "500", 2, "15.00"},
+	} {
+		amount, err := model.ParseMoney(tc.amount, model.DefaultCurrency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fee, err := Calculate(tc.schedule, amount, tc.used)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if fee.String() != tc.want || fee.Currency != amount.Currency {
+			t.Errorf("%s: fee of %s is %s %s, want %s", tc.name, tc.amount, fee, fee.Currency, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		schedule model.FeeSchedule
+		err      error
+	}{
+		{"unknown type", model.FeeSchedule{Type: "monthly"}, ErrFeeType},
+		{"negative amount", model.FeeSchedule{Type: model.FeeTypeFlat, Amount: "-1"}, ErrNegative},
+		{"percent sign", model.FeeSchedule{Type: model.FeeTypePercent, Percent: "0.25%"}, ErrPercentFormat},
+		{"no tiers", model.FeeSchedule{Type: model.FeeTypeTiered}, ErrTiers},
+		{"last tier has up_to", model.FeeSchedule{Type: model.FeeTypeTiered, Tiers: []model.FeeTier{{UpTo: "100", Amount: "1"}}}, ErrTiers},
+		{"tiers out of order", model.FeeSchedule{Type: model.FeeTypeTiered, Tiers: []model.FeeTier{{UpTo: "100"}, {UpTo: "50"}, {}}}, ErrTiers},
+	} {
+		if err := Validate(tc.schedule); err != tc.err {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
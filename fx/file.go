@@ -0,0 +1,132 @@
+package fx
+
+import (
+	"bankaccountapi/model"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+//FileRateProvider is RateProvider backed by JSON file of ExchangeRate list,
+//rate the other way round is used when only that one is in the file
+type FileRateProvider struct {
+	mu    sync.RWMutex
+	path  string
+	rates map[[2]string]model.ExchangeRate
+}
+
+//NewFileRateProvider for load FileRateProvider from path, missing file is empty rate table
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+	p := &FileRateProvider{path: path, rates: map[[2]string]model.ExchangeRate{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rates []model.ExchangeRate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, err
+	}
+	p.rates, err = rateTable(rates)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func rateTable(rates []model.ExchangeRate) (map[[2]string]model.ExchangeRate, error) {
+	table := map[[2]string]model.ExchangeRate{}
+	for _, rate := range rates {
+		if _, ok := model.CurrencyDecimals[rate.From]; !ok {
+			return nil, model.ErrCurrencyUnknown
+		}
+		if _, ok := model.CurrencyDecimals[rate.To]; !ok {
+			return nil, model.ErrCurrencyUnknown
+		}
+		if _, err := ParseRate(rate.Rate); err != nil {
+			return nil, err
+		}
+		table[[2]string{rate.From, rate.To}] = rate
+	}
+	return table, nil
+}
+
+//Rate for Rate
+func (p *FileRateProvider) Rate(from string, to string) (model.ExchangeRate, error) {
+	if from == to {
+		return model.ExchangeRate{From: from, To: to, Rate: "1"}, nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if rate, ok := p.rates[[2]string{from, to}]; ok {
+		return rate, nil
+	}
+	if rate, ok := p.rates[[2]string{to, from}]; ok {
+		return Inverse(rate)
+	}
+	return model.ExchangeRate{}, ErrRateNotFound
+}
+
+//Rates for list every ExchangeRate in the file
+func (p *FileRateProvider) Rates() []model.ExchangeRate {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	rates := []model.ExchangeRate{}
+	for _, rate := range p.rates {
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].From != rates[j].From {
+			return rates[i].From < rates[j].From
+		}
+		return rates[i].To < rates[j].To
+	})
+	return rates
+}
+
+//SetRates for replace every ExchangeRate and write them to the file,
+//the file is replaced at once so it is never half written
+func (p *FileRateProvider) SetRates(rates []model.ExchangeRate) error {
+	now := time.Now()
+	for i := range rates {
+		if rates[i].UpdatedAt.IsZero() {
+			rates[i].UpdatedAt = now
+		}
+	}
+	table, err := rateTable(rates)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rates, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tmp, err := ioutil.TempFile(filepath.Dir(p.path), filepath.Base(p.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	p.rates = table
+	return nil
+}
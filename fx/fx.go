@@ -0,0 +1,88 @@
+package fx
+
+import (
+	"bankaccountapi/model"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+var (
+	//ErrRateNotFound is returned when there is no ExchangeRate between two Currency
+	ErrRateNotFound = errors.New("exchange rate not found")
+	//ErrRateFormat is returned when Rate is not positive decimal number
+	ErrRateFormat = errors.New("rate must be positive decimal number like \"35.125\"")
+)
+
+//RateProvider is source of ExchangeRate
+type RateProvider interface {
+	//Rate returns ExchangeRate from one Currency to another, ErrRateNotFound when there is none
+	Rate(from string, to string) (model.ExchangeRate, error)
+}
+
+//RateTable is RateProvider that can be listed and replaced
+type RateTable interface {
+	RateProvider
+	Rates() []model.ExchangeRate
+	SetRates(rates []model.ExchangeRate) error
+}
+
+//ParseRate for parse Rate of ExchangeRate into exact fraction
+func ParseRate(rate string) (*big.Rat, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" || strings.Trim(rate, "0123456789.") != "" || strings.Count(rate, ".") > 1 {
+		return nil, ErrRateFormat
+	}
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return nil, ErrRateFormat
+	}
+	return r, nil
+}
+
+//Convert for convert amount by ExchangeRate into To of it, result is rounded half to even
+//to minor units of To so converting never goes through float64
+func Convert(amount model.Money, exchangeRate model.ExchangeRate) (model.Money, error) {
+	if amount.Currency != exchangeRate.From {
+		return model.Money{}, model.ErrCurrencyMismatch
+	}
+	decimalsFrom, ok := model.CurrencyDecimals[exchangeRate.From]
+	if !ok {
+		return model.Money{}, model.ErrCurrencyUnknown
+	}
+	decimalsTo, ok := model.CurrencyDecimals[exchangeRate.To]
+	if !ok {
+		return model.Money{}, model.ErrCurrencyUnknown
+	}
+	rate, err := ParseRate(exchangeRate.Rate)
+	if err != nil {
+		return model.Money{}, err
+	}
+
+	value := new(big.Rat).SetInt64(amount.Amount)
+	value.Mul(value, rate)
+	value.Mul(value, new(big.Rat).SetFrac(pow10(decimalsTo), pow10(decimalsFrom)))
+	amountTo, err := model.RoundHalfEven(value)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return model.Money{Amount: amountTo, Currency: exchangeRate.To}, nil
+}
+
+//Inverse for ExchangeRate the other way round, Rate is rounded to 10 decimals
+func Inverse(exchangeRate model.ExchangeRate) (model.ExchangeRate, error) {
+	rate, err := ParseRate(exchangeRate.Rate)
+	if err != nil {
+		return model.ExchangeRate{}, err
+	}
+	return model.ExchangeRate{
+		From:      exchangeRate.To,
+		To:        exchangeRate.From,
+		Rate:      strings.TrimRight(strings.TrimRight(new(big.Rat).Inv(rate).FloatString(10), "0"), "."),
+		UpdatedAt: exchangeRate.UpdatedAt,
+	}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
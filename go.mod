@@ -1,25 +1,44 @@
 module bankaccountapi
 
+go 1.27.1
+
 require (
 	github.com/BurntSushi/toml v0.3.1
-	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
-	github.com/ghodss/yaml v1.0.0 // indirect
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
-	github.com/go-openapi/jsonreference v0.18.0 // indirect
-	github.com/go-openapi/spec v0.18.0 // indirect
 	github.com/gorilla/mux v1.6.2
 	github.com/labstack/echo v3.3.5+incompatible
+	github.com/lib/pq v1.1.1
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/swaggo/echo-swagger v0.0.0-20180315045949-97f46bb9e5a5
+	github.com/swaggo/swag v1.4.0
+	golang.org/x/crypto v0.0.0-20181203042331-505ab145d0a9
+	gopkg.in/mgo.v2 v2.0.0-20180705113604-9856a29383ce
+)
+
+require (
+	github.com/PuerkitoBio/purell v1.1.0 // indirect
+	github.com/PuerkitoBio/urlesc v0.0.0-20170810143723-de5bf2ad4578 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/ghodss/yaml v1.0.0 // indirect
+	github.com/go-openapi/jsonpointer v0.17.0 // indirect
+	github.com/go-openapi/jsonreference v0.18.0 // indirect
+	github.com/go-openapi/spec v0.18.0 // indirect
+	github.com/go-openapi/swag v0.17.0 // indirect
 	github.com/labstack/gommon v0.2.8 // indirect
+	github.com/mailru/easyjson v0.0.0-20180823135443-60711f1a8329 // indirect
 	github.com/mattn/go-colorable v0.0.9 // indirect
 	github.com/mattn/go-isatty v0.0.4 // indirect
 	github.com/pkg/errors v0.8.0 // indirect
-	github.com/swaggo/echo-swagger v0.0.0-20180315045949-97f46bb9e5a5
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/testify v1.2.2 // indirect
 	github.com/swaggo/files v0.0.0-20180215091130-49c8a91ea3fa // indirect
-	github.com/swaggo/swag v1.4.0
 	github.com/urfave/cli v1.20.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v0.0.0-20170224212429-dcecefd839c4 // indirect
-	golang.org/x/crypto v0.0.0-20181203042331-505ab145d0a9 // indirect
+	golang.org/x/net v0.0.0-20181005035420-146acd28ed58 // indirect
+	golang.org/x/text v0.3.0 // indirect
 	golang.org/x/tools v0.0.0-20181221235234-d00ac6d27372 // indirect
-	gopkg.in/mgo.v2 v2.0.0-20180705113604-9856a29383ce
+	gopkg.in/check.v1 v0.0.0-20161208181325-20d25e280405 // indirect
+	gopkg.in/yaml.v2 v2.2.1 // indirect
 )
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	//HeaderIdempotencyKey is header client sends to make retry of the same request safe
+	HeaderIdempotencyKey = "Idempotency-Key"
+	//IdempotencyKeyCleanupInterval is how often expired IdempotencyKey are removed
+	IdempotencyKeyCleanupInterval = 10 * time.Minute
+	//contextCommitted is set on echo.Context when request failed after something was written
+	contextCommitted = "idempotency_committed"
+)
+
+//committedError is error of request that failed after something was written,
+//retry of the same Idempotency-Key gets its response again instead of writing it twice
+type committedError struct {
+	error
+}
+
+//committed for mark err as happened after something was written, nil stays nil
+func committed(err error) error {
+	if err == nil {
+		return nil
+	}
+	return committedError{err}
+}
+
+//MapCommittedHTTPError for MapHTTPError of err, request is marked for Idempotency when err is committedError
+func MapCommittedHTTPError(c echo.Context, err error) error {
+	if ce, ok := err.(committedError); ok {
+		c.Set(contextCommitted, true)
+		err = ce.error
+	}
+	return MapHTTPError(err)
+}
+
+//idempotencyRecorder for keep copy of response body while it is written to client
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *idempotencyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+//Idempotency is middleware for replay response of request with the same Idempotency-Key,
+//the same key with different request is rejected with 409. It must run after JWTAuth.
+//Key is only given back when request failed with 5xx before it wrote anything, see committed
+func Idempotency(idempotencyKeys repository.IdempotencyKeyRepository, ttl time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+			body, err := ioutil.ReadAll(c.Request().Body)
+			if err != nil {
+				return err
+			}
+			c.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			idempotencyKey := model.IdempotencyKey{
+				ID:          hashIdempotency(AuthUserID(c), key),
+				RequestHash: hashIdempotency(c.Request().Method, c.Request().URL.Path, string(body)),
+				ExpiresAt:   now.Add(ttl),
+				CreatedAt:   now,
+			}
+			err = idempotencyKeys.Insert(&idempotencyKey)
+			if err == repository.ErrDuplicate && deleteExpiredIdempotency(idempotencyKeys, idempotencyKey.ID) {
+				err = idempotencyKeys.Insert(&idempotencyKey)
+			}
+			if err == repository.ErrDuplicate {
+				return replayIdempotency(c, idempotencyKeys, idempotencyKey)
+			}
+			if err != nil {
+				return err
+			}
+
+			recorder := &idempotencyRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+			err = next(c)
+			status, contentType, responseBody := c.Response().Status, c.Response().Header().Get(echo.HeaderContentType), recorder.body.Bytes()
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				if !ok {
+					he = echo.NewHTTPError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				}
+				if he.Code >= http.StatusInternalServerError && c.Get(contextCommitted) != true {
+					//nothing was written and request may succeed when it is retried, so the key is given back
+					if deleteErr := idempotencyKeys.Delete(idempotencyKey.ID); deleteErr != nil && deleteErr != repository.ErrNotFound {
+						log.Println("idempotency:", deleteErr)
+					}
+					return err
+				}
+				//anything else got past the point where it wrote, retry must get the same response
+				status, contentType = he.Code, echo.MIMEApplicationJSONCharsetUTF8
+				responseBody, _ = json.Marshal(map[string]interface{}{"message": he.Message})
+			}
+			if completeErr := idempotencyKeys.Complete(idempotencyKey.ID, status, contentType, responseBody); completeErr != nil {
+				log.Println("idempotency:", completeErr)
+			}
+			return err
+		}
+	}
+}
+
+//replayIdempotency for send response of stored IdempotencyKey again
+func replayIdempotency(c echo.Context, idempotencyKeys repository.IdempotencyKeyRepository, idempotencyKey model.IdempotencyKey) error {
+	stored, err := idempotencyKeys.FindByID(idempotencyKey.ID)
+	if err == repository.ErrNotFound {
+		return echo.NewHTTPError(http.StatusConflict, "request with this Idempotency-Key is in progress")
+	}
+	if err != nil {
+		return err
+	}
+	if stored.RequestHash != idempotencyKey.RequestHash {
+		return echo.NewHTTPError(http.StatusConflict, "Idempotency-Key is already used by another request")
+	}
+	if !stored.Completed {
+		return echo.NewHTTPError(http.StatusConflict, "request with this Idempotency-Key is in progress")
+	}
+	return c.Blob(stored.Status, stored.ContentType, stored.Body)
+}
+
+//deleteExpiredIdempotency for remove IdempotencyKey that expired but was not cleaned up yet,
+//so its key can be used again
+func deleteExpiredIdempotency(idempotencyKeys repository.IdempotencyKeyRepository, id string) bool {
+	stored, err := idempotencyKeys.FindByID(id)
+	if err != nil || !stored.ExpiresAt.Before(time.Now()) {
+		return false
+	}
+	return idempotencyKeys.Delete(id) == nil
+}
+
+//hashIdempotency for sha256 of parts, parts are separated so they can not run into each other
+func hashIdempotency(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//CleanIdempotencyKeys for remove expired IdempotencyKey every interval, it never returns
+func CleanIdempotencyKeys(idempotencyKeys repository.IdempotencyKeyRepository, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := idempotencyKeys.DeleteExpired(time.Now()); err != nil {
+			log.Println("idempotency cleanup:", err)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bankaccountapi/internal"
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+//importUserColumns is columns of User every CSV of bulk import must have
+var importUserColumns = []string{"first_name", "last_name", "username", "password", "idcard", "age", "email", "tel"}
+
+//importBankAccountColumns is columns of BankAccount CSV of bulk import may have
+var importBankAccountColumns = []string{"bank_name", "account_number", "currency", "product", "balance", "overdraft_limit"}
+
+//ErrImportHeader is returned when header of CSV of bulk import is missing a column of User or has unknown column
+var ErrImportHeader = errors.New("CSV header must have columns " + strings.Join(importUserColumns, ",") +
+	" and may have columns " + strings.Join(importBankAccountColumns, ","))
+
+//ImportService is interface
+type ImportService interface {
+	ImportUsers(r io.Reader, dryRun bool, report func(row model.ImportRow) error) (model.ImportSummary, error)
+}
+
+//ImportServiceImplement is struct
+type ImportServiceImplement struct {
+	users              repository.UserRepository
+	bankAccounts       repository.BankAccountRepository
+	bankAccountService *BankAccountServiceImplement
+	//chunkSize is rows stored at once, a User is never split between chunks
+	chunkSize int
+}
+
+//importRow is one row of CSV with BankAccount of it, bankAccount is nil when row has no bank account
+type importRow struct {
+	model.ImportRow
+	bankAccount *model.BankAccount
+}
+
+//importUser is User of consecutive rows with the same username
+type importUser struct {
+	user     model.User
+	rows     []importRow
+	rejected bool
+}
+
+//importReader is CSV of bulk import read row by row, usernames and accountNumbers are every one seen in the file
+type importReader struct {
+	csv            *csv.Reader
+	columns        map[string]int
+	row            int
+	usernames      map[string]bool
+	accountNumbers map[string]bool
+}
+
+func newImportReader(r io.Reader) (*importReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrImportHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, column := range append(importUserColumns, importBankAccountColumns...) {
+		known[column] = true
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		if i == 0 {
+			//Excel writes UTF-8 CSV with byte order mark
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := columns[column]; ok || !known[column] {
+			return nil, ErrImportHeader
+		}
+		columns[column] = i
+	}
+	for _, column := range importUserColumns {
+		if _, ok := columns[column]; !ok {
+			return nil, ErrImportHeader
+		}
+	}
+	return &importReader{
+		csv:            reader,
+		columns:        columns,
+		usernames:      map[string]bool{},
+		accountNumbers: map[string]bool{},
+	}, nil
+}
+
+//read for next row of CSV with its fields by column, row with wrong number of fields is csv.ErrFieldCount without fields
+func (r *importReader) read() (map[string]string, error) {
+	record, err := r.csv.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	r.row++
+	if err != nil {
+		if parseErr, ok := err.(*csv.ParseError); ok && parseErr.Err == csv.ErrFieldCount {
+			return nil, csv.ErrFieldCount
+		}
+		return nil, err
+	}
+	fields := map[string]string{}
+	for column, i := range r.columns {
+		fields[column] = strings.TrimSpace(record[i])
+	}
+	return fields, nil
+}
+
+//parseUser for User of fields, it is checked with validateUser and username must not be taken
+func (s *ImportServiceImplement) parseUser(r *importReader, fields map[string]string) (model.User, error) {
+	user := model.User{
+		FirstName: fields["first_name"],
+		LastName:  fields["last_name"],
+		Username:  fields["username"],
+		Password:  fields["password"],
+		IDcard:    fields["idcard"],
+		Email:     fields["email"],
+		Tel:       fields["tel"],
+	}
+	if fields["age"] != "" {
+		age, err := strconv.ParseInt(fields["age"], 10, 64)
+		if err != nil || age <= 0 {
+			return user, errors.New("age must be whole number of years")
+		}
+		user.Age = age
+	}
+	if err := validateUser(user); err != nil {
+		return user, err
+	}
+	if r.usernames[user.Username] {
+		return user, errors.New("Username is already in this file, rows of one user must be next to each other")
+	}
+	r.usernames[user.Username] = true
+	_, err := s.users.FindByUsername(user.Username)
+	if err == nil {
+		return user, errors.New("Username is taken")
+	}
+	if err != repository.ErrNotFound {
+		return user, err
+	}
+	return user, nil
+}
+
+//parseBankAccount for BankAccount of fields checked like CreateBankAccount, it is nil when row has no bank account
+func (s *ImportServiceImplement) parseBankAccount(r *importReader, fields map[string]string) (*model.BankAccount, error) {
+	empty := true
+	for _, column := range importBankAccountColumns {
+		if fields[column] != "" {
+			empty = false
+		}
+	}
+	if empty {
+		return nil, nil
+	}
+	bankAccount := &model.BankAccount{
+		BankName:      fields["bank_name"],
+		AccountNumber: fields["account_number"],
+		Currency:      strings.ToUpper(fields["currency"]),
+		Product:       model.Product(fields["product"]),
+	}
+	var err error
+	if fields["balance"] != "" {
+		if bankAccount.Balance, err = model.ParseMoney(fields["balance"], bankAccount.Currency); err != nil {
+			return bankAccount, err
+		}
+	}
+	if fields["overdraft_limit"] != "" {
+		if bankAccount.OverdraftLimit, err = model.ParseMoney(fields["overdraft_limit"], bankAccount.Currency); err != nil {
+			return bankAccount, err
+		}
+	}
+	if err := s.bankAccountService.prepareBankAccount(bankAccount); err != nil {
+		return bankAccount, err
+	}
+	if r.accountNumbers[bankAccount.AccountNumber] {
+		return bankAccount, errors.New("AccountNumber is already in this file")
+	}
+	r.accountNumbers[bankAccount.AccountNumber] = true
+	_, err = s.bankAccounts.FindByAccountNumber(bankAccount.AccountNumber)
+	if err == nil {
+		return bankAccount, errors.New("AccountNumber Dupicate")
+	}
+	if err != repository.ErrNotFound {
+		return bankAccount, err
+	}
+	return bankAccount, nil
+}
+
+//ImportUsers for create User and BankAccount of every row of CSV in r, checked with the same rules as InsertUser and CreateBankAccount.
+//Each row has columns of User and may have columns of one BankAccount, consecutive rows with the same username are one User
+//with a BankAccount from each row and they are stored together or not at all. Rows are read and stored chunkSize at a time,
+//report is called for every row in order once its chunk is stored. Nothing is stored in dryRun.
+//When storing a chunk fails its rows are reported rejected and ImportUsers stops, every chunk before it stays stored
+func (s *ImportServiceImplement) ImportUsers(r io.Reader, dryRun bool, report func(row model.ImportRow) error) (model.ImportSummary, error) {
+	summary := model.ImportSummary{DryRun: dryRun}
+	reader, err := newImportReader(r)
+	if err != nil {
+		return summary, err
+	}
+
+	var chunk []*importUser
+	var current *importUser
+	rows := 0
+	for {
+		fields, err := reader.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != csv.ErrFieldCount {
+			return summary, err
+		}
+		row := importRow{ImportRow: model.ImportRow{Row: reader.row, Username: fields["username"], AccountNumber: fields["account_number"], Result: model.ImportValid}}
+		if current == nil || row.Username == "" || row.Username != current.user.Username {
+			if rows >= s.chunkSize {
+				if err := s.storeChunk(chunk, dryRun, &summary, report); err != nil {
+					return summary, err
+				}
+				chunk, rows = nil, 0
+			}
+			current = &importUser{}
+			chunk = append(chunk, current)
+			if err == nil {
+				current.user, err = s.parseUser(reader, fields)
+			}
+		}
+		if err == nil {
+			row.bankAccount, err = s.parseBankAccount(reader, fields)
+		}
+		if err == csv.ErrFieldCount {
+			err = fmt.Errorf("row must have %d fields like header", len(reader.columns))
+		}
+		if err != nil {
+			row.Result = model.ImportRejected
+			row.Error = err.Error()
+			current.rejected = true
+		}
+		current.rows = append(current.rows, row)
+		rows++
+	}
+	return summary, s.storeChunk(chunk, dryRun, &summary, report)
+}
+
+//storeChunk for store every User of chunk without rejected row at once and report each row of chunk
+func (s *ImportServiceImplement) storeChunk(chunk []*importUser, dryRun bool, summary *model.ImportSummary, report func(row model.ImportRow) error) error {
+	var users []model.User
+	var bankAccounts []model.BankAccount
+	for _, user := range chunk {
+		if user.rejected {
+			continue
+		}
+		user.user.ID = bson.NewObjectId()
+		users = append(users, user.user)
+		for _, row := range user.rows {
+			if row.bankAccount != nil {
+				row.bankAccount.ID = bson.NewObjectId()
+				row.bankAccount.UserID = user.user.ID
+				bankAccounts = append(bankAccounts, *row.bankAccount)
+			}
+		}
+	}
+
+	var err error
+	if !dryRun && len(users) > 0 {
+		if err = hashPasswords(users); err == nil {
+			err = s.users.InsertWithBankAccounts(users, bankAccounts)
+		}
+		if err == repository.ErrDuplicate {
+			err = errors.New("AccountNumber Dupicate")
+		}
+	}
+	if err == nil {
+		summary.Users += len(users)
+		summary.BankAccounts += len(bankAccounts)
+	}
+
+	for _, user := range chunk {
+		rejectedRow := 0
+		for _, row := range user.rows {
+			if row.Result == model.ImportRejected {
+				rejectedRow = row.Row
+				break
+			}
+		}
+		for _, row := range user.rows {
+			switch {
+			case row.Result == model.ImportRejected:
+			case rejectedRow != 0:
+				row.Result = model.ImportSkipped
+				row.Error = fmt.Sprintf("row %d of the same user was rejected", rejectedRow)
+			case err != nil:
+				row.Result = model.ImportRejected
+				row.Error = err.Error()
+			case !dryRun:
+				row.Result = model.ImportCreated
+			}
+			summary.Rows++
+			switch row.Result {
+			case model.ImportRejected:
+				summary.Rejected++
+			case model.ImportSkipped:
+				summary.Skipped++
+			}
+			if reportErr := report(row.ImportRow); reportErr != nil {
+				return reportErr
+			}
+		}
+	}
+	return err
+}
+
+//hashPasswords for hash Password of every user like InsertUser, bcrypt is slow so they are hashed on every CPU
+func hashPasswords(users []model.User) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var hashErr error
+	next := make(chan int)
+	for worker := 0; worker < runtime.NumCPU(); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				password, err := internal.HashPassword(users[i].Password)
+				if err != nil {
+					mu.Lock()
+					hashErr = err
+					mu.Unlock()
+					continue
+				}
+				users[i].Password = password
+			}
+		}()
+	}
+	for i := range users {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return hashErr
+}
+
+//ImportUsersEndPoint is ImportUsersEndPoint, body is CSV or multipart form with CSV in field file.
+//Only rows that were not stored are answered with the count of every row, dry_run=true checks rows without storing them.
+//Answer has error when import stopped on the way
+func (m *DataObjectAccess) ImportUsersEndPoint(c echo.Context) (err error) {
+	dryRun := c.QueryParam("dry_run") == "true"
+	body := c.Request().Body
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "please require file")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		body = file
+	}
+
+	errorRows := []model.ImportRow{}
+	summary, err := m.importService.ImportUsers(body, dryRun, func(row model.ImportRow) error {
+		if row.Result == model.ImportRejected || row.Result == model.ImportSkipped {
+			errorRows = append(errorRows, row)
+		}
+		return nil
+	})
+	PrintLog(summary)
+	if err == ErrImportHeader {
+		return MapHTTPError(err)
+	}
+	importResp := MapJSONImport(summary, errorRows)
+	if err != nil {
+		//rows already answered were stored, the rest of CSV was not read
+		importResp["error"] = err.Error()
+		if _, ok := err.(*csv.ParseError); ok {
+			return c.JSON(http.StatusBadRequest, importResp)
+		}
+		return c.JSON(http.StatusInternalServerError, importResp)
+	}
+	return c.JSON(http.StatusOK, importResp)
+}
+
+//MapJSONImport for MapJSONImport
+func MapJSONImport(summary model.ImportSummary, errorRows []model.ImportRow) map[string]interface{} {
+	dataJSON := map[string]interface{}{
+		"import": summary,
+		"errors": errorRows,
+	}
+	return dataJSON
+}
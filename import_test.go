@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+//chunkedUsers is UserRepository that keeps usernames of every InsertWithBankAccounts
+type chunkedUsers struct {
+	repository.UserRepository
+	chunks [][]string
+}
+
+func (c *chunkedUsers) InsertWithBankAccounts(users []model.User, bankAccounts []model.BankAccount) error {
+	var usernames []string
+	for _, user := range users {
+		usernames = append(usernames, user.Username)
+	}
+	c.chunks = append(c.chunks, usernames)
+	return c.UserRepository.InsertWithBankAccounts(users, bankAccounts)
+}
+
+const testImportCSV = `first_name,last_name,username,password,idcard,age,email,tel,bank_name,account_number,balance
+Alice,Example,alice,secret,1100000000001,30,alice@example.com,0811111111,KBank,111-1,100
+Alice,Example,alice,secret,1100000000001,30,alice@example.com,0811111111,KBank,111-2,50
+Bob,Example,bob,secret,1100000000002,31,bob@example.com,0822222222,KBank,222-2,10
+Carol,Example,carol,secret,1100000000003,32,carol@example.com,0833333333,KBank,333-3,10
+Carol,Example,carol,secret,1100000000003,32,carol@example.com,0833333333,KBank,333-4,ten
+Dave,Example,dave,secret,1100000000004,33,dave@example.com,0844444444,,,
+Erin,Example,erin
+`
+
+func importTestCSV(t *testing.T, d *DataObjectAccess, dryRun bool) (model.ImportSummary, []model.ImportRow) {
+	t.Helper()
+	var rows []model.ImportRow
+	summary, err := d.importService.ImportUsers(strings.NewReader(testImportCSV), dryRun, func(row model.ImportRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary, rows
+}
+
+func TestImportUsers(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			importService := d.importService.(*ImportServiceImplement)
+			users := &chunkedUsers{UserRepository: store.Users}
+			importService.users = users
+			importService.chunkSize = 2
+
+			//nothing is stored in dry run but every row is checked
+			summary, rows := importTestCSV(t, d, true)
+			want := []model.ImportResult{model.ImportValid, model.ImportValid, model.ImportValid, model.ImportSkipped, model.ImportRejected, model.ImportValid, model.ImportRejected}
+			for i, row := range rows {
+				if row.Row != i+1 || row.Result != want[i] {
+					t.Fatalf("dry run row %d of %s is %s %q, want row %d %s", row.Row, row.Username, row.Result, row.Error, i+1, want[i])
+				}
+			}
+			if len(users.chunks) != 0 || summary.Users != 3 || summary.BankAccounts != 3 {
+				t.Fatalf("dry run stored %v with summary %+v, want nothing stored of 3 users with 3 bank accounts", users.chunks, summary)
+			}
+			if _, err := store.Users.FindByUsername("alice"); err != repository.ErrNotFound {
+				t.Fatalf("alice after dry run got %v, want %v", err, repository.ErrNotFound)
+			}
+
+			summary, rows = importTestCSV(t, d, false)
+			want = []model.ImportResult{model.ImportCreated, model.ImportCreated, model.ImportCreated, model.ImportSkipped, model.ImportRejected, model.ImportCreated, model.ImportRejected}
+			for i, row := range rows {
+				if row.Result != want[i] {
+					t.Fatalf("row %d of %s is %s %q, want %s", row.Row, row.Username, row.Result, row.Error, want[i])
+				}
+			}
+			if summary != (model.ImportSummary{Rows: 7, Users: 3, BankAccounts: 3, Rejected: 2, Skipped: 1}) {
+				t.Fatalf("summary is %+v", summary)
+			}
+			//rejected row of carol drops her other row too
+			if _, err := store.Users.FindByUsername("carol"); err != repository.ErrNotFound {
+				t.Fatalf("carol got %v, want %v", err, repository.ErrNotFound)
+			}
+			if _, err := store.BankAccounts.FindByAccountNumber("333-3"); err != repository.ErrNotFound {
+				t.Fatalf("333-3 of carol got %v, want %v", err, repository.ErrNotFound)
+			}
+			//two rows of alice fill a chunk and are never split from each other
+			if chunks := fmt.Sprint(users.chunks); chunks != "[[alice] [bob] [dave]]" {
+				t.Fatalf("users are stored in chunks %s, want [[alice] [bob] [dave]]", chunks)
+			}
+			alice, err := store.Users.FindByUsername("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(alice.UserBankAccount) != 2 || balanceOf(t, store, "111-2") != "50.00" {
+				t.Fatalf("alice has %d bank accounts, want 111-1 and 111-2", len(alice.UserBankAccount))
+			}
+
+			//the same file again stores nothing, usernames and account numbers are taken
+			summary, _ = importTestCSV(t, d, false)
+			if summary.Users != 0 || summary.Rejected != 6 || summary.Skipped != 1 {
+				t.Fatalf("second import is %+v, want every user rejected", summary)
+			}
+		})
+	}
+}
+
+func TestImportUsersBadHeader(t *testing.T) {
+	d := newTestDAO(t, repository.NewMemoryStore())
+	for _, header := range []string{
+		"",
+		"first_name,last_name,username,password,idcard,age,email\n",
+		"first_name,last_name,username,password,idcard,age,email,tel,nickname\n",
+		"first_name,last_name,username,password,idcard,age,email,tel,username\n",
+	} {
+		_, err := d.importService.ImportUsers(strings.NewReader(header), false, func(row model.ImportRow) error {
+			t.Fatalf("row %d is reported for header %q", row.Row, header)
+			return nil
+		})
+		if err != ErrImportHeader {
+			t.Errorf("header %q got %v, want %v", header, err, ErrImportHeader)
+		}
+	}
+	//header of Excel with byte order mark and columns in any case is read
+	if _, err := d.importService.ImportUsers(strings.NewReader("\ufeffFirst_Name,LAST_NAME,username,password,idcard,age,email,tel\n"), true, func(model.ImportRow) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bankaccountapi/interest"
+	"bankaccountapi/internal"
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+const (
+	//BusinessDateFormat is format of BusinessDate of InterestAccrual
+	BusinessDateFormat = "2006-01-02"
+	//InterestMonthFormat is format of Month of InterestPosting
+	InterestMonthFormat = "2006-01"
+	//InterestAccrualInterval is how often RunInterest is run, it does nothing more after first run of a day
+	InterestAccrualInterval = time.Hour
+)
+
+//ErrProductUnknown is returned when Product of BankAccount is not in products
+var ErrProductUnknown = errors.New("product must be savings, current or fixed_deposit")
+
+//InterestService is interface
+type InterestService interface {
+	RunInterest(today time.Time) error
+	PreviewInterest(user model.User, id string) (*model.InterestPreview, error)
+}
+
+//InterestServiceImplement is struct
+type InterestServiceImplement struct {
+	bankAccounts repository.BankAccountRepository
+	interest     repository.InterestRepository
+	transactions repository.TransactionRepository
+	products     map[model.Product]model.InterestProduct
+}
+
+//LoadProducts for InterestProduct of every Product, products of config.toml replace DefaultProducts
+func LoadProducts(products map[string]internal.Product) (map[model.Product]model.InterestProduct, error) {
+	loaded := map[model.Product]model.InterestProduct{}
+	for product, interestProduct := range model.DefaultProducts {
+		loaded[product] = interestProduct
+	}
+	for name, product := range products {
+		if _, ok := loaded[model.Product(name)]; !ok {
+			return nil, fmt.Errorf("config.toml: unknown product %q", name)
+		}
+		interestProduct := model.InterestProduct{InterestRate: product.InterestRate, DayCount: model.DayCount(product.DayCount)}
+		if err := interest.Validate(interestProduct); err != nil {
+			return nil, fmt.Errorf("config.toml: product %s: %s", name, err)
+		}
+		loaded[model.Product(name)] = interestProduct
+	}
+	return loaded, nil
+}
+
+//RunInterest for accrue interest of every business date from the last one accrued until the one before today
+//and post every month before month of today. It is safe to run again for the same today,
+//a business date is accrued once and a month is posted once
+func (i *InterestServiceImplement) RunInterest(today time.Time) error {
+	err := i.recoverPostings()
+	if err != nil {
+		return err
+	}
+	bankAccounts, err := i.bankAccounts.FindAll()
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, bankAccount := range bankAccounts {
+		err = i.catchUp(bankAccount, today)
+		if err == nil {
+			err = i.post(bankAccount, today)
+		}
+		if err != nil {
+			//one BankAccount must not stop interest of the others
+			log.Printf("interest of %s: %s", bankAccount.AccountNumber, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+//catchUp for accrue every business date of bankAccount after the last one accrued until the one before today,
+//so days the service was down are not lost. BankAccount never accrued starts from the day it was created
+func (i *InterestServiceImplement) catchUp(bankAccount model.BankAccount, today time.Time) error {
+	date := BusinessDay(bankAccount.ID.Time())
+	last, err := i.interest.LastAccrualDate(bankAccount.ID)
+	if err == nil {
+		date, err = time.ParseInLocation(BusinessDateFormat, last, today.Location())
+		if err != nil {
+			return err
+		}
+		date = date.AddDate(0, 0, 1)
+	} else if err != repository.ErrNotFound {
+		return err
+	}
+	for ; date.Before(today); date = date.AddDate(0, 0, 1) {
+		balance, err := balanceAt(i.transactions, bankAccount, businessDayStart(date.AddDate(0, 0, 1)))
+		if err != nil {
+			return err
+		}
+		err = i.accrue(bankAccount, balance, date)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//accrue for write InterestAccrual of bankAccount for date from balance it had at end of date,
+//date that earns nothing is written too so it is not accrued again
+func (i *InterestServiceImplement) accrue(bankAccount model.BankAccount, balance model.Money, date time.Time) error {
+	product, ok := i.products[bankAccount.AccountProduct()]
+	if !ok {
+		return ErrProductUnknown
+	}
+	interestMicros, err := interest.Daily(balance, product, date)
+	if err != nil {
+		return err
+	}
+	err = i.interest.InsertAccrual(&model.InterestAccrual{
+		ID:             bson.NewObjectId(),
+		BankAccountID:  bankAccount.ID,
+		UserID:         bankAccount.UserID,
+		BusinessDate:   date.Format(BusinessDateFormat),
+		Product:        bankAccount.AccountProduct(),
+		InterestRate:   product.InterestRate,
+		DayCount:       product.DayCount,
+		Balance:        balance,
+		InterestMicros: interestMicros,
+		CreatedAt:      time.Now(),
+	})
+	if err == repository.ErrDuplicate {
+		return nil
+	}
+	return err
+}
+
+//unposted for InterestAccrual of bankAccount after its last InterestPosting
+func (i *InterestServiceImplement) unposted(bankAccount model.BankAccount) ([]model.InterestAccrual, error) {
+	from := ""
+	posting, err := i.interest.LastPosting(bankAccount.ID)
+	if err == nil {
+		month, err := time.Parse(InterestMonthFormat, posting.Month)
+		if err != nil {
+			return nil, err
+		}
+		from = month.AddDate(0, 1, 0).Format(BusinessDateFormat)
+	} else if err != repository.ErrNotFound {
+		return nil, err
+	}
+	return i.interest.FindAccruals(bankAccount.ID, from)
+}
+
+//post for add InterestAccrual of every month of bankAccount before month of today to its Balance
+func (i *InterestServiceImplement) post(bankAccount model.BankAccount, today time.Time) error {
+	accruals, err := i.unposted(bankAccount)
+	if err != nil {
+		return err
+	}
+	currentMonth := today.Format(InterestMonthFormat)
+	for len(accruals) > 0 {
+		month := accruals[0].BusinessDate[:len(InterestMonthFormat)]
+		if month >= currentMonth {
+			return nil
+		}
+		n := 1
+		for n < len(accruals) && accruals[n].BusinessDate[:len(InterestMonthFormat)] == month {
+			n++
+		}
+		amount, err := interest.Total(accruals[:n], bankAccount.AccountCurrency())
+		if err != nil {
+			return err
+		}
+		accruals = accruals[n:]
+
+		posting := model.InterestPosting{
+			ID:            bson.NewObjectId(),
+			BankAccountID: bankAccount.ID,
+			UserID:        bankAccount.UserID,
+			AccountNumber: bankAccount.AccountNumber,
+			Month:         month,
+			Amount:        amount,
+			State:         model.InterestPostingStatePending,
+			LastModified:  time.Now(),
+		}
+		if amount.IsZero() {
+			//less than half of minor unit in the month, nothing goes to ledger
+			posting.State = model.InterestPostingStateDone
+		}
+		err = i.interest.InsertPosting(&posting)
+		if err == repository.ErrDuplicate {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = i.applyPosting(&posting)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//applyPosting runs InterestPosting until it is done, each step is safe to repeat like apply of Tranfer
+func (i *InterestServiceImplement) applyPosting(posting *model.InterestPosting) error {
+	if posting.State == model.InterestPostingStatePending {
+		bankAccount, err := i.bankAccounts.ApplyTranfer(posting.ID, posting.AccountNumber, posting.Amount, nil)
+		if err == repository.ErrNotFound {
+			return fmt.Errorf("Not Have BankAccount %s", posting.AccountNumber)
+		}
+		if err != nil {
+			return err
+		}
+		err = i.transactions.InsertTranfer(&model.TransactionLog{
+			ID:            bson.NewObjectId(),
+			UserID:        bankAccount.UserID,
+			BankAccountID: bankAccount.ID,
+			AccountNumber: bankAccount.AccountNumber,
+			Type:          model.TransactionTypeInterest,
+			Amount:        posting.Amount,
+			BalanceAfter:  bankAccount.Balance,
+			TranferID:     posting.ID,
+			CreatedBy:     posting.UserID,
+			CreatedAt:     time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		err = i.setPostingState(posting, model.InterestPostingStatePending, model.InterestPostingStateApplied)
+		if err != nil {
+			return err
+		}
+	}
+	if posting.State == model.InterestPostingStateApplied {
+		err := i.bankAccounts.FinishTranfer(posting.ID, posting.AccountNumber)
+		if err != nil {
+			return err
+		}
+		return i.setPostingState(posting, model.InterestPostingStateApplied, model.InterestPostingStateDone)
+	}
+	return nil
+}
+
+func (i *InterestServiceImplement) setPostingState(posting *model.InterestPosting, state model.InterestPostingState, next model.InterestPostingState) error {
+	now := time.Now()
+	err := i.interest.SetPostingState(posting.ID, state, next, now)
+	if err != nil {
+		return err
+	}
+	posting.State = next
+	posting.LastModified = now
+	return nil
+}
+
+//recoverPostings for finish every InterestPosting left behind by a crash
+func (i *InterestServiceImplement) recoverPostings() error {
+	for _, state := range []model.InterestPostingState{model.InterestPostingStatePending, model.InterestPostingStateApplied} {
+		postings, err := i.interest.FindPostingsByState(state)
+		if err != nil {
+			return err
+		}
+		for j := range postings {
+			if err := i.applyPosting(&postings[j]); err != nil {
+				return fmt.Errorf("recover interest posting %s: %s", postings[j].ID.Hex(), err)
+			}
+		}
+	}
+	return nil
+}
+
+//PreviewInterest for interest BankAccount of user accrued but not posted yet
+func (i *InterestServiceImplement) PreviewInterest(user model.User, id string) (*model.InterestPreview, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
+	}
+	bankAccount, ok := findBankAccount(user, bson.ObjectIdHex(id))
+	if !ok {
+		return nil, ErrBankAccountNotFound
+	}
+	accruals, err := i.unposted(bankAccount)
+	if err != nil {
+		return nil, err
+	}
+	accrued, err := interest.Total(accruals, bankAccount.AccountCurrency())
+	if err != nil {
+		return nil, err
+	}
+	if accruals == nil {
+		accruals = []model.InterestAccrual{}
+	}
+	return &model.InterestPreview{
+		BankAccountID: bankAccount.ID,
+		Product:       bankAccount.AccountProduct(),
+		Accrued:       accrued,
+		Accruals:      accruals,
+	}, nil
+}
+
+//RunInterestAccrual for RunInterest every interval, it never returns
+func RunInterestAccrual(interestService InterestService, interval time.Duration) {
+	for {
+		if err := interestService.RunInterest(BusinessDay(time.Now())); err != nil {
+			log.Println("interest accrual:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+//PreviewInterestEndPoint is PreviewInterestEndPoint
+func (m *DataObjectAccess) PreviewInterestEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	preview, err := m.interestService.PreviewInterest(user, c.Param("idBankAccount"))
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(preview)
+	return c.JSON(http.StatusOK, MapJSONInterest(preview))
+}
+
+//MapJSONInterest for MapJSONInterest
+func MapJSONInterest(preview interface{}) interface{} {
+	dataJSON := map[string]interface{}{
+		"interest": preview,
+	}
+	return dataJSON
+}
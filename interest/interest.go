@@ -0,0 +1,100 @@
+package interest
+
+import (
+	"bankaccountapi/model"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+//MicrosPerMinorUnit is how many InterestMicros make one minor unit of Money
+const MicrosPerMinorUnit = 1000000
+
+var (
+	//ErrRateFormat is returned when InterestRate is not decimal percent
+	ErrRateFormat = errors.New("interest rate must be decimal percent like \"1.25\"")
+	//ErrDayCount is returned when DayCount is not supported
+	ErrDayCount = errors.New("day count must be ACT/365 or 30/360")
+)
+
+//ParseRate for parse yearly percent InterestRate into fraction, "1.25" is 1/80
+func ParseRate(rate string) (*big.Rat, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" || strings.Trim(rate, "0123456789.") != "" || strings.Count(rate, ".") > 1 {
+		return nil, ErrRateFormat
+	}
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok {
+		return nil, ErrRateFormat
+	}
+	return r.Quo(r, big.NewRat(100, 1)), nil
+}
+
+//Validate for check InterestRate and DayCount of product
+func Validate(product model.InterestProduct) error {
+	if _, err := ParseRate(product.InterestRate); err != nil {
+		return err
+	}
+	_, err := YearFraction(product.DayCount, time.Now())
+	return err
+}
+
+//YearFraction for how much of a year date earns under dayCount,
+//date earns interest from its start until start of the next day
+func YearFraction(dayCount model.DayCount, date time.Time) (*big.Rat, error) {
+	switch dayCount {
+	case model.DayCountACT365:
+		return big.NewRat(1, 365), nil
+	case model.DayCount30360:
+		return big.NewRat(days30360(date, date.AddDate(0, 0, 1)), 360), nil
+	}
+	return nil, ErrDayCount
+}
+
+//days30360 for days between from and to of 30/360 bond basis, D1 of 31 is 30 and D2 of 31 is 30 when D1 is 30,
+//so a month of 31 days earns 30 days and last day of February earns the days up to 30
+func days30360(from time.Time, to time.Time) int64 {
+	d1, d2 := from.Day(), to.Day()
+	if d1 == 31 {
+		d1 = 30
+	}
+	if d2 == 31 && d1 == 30 {
+		d2 = 30
+	}
+	return int64(360*(to.Year()-from.Year()) + 30*(int(to.Month())-int(from.Month())) + d2 - d1)
+}
+
+//Daily for interest balance earns in date under product in InterestMicros,
+//Balance that is not above zero earns nothing
+func Daily(balance model.Money, product model.InterestProduct, date time.Time) (int64, error) {
+	rate, err := ParseRate(product.InterestRate)
+	if err != nil {
+		return 0, err
+	}
+	fraction, err := YearFraction(product.DayCount, date)
+	if err != nil {
+		return 0, err
+	}
+	if balance.Amount <= 0 {
+		return 0, nil
+	}
+	value := new(big.Rat).SetInt64(balance.Amount)
+	value.Mul(value, rate)
+	value.Mul(value, fraction)
+	value.Mul(value, big.NewRat(MicrosPerMinorUnit, 1))
+	return model.RoundHalfEven(value)
+}
+
+//Total for sum of InterestMicros of accruals as Money of currency, rounded half to even once at the end
+func Total(accruals []model.InterestAccrual, currency string) (model.Money, error) {
+	sum := new(big.Int)
+	for _, accrual := range accruals {
+		sum.Add(sum, big.NewInt(accrual.InterestMicros))
+	}
+	amount, err := model.RoundHalfEven(new(big.Rat).SetFrac(sum, big.NewInt(MicrosPerMinorUnit)))
+	if err != nil {
+		return model.Money{}, err
+	}
+	return model.Money{Amount: amount, Currency: currency}, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+//businessDate for noon of year, month and day in business_time_zone
+func businessDate(year int, month time.Month, day int) time.Time {
+	return BusinessDay(time.Date(year, month, day, 12, 0, 0, 0, config.BusinessTimeZone.Location))
+}
+
+//createInterestBankAccount for BankAccount of product with balance, created at noon of created
+func createInterestBankAccount(t *testing.T, store *repository.Store, product model.Product, balance string, created time.Time) model.BankAccount {
+	t.Helper()
+	bankAccount := model.BankAccount{
+		ID:            bson.NewObjectIdWithTime(created.Add(12 * time.Hour)),
+		UserID:        bson.NewObjectId(),
+		BankName:      "KBank",
+		AccountNumber: "333-3",
+		Balance:       testMoney(t, balance),
+		Currency:      model.DefaultCurrency,
+		Product:       product,
+	}
+	if err := store.BankAccounts.Insert(&bankAccount); err != nil {
+		t.Fatal(err)
+	}
+	return bankAccount
+}
+
+//postedInterest for Amount of InterestPosting of month of bankAccount
+func postedInterest(t *testing.T, store *repository.Store, bankAccount model.BankAccount, month string) string {
+	t.Helper()
+	posting, err := store.Interest.LastPosting(bankAccount.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posting.Month != month {
+		t.Fatalf("last posting is of %s, want %s", posting.Month, month)
+	}
+	return posting.Amount.String()
+}
+
+func TestInterestCatchUpLeapYear(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		product model.InterestProduct
+		balance string
+		want    string
+	}{
+		//29 days of 10.00
+		{"ACT/365", model.InterestProduct{InterestRate: "1", DayCount: model.DayCountACT365}, "365000", "290.00"},
+		//February 29 earns the days up to March 1 as 30th, so February is 30 days of 12.00
+		{"30/360", model.InterestProduct{InterestRate: "1.2", DayCount: model.DayCount30360}, "360000", "360.00"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := repository.NewMemoryStore()
+			d := newTestDAO(t, store)
+			interestService := d.interestService.(*InterestServiceImplement)
+			interestService.products[model.ProductSavings] = tc.product
+			bankAccount := createInterestBankAccount(t, store, model.ProductSavings, tc.balance, businessDate(2024, time.February, 1))
+
+			//service was down the whole of February, one run catches up every day of it
+			if err := d.interestService.RunInterest(businessDate(2024, time.March, 1)); err != nil {
+				t.Fatal(err)
+			}
+			accruals, err := store.Interest.FindAccruals(bankAccount.ID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(accruals) != 29 || accruals[28].BusinessDate != "2024-02-29" {
+				t.Fatalf("%d accruals, want every day of February 2024 up to 2024-02-29", len(accruals))
+			}
+			if posted := postedInterest(t, store, bankAccount, "2024-02"); posted != tc.want {
+				t.Fatalf("posted %s, want %s", posted, tc.want)
+			}
+		})
+	}
+}
+
+func TestInterestCatchUpUsesBalanceOfEachDay(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	d.interestService.(*InterestServiceImplement).products[model.ProductSavings] = model.InterestProduct{InterestRate: "1", DayCount: model.DayCountACT365}
+	bankAccount := createInterestBankAccount(t, store, model.ProductSavings, "730000", businessDate(2024, time.February, 1))
+	//365000 of the 730000 arrived on February 15, days before it earn on 365000 only
+	err := store.Transactions.Insert(&model.TransactionLog{
+		ID:            bson.NewObjectId(),
+		UserID:        bankAccount.UserID,
+		BankAccountID: bankAccount.ID,
+		AccountNumber: bankAccount.AccountNumber,
+		Type:          model.TransactionTypeDeposit,
+		Amount:        testMoney(t, "365000"),
+		BalanceAfter:  testMoney(t, "730000"),
+		CreatedBy:     bankAccount.UserID,
+		CreatedAt:     businessDate(2024, time.February, 15).Add(12 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.interestService.RunInterest(businessDate(2024, time.March, 1)); err != nil {
+		t.Fatal(err)
+	}
+	//14 days of 10.00 and 15 days of 20.00
+	if posted := postedInterest(t, store, bankAccount, "2024-02"); posted != "440.00" {
+		t.Fatalf("posted %s, want 440.00", posted)
+	}
+}
+
+func TestInterestRateChangeMidMonth(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	interestService := d.interestService.(*InterestServiceImplement)
+	interestService.products[model.ProductSavings] = model.InterestProduct{InterestRate: "1", DayCount: model.DayCountACT365}
+	bankAccount := createInterestBankAccount(t, store, model.ProductSavings, "365000", businessDate(2023, time.March, 1))
+
+	//March 1 to March 15 accrue at 1%
+	if err := d.interestService.RunInterest(businessDate(2023, time.March, 16)); err != nil {
+		t.Fatal(err)
+	}
+	interestService.products[model.ProductSavings] = model.InterestProduct{InterestRate: "2", DayCount: model.DayCountACT365}
+	//rerun of the same day must not accrue anything again at the new rate
+	if err := d.interestService.RunInterest(businessDate(2023, time.March, 16)); err != nil {
+		t.Fatal(err)
+	}
+	//March 16 to March 31 accrue at 2%
+	if err := d.interestService.RunInterest(businessDate(2023, time.April, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	accruals, err := store.Interest.FindAccruals(bankAccount.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, accrual := range accruals {
+		want := "1"
+		if accrual.BusinessDate >= "2023-03-16" {
+			want = "2"
+		}
+		if accrual.InterestRate != want {
+			t.Fatalf("%s accrued at %s%%, want %s%%", accrual.BusinessDate, accrual.InterestRate, want)
+		}
+	}
+	//15 days of 10.00 and 16 days of 20.00
+	if posted := postedInterest(t, store, bankAccount, "2023-03"); posted != "470.00" {
+		t.Fatalf("posted %s, want 470.00", posted)
+	}
+}
+
+func TestPreviewInterestEndPointNotFound(t *testing.T) {
+	d := newTestDAO(t, repository.NewMemoryStore())
+	alice := createTestUser(t, d, "alice")
+	e := echo.New()
+	for _, id := range []string{"111-1", bson.NewObjectId().Hex()} {
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+		c.SetParamNames("id", "idBankAccount")
+		c.SetParamValues(alice.ID.Hex(), id)
+		err := d.PreviewInterestEndPoint(c)
+		if he, ok := err.(*echo.HTTPError); !ok || he.Code != http.StatusNotFound {
+			t.Errorf("preview of bank account %s got %v, want %d", id, err, http.StatusNotFound)
+		}
+	}
+}
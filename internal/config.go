@@ -1,21 +1,161 @@
 package internal
 
 import (
+	"bankaccountapi/model"
+	"errors"
 	"log"
+	"os"
+	"time"
+	//business_time_zone must load without zoneinfo of the system
+	_ "time/tzdata"
 
 	"github.com/BurntSushi/toml"
 )
 
+const (
+	//BackendMongo is Backend for MongoDB with Server and Database
+	BackendMongo = "mongo"
+	//BackendPostgres is Backend for PostgreSQL with DSN
+	BackendPostgres = "postgres"
+	//BackendSQLite is Backend for SQLite with DSN as file name
+	BackendSQLite = "sqlite"
+)
+
+const (
+	//EnvJWTSecret is environment variable jwt_secret is read from, it is used instead of config.toml when it is set
+	EnvJWTSecret = "JWT_SECRET"
+	//placeholderJWTSecret is jwt_secret config.toml used to ship with, server refuses to sign with it
+	placeholderJWTSecret = "change-me-in-production"
+)
+
+//ErrJWTSecret is returned when jwt_secret is not set or is still the placeholder
+var ErrJWTSecret = errors.New("jwt_secret is not set, set environment variable " + EnvJWTSecret)
+
 //Config to use for Setup Server and Database
 type Config struct {
-	Server   string
-	Database string
+	Server                     string
+	Database                   string
+	Backend                    string             `toml:"backend"`
+	DSN                        string             `toml:"dsn"`
+	JWTSecret                  string             `toml:"jwt_secret"`
+	AccessTokenTTL             Duration           `toml:"access_token_ttl"`
+	RefreshTokenTTL            Duration           `toml:"refresh_token_ttl"`
+	IdempotencyKeyTTL          Duration           `toml:"idempotency_key_ttl"`
+	RatesFile                  string             `toml:"rates_file"`
+	FeeIncomeAccount           string             `toml:"fee_income_account"`
+	Admins                     []string           `toml:"admins"`
+	Products                   map[string]Product `toml:"products"`
+	BusinessTimeZone           Location           `toml:"business_time_zone"`
+	BusinessDayStart           ClockTime          `toml:"business_day_start"`
+	Limits                     Limits             `toml:"limits"`
+	StandingOrderRetries       int                `toml:"standing_order_retries"`
+	StandingOrderRetryInterval Duration           `toml:"standing_order_retry_interval"`
+	ImportChunkSize            int                `toml:"import_chunk_size"`
+	ApprovalTranferThreshold   string             `toml:"approval_tranfer_threshold"`
+	ApprovalWindow             Duration           `toml:"approval_window"`
+}
+
+//Limits is limits of the bank in config.toml, customer can only lower them
+type Limits struct {
+	BankAccount model.Limits `toml:"bank_account"`
+	User        model.Limits `toml:"user"`
+}
+
+//Product is interest and fees of one product of bank account in config.toml
+type Product struct {
+	InterestRate string            `toml:"interest_rate"`
+	DayCount     string            `toml:"day_count"`
+	WithdrawFee  model.FeeSchedule `toml:"withdraw_fee"`
+	TranferFee   model.FeeSchedule `toml:"tranfer_fee"`
+}
+
+//Duration is time.Duration read from string like "15m" in config.toml
+type Duration struct {
+	time.Duration
+}
+
+//UnmarshalText is parse Duration for toml
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+//Location is time.Location read from IANA name like "Asia/Bangkok" in config.toml
+type Location struct {
+	*time.Location
+}
+
+//UnmarshalText is parse Location for toml
+func (l *Location) UnmarshalText(text []byte) error {
+	var err error
+	l.Location, err = time.LoadLocation(string(text))
+	return err
+}
+
+//ClockTime is time of day read from string like "23:30" in config.toml, it is kept as time.Duration since midnight
+type ClockTime struct {
+	time.Duration
+}
+
+//UnmarshalText is parse ClockTime for toml
+func (c *ClockTime) UnmarshalText(text []byte) error {
+	clock, err := time.Parse("15:04", string(text))
+	if err != nil {
+		return err
+	}
+	c.Duration = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+	return nil
 }
 
 //Read is Readfile in config.toml It's have to set server and database
 func (c *Config) Read() {
-	_, err := toml.DecodeFile("internal/config.toml", &c)
+	md, err := toml.DecodeFile("internal/config.toml", &c)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if secret := os.Getenv(EnvJWTSecret); secret != "" {
+		c.JWTSecret = secret
+	}
+	if c.Backend == "" {
+		c.Backend = BackendMongo
+	}
+	if c.Backend != BackendMongo && c.DSN == "" {
+		log.Fatal("config.toml: dsn is required for backend ", c.Backend)
+	}
+	if c.AccessTokenTTL.Duration == 0 {
+		c.AccessTokenTTL.Duration = 15 * time.Minute
+	}
+	if c.RefreshTokenTTL.Duration == 0 {
+		c.RefreshTokenTTL.Duration = 7 * 24 * time.Hour
+	}
+	if c.IdempotencyKeyTTL.Duration == 0 {
+		c.IdempotencyKeyTTL.Duration = 24 * time.Hour
+	}
+	if c.RatesFile == "" {
+		c.RatesFile = "internal/rates.json"
+	}
+	if !md.IsDefined("standing_order_retries") {
+		c.StandingOrderRetries = 3
+	}
+	if c.StandingOrderRetryInterval.Duration == 0 {
+		c.StandingOrderRetryInterval.Duration = time.Hour
+	}
+	if c.ImportChunkSize <= 0 {
+		c.ImportChunkSize = 1000
+	}
+	if c.ApprovalWindow.Duration == 0 {
+		c.ApprovalWindow.Duration = 24 * time.Hour
+	}
+	if c.BusinessTimeZone.Location == nil {
+		c.BusinessTimeZone.Location = time.Local
+	}
+}
+
+//ValidateJWTSecret for check jwt_secret is set to something other than the placeholder, server must not start without it
+func (c *Config) ValidateJWTSecret() error {
+	if c.JWTSecret == "" || c.JWTSecret == placeholderJWTSecret {
+		return ErrJWTSecret
+	}
+	return nil
 }
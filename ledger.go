@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+const (
+	//TransactionPageLimit is default page size of TransactionLog
+	TransactionPageLimit = 20
+	//TransactionPageLimitMax is max page size of TransactionLog
+	TransactionPageLimitMax = 100
+)
+
+//insertTransactionLog append TransactionLog to ledger, ledger is never updated
+func (b *BankAccountServiceImplement) insertTransactionLog(transactionLog model.TransactionLog) error {
+	transactionLog.ID = bson.NewObjectId()
+	transactionLog.CreatedAt = time.Now()
+	return b.transactions.Insert(&transactionLog)
+}
+
+//findBankAccountByNumber for find BankAccount of user by AccountNumber
+func findBankAccountByNumber(user model.User, accountNumber string) (model.BankAccount, bool) {
+	for _, bankAccount := range user.UserBankAccount {
+		if bankAccount.AccountNumber == accountNumber {
+			return bankAccount, true
+		}
+	}
+	return model.BankAccount{}, false
+}
+
+//findBankAccount for find BankAccount of user by ID
+func findBankAccount(user model.User, id bson.ObjectId) (model.BankAccount, bool) {
+	for _, bankAccount := range user.UserBankAccount {
+		if bankAccount.ID == id {
+			return bankAccount, true
+		}
+	}
+	return model.BankAccount{}, false
+}
+
+//FindAllTransaction for FindAllTransaction
+func (b *BankAccountServiceImplement) FindAllTransaction(user model.User, id string, filter model.TransactionFilter) ([]model.TransactionLog, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
+	}
+	if _, ok := findBankAccount(user, bson.ObjectIdHex(id)); !ok {
+		return nil, ErrBankAccountNotFound
+	}
+
+	return b.transactions.FindByBankAccount(bson.ObjectIdHex(id), filter)
+}
+
+//FindAllTransactionEndPoint is FindAllTransactionEndPoint
+func (m *DataObjectAccess) FindAllTransactionEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	filter, err := ParseTransactionFilter(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	transactionResp, err := m.bankAccountService.FindAllTransaction(user, c.Param("idBankAccount"), filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	PrintLog(transactionResp)
+	return c.JSON(http.StatusOK, MapJSONTransaction(transactionResp, filter))
+}
+
+//ParseTransactionFilter for read from, to, page and limit in query string
+//from and to accept RFC3339 or date, to as date is include whole day
+func ParseTransactionFilter(c echo.Context) (model.TransactionFilter, error) {
+	var err error
+	filter := model.TransactionFilter{
+		Page:  1,
+		Limit: TransactionPageLimit,
+	}
+	if from := c.QueryParam("from"); from != "" {
+		filter.From, err = parseTransactionTime(from, false)
+		if err != nil {
+			return filter, errors.New("from must be RFC3339 or YYYY-MM-DD")
+		}
+	}
+	if to := c.QueryParam("to"); to != "" {
+		filter.To, err = parseTransactionTime(to, true)
+		if err != nil {
+			return filter, errors.New("to must be RFC3339 or YYYY-MM-DD")
+		}
+	}
+	if page := c.QueryParam("page"); page != "" {
+		filter.Page, err = strconv.Atoi(page)
+		if err != nil || filter.Page < 1 {
+			return filter, errors.New("page must be positive number")
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > TransactionPageLimitMax {
+			return filter, errors.New("limit must be between 1 and " + strconv.Itoa(TransactionPageLimitMax))
+		}
+	}
+	return filter, nil
+}
+
+func parseTransactionTime(value string, endOfDay bool) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+//MapJSONTransaction for MapJSONTransaction
+func MapJSONTransaction(transaction interface{}, filter model.TransactionFilter) interface{} {
+	dataJSON := map[string]interface{}{
+		"transactions": transaction,
+		"page":         filter.Page,
+		"limit":        filter.Limit,
+	}
+	return dataJSON
+}
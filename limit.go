@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bankaccountapi/fx"
+	"bankaccountapi/internal"
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+var (
+	//ErrWithdrawLimit is returned when amount of withdraw is above max_withdraw
+	ErrWithdrawLimit = errors.New("amount is above max_withdraw")
+	//ErrTranferDailyLimit is returned when Tranfer would take total of the business date above max_tranfer_daily
+	ErrTranferDailyLimit = errors.New("tranfer total of today would be above max_tranfer_daily")
+	//ErrTranferCountLimit is returned when Tranfer would take count of the business date above max_tranfer_count
+	ErrTranferCountLimit = errors.New("tranfer count of today would be above max_tranfer_count")
+	//ErrLimitRaised is returned when customer sets limit above limit of the bank
+	ErrLimitRaised = errors.New("limit can only be lowered below limit of the bank")
+	//ErrLimitNegative is returned when limit is less than zero
+	ErrLimitNegative = errors.New("limit must not be negative")
+)
+
+//LimitService is interface
+type LimitService interface {
+	FindLimits(user model.User) (*model.UserLimits, error)
+	SetUserLimits(user model.User, limits model.Limits) (*model.UserLimits, error)
+	SetBankAccountLimits(user model.User, id string, limits model.Limits) (*model.UserLimits, error)
+}
+
+//LimitServiceImplement is struct
+type LimitServiceImplement struct {
+	limits repository.LimitRepository
+	rates  fx.RateProvider
+	//bank is limits of the bank, customer can only lower them
+	bank internal.Limits
+}
+
+//ValidateLimits for check limits of the bank in config.toml
+func ValidateLimits(limits internal.Limits) error {
+	for name, bank := range map[string]model.Limits{"bank_account": limits.BankAccount, "user": limits.User} {
+		if _, err := lowered(model.Limits{}, bank, model.DefaultCurrency); err != nil {
+			return fmt.Errorf("config.toml: limits.%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+//limitAmount for limit in currency, ok is false when there is no limit
+func limitAmount(limit string, currency string) (amount model.Money, ok bool, err error) {
+	if limit == "" {
+		return model.Money{}, false, nil
+	}
+	amount, err = model.ParseMoney(limit, currency)
+	if err != nil {
+		return model.Money{}, false, err
+	}
+	if amount.IsNegative() {
+		return model.Money{}, false, ErrLimitNegative
+	}
+	return amount, true, nil
+}
+
+//lowerAmount for lower one of two limits in currency, "" is no limit
+func lowerAmount(limit string, other string, currency string) (string, error) {
+	amount, ok, err := limitAmount(limit, currency)
+	if err != nil {
+		return "", err
+	}
+	otherAmount, otherOK, err := limitAmount(other, currency)
+	if err != nil {
+		return "", err
+	}
+	if otherOK && (!ok || otherAmount.Amount < amount.Amount) {
+		amount, ok = otherAmount, true
+	}
+	if !ok {
+		return "", nil
+	}
+	return amount.String(), nil
+}
+
+//lowest for the lower of limits of the bank and limits owner set for itself, in currency
+func lowest(bank model.Limits, own model.Limits, currency string) (model.Limits, error) {
+	var limits model.Limits
+	var err error
+	limits.MaxWithdraw, err = lowerAmount(bank.MaxWithdraw, own.MaxWithdraw, currency)
+	if err != nil {
+		return model.Limits{}, err
+	}
+	limits.MaxTranferDaily, err = lowerAmount(bank.MaxTranferDaily, own.MaxTranferDaily, currency)
+	if err != nil {
+		return model.Limits{}, err
+	}
+	limits.MaxTranferCount = bank.MaxTranferCount
+	if own.MaxTranferCount != nil && (bank.MaxTranferCount == nil || *own.MaxTranferCount < *bank.MaxTranferCount) {
+		limits.MaxTranferCount = own.MaxTranferCount
+	}
+	return limits, nil
+}
+
+//lowered for own written in currency, ErrLimitRaised when some of it is above bank
+func lowered(bank model.Limits, own model.Limits, currency string) (model.Limits, error) {
+	var limits model.Limits
+	var err error
+	limits.MaxWithdraw, err = lowerOnly(bank.MaxWithdraw, own.MaxWithdraw, currency)
+	if err != nil {
+		return model.Limits{}, err
+	}
+	limits.MaxTranferDaily, err = lowerOnly(bank.MaxTranferDaily, own.MaxTranferDaily, currency)
+	if err != nil {
+		return model.Limits{}, err
+	}
+	if own.MaxTranferCount != nil {
+		if *own.MaxTranferCount < 0 {
+			return model.Limits{}, ErrLimitNegative
+		}
+		if bank.MaxTranferCount != nil && *own.MaxTranferCount > *bank.MaxTranferCount {
+			return model.Limits{}, ErrLimitRaised
+		}
+		limits.MaxTranferCount = own.MaxTranferCount
+	}
+	return limits, nil
+}
+
+//lowerOnly for own limit written in currency, ErrLimitRaised when it is above limit of the bank
+func lowerOnly(bank string, own string, currency string) (string, error) {
+	amount, ok, err := limitAmount(own, currency)
+	if err != nil || !ok {
+		return "", err
+	}
+	above, err := aboveMax(bank, amount)
+	if err != nil {
+		return "", err
+	}
+	if above {
+		return "", ErrLimitRaised
+	}
+	return amount.String(), nil
+}
+
+//own for Limits ownerID set for itself, they are empty when it has not set any
+func (l *LimitServiceImplement) own(ownerID bson.ObjectId) (model.Limits, error) {
+	limits, err := l.limits.FindLimits(ownerID)
+	if err == repository.ErrNotFound {
+		return model.Limits{}, nil
+	}
+	return limits, err
+}
+
+//bankAccountLimits for Limits bankAccount is held to, in its Currency
+func (l *LimitServiceImplement) bankAccountLimits(bankAccount model.BankAccount) (model.Limits, error) {
+	own, err := l.own(bankAccount.ID)
+	if err != nil {
+		return model.Limits{}, err
+	}
+	return lowest(l.bank.BankAccount, own, bankAccount.AccountCurrency())
+}
+
+//userLimits for Limits user is held to, in DefaultCurrency
+func (l *LimitServiceImplement) userLimits(userID bson.ObjectId) (model.Limits, error) {
+	own, err := l.own(userID)
+	if err != nil {
+		return model.Limits{}, err
+	}
+	return lowest(l.bank.User, own, model.DefaultCurrency)
+}
+
+//inUserCurrency for amount in DefaultCurrency, limits of User are in it
+func (l *LimitServiceImplement) inUserCurrency(amount model.Money) (model.Money, error) {
+	exchangeRate, err := l.rates.Rate(amount.Currency, model.DefaultCurrency)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return fx.Convert(amount, exchangeRate)
+}
+
+//aboveMax for check amount is above limit, there is nothing above no limit
+func aboveMax(limit string, amount model.Money) (bool, error) {
+	max, ok, err := limitAmount(limit, amount.Currency)
+	if err != nil || !ok {
+		return false, err
+	}
+	return amount.Amount > max.Amount, nil
+}
+
+//checkWithdraw for check amount in Currency of bankAccount is within max_withdraw of bankAccount and user
+func (l *LimitServiceImplement) checkWithdraw(user model.User, bankAccount model.BankAccount, amount model.Money) error {
+	limits, err := l.bankAccountLimits(bankAccount)
+	if err != nil {
+		return err
+	}
+	above, err := aboveMax(limits.MaxWithdraw, amount)
+	if err != nil {
+		return err
+	}
+	if above {
+		return ErrWithdrawLimit
+	}
+	limits, err = l.userLimits(user.ID)
+	if err != nil || limits.MaxWithdraw == "" {
+		return err
+	}
+	amount, err = l.inUserCurrency(amount)
+	if err != nil {
+		return err
+	}
+	above, err = aboveMax(limits.MaxWithdraw, amount)
+	if above {
+		return ErrWithdrawLimit
+	}
+	return err
+}
+
+//reserve for add amount and one Tranfer to usage of ownerID in businessDate,
+//it is taken back again when usage goes above limits
+func (l *LimitServiceImplement) reserve(ownerID bson.ObjectId, businessDate string, limits model.Limits, amount model.Money) error {
+	usage, err := l.limits.AddUsage(ownerID, businessDate, amount.Amount, 1)
+	if err != nil {
+		return err
+	}
+	above, err := aboveMax(limits.MaxTranferDaily, model.Money{Amount: usage.TranferTotal, Currency: amount.Currency})
+	if err == nil && above {
+		err = ErrTranferDailyLimit
+	}
+	if err == nil && limits.MaxTranferCount != nil && usage.TranferCount > *limits.MaxTranferCount {
+		err = ErrTranferCountLimit
+	}
+	if err != nil {
+		l.release(ownerID, businessDate, amount)
+	}
+	return err
+}
+
+//release for take amount and one Tranfer back from usage of ownerID in businessDate
+func (l *LimitServiceImplement) release(ownerID bson.ObjectId, businessDate string, amount model.Money) {
+	if _, err := l.limits.AddUsage(ownerID, businessDate, -amount.Amount, -1); err != nil {
+		log.Printf("release limit usage of %s: %s", ownerID.Hex(), err)
+	}
+}
+
+//reserveTranfer for count Tranfer of amount from bankAccount of user against daily limits of both,
+//release returned must be called on every path the Tranfer fails in before any money can have moved
+func (l *LimitServiceImplement) reserveTranfer(user model.User, bankAccount model.BankAccount, amount model.Money) (func(), error) {
+	businessDate := BusinessDay(time.Now()).Format(BusinessDateFormat)
+	bankAccountLimits, err := l.bankAccountLimits(bankAccount)
+	if err != nil {
+		return nil, err
+	}
+	userLimits, err := l.userLimits(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	userAmount, err := l.inUserCurrency(amount)
+	if err != nil {
+		return nil, err
+	}
+	err = l.reserve(bankAccount.ID, businessDate, bankAccountLimits, amount)
+	if err != nil {
+		return nil, err
+	}
+	err = l.reserve(user.ID, businessDate, userLimits, userAmount)
+	if err != nil {
+		l.release(bankAccount.ID, businessDate, amount)
+		return nil, err
+	}
+	return func() {
+		l.release(bankAccount.ID, businessDate, amount)
+		l.release(user.ID, businessDate, userAmount)
+	}, nil
+}
+
+//status for LimitStatus of ownerID held to limits in currency
+func (l *LimitServiceImplement) status(ownerID bson.ObjectId, businessDate string, limits model.Limits, currency string) (model.LimitStatus, error) {
+	usage, err := l.limits.FindUsage(ownerID, businessDate)
+	if err != nil {
+		return model.LimitStatus{}, err
+	}
+	status := model.LimitStatus{
+		Limits:       limits,
+		TranferTotal: model.Money{Amount: usage.TranferTotal, Currency: currency},
+		TranferCount: usage.TranferCount,
+		Remaining:    model.Limits{MaxWithdraw: limits.MaxWithdraw},
+	}
+	if max, ok, err := limitAmount(limits.MaxTranferDaily, currency); err != nil {
+		return model.LimitStatus{}, err
+	} else if ok {
+		remaining, err := max.Sub(status.TranferTotal)
+		if err != nil {
+			return model.LimitStatus{}, err
+		}
+		if remaining.IsNegative() {
+			remaining.Amount = 0
+		}
+		status.Remaining.MaxTranferDaily = remaining.String()
+	}
+	if limits.MaxTranferCount != nil {
+		remaining := *limits.MaxTranferCount - usage.TranferCount
+		if remaining < 0 {
+			remaining = 0
+		}
+		status.Remaining.MaxTranferCount = &remaining
+	}
+	return status, nil
+}
+
+//FindLimits for Limits of user and every BankAccount of user with what is left of them today
+func (l *LimitServiceImplement) FindLimits(user model.User) (*model.UserLimits, error) {
+	businessDate := BusinessDay(time.Now()).Format(BusinessDateFormat)
+	limits, err := l.userLimits(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	userStatus, err := l.status(user.ID, businessDate, limits, model.DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	userLimits := &model.UserLimits{
+		BusinessDate: businessDate,
+		User:         userStatus,
+		BankAccounts: []model.LimitStatus{},
+	}
+	for _, bankAccount := range user.UserBankAccount {
+		limits, err := l.bankAccountLimits(bankAccount)
+		if err != nil {
+			return nil, err
+		}
+		status, err := l.status(bankAccount.ID, businessDate, limits, bankAccount.AccountCurrency())
+		if err != nil {
+			return nil, err
+		}
+		status.BankAccountID = bankAccount.ID
+		status.AccountNumber = bankAccount.AccountNumber
+		userLimits.BankAccounts = append(userLimits.BankAccounts, status)
+	}
+	return userLimits, nil
+}
+
+//SetUserLimits for lower Limits of user, empty field goes back to limit of the bank
+func (l *LimitServiceImplement) SetUserLimits(user model.User, limits model.Limits) (*model.UserLimits, error) {
+	own, err := lowered(l.bank.User, limits, model.DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	err = l.limits.SetLimits(user.ID, own)
+	if err != nil {
+		return nil, err
+	}
+	return l.FindLimits(user)
+}
+
+//SetBankAccountLimits for lower Limits of BankAccount id of user, empty field goes back to limit of the bank
+func (l *LimitServiceImplement) SetBankAccountLimits(user model.User, id string, limits model.Limits) (*model.UserLimits, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
+	}
+	bankAccount, ok := findBankAccount(user, bson.ObjectIdHex(id))
+	if !ok {
+		return nil, ErrBankAccountNotFound
+	}
+	own, err := lowered(l.bank.BankAccount, limits, bankAccount.AccountCurrency())
+	if err != nil {
+		return nil, err
+	}
+	err = l.limits.SetLimits(bankAccount.ID, own)
+	if err != nil {
+		return nil, err
+	}
+	return l.FindLimits(user)
+}
+
+//FindLimitsEndPoint is FindLimitsEndPoint
+func (m *DataObjectAccess) FindLimitsEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	userLimits, err := m.limitService.FindLimits(user)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(userLimits)
+	return c.JSON(http.StatusOK, MapJSONLimits(userLimits))
+}
+
+//SetUserLimitsEndPoint is SetUserLimitsEndPoint
+func (m *DataObjectAccess) SetUserLimitsEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	var limits model.Limits
+	if err := c.Bind(&limits); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	userLimits, err := m.limitService.SetUserLimits(user, limits)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(userLimits)
+	return c.JSON(http.StatusOK, MapJSONLimits(userLimits))
+}
+
+//SetBankAccountLimitsEndPoint is SetBankAccountLimitsEndPoint
+func (m *DataObjectAccess) SetBankAccountLimitsEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	var limits model.Limits
+	if err := c.Bind(&limits); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	userLimits, err := m.limitService.SetBankAccountLimits(user, c.Param("idBankAccount"), limits)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(userLimits)
+	return c.JSON(http.StatusOK, MapJSONLimits(userLimits))
+}
+
+//MapJSONLimits for MapJSONLimits
+func MapJSONLimits(userLimits interface{}) interface{} {
+	dataJSON := map[string]interface{}{
+		"limits": userLimits,
+	}
+	return dataJSON
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	_ "bankaccountapi/docs"
+	"bankaccountapi/fx"
 	"bankaccountapi/internal"
 	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"bankaccountapi/schedule"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	mgo "github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
@@ -20,9 +24,19 @@ import (
 
 //DataObjectAccess is dao
 type DataObjectAccess struct {
-	userService        UserService
-	bankAccountService BankAccountService
-	tranferService     TranferService
+	userService          UserService
+	bankAccountService   BankAccountService
+	tranferService       TranferService
+	authService          AuthService
+	importService        ImportService
+	searchService        SearchService
+	interestService      InterestService
+	feeService           FeeService
+	limitService         LimitService
+	standingOrderService StandingOrderService
+	bulkPaymentService   BulkPaymentService
+	approvalService      ApprovalService
+	rates                fx.RateTable
 }
 
 //Server for set Server and Database
@@ -33,138 +47,250 @@ type Server struct {
 
 //UserService is interface
 type UserService interface {
-	FindAllUser() ([]model.User, error)
+	FindAllUser(filter model.UserFilter) ([]model.User, string, error)
 	FindByIDUser(id string) (model.User, error)
+	FindByUsername(username string) (model.User, error)
 	InsertUser(UserCreate *model.User) (*model.User, error)
-	UpdateUser(UserUpdate *model.User, user model.User) (*model.User, error)
+	UpdateUser(UserUpdate *model.User, user model.User, version int64) (*model.User, error)
 	DeleteUser(user model.User) (*model.User, error)
+	SetRoles(user model.User, roles []model.Role, version int64) (*model.User, error)
+	CheckPassword(user model.User, password string) (bool, error)
 }
 
 //BankAccountService is interface
 type BankAccountService interface {
-	CreateBankAccount(bankaccountReq *model.BankAccount, user model.User, users []model.User) ([]model.BankAccount, error)
+	CreateBankAccount(bankaccountReq *model.BankAccount, user model.User) (*model.BankAccount, error)
 	FindAllBankAccount(user model.User) []model.BankAccount
+	FindBankAccount(user model.User, id string) (*model.BankAccount, error)
 	DeleteBankAccount(user model.User, id string) (*model.BankAccount, error)
-	DepositBankAccount(tranSaction *model.Transaction, user model.User, id string) (*model.BankAccount, error)
-	WithdrawBankAccount(tranSaction *model.Transaction, user model.User, id string) (*model.BankAccount, error)
+	DepositBankAccount(tranSaction *model.Transaction, user model.User, id string, version int64) (*model.BankAccount, error)
+	WithdrawBankAccount(tranSaction *model.Transaction, user model.User, id string, version int64) (*model.BankAccount, error)
+	FindAllTransaction(user model.User, id string, filter model.TransactionFilter) ([]model.TransactionLog, error)
+	Statement(user model.User, id string, from time.Time, to time.Time) (model.Statement, error)
 }
 
 //TranferService is interface
 type TranferService interface {
-	Tranfer(tranfer *model.Tranfer, userFrom model.User, userTo model.User) (*[]model.User, error)
+	Tranfer(tranfer *model.Tranfer, userFrom model.User) (*model.TranferLog, error)
+	RecoverTranfer() error
 }
 
 //UserServiceImplement is struct
 type UserServiceImplement struct {
-	db *mgo.Database
+	users        repository.UserRepository
+	bankAccounts repository.BankAccountRepository
 }
 
 //BankAccountServiceImplement is struct
 type BankAccountServiceImplement struct {
-	db *mgo.Database
+	bankAccounts repository.BankAccountRepository
+	transactions repository.TransactionRepository
+	products     map[model.Product]model.InterestProduct
+	fees         *FeeServiceImplement
+	tranfers     *TranferServiceImplement
+	limits       *LimitServiceImplement
 }
 
 //TranferServiceImplement is struct
 type TranferServiceImplement struct {
-	db *mgo.Database
+	bankAccounts repository.BankAccountRepository
+	tranfers     repository.TranferRepository
+	transactions repository.TransactionRepository
+	rates        fx.RateProvider
+	fees         *FeeServiceImplement
+	limits       *LimitServiceImplement
+	//feeIncomeAccount is AccountNumber every fee is moved to
+	feeIncomeAccount string
+	//approvalThreshold is amount in DefaultCurrency above which Tranfer needs Approval, empty is never
+	approvalThreshold string
 }
 
-//Tranfer for Tranfer
-func (t *TranferServiceImplement) Tranfer(tranfer *model.Tranfer, userFrom model.User, userTo model.User) (*[]model.User, error) {
-	var err error
-	var user []model.User
-	if tranfer.Amount == 0 {
+//Tranfer for Tranfer, Amount is in Currency of From and arrives at To in its own Currency.
+//Tranfer above approval_tranfer_threshold is refused whoever runs it, only Approve runs it
+func (t *TranferServiceImplement) Tranfer(tranfer *model.Tranfer, userFrom model.User) (*model.TranferLog, error) {
+	return t.tranfer(tranfer, userFrom, bson.NewObjectId(), false)
+}
+
+//needsApproval for check amount is above approval_tranfer_threshold once it is in DefaultCurrency
+func (t *TranferServiceImplement) needsApproval(amount model.Money) (bool, error) {
+	if t.approvalThreshold == "" {
+		return false, nil
+	}
+	exchangeRate, err := t.rates.Rate(amount.Currency, model.DefaultCurrency)
+	if err != nil {
+		return false, err
+	}
+	amount, err = fx.Convert(amount, exchangeRate)
+	if err != nil {
+		return false, err
+	}
+	return aboveMax(t.approvalThreshold, amount)
+}
+
+//tranfer for Tranfer stored as TranferLog id, approved is Tranfer run by Approve so it may be above
+//approval_tranfer_threshold
+func (t *TranferServiceImplement) tranfer(tranfer *model.Tranfer, userFrom model.User, id bson.ObjectId, approved bool) (*model.TranferLog, error) {
+	if tranfer.Amount.IsZero() {
 		return nil, errors.New("please require Amount")
 	}
+	if tranfer.Amount.IsNegative() {
+		return nil, ErrInvalidAmount
+	}
 	if tranfer.From == "" {
 		return nil, errors.New("please require AccountNumberFrom")
 	}
 	if tranfer.To == "" {
 		return nil, errors.New("please require AccountNumberTo")
 	}
-	var bankAccountForAccountFrom model.BankAccount
-	var bankAccountsForAccountFrom []model.BankAccount
-	hasBankAccountFrom := false
-	for _, userFromBankAccountList := range userFrom.UserBankAccount {
-		if userFromBankAccountList.AccountNumber == tranfer.From {
-			hasBankAccountFrom = true
-			bankAccountForAccountFrom = userFromBankAccountList
-			bankAccountForAccountFrom.Balance = bankAccountForAccountFrom.Balance - tranfer.Amount
-			bankAccountsForAccountFrom = append(bankAccountsForAccountFrom, bankAccountForAccountFrom)
-		} else {
-			bankAccountForAccountFrom = userFromBankAccountList
-			bankAccountsForAccountFrom = append(bankAccountsForAccountFrom, bankAccountForAccountFrom)
-		}
+	if tranfer.From == tranfer.To {
+		return nil, ErrTranferSameAccount
 	}
-
-	if hasBankAccountFrom == false {
+	bankAccountFrom, ok := findBankAccountByNumber(userFrom, tranfer.From)
+	if !ok {
 		return nil, errors.New("Not Have BankAccountID From")
 	}
-	userFrom.UserBankAccount = bankAccountsForAccountFrom
-	user = append(user, userFrom)
-
-	var bankAccountForAccountTo model.BankAccount
-	var bankAccountsForAccountTo []model.BankAccount
-	hasBankAccountTo := false
-	for _, userFromBankAccountList := range userTo.UserBankAccount {
-		if userFromBankAccountList.AccountNumber == tranfer.To {
-			hasBankAccountTo = true
-			bankAccountForAccountTo = userFromBankAccountList
-			bankAccountForAccountTo.Balance = bankAccountForAccountTo.Balance + tranfer.Amount
-			bankAccountsForAccountTo = append(bankAccountsForAccountTo, bankAccountForAccountTo)
-		} else {
-			bankAccountForAccountTo = userFromBankAccountList
-			bankAccountsForAccountTo = append(bankAccountsForAccountTo, bankAccountForAccountTo)
+	amount, err := tranfer.Amount.In(bankAccountFrom.AccountCurrency())
+	if err != nil {
+		return nil, err
+	}
+	if !approved {
+		needsApproval, err := t.needsApproval(amount)
+		if err != nil {
+			return nil, err
+		}
+		if needsApproval {
+			return nil, ErrApprovalRequired
 		}
 	}
+	feeQuote, err := t.fees.quote(bankAccountFrom, model.FeeKindTranfer, amount)
+	if err != nil {
+		return nil, err
+	}
 
-	if hasBankAccountTo == false {
-		return nil, errors.New("Not Have BankAccountID To")
+	bankAccountTo, err := t.bankAccounts.FindByAccountNumber(tranfer.To)
+	if err == repository.ErrNotFound {
+		return nil, ErrBankAccountToNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	exchangeRate, err := t.rates.Rate(amount.Currency, bankAccountTo.AccountCurrency())
+	if err != nil {
+		return nil, err
+	}
+	amountTo, err := fx.Convert(amount, exchangeRate)
+	if err != nil {
+		return nil, err
+	}
+	if amountTo.IsZero() {
+		return nil, ErrInvalidAmount
 	}
-	userTo.UserBankAccount = bankAccountsForAccountTo
-	user = append(user, userTo)
 
-	err = t.db.C(COLLECTIONUser).UpdateId(userFrom.ID, &userFrom)
+	release, err := t.limits.reserveTranfer(userFrom, bankAccountFrom, amount)
+	if err != nil {
+		return nil, err
+	}
+	//Tranfer is not counted only when its TranferLog is never stored or ends cancelled, one that
+	//failed after money moved is finished by RecoverTranfer and keeps counting
+	counted := false
+	defer func() {
+		if !counted {
+			release()
+		}
+	}()
+
+	tranferLog := model.TranferLog{
+		ID:                id,
+		UserFrom:          userFrom.ID,
+		UserTo:            bankAccountTo.UserID,
+		AccountNumberFrom: tranfer.From,
+		AccountNumberTo:   tranfer.To,
+		Amount:            amount,
+		AmountTo:          amountTo,
+		State:             model.TranferStateInitial,
+		LastModified:      time.Now(),
+	}
+	if exchangeRate.From != exchangeRate.To {
+		tranferLog.Rate = exchangeRate.Rate
+	}
+	err = t.addFee(&tranferLog, feeQuote.Fee)
 	if err != nil {
 		return nil, err
 	}
-	err = t.db.C(COLLECTIONUser).UpdateId(userTo.ID, &userTo)
-	return &user, err
+	err = t.tranfers.Insert(&tranferLog)
+	if err != nil {
+		return nil, err
+	}
+	err = t.apply(&tranferLog, nil)
+	if err != nil && tranferLog.State != model.TranferStateCancelled {
+		//money may have moved, RecoverTranfer finishes or rolls it back
+		counted = true
+		return nil, committed(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	counted = true
+	return &tranferLog, nil
 }
 
-//CreateBankAccount for CreateBankAccount
-func (b *BankAccountServiceImplement) CreateBankAccount(bankaccountReq *model.BankAccount, user model.User, users []model.User) ([]model.BankAccount, error) {
-	var err error
-
+//prepareBankAccount for check bankaccountReq has every field CreateBankAccount requires and fill
+//its Currency and Product when they are not given
+func (b *BankAccountServiceImplement) prepareBankAccount(bankaccountReq *model.BankAccount) error {
 	if bankaccountReq.BankName == "" {
-		return nil, errors.New("please require BankName")
+		return errors.New("please require BankName")
 	}
 
 	if bankaccountReq.AccountNumber == "" {
-		return nil, errors.New("please require AccountNumber")
+		return errors.New("please require AccountNumber")
 	}
 
-	if bankaccountReq.Balance == 0 {
-		return nil, errors.New("please require Balance")
+	if bankaccountReq.Balance.IsZero() {
+		return errors.New("please require Balance")
 	}
-	for _, usersList := range users {
-		for _, bankAccountOfuserList := range usersList.UserBankAccount {
-			if bankAccountOfuserList.AccountNumber == bankaccountReq.AccountNumber {
-				return nil, errors.New("AccountNumber Dupicate")
-			}
-		}
+	if bankaccountReq.OverdraftLimit.IsNegative() {
+		return errors.New("OverdraftLimit must not be negative")
+	}
+	currency := bankaccountReq.Currency
+	if currency == "" {
+		currency = bankaccountReq.Balance.Currency
+	}
+	if currency == "" {
+		currency = model.DefaultCurrency
 	}
+	var err error
+	bankaccountReq.Balance, err = bankaccountReq.Balance.In(currency)
+	if err != nil {
+		return err
+	}
+	bankaccountReq.OverdraftLimit, err = bankaccountReq.OverdraftLimit.In(currency)
+	if err != nil {
+		return err
+	}
+	bankaccountReq.Currency = currency
+	if bankaccountReq.Product == "" {
+		bankaccountReq.Product = model.ProductCurrent
+	}
+	if _, ok := b.products[bankaccountReq.Product]; !ok {
+		return ErrProductUnknown
+	}
+	return nil
+}
 
-	for _, bankAccountOfuser := range user.UserBankAccount {
-		if bankAccountOfuser.AccountNumber == bankaccountReq.AccountNumber {
-			return nil, errors.New("AccountNumber Dupicate")
-		}
+//CreateBankAccount for CreateBankAccount
+func (b *BankAccountServiceImplement) CreateBankAccount(bankaccountReq *model.BankAccount, user model.User) (*model.BankAccount, error) {
+	if err := b.prepareBankAccount(bankaccountReq); err != nil {
+		return nil, err
 	}
 	bankaccountReq.ID = bson.NewObjectId()
+	bankaccountReq.UserID = user.ID
+	bankaccountReq.PendingTranfers = nil
 
-	user.UserBankAccount = append(user.UserBankAccount, *bankaccountReq)
-	err = b.db.C(COLLECTIONUser).UpdateId(user.ID, &user)
-	return user.UserBankAccount, err
+	err := b.bankAccounts.Insert(bankaccountReq)
+	if err == repository.ErrDuplicate {
+		return nil, errors.New("AccountNumber Dupicate")
+	}
+	return bankaccountReq, err
 }
 
 //FindAllBankAccount for FindAllBankAccount
@@ -176,150 +302,313 @@ func (b *BankAccountServiceImplement) FindAllBankAccount(user model.User) []mode
 	return bankAccount
 }
 
+//FindBankAccount for BankAccount id of user as it is stored now, its Version is what deposit and withdraw
+//compare If-Match to
+func (b *BankAccountServiceImplement) FindBankAccount(user model.User, id string) (*model.BankAccount, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
+	}
+	if _, ok := findBankAccount(user, bson.ObjectIdHex(id)); !ok {
+		return nil, ErrBankAccountNotFound
+	}
+	bankAccount, err := b.bankAccounts.FindByID(bson.ObjectIdHex(id))
+	if err == repository.ErrNotFound {
+		return nil, ErrBankAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bankAccount, nil
+}
+
 //DeleteBankAccount for DeleteBankAccount
 func (b *BankAccountServiceImplement) DeleteBankAccount(user model.User, id string) (*model.BankAccount, error) {
-	var bankAccounts []model.BankAccount
-	var bankAccount model.BankAccount
-	hasBankAccount := false
-	for _, userBankAccountList := range user.UserBankAccount {
-		if userBankAccountList.ID == bson.ObjectIdHex(id) {
-			bankAccount = userBankAccountList
-			hasBankAccount = true
-		} else {
-			bankAccounts = append(bankAccounts, userBankAccountList)
-		}
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
 	}
-	if !hasBankAccount {
-		return nil, errors.New("Not Have BankAccountID")
+	bankAccount, ok := findBankAccount(user, bson.ObjectIdHex(id))
+	if !ok {
+		return nil, ErrBankAccountNotFound
+	}
+	err := b.bankAccounts.Delete(user.ID, bankAccount.ID, func(bankAccount model.BankAccount) error {
+		return checkEmpty([]model.BankAccount{bankAccount})
+	})
+	if err == repository.ErrNotFound {
+		return nil, ErrBankAccountNotFound
 	}
-	user.UserBankAccount = bankAccounts
-	err := b.db.C(COLLECTIONUser).UpdateId(user.ID, &user)
 	return &bankAccount, err
 }
 
-//DepositBankAccount for DepositBankAccount
-func (b *BankAccountServiceImplement) DepositBankAccount(tranSaction *model.Transaction, user model.User, id string) (*model.BankAccount, error) {
-	var bankAccounts []model.BankAccount
-	var bankAccount model.BankAccount
-	var bankAccountHasTransaction model.BankAccount
-	hasBankAccount := false
-
-	if tranSaction.Amount == 0 {
+//DepositBankAccount for DepositBankAccount, version is Version of BankAccount from If-Match or AnyVersion
+func (b *BankAccountServiceImplement) DepositBankAccount(tranSaction *model.Transaction, user model.User, id string, version int64) (*model.BankAccount, error) {
+	if tranSaction.Amount.IsZero() {
 		return nil, errors.New("please require Amount")
 	}
-	for _, userBankAccountList := range user.UserBankAccount {
-		if userBankAccountList.ID == bson.ObjectIdHex(id) {
-			hasBankAccount = true
-			bankAccount = userBankAccountList
-			bankAccount.Balance = bankAccount.Balance + tranSaction.Amount
-			bankAccountHasTransaction = bankAccount
-			bankAccounts = append(bankAccounts, bankAccount)
-		} else {
-			bankAccount = userBankAccountList
-			bankAccounts = append(bankAccounts, bankAccount)
+	if tranSaction.Amount.IsNegative() {
+		return nil, ErrInvalidAmount
+	}
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
+	}
+	var amount model.Money
+	bankAccount, err := b.bankAccounts.UpdateBalance(user.ID, bson.ObjectIdHex(id), func(bankAccount *model.BankAccount) error {
+		if version != AnyVersion && bankAccount.Version != version {
+			return ErrPreconditionFailed
 		}
+		var err error
+		amount, err = tranSaction.Amount.In(bankAccount.AccountCurrency())
+		if err != nil {
+			return err
+		}
+		bankAccount.Balance, err = bankAccount.Balance.Add(amount)
+		return err
+	})
+	if err == repository.ErrNotFound {
+		return nil, ErrBankAccountNotFound
 	}
-
-	if !hasBankAccount {
-		return nil, errors.New("Not Have BankAccountID")
+	if err != nil {
+		return nil, err
 	}
-
-	user.UserBankAccount = bankAccounts
-	err := b.db.C(COLLECTIONUser).UpdateId(user.ID, &user)
-	return &bankAccountHasTransaction, err
+	err = b.insertTransactionLog(model.TransactionLog{
+		UserID:        user.ID,
+		BankAccountID: bankAccount.ID,
+		AccountNumber: bankAccount.AccountNumber,
+		Type:          model.TransactionTypeDeposit,
+		Amount:        amount,
+		BalanceAfter:  bankAccount.Balance,
+		CreatedBy:     user.ID,
+	})
+	if err != nil {
+		return nil, committed(err)
+	}
+	return &bankAccount, nil
 }
 
-//WithdrawBankAccount for WithdrawBankAccount
-func (b *BankAccountServiceImplement) WithdrawBankAccount(tranSaction *model.Transaction, user model.User, id string) (*model.BankAccount, error) {
-	var bankAccounts []model.BankAccount
-	var bankAccount model.BankAccount
-	var bankAccountHasTransaction model.BankAccount
-	hasBankAccount := false
-
-	if tranSaction.Amount == 0 {
+//WithdrawBankAccount for WithdrawBankAccount, version is Version of BankAccount from If-Match or AnyVersion
+func (b *BankAccountServiceImplement) WithdrawBankAccount(tranSaction *model.Transaction, user model.User, id string, version int64) (*model.BankAccount, error) {
+	if tranSaction.Amount.IsZero() {
 		return nil, errors.New("please require Amount")
 	}
-	for _, userBankAccountList := range user.UserBankAccount {
-		if userBankAccountList.ID == bson.ObjectIdHex(id) {
-			hasBankAccount = true
-			bankAccount = userBankAccountList
-			bankAccount.Balance = bankAccount.Balance - tranSaction.Amount
-			bankAccountHasTransaction = bankAccount
-			bankAccounts = append(bankAccounts, bankAccount)
-		} else {
-			bankAccount = userBankAccountList
-			bankAccounts = append(bankAccounts, bankAccount)
+	if tranSaction.Amount.IsNegative() {
+		return nil, ErrInvalidAmount
+	}
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrBankAccountNotFound
+	}
+	bankAccountFound, ok := findBankAccount(user, bson.ObjectIdHex(id))
+	if !ok {
+		return nil, ErrBankAccountNotFound
+	}
+	amount, err := tranSaction.Amount.In(bankAccountFound.AccountCurrency())
+	if err != nil {
+		return nil, err
+	}
+	//fee is quoted before BankAccount is locked, quote reads ledger
+	feeQuote, err := b.fees.quote(bankAccountFound, model.FeeKindWithdraw, amount)
+	if err != nil {
+		return nil, err
+	}
+	err = b.limits.checkWithdraw(user, bankAccountFound, amount)
+	if err != nil {
+		return nil, err
+	}
+	//withdraw leaves by TranferLog so fee leaves with it in the same two-phase commit
+	tranferLog := model.TranferLog{
+		ID:                bson.NewObjectId(),
+		UserFrom:          user.ID,
+		UserTo:            user.ID,
+		AccountNumberFrom: bankAccountFound.AccountNumber,
+		Amount:            amount,
+		Kind:              model.TranferKindWithdraw,
+		State:             model.TranferStateInitial,
+		LastModified:      time.Now(),
+	}
+	err = b.tranfers.addFee(&tranferLog, feeQuote.Fee)
+	if err != nil {
+		return nil, err
+	}
+	err = b.tranfers.tranfers.Insert(&tranferLog)
+	if err != nil {
+		return nil, err
+	}
+	err = b.tranfers.apply(&tranferLog, func(bankAccount model.BankAccount) error {
+		if version != AnyVersion && bankAccount.Version != version {
+			return ErrPreconditionFailed
 		}
+		return nil
+	})
+	if err != nil && tranferLog.State != model.TranferStateCancelled {
+		return nil, committed(err)
 	}
-
-	if !hasBankAccount {
-		return nil, errors.New("Not Have BankAccountID")
+	if err != nil {
+		return nil, err
 	}
-
-	user.UserBankAccount = bankAccounts
-	err := b.db.C(COLLECTIONUser).UpdateId(user.ID, &user)
-	return &bankAccountHasTransaction, err
+	//Balance and Version as they are after fee
+	bankAccount, err := b.bankAccounts.FindByID(bankAccountFound.ID)
+	if err != nil {
+		return nil, committed(err)
+	}
+	return &bankAccount, nil
 }
 
-//FindAllUser for FindAllUser
-func (u *UserServiceImplement) FindAllUser() ([]model.User, error) {
-	var users []model.User
-	err := u.db.C(COLLECTIONUser).Find(bson.M{}).All(&users)
-	return users, err
+//FindAllUser for page of User matching filter and cursor of the next page, cursor is empty on the last page
+func (u *UserServiceImplement) FindAllUser(filter model.UserFilter) ([]model.User, string, error) {
+	limit := filter.Limit
+	filter.Limit++
+	users, err := u.users.Find(filter)
+	if err != nil || len(users) <= limit {
+		return users, "", err
+	}
+	users = users[:limit]
+	return users, encodeUserCursor(filter, users[limit-1]), nil
 }
 
 //FindByIDUser for FindByIDUser
 func (u *UserServiceImplement) FindByIDUser(id string) (model.User, error) {
-	var user model.User
-	err := u.db.C(COLLECTIONUser).FindId(bson.ObjectIdHex(id)).One(&user)
-	return user, err
+	if !bson.IsObjectIdHex(id) {
+		return model.User{}, repository.ErrNotFound
+	}
+	return u.users.FindByID(bson.ObjectIdHex(id))
+}
+
+//FindByUsername for FindByUsername
+func (u *UserServiceImplement) FindByUsername(username string) (model.User, error) {
+	return u.users.FindByUsername(username)
+}
+
+//validateUser for check User has every field InsertUser requires
+func validateUser(user model.User) error {
+	if user.FirstName == "" || user.LastName == "" || user.Username == "" || user.Password == "" || user.IDcard == "" || user.Tel == "" || user.Email == "" || user.Age == 0 {
+		return errors.New("please require All Field in User")
+	}
+	return nil
 }
 
 //InsertUser for InsertUser
 func (u *UserServiceImplement) InsertUser(UserCreate *model.User) (*model.User, error) {
 	var err error
-	if UserCreate.FirstName == "" || UserCreate.LastName == "" || UserCreate.Username == "" || UserCreate.Password == "" || UserCreate.IDcard == "" || UserCreate.Tel == "" || UserCreate.Email == "" || UserCreate.Age == 0 {
-		return nil, errors.New("please require All Field in User")
+	if err := validateUser(*UserCreate); err != nil {
+		return nil, err
+	}
+	UserCreate.Password, err = internal.HashPassword(UserCreate.Password)
+	if err != nil {
+		return nil, err
 	}
 	UserCreate.ID = bson.NewObjectId()
-	err = u.db.C(COLLECTIONUser).Insert(&UserCreate)
+	UserCreate.Roles = nil
+	UserCreate.UserBankAccount = nil
+	err = u.users.Insert(UserCreate)
 	return UserCreate, err
 }
 
-//UpdateUser for UpdateUser
-func (u *UserServiceImplement) UpdateUser(UserUpdate *model.User, user model.User) (*model.User, error) {
-	if UserUpdate.FirstName != "" {
-		user.FirstName = UserUpdate.FirstName
-	}
-	if UserUpdate.LastName != "" {
-		user.LastName = UserUpdate.LastName
-	}
-	if UserUpdate.Username != "" {
-		user.Username = UserUpdate.Username
-	}
+//UpdateUser for UpdateUser, when someone else stored User first it is read again and UserUpdate
+//is applied to it again, version is Version of User from If-Match or AnyVersion
+func (u *UserServiceImplement) UpdateUser(UserUpdate *model.User, user model.User, version int64) (*model.User, error) {
+	var err error
+	password := ""
 	if UserUpdate.Password != "" {
-		user.Password = UserUpdate.Password
-	}
-	if UserUpdate.IDcard != "" {
-		user.IDcard = UserUpdate.IDcard
+		password, err = internal.HashPassword(UserUpdate.Password)
+		if err != nil {
+			return nil, err
+		}
 	}
-	if UserUpdate.Age != 0 {
-		user.Age = UserUpdate.Age
+	for retry := 0; ; retry++ {
+		if version != AnyVersion && user.Version != version {
+			return nil, ErrPreconditionFailed
+		}
+		if UserUpdate.FirstName != "" {
+			user.FirstName = UserUpdate.FirstName
+		}
+		if UserUpdate.LastName != "" {
+			user.LastName = UserUpdate.LastName
+		}
+		if UserUpdate.Username != "" {
+			user.Username = UserUpdate.Username
+		}
+		if password != "" {
+			user.Password = password
+		}
+		if UserUpdate.IDcard != "" {
+			user.IDcard = UserUpdate.IDcard
+		}
+		if UserUpdate.Age != 0 {
+			user.Age = UserUpdate.Age
+		}
+		if UserUpdate.Email != "" {
+			user.Email = UserUpdate.Email
+		}
+		if UserUpdate.Tel != "" {
+			user.Tel = UserUpdate.Tel
+		}
+		err = u.users.Update(&user)
+		if err != repository.ErrConflict || retry == MaxConflictRetry {
+			return &user, err
+		}
+		user, err = u.users.FindByID(user.ID)
+		if err != nil {
+			return nil, err
+		}
 	}
-	if UserUpdate.Email != "" {
-		user.Email = UserUpdate.Email
+}
+
+//SetRoles for replace Roles of User, empty roles is RoleCustomer. version is Version of User from If-Match or AnyVersion
+func (u *UserServiceImplement) SetRoles(user model.User, roles []model.Role, version int64) (*model.User, error) {
+	if err := model.ValidateRoles(roles); err != nil {
+		return nil, err
 	}
-	if UserUpdate.Tel != "" {
-		user.Tel = UserUpdate.Tel
+	for retry := 0; ; retry++ {
+		if version != AnyVersion && user.Version != version {
+			return nil, ErrPreconditionFailed
+		}
+		user.Roles = roles
+		err := u.users.Update(&user)
+		if err != repository.ErrConflict || retry == MaxConflictRetry {
+			return &user, err
+		}
+		user, err = u.users.FindByID(user.ID)
+		if err != nil {
+			return nil, err
+		}
 	}
-	err := u.db.C(COLLECTIONUser).UpdateId(user.ID, &user)
-	return UserUpdate, err
 }
 
-//DeleteUser for DeleteUser
+//DeleteUser for DeleteUser, User and every BankAccount of them are deleted at once only when no BankAccount
+//has money or a pending Tranfer
 func (u *UserServiceImplement) DeleteUser(user model.User) (*model.User, error) {
-	err := u.db.C(COLLECTIONUser).Remove(&user)
-	return &user, err
+	err := u.users.DeleteWithBankAccounts(user.ID, checkEmpty)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+//checkEmpty for check no BankAccount of bankAccounts has Balance or pending Tranfer, so deleting them loses no money
+func checkEmpty(bankAccounts []model.BankAccount) error {
+	for _, bankAccount := range bankAccounts {
+		if !bankAccount.Balance.IsZero() || len(bankAccount.PendingTranfers) > 0 {
+			return ErrBankAccountNotEmpty
+		}
+	}
+	return nil
+}
+
+//CheckPassword for CheckPassword, legacy plaintext Password is rehashed after it matches
+func (u *UserServiceImplement) CheckPassword(user model.User, password string) (bool, error) {
+	if !internal.CheckPassword(user.Password, password) {
+		return false, nil
+	}
+	if internal.IsPasswordHash(user.Password) {
+		return true, nil
+	}
+	hash, err := internal.HashPassword(password)
+	if err != nil {
+		return true, err
+	}
+	err = u.users.SetPassword(user.ID, user.Password, hash)
+	if err == repository.ErrNotFound {
+		return true, nil
+	}
+	return true, err
 }
 
 var (
@@ -330,28 +619,110 @@ var (
 	dao    = &DataObjectAccess{}
 )
 
-const (
-	//COLLECTIONUser users in mgo
-	COLLECTIONUser = "users"
+var (
+	//ErrInsufficientFunds is returned when Balance plus OverdraftLimit does not cover Amount
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	//ErrInvalidAmount is returned when Amount is not greater than zero
+	ErrInvalidAmount = errors.New("Amount must be greater than zero")
+	//ErrBankAccountNotFound is returned when user has no BankAccount of id
+	ErrBankAccountNotFound = errors.New("Not Have BankAccountID")
+	//ErrBankAccountToNotFound is returned when nobody has AccountNumber of Tranfer To
+	ErrBankAccountToNotFound = errors.New("Not Have BankAccountID To")
+	//ErrTranferSameAccount is returned when Tranfer From and To are the same BankAccount
+	ErrTranferSameAccount = errors.New("AccountNumberFrom and AccountNumberTo must be different")
+	//ErrBankAccountNotEmpty is returned when User to delete still has money or a pending Tranfer in some BankAccount
+	ErrBankAccountNotEmpty = errors.New("every bank account must have no balance and no pending tranfer")
 )
 
 func init() {
 
 	config.Read()
+}
 
-	s.Server = config.Server
-	s.Database = config.Database
-	s.Connect()
-	dao = &DataObjectAccess{
-		userService: &UserServiceImplement{
-			db: dbs,
+//NewDataObjectAccess for wire every service to repositories of store, exchange rates, interest and fees of products
+func NewDataObjectAccess(store *repository.Store, rates fx.RateTable, products map[model.Product]model.InterestProduct, fees map[model.Product]model.ProductFees) *DataObjectAccess {
+	userService := &UserServiceImplement{
+		users:        store.Users,
+		bankAccounts: store.BankAccounts,
+	}
+	feeService := &FeeServiceImplement{
+		transactions: store.Transactions,
+		fees:         fees,
+	}
+	limitService := &LimitServiceImplement{
+		limits: store.Limits,
+		rates:  rates,
+		bank:   config.Limits,
+	}
+	tranferService := &TranferServiceImplement{
+		bankAccounts:      store.BankAccounts,
+		tranfers:          store.Tranfers,
+		transactions:      store.Transactions,
+		rates:             rates,
+		fees:              feeService,
+		limits:            limitService,
+		feeIncomeAccount:  config.FeeIncomeAccount,
+		approvalThreshold: config.ApprovalTranferThreshold,
+	}
+	bankAccountService := &BankAccountServiceImplement{
+		bankAccounts: store.BankAccounts,
+		transactions: store.Transactions,
+		products:     products,
+		fees:         feeService,
+		tranfers:     tranferService,
+		limits:       limitService,
+	}
+	return &DataObjectAccess{
+		userService:        userService,
+		bankAccountService: bankAccountService,
+		tranferService:     tranferService,
+		authService: &AuthServiceImplement{
+			refreshTokens:   store.RefreshTokens,
+			userService:     userService,
+			secret:          []byte(config.JWTSecret),
+			accessTokenTTL:  config.AccessTokenTTL.Duration,
+			refreshTokenTTL: config.RefreshTokenTTL.Duration,
+		},
+		searchService: &SearchServiceImplement{
+			users: store.Users,
+		},
+		importService: &ImportServiceImplement{
+			users:              store.Users,
+			bankAccounts:       store.BankAccounts,
+			bankAccountService: bankAccountService,
+			chunkSize:          config.ImportChunkSize,
 		},
-		bankAccountService: &BankAccountServiceImplement{
-			db: dbs,
+		interestService: &InterestServiceImplement{
+			bankAccounts: store.BankAccounts,
+			interest:     store.Interest,
+			transactions: store.Transactions,
+			products:     products,
 		},
-		tranferService: &TranferServiceImplement{
-			db: dbs,
+		feeService:   feeService,
+		limitService: limitService,
+		standingOrderService: &StandingOrderServiceImplement{
+			orders:        store.StandingOrders,
+			bankAccounts:  store.BankAccounts,
+			userService:   userService,
+			tranfers:      tranferService,
+			retries:       config.StandingOrderRetries,
+			retryInterval: config.StandingOrderRetryInterval.Duration,
 		},
+		approvalService: &ApprovalServiceImplement{
+			approvals:          store.Approvals,
+			audit:              store.Audit,
+			userService:        userService,
+			bankAccountService: bankAccountService,
+			tranfers:           tranferService,
+			window:             config.ApprovalWindow.Duration,
+		},
+		bulkPaymentService: &BulkPaymentServiceImplement{
+			bulkPayments: store.BulkPayments,
+			bankAccounts: store.BankAccounts,
+			userService:  userService,
+			tranfers:     tranferService,
+		},
+		rates: rates,
 	}
 }
 
@@ -370,7 +741,52 @@ func init() {
 // @host petstore.swagger.io
 // @BasePath /v1
 func main() {
-	SetUpRoute(dao)
+	if len(os.Args) > 1 {
+		if err := RunCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := config.ValidateJWTSecret(); err != nil {
+		log.Fatal(err)
+	}
+	store, err := OpenStore(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rates, err := fx.NewFileRateProvider(config.RatesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	products, err := LoadProducts(config.Products)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fees, err := LoadFees(config.Products, config.FeeIncomeAccount)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ValidateLimits(config.Limits); err != nil {
+		log.Fatal(err)
+	}
+	if _, _, err := limitAmount(config.ApprovalTranferThreshold, model.DefaultCurrency); err != nil {
+		log.Fatal("config.toml: approval_tranfer_threshold: ", err)
+	}
+	dao = NewDataObjectAccess(store, rates, products, fees)
+	if err := dao.tranferService.RecoverTranfer(); err != nil {
+		log.Fatal(err)
+	}
+	if err := dao.approvalService.RecoverApprovals(); err != nil {
+		log.Fatal(err)
+	}
+	if err := dao.bulkPaymentService.RecoverBulkPayment(); err != nil {
+		log.Fatal(err)
+	}
+	go CleanIdempotencyKeys(store.IdempotencyKeys, IdempotencyKeyCleanupInterval)
+	go RunInterestAccrual(dao.interestService, InterestAccrualInterval)
+	go ScheduleStandingOrders(dao.standingOrderService, StandingOrderInterval)
+	go RunApprovalExpiry(dao.approvalService, ApprovalExpiryInterval)
 
 	// Middleware
 	logger := middleware.LoggerWithConfig(middleware.LoggerConfig{
@@ -382,31 +798,71 @@ func main() {
 	e.GET("/swagger/*", echoswagger.WrapHandler)
 
 	// Routes
+	SetUpRoute(e, dao, store)
+
+	// Start Server
+	e.Logger.Fatal(e.Start(":1323"))
+}
+
+// SetUpRoute with echo, every route of d is added to e
+func SetUpRoute(e *echo.Echo, d *DataObjectAccess, store *repository.Store) {
+	idempotency := Idempotency(store.IdempotencyKeys, config.IdempotencyKeyTTL.Duration)
 	gVersion := e.Group("/v1")
+	roles := LoadRoles(d.userService, config.Admins)
 	users := gVersion.Group("/users")
 
-	users.GET("", dao.FindAllUserEndPoint)
-	users.POST("", dao.InsertUserEndPoint)
+	//list of every User shows PII of all of them, it is for staff who search customers
+	users.GET("", d.FindAllUserEndPoint, JWTAuth([]byte(config.JWTSecret)), roles, Allow(model.PermissionUserSearch))
+	users.POST("", d.InsertUserEndPoint)
+
+	auth := gVersion.Group("/auth")
+	auth.POST("/login", d.LoginEndPoint)
+	auth.POST("/refresh", d.RefreshEndPoint)
+	auth.POST("/logout", d.LogoutEndPoint)
 
 	user := gVersion.Group("/user")
-	user.Use(middleware.BasicAuth(dao.ValidateUser))
-	user.GET("/:id", dao.FindByIDUserEndPoint)
-	user.PUT("/:id", dao.UpdateUserEndPoint)
-	user.DELETE("/:id", dao.DeleteUserEndPoint)
-	user.POST("/:id/bankAccount", dao.CreateBankAccountEndPoint)
-	user.GET("/:id/bankAccount", dao.FindAllBankAccountEndPoint)
-	user.DELETE("/:id/bankAccount/:idBankAccount", dao.DeleteBankAccountEndPoint)
-	user.PUT("/:id/bankAccount/:idBankAccount/deposit", dao.DepositBankAccountEndPoint)
-	user.PUT("/:id/bankAccount/:idBankAccount/withdraw", dao.WithDrawBankAccountEndPoint)
+	user.Use(JWTAuth([]byte(config.JWTSecret)), roles)
+	user.GET("/:id", d.FindByIDUserEndPoint, Allow(model.PermissionUserRead))
+	user.PUT("/:id", d.UpdateUserEndPoint, Allow(model.PermissionUserUpdate))
+	user.DELETE("/:id", d.DeleteUserEndPoint, Allow(model.PermissionUserDelete))
+	user.POST("/:id/bankAccount", d.CreateBankAccountEndPoint, Allow(model.PermissionBankAccountCreate))
+	user.GET("/:id/bankAccount", d.FindAllBankAccountEndPoint, Allow(model.PermissionBankAccountRead))
+	user.GET("/:id/bankAccount/:idBankAccount", d.FindBankAccountEndPoint, Allow(model.PermissionBankAccountRead))
+	user.DELETE("/:id/bankAccount/:idBankAccount", d.DeleteBankAccountEndPoint, Allow(model.PermissionBankAccountDelete))
+	user.PUT("/:id/bankAccount/:idBankAccount/deposit", d.DepositBankAccountEndPoint, Allow(model.PermissionDeposit), idempotency)
+	user.PUT("/:id/bankAccount/:idBankAccount/withdraw", d.WithDrawBankAccountEndPoint, Allow(model.PermissionWithdraw), idempotency)
+	user.GET("/:id/bankAccount/:idBankAccount/transactions", d.FindAllTransactionEndPoint, Allow(model.PermissionBankAccountRead))
+	user.GET("/:id/bankAccount/:idBankAccount/statement", d.StatementEndPoint, Allow(model.PermissionBankAccountRead))
+	user.GET("/:id/bankAccount/:idBankAccount/interest", d.PreviewInterestEndPoint, Allow(model.PermissionBankAccountRead))
+	user.GET("/:id/bankAccount/:idBankAccount/fee", d.QuoteFeeEndPoint, Allow(model.PermissionBankAccountRead))
+	user.PUT("/:id/bankAccount/:idBankAccount/limits", d.SetBankAccountLimitsEndPoint, Allow(model.PermissionLimitUpdate))
+	user.GET("/:id/limits", d.FindLimitsEndPoint, Allow(model.PermissionBankAccountRead))
+	user.PUT("/:id/limits", d.SetUserLimitsEndPoint, Allow(model.PermissionLimitUpdate))
+	user.POST("/:id/standingOrders", d.CreateStandingOrderEndPoint, Allow(model.PermissionStandingOrder))
+	user.GET("/:id/standingOrders", d.FindAllStandingOrderEndPoint, Allow(model.PermissionStandingOrder))
+	user.PUT("/:id/standingOrders/:idStandingOrder/pause", d.PauseStandingOrderEndPoint, Allow(model.PermissionStandingOrder))
+	user.PUT("/:id/standingOrders/:idStandingOrder/resume", d.ResumeStandingOrderEndPoint, Allow(model.PermissionStandingOrder))
+	user.PUT("/:id/standingOrders/:idStandingOrder/cancel", d.CancelStandingOrderEndPoint, Allow(model.PermissionStandingOrder))
+	user.GET("/:id/standingOrders/:idStandingOrder/executions", d.FindAllStandingOrderExecutionEndPoint, Allow(model.PermissionStandingOrder))
+	user.POST("/:id/bulkPayments", d.CreateBulkPaymentEndPoint, Allow(model.PermissionBulkPayment))
+	user.GET("/:id/bulkPayments", d.FindAllBulkPaymentEndPoint, Allow(model.PermissionBulkPayment))
+	user.GET("/:id/bulkPayments/:idBulkPayment", d.FindBulkPaymentEndPoint, Allow(model.PermissionBulkPayment))
 
 	tranfers := e.Group("/tranfers")
-	tranfers.POST("/from/:idFrom/to/:idTo", dao.TranfersEndPoint)
-	// Start Server
-	e.Logger.Fatal(e.Start(":1323"))
-}
-
-// SetUpRoute with echo
-func SetUpRoute(d *DataObjectAccess) {
+	tranfers.Use(JWTAuth([]byte(config.JWTSecret)), roles)
+	tranfers.POST("/from/:idFrom", d.TranfersEndPoint, Allow(model.PermissionTranfer), idempotency)
+
+	admin := gVersion.Group("/admin")
+	admin.Use(JWTAuth([]byte(config.JWTSecret)), roles)
+	admin.GET("/rates", d.FindAllRateEndPoint, Allow(model.PermissionRateRead))
+	admin.PUT("/rates", d.SetRatesEndPoint, Allow(model.PermissionRateUpdate))
+	admin.POST("/users/import", d.ImportUsersEndPoint, Allow(model.PermissionUserImport))
+	admin.PUT("/users/:id/roles", d.SetUserRolesEndPoint, Allow(model.PermissionRoleAssign))
+	admin.GET("/search", d.SearchCustomersEndPoint, Allow(model.PermissionUserSearch))
+	admin.GET("/approvals", d.FindAllApprovalEndPoint, Allow(model.PermissionApprove))
+	admin.GET("/approvals/:idApproval", d.FindApprovalEndPoint, Allow(model.PermissionApprove))
+	admin.PUT("/approvals/:idApproval/approve", d.ApproveEndPoint, Allow(model.PermissionApprove))
+	admin.PUT("/approvals/:idApproval/reject", d.RejectEndPoint, Allow(model.PermissionApprove))
 }
 
 //Connect is func for Connect db
@@ -419,14 +875,29 @@ func (m *Server) Connect() *mgo.Database {
 	return dbs
 }
 
-//FindAllUserEndPoint is FindAllUserEndPoint
+//FindAllUserEndPoint is FindAllUserEndPoint, roles that must not see PII get User masked like customer search
 func (m *DataObjectAccess) FindAllUserEndPoint(c echo.Context) (err error) {
-	users, err := m.userService.FindAllUser()
+	filter, err := ParseUserFilter(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	roles := AuthRoles(c)
+	if (filter.Email != "" || filter.Tel != "") && model.ScopeOf(roles, model.PermissionUserSearch) != model.ScopeAny {
+		//finding User by email or tel tells who is a customer
+		return echo.NewHTTPError(http.StatusForbidden, "not allowed to "+string(model.PermissionUserSearch))
+	}
+	users, nextCursor, err := m.userService.FindAllUser(filter)
 	if err != nil {
 		return err
 	}
-	PrintLog(users)
-	return c.JSON(http.StatusOK, MapJSONUser(users))
+	if !unmaskedRoles(roles) {
+		userResp := maskedCustomers(users)
+		PrintLog(userResp)
+		return c.JSON(http.StatusOK, MapJSONUserPage(userResp, filter, nextCursor))
+	}
+	userResp := model.NewUserResponses(users)
+	PrintLog(userResp)
+	return c.JSON(http.StatusOK, MapJSONUserPage(userResp, filter, nextCursor))
 }
 
 //FindByIDUserEndPoint is FindByIDUserEndPoint
@@ -436,8 +907,10 @@ func (m *DataObjectAccess) FindByIDUserEndPoint(c echo.Context) (err error) {
 	if err != nil {
 		return err
 	}
-	PrintLog(user)
-	return c.JSON(http.StatusOK, MapJSONUser(user))
+	userResp := model.NewUserResponse(user)
+	PrintLog(userResp)
+	c.Response().Header().Set(HeaderETag, ETag(user.Version))
+	return c.JSON(http.StatusOK, MapJSONUser(userResp))
 }
 
 //InsertUserEndPoint is InsertUserEndPoint
@@ -452,7 +925,7 @@ func (m *DataObjectAccess) InsertUserEndPoint(c echo.Context) (err error) {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	PrintLog(user)
+	PrintLog(model.NewUserResponse(*user))
 	return c.JSON(http.StatusCreated, map[string]string{"result": "Create Success"})
 }
 
@@ -466,35 +939,35 @@ func (m *DataObjectAccess) UpdateUserEndPoint(c echo.Context) (err error) {
 	if err := c.Bind(u); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("json: wrong params: %s", err))
 	}
-	userResp, err := m.userService.UpdateUser(u, user)
+	version, err := ParseIfMatch(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	PrintLog(userResp)
+	userResp, err := m.userService.UpdateUser(u, user, version)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(model.NewUserResponse(*userResp))
+	c.Response().Header().Set(HeaderETag, ETag(userResp.Version))
 	return c.JSON(http.StatusCreated, map[string]string{"result": "Update Success"})
 }
 
-//DeleteUserEndPoint is DeleteUserEndPoint
+//DeleteUserEndPoint is DeleteUserEndPoint, User is deleted only after a different admin approves it
 func (m *DataObjectAccess) DeleteUserEndPoint(c echo.Context) (err error) {
 	user, err := m.userService.FindByIDUser(c.Param("id"))
 	if err != nil {
 		return err
 	}
-	userResp, err := m.userService.DeleteUser(user)
+	approval, err := m.approvalService.RequestDeleteUser(user, AuthUserID(c))
 	if err != nil {
-		return err
+		return MapHTTPError(err)
 	}
-	PrintLog(userResp)
-	return c.JSON(http.StatusOK, map[string]string{"result": "Delete Success"})
+	PrintLog(approval)
+	return c.JSON(http.StatusAccepted, MapJSONApproval(approval))
 }
 
 //CreateBankAccountEndPoint is CreateBankAccountEndPoint
 func (m *DataObjectAccess) CreateBankAccountEndPoint(c echo.Context) (err error) {
-	users, err := m.userService.FindAllUser()
-	if err != nil {
-		return err
-	}
-
 	user, err := m.userService.FindByIDUser(c.Param("id"))
 	if err != nil {
 		return err
@@ -505,12 +978,12 @@ func (m *DataObjectAccess) CreateBankAccountEndPoint(c echo.Context) (err error)
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("json: wrong params: %s", err))
 	}
 
-	userResp, err := m.bankAccountService.CreateBankAccount(b, user, users)
+	bankAccountResp, err := m.bankAccountService.CreateBankAccount(b, user)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	PrintLog(userResp)
+	PrintLog(bankAccountResp)
 	return c.JSON(http.StatusOK, map[string]string{"result": "Create Success"})
 }
 
@@ -525,19 +998,34 @@ func (m *DataObjectAccess) FindAllBankAccountEndPoint(c echo.Context) (err error
 	return c.JSON(http.StatusOK, MapJSONBankAccount(bankAccountResp))
 }
 
-//DeleteBankAccountEndPoint is DeleteBankAccountEndPoint
+//FindBankAccountEndPoint is FindBankAccountEndPoint, ETag is Version of BankAccount for If-Match of deposit and withdraw
+func (m *DataObjectAccess) FindBankAccountEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	bankAccountResp, err := m.bankAccountService.FindBankAccount(user, c.Param("idBankAccount"))
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(bankAccountResp)
+	c.Response().Header().Set(HeaderETag, ETag(bankAccountResp.Version))
+	return c.JSON(http.StatusOK, MapJSONBankAccount(bankAccountResp))
+}
+
+//DeleteBankAccountEndPoint is DeleteBankAccountEndPoint, BankAccount is deleted only after an admin approves it
 func (m *DataObjectAccess) DeleteBankAccountEndPoint(c echo.Context) (err error) {
 	user, err := m.userService.FindByIDUser(c.Param("id"))
 	if err != nil {
 		return err
 	}
 
-	bankAccountResp, err := m.bankAccountService.DeleteBankAccount(user, c.Param("idBankAccount"))
+	approval, err := m.approvalService.RequestDeleteBankAccount(user, c.Param("idBankAccount"), AuthUserID(c))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return MapHTTPError(err)
 	}
-	PrintLog(bankAccountResp)
-	return c.JSON(http.StatusOK, map[string]string{"result": "Delete Success"})
+	PrintLog(approval)
+	return c.JSON(http.StatusAccepted, MapJSONApproval(approval))
 }
 
 //DepositBankAccountEndPoint is DepositBankAccountEndPoint
@@ -552,12 +1040,17 @@ func (m *DataObjectAccess) DepositBankAccountEndPoint(c echo.Context) (err error
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("json: wrong params: %s", err))
 	}
 
-	bankAccountResp, err := m.bankAccountService.DepositBankAccount(t, user, c.Param("idBankAccount"))
+	version, err := ParseIfMatch(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	bankAccountResp, err := m.bankAccountService.DepositBankAccount(t, user, c.Param("idBankAccount"), version)
+	if err != nil {
+		return MapCommittedHTTPError(c, err)
 	}
 
 	PrintLog(bankAccountResp)
+	c.Response().Header().Set(HeaderETag, ETag(bankAccountResp.Version))
 	return c.JSON(http.StatusOK, map[string]string{"result": "Deposit Success"})
 }
 
@@ -573,12 +1066,17 @@ func (m *DataObjectAccess) WithDrawBankAccountEndPoint(c echo.Context) (err erro
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("json: wrong params: %s", err))
 	}
 
-	bankAccountResp, err := m.bankAccountService.WithdrawBankAccount(t, user, c.Param("idBankAccount"))
+	version, err := ParseIfMatch(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	bankAccountResp, err := m.bankAccountService.WithdrawBankAccount(t, user, c.Param("idBankAccount"), version)
+	if err != nil {
+		return MapCommittedHTTPError(c, err)
 	}
 
 	PrintLog(bankAccountResp)
+	c.Response().Header().Set(HeaderETag, ETag(bankAccountResp.Version))
 	return c.JSON(http.StatusOK, map[string]string{"result": "Withdraw Success"})
 }
 
@@ -589,35 +1087,70 @@ func (m *DataObjectAccess) TranfersEndPoint(c echo.Context) (err error) {
 		return err
 	}
 
-	userTo, err := m.userService.FindByIDUser(c.Param("idTo"))
-	if err != nil {
-		return err
-	}
-
 	t := new(model.Tranfer)
 	if err := c.Bind(t); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("json: wrong params: %s", err))
 	}
 
-	userResp, err := m.tranferService.Tranfer(t, userFrom, userTo)
+	needsApproval, err := m.approvalService.NeedsApproval(t, userFrom)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return MapCommittedHTTPError(c, err)
+	}
+	if needsApproval {
+		approval, err := m.approvalService.RequestTranfer(t, userFrom, AuthUserID(c))
+		if err != nil {
+			return MapCommittedHTTPError(c, err)
+		}
+		PrintLog(approval)
+		return c.JSON(http.StatusAccepted, MapJSONApproval(approval))
 	}
 
-	PrintLog(userResp)
+	tranferResp, err := m.tranferService.Tranfer(t, userFrom)
+	if err != nil {
+		return MapCommittedHTTPError(c, err)
+	}
+
+	PrintLog(tranferResp)
 	return c.JSON(http.StatusOK, map[string]string{"result": "Tranfer Success"})
 }
 
-//ValidateUser for check username and password
-func (m *DataObjectAccess) ValidateUser(username, password string, c echo.Context) (bool, error) {
-	user, err := m.userService.FindByIDUser(c.Param("id"))
+//checkFunds for check Balance after withdraw or Tranfer is not below OverdraftLimit
+func checkFunds(bankAccount model.BankAccount) error {
+	available, err := bankAccount.Balance.Add(bankAccount.OverdraftLimit)
 	if err != nil {
-		return false, err
+		return err
 	}
-	if user.Username == username && user.Password == password {
-		return true, nil
+	if available.IsNegative() {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+//MapHTTPError for map domain error to HTTP status
+func MapHTTPError(err error) error {
+	switch err {
+	case ErrInsufficientFunds, ErrInvalidAmount, model.ErrCurrencyUnknown, model.ErrCurrencyMismatch, model.ErrMoneyDecimals,
+		fx.ErrRateNotFound, fx.ErrRateFormat, ErrProductUnknown, ErrFeeKindUnknown,
+		ErrWithdrawLimit, ErrTranferDailyLimit, ErrTranferCountLimit, ErrLimitRaised, ErrLimitNegative, model.ErrMoneyFormat,
+		schedule.ErrKind, schedule.ErrDate, schedule.ErrWeekday, schedule.ErrDay, ErrScheduleEnded, ErrStandingOrderCount, ErrStandingOrderState,
+		ErrApprovalRequired, ErrStatementFormat, ErrStatementPeriod, ErrBulkPaymentEmpty, ErrBulkPaymentTooLarge, ErrImportHeader,
+		model.ErrRoleUnknown:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case ErrTranferSameAccount:
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case ErrInvalidCredentials, ErrInvalidRefreshToken:
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	case ErrApprovalSelf:
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case ErrBankAccountNotFound, ErrBankAccountToNotFound, ErrApprovalNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case ErrPreconditionFailed:
+		return echo.NewHTTPError(http.StatusPreconditionFailed, err.Error())
+	case repository.ErrConflict, ErrBulkPaymentDuplicate, ErrApprovalDecided, ErrApprovalExpired, ErrApprovalPending,
+		ErrBankAccountNotEmpty:
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
 	}
-	return false, nil
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 }
 
 //PrintLog for GetLog
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bankaccountapi/fx"
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+func TestMain(m *testing.M) {
+	//config.toml has no jwt_secret, it comes from JWT_SECRET when the server runs
+	config.JWTSecret = "jwt secret of tests"
+	os.Exit(m.Run())
+}
+
+//testStores for every Store services are tested against, SQLite is in a file of its own for each test
+func testStores(t *testing.T) map[string]*repository.Store {
+	t.Helper()
+	sqliteStore, db, err := openSQL(repository.DialectSQLite, filepath.Join(t.TempDir(), "bankaccount.db")+"?_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return map[string]*repository.Store{
+		"memory": repository.NewMemoryStore(),
+		"sqlite": sqliteStore,
+	}
+}
+
+//newTestDAO for every service wired to store with products, fees and limits of config.toml
+func newTestDAO(t *testing.T, store *repository.Store) *DataObjectAccess {
+	t.Helper()
+	rates, err := fx.NewFileRateProvider(config.RatesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	products, err := LoadProducts(config.Products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fees, err := LoadFees(config.Products, config.FeeIncomeAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewDataObjectAccess(store, rates, products, fees)
+}
+
+func testMoney(t *testing.T, value string) model.Money {
+	t.Helper()
+	money, err := model.ParseMoney(value, model.DefaultCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return money
+}
+
+//createTestUser for User of username with roles
+func createTestUser(t *testing.T, d *DataObjectAccess, username string, roles ...model.Role) model.User {
+	t.Helper()
+	user, err := d.userService.InsertUser(&model.User{
+		FirstName: "First " + username,
+		LastName:  "Last " + username,
+		Username:  username,
+		Password:  "password of " + username,
+		IDcard:    "1100700000000",
+		Age:       30,
+		Email:     username + "@example.com",
+		Tel:       "0812345678",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) > 0 {
+		if _, err := d.userService.SetRoles(*user, roles, AnyVersion); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return findTestUser(t, d, user.ID.Hex())
+}
+
+func findTestUser(t *testing.T, d *DataObjectAccess, id string) model.User {
+	t.Helper()
+	user, err := d.userService.FindByIDUser(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+//createTestBankAccount for BankAccount of accountNumber in THB with balance, user is read again after it
+func createTestBankAccount(t *testing.T, d *DataObjectAccess, user *model.User, accountNumber string, balance string) model.BankAccount {
+	t.Helper()
+	bankAccount, err := d.bankAccountService.CreateBankAccount(&model.BankAccount{
+		BankName:      "KBank",
+		AccountNumber: accountNumber,
+		Balance:       testMoney(t, balance),
+	}, *user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*user = findTestUser(t, d, user.ID.Hex())
+	return *bankAccount
+}
+
+func balanceOf(t *testing.T, store *repository.Store, accountNumber string) string {
+	t.Helper()
+	bankAccount, err := store.BankAccounts.FindByAccountNumber(accountNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bankAccount.Balance.String()
+}
+
+func TestDepositParallel(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			user := createTestUser(t, d, "alice")
+			bankAccount := createTestBankAccount(t, d, &user, "111-1", "1")
+
+			const deposits = 1000
+			var wg sync.WaitGroup
+			errs := make(chan error, deposits)
+			for i := 0; i < deposits; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := d.bankAccountService.DepositBankAccount(&model.Transaction{Amount: testMoney(t, "1")}, user, bankAccount.ID.Hex(), AnyVersion)
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "1001.00" {
+				t.Fatalf("balance is %s after %d deposits, want 1001.00", balance, deposits)
+			}
+			transactionLogs, err := store.Transactions.FindByBankAccount(bankAccount.ID, model.TransactionFilter{Page: 1, Limit: 2 * deposits})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(transactionLogs) != deposits {
+				t.Fatalf("ledger has %d deposits, want %d", len(transactionLogs), deposits)
+			}
+		})
+	}
+}
+
+func TestTranferParallelNeverOverdraws(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			bankAccount := createTestBankAccount(t, d, &alice, "111-1", "100")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+
+			//every Tranfer and withdraw sees Balance 100 before it starts, only 10 of them fit
+			const attempts = 40
+			var wg sync.WaitGroup
+			errs := make(chan error, attempts)
+			for i := 0; i < attempts; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					var err error
+					if i%2 == 0 {
+						_, err = d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "10"), From: "111-1", To: "222-2"}, alice)
+					} else {
+						_, err = d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "10")}, alice, bankAccount.ID.Hex(), AnyVersion)
+					}
+					errs <- err
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			succeeded := 0
+			for err := range errs {
+				switch err {
+				case nil:
+					succeeded++
+				case ErrInsufficientFunds:
+				default:
+					t.Fatal(err)
+				}
+			}
+			if succeeded != 10 {
+				t.Fatalf("%d of %d succeeded, want 10", succeeded, attempts)
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "0.00" {
+				t.Fatalf("balance of 111-1 is %s, want 0.00", balance)
+			}
+		})
+	}
+}
+
+func TestFeeMovesWithWhatItIsChargedFor(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			flat := model.FeeSchedule{Type: model.FeeTypeFlat, Amount: "5"}
+			d.tranferService.(*TranferServiceImplement).fees.fees[model.ProductCurrent] = model.ProductFees{
+				model.FeeKindWithdraw: flat,
+				model.FeeKindTranfer:  flat,
+			}
+			d.tranferService.(*TranferServiceImplement).feeIncomeAccount = "999-9"
+			bank := createTestUser(t, d, "bank")
+			createTestBankAccount(t, d, &bank, "999-9", "1")
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			bankAccount := createTestBankAccount(t, d, &alice, "111-1", "30")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+
+			//fee does not fit, neither Amount nor fee leaves
+			_, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "28"), From: "111-1", To: "222-2"}, alice)
+			if err != ErrInsufficientFunds {
+				t.Fatalf("tranfer without funds for fee: %v, want %v", err, ErrInsufficientFunds)
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "30.00" {
+				t.Fatalf("balance of 111-1 is %s after refused tranfer, want 30.00", balance)
+			}
+
+			_, err = d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "10"), From: "111-1", To: "222-2"}, alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			withdrawn, err := d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "10")}, alice, bankAccount.ID.Hex(), AnyVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if withdrawn.Balance.String() != "0.00" {
+				t.Fatalf("withdraw returned balance %s, want 0.00", withdrawn.Balance)
+			}
+			for accountNumber, want := range map[string]string{"111-1": "0.00", "222-2": "11.00", "999-9": "11.00"} {
+				if balance := balanceOf(t, store, accountNumber); balance != want {
+					t.Fatalf("balance of %s is %s, want %s", accountNumber, balance, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFeeFreePerMonthCountsLedger(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			d.tranferService.(*TranferServiceImplement).fees.fees[model.ProductCurrent] = model.ProductFees{
+				model.FeeKindWithdraw: {Type: model.FeeTypeFlat, Amount: "5", FreePerMonth: 2},
+				model.FeeKindTranfer:  {Type: model.FeeTypeFlat, Amount: "3", FreePerMonth: 1},
+			}
+			d.tranferService.(*TranferServiceImplement).feeIncomeAccount = "999-9"
+			bank := createTestUser(t, d, "bank")
+			createTestBankAccount(t, d, &bank, "999-9", "1")
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			bankAccount := createTestBankAccount(t, d, &alice, "111-1", "100")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+			id := bankAccount.ID.Hex()
+
+			quote := func(kind model.FeeKind) model.FeeQuote {
+				t.Helper()
+				feeQuote, err := d.feeService.QuoteFee(alice, id, kind, testMoney(t, "10"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return *feeQuote
+			}
+			//withdraws and tranfers are counted apart, only withdraw of the ledger counts for withdraw fee
+			for i, want := range []struct {
+				fee           string
+				freeRemaining int
+			}{{"0.00", 2}, {"0.00", 1}, {"5.00", 0}} {
+				if feeQuote := quote(model.FeeKindWithdraw); feeQuote.Fee.String() != want.fee || feeQuote.FreeRemaining != want.freeRemaining {
+					t.Fatalf("withdraw %d is quoted %s with %d free, want %s with %d free", i+1, feeQuote.Fee, feeQuote.FreeRemaining, want.fee, want.freeRemaining)
+				}
+				if _, err := d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "10")}, alice, id, AnyVersion); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if feeQuote := quote(model.FeeKindTranfer); feeQuote.Fee.String() != "0.00" || feeQuote.FreeRemaining != 1 {
+				t.Fatalf("first tranfer is quoted %s with %d free, want it free", feeQuote.Fee, feeQuote.FreeRemaining)
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "10"), From: "111-1", To: "222-2"}, alice); err != nil {
+					t.Fatal(err)
+				}
+			}
+			//100 - 3 withdraws of 10 - fee 5 - 2 tranfers of 10 - fee 3
+			for accountNumber, want := range map[string]string{"111-1": "42.00", "222-2": "21.00", "999-9": "9.00"} {
+				if balance := balanceOf(t, store, accountNumber); balance != want {
+					t.Fatalf("balance of %s is %s, want %s", accountNumber, balance, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeepsKeyOnceWritten(t *testing.T) {
+	store := repository.NewMemoryStore()
+	e := echo.New()
+	calls := 0
+	e.POST("/written", func(c echo.Context) error {
+		calls++
+		return MapCommittedHTTPError(c, committed(errors.New("ledger is down")))
+	}, Idempotency(store.IdempotencyKeys, time.Hour))
+	e.POST("/unwritten", func(c echo.Context) error {
+		calls++
+		return MapCommittedHTTPError(c, errors.New("database is down"))
+	}, Idempotency(store.IdempotencyKeys, time.Hour))
+
+	send := func(path string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		req.Header.Set(HeaderIdempotencyKey, "key-"+path)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for i := 0; i < 2; i++ {
+		if code := send("/written"); code != http.StatusInternalServerError {
+			t.Fatalf("written request returned %d, want 500", code)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("request that wrote ran %d times, want it replayed", calls)
+	}
+	calls = 0
+	for i := 0; i < 2; i++ {
+		send("/unwritten")
+	}
+	if calls != 2 {
+		t.Fatalf("request that wrote nothing ran %d times, want it run again", calls)
+	}
+}
+
+func TestBankAccountVersionIsWhatWithdrawComparesTo(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	alice := createTestUser(t, d, "alice")
+	created := createTestBankAccount(t, d, &alice, "111-1", "100")
+
+	bankAccount, err := d.bankAccountService.FindBankAccount(alice, created.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "10")}, alice, created.ID.Hex(), bankAccount.Version)
+	if err != nil {
+		t.Fatalf("withdraw with Version of FindBankAccount: %v", err)
+	}
+	_, err = d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "10")}, alice, created.ID.Hex(), bankAccount.Version)
+	if err != ErrPreconditionFailed {
+		t.Fatalf("withdraw with stale Version: %v, want %v", err, ErrPreconditionFailed)
+	}
+	if balance := balanceOf(t, store, "111-1"); balance != "90.00" {
+		t.Fatalf("balance is %s, want 90.00", balance)
+	}
+}
+
+//errCrash is injected error that stops the server in the middle of what it is doing
+var errCrash = errors.New("server stopped")
+
+//crash for run f until it stops the server with errCrash, nothing after it in f runs
+func crash(t *testing.T, f func()) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != errCrash {
+			t.Fatalf("got %v, want server stopped by %v", r, errCrash)
+		}
+	}()
+	f()
+}
+
+//faultyBankAccounts is BankAccountRepository that fails ApplyTranfer or FinishTranfer of an account
+//while its error is set, the call that fails changes nothing
+type faultyBankAccounts struct {
+	repository.BankAccountRepository
+	mu        sync.Mutex
+	applyErr  map[string]error
+	finishErr map[string]error
+}
+
+func newFaultyBankAccounts(store *repository.Store) *faultyBankAccounts {
+	faulty := &faultyBankAccounts{
+		BankAccountRepository: store.BankAccounts,
+		applyErr:              map[string]error{},
+		finishErr:             map[string]error{},
+	}
+	store.BankAccounts = faulty
+	return faulty
+}
+
+func (f *faultyBankAccounts) fail(errs map[string]error, accountNumber string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	errs[accountNumber] = err
+}
+
+//errOf for error injected for accountNumber, errCrash stops the server right there
+func (f *faultyBankAccounts) errOf(errs map[string]error, accountNumber string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if errs[accountNumber] == errCrash {
+		panic(errCrash)
+	}
+	return errs[accountNumber]
+}
+
+func (f *faultyBankAccounts) ApplyTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money, check func(bankAccount model.BankAccount) error) (model.BankAccount, error) {
+	if err := f.errOf(f.applyErr, accountNumber); err != nil {
+		return model.BankAccount{}, err
+	}
+	return f.BankAccountRepository.ApplyTranfer(tranferID, accountNumber, amount, check)
+}
+
+func (f *faultyBankAccounts) FinishTranfer(tranferID bson.ObjectId, accountNumber string) error {
+	if err := f.errOf(f.finishErr, accountNumber); err != nil {
+		return err
+	}
+	return f.BankAccountRepository.FinishTranfer(tranferID, accountNumber)
+}
+
+func TestTranferReleasesLimitOnlyWhenCancelled(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			faulty := newFaultyBankAccounts(store)
+			d := newTestDAO(t, store)
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			bankAccount := createTestBankAccount(t, d, &alice, "111-1", "100")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+			businessDate := BusinessDay(time.Now()).Format(BusinessDateFormat)
+			usageIs := func(when string, count int, total int64) {
+				t.Helper()
+				for _, ownerID := range []bson.ObjectId{alice.ID, bankAccount.ID} {
+					usage, err := store.Limits.FindUsage(ownerID, businessDate)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if usage.TranferCount != count || usage.TranferTotal != total {
+						t.Fatalf("usage of %s is %d tranfers of %d %s, want %d of %d", ownerID.Hex(), usage.TranferCount, usage.TranferTotal, when, count, total)
+					}
+				}
+			}
+
+			//cancelled by insufficient funds and cancelled after debit was rolled back
+			faulty.fail(faulty.applyErr, "222-2", errors.New("disk is full"))
+			for _, amount := range []string{"1000", "10"} {
+				if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, amount), From: "111-1", To: "222-2"}, alice); err == nil {
+					t.Fatalf("tranfer of %s succeeded", amount)
+				}
+			}
+			faulty.fail(faulty.applyErr, "222-2", nil)
+			usageIs("after cancelled tranfers", 0, 0)
+
+			//failed after money moved, RecoverTranfer finishes it so it still counts
+			faulty.fail(faulty.finishErr, "111-1", errors.New("disk is full"))
+			_, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "10"), From: "111-1", To: "222-2"}, alice)
+			if _, ok := err.(committedError); !ok {
+				t.Fatalf("tranfer that could not finish got %v, want committed error", err)
+			}
+			faulty.fail(faulty.finishErr, "111-1", nil)
+			usageIs("after tranfer failed past its debit", 1, 1000)
+			if err := d.tranferService.RecoverTranfer(); err != nil {
+				t.Fatal(err)
+			}
+			usageIs("after recovery", 1, 1000)
+		})
+	}
+}
+
+func TestFindAllUserMasksPIIForTeller(t *testing.T) {
+	d := newTestDAO(t, repository.NewMemoryStore())
+	createTestUser(t, d, "alice")
+	e := echo.New()
+
+	list := func(roles []model.Role, query string) (int, string) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/v1/users"+query, nil), rec)
+		c.Set(ContextRoles, roles)
+		err := d.FindAllUserEndPoint(c)
+		if he, ok := err.(*echo.HTTPError); ok {
+			return he.Code, ""
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, rec.Body.String()
+	}
+	if _, body := list([]model.Role{model.RoleAdmin}, ""); !strings.Contains(body, "alice@example.com") {
+		t.Fatalf("admin does not see email: %s", body)
+	}
+	code, body := list([]model.Role{model.RoleTeller}, "?email=alice@example.com")
+	if code != http.StatusOK || strings.Contains(body, "alice@example.com") || strings.Contains(body, "0812345678") || !strings.Contains(body, "alice") {
+		t.Fatalf("teller gets %d: %s, want alice with email and tel masked", code, body)
+	}
+	if code, _ := list([]model.Role{model.RoleCustomer}, "?tel=0812345678"); code != http.StatusForbidden {
+		t.Fatalf("customer finding by tel gets %d, want 403", code)
+	}
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//ApprovalKind is operation that waits for Approval
+type ApprovalKind string
+
+const (
+	//ApprovalTranfer is Tranfer above approval_tranfer_threshold
+	ApprovalTranfer ApprovalKind = "tranfer"
+	//ApprovalDeleteBankAccount is delete of BankAccount
+	ApprovalDeleteBankAccount ApprovalKind = "delete_bank_account"
+	//ApprovalDeleteUser is delete of User and every BankAccount of them
+	ApprovalDeleteUser ApprovalKind = "delete_user"
+)
+
+//ApprovalState is state of Approval
+type ApprovalState string
+
+const (
+	//ApprovalPending is Approval waiting for checker until ExpiresAt
+	ApprovalPending ApprovalState = "pending"
+	//ApprovalExecuting is Approval approved by checker whose operation is running, Approval left executing
+	//when the server stopped is finished by RecoverApprovals
+	ApprovalExecuting ApprovalState = "executing"
+	//ApprovalDone is Approval whose operation is done
+	ApprovalDone ApprovalState = "done"
+	//ApprovalFailed is Approval whose operation failed after it was approved, Error is why
+	ApprovalFailed ApprovalState = "failed"
+	//ApprovalRejected is Approval rejected by checker, Reason is why
+	ApprovalRejected ApprovalState = "rejected"
+	//ApprovalExpired is Approval nobody approved or rejected before ExpiresAt
+	ApprovalExpired ApprovalState = "expired"
+)
+
+//ApprovalStates is every ApprovalState
+var ApprovalStates = []ApprovalState{ApprovalPending, ApprovalExecuting, ApprovalDone, ApprovalFailed, ApprovalRejected, ApprovalExpired}
+
+//Approval is model of operation asked for by maker that runs only after a different checker approves it.
+//UserID is user the operation is for, BankAccountID is set for ApprovalDeleteBankAccount and Tranfer for ApprovalTranfer
+type Approval struct {
+	ID            bson.ObjectId `bson:"_id" json:"id"`
+	Kind          ApprovalKind  `bson:"kind" json:"kind"`
+	State         ApprovalState `bson:"state" json:"state"`
+	MakerID       bson.ObjectId `bson:"maker_id" json:"maker_id"`
+	CheckerID     bson.ObjectId `bson:"checker_id,omitempty" json:"checker_id,omitempty"`
+	UserID        bson.ObjectId `bson:"user_id" json:"user_id"`
+	BankAccountID bson.ObjectId `bson:"bank_account_id,omitempty" json:"bank_account_id,omitempty"`
+	Tranfer       *Tranfer      `bson:"tranfer,omitempty" json:"tranfer,omitempty"`
+	TranferID     bson.ObjectId `bson:"tranfer_id,omitempty" json:"tranfer_id,omitempty"` //TranferLog made when Tranfer ran
+	Reason        string        `bson:"reason,omitempty" json:"reason,omitempty"`
+	Error         string        `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt     time.Time     `bson:"created_at" json:"created_at"`
+	ExpiresAt     time.Time     `bson:"expires_at" json:"expires_at"`
+	LastModified  time.Time     `bson:"last_modified" json:"last_modified"`
+}
+
+//AuditAction is what happened to Approval
+type AuditAction string
+
+const (
+	//AuditRequested is Approval made by maker
+	AuditRequested AuditAction = "requested"
+	//AuditApproved is Approval approved by checker
+	AuditApproved AuditAction = "approved"
+	//AuditRejected is Approval rejected by checker
+	AuditRejected AuditAction = "rejected"
+	//AuditExpired is Approval expired, it has no ActorID
+	AuditExpired AuditAction = "expired"
+	//AuditExecuted is operation of Approval done
+	AuditExecuted AuditAction = "executed"
+	//AuditFailed is operation of Approval failed
+	AuditFailed AuditAction = "failed"
+)
+
+//AuditEntry is model of one thing that happened to Approval, it is never changed after it is stored.
+//ActorID is user who did it, empty when the server did it
+type AuditEntry struct {
+	ID         bson.ObjectId `bson:"_id" json:"id"`
+	ApprovalID bson.ObjectId `bson:"approval_id" json:"approval_id"`
+	Action     AuditAction   `bson:"action" json:"action"`
+	ActorID    bson.ObjectId `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	Detail     string        `bson:"detail,omitempty" json:"detail,omitempty"`
+	CreatedAt  time.Time     `bson:"created_at" json:"created_at"`
+}
+
+//ApprovalDecision is body of reject of Approval
+type ApprovalDecision struct {
+	Reason string `json:"reason"`
+}
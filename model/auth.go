@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//Login is model
+type Login struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+//RefreshTokenRequest is model
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+//Token is model of access token and refresh token sent back after login
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+//RefreshToken is model of stored refresh token, ID is sha256 of the token so the token itself is never stored.
+//Every refresh token from one login share Family, reuse of a rotated token revokes the whole Family
+type RefreshToken struct {
+	ID        string        `bson:"_id"`
+	UserID    bson.ObjectId `bson:"user_id"`
+	Family    bson.ObjectId `bson:"family"`
+	Revoked   bool          `bson:"revoked"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+	CreatedAt time.Time     `bson:"created_at"`
+}
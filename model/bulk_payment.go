@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//BulkPaymentState is state of BulkPayment
+type BulkPaymentState string
+
+const (
+	//BulkPaymentReceived is BulkPayment validated and waiting to run
+	BulkPaymentReceived BulkPaymentState = "received"
+	//BulkPaymentProcessing is BulkPayment running its lines one by one
+	BulkPaymentProcessing BulkPaymentState = "processing"
+	//BulkPaymentCompleted is BulkPayment with every line done
+	BulkPaymentCompleted BulkPaymentState = "completed"
+	//BulkPaymentPartiallyCompleted is BulkPayment finished with some lines not done, or with line of unknown outcome
+	BulkPaymentPartiallyCompleted BulkPaymentState = "partially_completed"
+	//BulkPaymentRejected is BulkPayment finished with every line rejected
+	BulkPaymentRejected BulkPaymentState = "rejected"
+)
+
+//BulkPaymentLineStatus is status of BulkPaymentLine
+type BulkPaymentLineStatus string
+
+const (
+	//BulkPaymentLinePending is line validated and waiting for its Tranfer
+	BulkPaymentLinePending BulkPaymentLineStatus = "pending"
+	//BulkPaymentLineExecuting is line claimed by the run making its Tranfer
+	BulkPaymentLineExecuting BulkPaymentLineStatus = "executing"
+	//BulkPaymentLineDone is line whose Tranfer is done
+	BulkPaymentLineDone BulkPaymentLineStatus = "done"
+	//BulkPaymentLineRejected is line that failed validation or whose Tranfer failed
+	BulkPaymentLineRejected BulkPaymentLineStatus = "rejected"
+	//BulkPaymentLineUnknown is line that was executing without TranferID when the server stopped,
+	//its Tranfer may or may not be done so it must be checked in ledger and is never run again
+	BulkPaymentLineUnknown BulkPaymentLineStatus = "unknown"
+)
+
+//BulkPayment is model of one pain.001 customer credit transfer initiation uploaded by User,
+//each credit transfer of it is one BulkPaymentLine run as Tranfer of User
+type BulkPayment struct {
+	ID                   bson.ObjectId     `bson:"_id" json:"id"`
+	UserID               bson.ObjectId     `bson:"user_id" json:"user_id"`
+	MessageID            string            `bson:"message_id" json:"message_id"` //MsgId of pain.001, unique per User
+	MessageCreatedAt     string            `bson:"message_created_at" json:"message_created_at"`
+	NumberOfTransactions string            `bson:"number_of_transactions" json:"number_of_transactions"`
+	ControlSum           string            `bson:"control_sum,omitempty" json:"control_sum,omitempty"`
+	State                BulkPaymentState  `bson:"state" json:"state"`
+	ReasonCode           string            `bson:"reason_code,omitempty" json:"reason_code,omitempty"` //ISO 20022 status reason when whole file is rejected
+	Reason               string            `bson:"reason,omitempty" json:"reason,omitempty"`
+	Lines                []BulkPaymentLine `bson:"lines" json:"lines"`
+	CreatedAt            time.Time         `bson:"created_at" json:"created_at"`
+	LastModified         time.Time         `bson:"last_modified" json:"last_modified"`
+}
+
+//BulkPaymentLine is model of one credit transfer of BulkPayment, Line counts from 1 in order of the file
+type BulkPaymentLine struct {
+	Line                 int                   `bson:"line" json:"line"`
+	PaymentInformationID string                `bson:"payment_information_id" json:"payment_information_id"`
+	InstructionID        string                `bson:"instruction_id,omitempty" json:"instruction_id,omitempty"`
+	EndToEndID           string                `bson:"end_to_end_id" json:"end_to_end_id"`
+	AccountNumberFrom    string                `bson:"account_number_from" json:"from"`
+	AccountNumberTo      string                `bson:"account_number_to" json:"to"`
+	Amount               Money                 `bson:"amount" json:"amount"`
+	Status               BulkPaymentLineStatus `bson:"status" json:"status"`
+	ReasonCode           string                `bson:"reason_code,omitempty" json:"reason_code,omitempty"` //ISO 20022 status reason like AM04
+	Reason               string                `bson:"reason,omitempty" json:"reason,omitempty"`
+	TranferID            bson.ObjectId         `bson:"tranfer_id,omitempty" json:"tranfer_id,omitempty"`
+	LastModified         time.Time             `bson:"last_modified" json:"last_modified"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+//ExchangeRate is model of how many To one From buys, Rate is decimal string like "35.125"
+type ExchangeRate struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      string    `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import "github.com/globalsign/mgo/bson"
+
+//FeeKind is what BankAccount pays fee for
+type FeeKind string
+
+const (
+	//FeeKindWithdraw is fee of withdraw
+	FeeKindWithdraw FeeKind = "withdraw"
+	//FeeKindTranfer is fee of Tranfer paid by BankAccount From
+	FeeKindTranfer FeeKind = "tranfer"
+)
+
+//FeeType is how fee is calculated from amount
+type FeeType string
+
+const (
+	//FeeTypeNone is no fee
+	FeeTypeNone FeeType = ""
+	//FeeTypeFlat is the same Amount whatever amount is
+	FeeTypeFlat FeeType = "flat"
+	//FeeTypePercent is Percent of amount between Minimum and Maximum
+	FeeTypePercent FeeType = "percent"
+	//FeeTypeTiered is Amount plus Percent of the first FeeTier amount is not above
+	FeeTypeTiered FeeType = "tiered"
+)
+
+//FeeSchedule is fee of one FeeKind of Product, Amount, Minimum, Maximum and UpTo are decimal
+//in Currency of BankAccount and Percent is percent like "0.25". The first FreePerMonth of a month are free
+type FeeSchedule struct {
+	Type         FeeType   `toml:"type" json:"type"`
+	Amount       string    `toml:"amount" json:"amount,omitempty"`
+	Percent      string    `toml:"percent" json:"percent,omitempty"`
+	Minimum      string    `toml:"minimum" json:"minimum,omitempty"`
+	Maximum      string    `toml:"maximum" json:"maximum,omitempty"`
+	Tiers        []FeeTier `toml:"tiers" json:"tiers,omitempty"`
+	FreePerMonth int       `toml:"free_per_month" json:"free_per_month,omitempty"`
+}
+
+//FeeTier is one tier of FeeTypeTiered, the last FeeTier may have no UpTo
+type FeeTier struct {
+	UpTo    string `toml:"up_to" json:"up_to,omitempty"`
+	Amount  string `toml:"amount" json:"amount,omitempty"`
+	Percent string `toml:"percent" json:"percent,omitempty"`
+}
+
+//ProductFees is FeeSchedule of every FeeKind of Product
+type ProductFees map[FeeKind]FeeSchedule
+
+//FeeQuote is model of fee BankAccount would pay for Amount
+type FeeQuote struct {
+	BankAccountID bson.ObjectId `json:"bank_account_id"`
+	Kind          FeeKind       `json:"kind"`
+	Amount        Money         `json:"amount"`
+	Fee           Money         `json:"fee"`
+	FreeRemaining int           `json:"free_remaining"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+//IdempotencyKey is model of stored Idempotency-Key header and the response sent for it.
+//ID is sha256 of user and key so keys of different users never clash,
+//RequestHash is sha256 of method, path and body of the first request
+type IdempotencyKey struct {
+	ID          string    `bson:"_id"`
+	RequestHash string    `bson:"request_hash"`
+	Completed   bool      `bson:"completed"`
+	Status      int       `bson:"status"`
+	ContentType string    `bson:"content_type"`
+	Body        []byte    `bson:"body"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
@@ -0,0 +1,34 @@
+package model
+
+//ImportResult is what bulk import did with one row of CSV
+type ImportResult string
+
+const (
+	//ImportCreated is row whose User or BankAccount is stored
+	ImportCreated ImportResult = "created"
+	//ImportValid is row that passed every check in dry run, nothing is stored
+	ImportValid ImportResult = "valid"
+	//ImportRejected is row that failed a check, Error says which
+	ImportRejected ImportResult = "rejected"
+	//ImportSkipped is row not stored because another row of the same User was rejected
+	ImportSkipped ImportResult = "skipped"
+)
+
+//ImportRow is report of one row of CSV of bulk import, Row counts from 1 after the header
+type ImportRow struct {
+	Row           int          `json:"row"`
+	Username      string       `json:"username"`
+	AccountNumber string       `json:"account_number,omitempty"`
+	Result        ImportResult `json:"result"`
+	Error         string       `json:"error,omitempty"`
+}
+
+//ImportSummary is count of rows of bulk import and what was stored
+type ImportSummary struct {
+	DryRun       bool `json:"dry_run"`
+	Rows         int  `json:"rows"`
+	Users        int  `json:"users"`
+	BankAccounts int  `json:"bank_accounts"`
+	Rejected     int  `json:"rejected"`
+	Skipped      int  `json:"skipped"`
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//Product is kind of BankAccount, it decides InterestRate and DayCount of BankAccount
+type Product string
+
+const (
+	//ProductSavings is BankAccount that earns interest
+	ProductSavings Product = "savings"
+	//ProductCurrent is BankAccount for payment, it earns no interest by default
+	ProductCurrent Product = "current"
+	//ProductFixedDeposit is BankAccount for money kept for a term
+	ProductFixedDeposit Product = "fixed_deposit"
+)
+
+//DayCount is convention for how much of a year one day of interest is
+type DayCount string
+
+const (
+	//DayCountACT365 is actual days over 365, February 29 earns one more day in leap year
+	DayCountACT365 DayCount = "ACT/365"
+	//DayCount30360 is every month as 30 days over 360
+	DayCount30360 DayCount = "30/360"
+)
+
+//InterestProduct is interest of Product, InterestRate is yearly percent like "1.25"
+type InterestProduct struct {
+	InterestRate string   `json:"interest_rate"`
+	DayCount     DayCount `json:"day_count"`
+}
+
+//DefaultProducts is InterestProduct of every Product when it is not in config.toml
+var DefaultProducts = map[Product]InterestProduct{
+	ProductSavings:      {InterestRate: "0.50", DayCount: DayCountACT365},
+	ProductCurrent:      {InterestRate: "0", DayCount: DayCountACT365},
+	ProductFixedDeposit: {InterestRate: "1.50", DayCount: DayCount30360},
+}
+
+//InterestAccrual is model of interest earned by BankAccount in one BusinessDate,
+//InterestMicros is in millionths of minor unit so daily interest is not rounded away
+type InterestAccrual struct {
+	ID             bson.ObjectId `bson:"_id" json:"id"`
+	BankAccountID  bson.ObjectId `bson:"bank_account_id" json:"bank_account_id"`
+	UserID         bson.ObjectId `bson:"user_id" json:"user_id"`
+	BusinessDate   string        `bson:"business_date" json:"business_date"`
+	Product        Product       `bson:"product" json:"product"`
+	InterestRate   string        `bson:"interest_rate" json:"interest_rate"`
+	DayCount       DayCount      `bson:"day_count" json:"day_count"`
+	Balance        Money         `bson:"balance" json:"balance"`
+	InterestMicros int64         `bson:"interest_micros" json:"interest_micros"`
+	CreatedAt      time.Time     `bson:"created_at" json:"created_at"`
+}
+
+//InterestPostingState is state of InterestPosting
+type InterestPostingState string
+
+const (
+	//InterestPostingStatePending is InterestPosting being added to Balance
+	InterestPostingStatePending InterestPostingState = "pending"
+	//InterestPostingStateApplied is InterestPosting added to Balance and ledger
+	InterestPostingStateApplied InterestPostingState = "applied"
+	//InterestPostingStateDone is InterestPosting finished
+	InterestPostingStateDone InterestPostingState = "done"
+)
+
+//InterestPosting is model of every InterestAccrual of one Month added to Balance as one ledger entry
+type InterestPosting struct {
+	ID            bson.ObjectId        `bson:"_id" json:"id"`
+	BankAccountID bson.ObjectId        `bson:"bank_account_id" json:"bank_account_id"`
+	UserID        bson.ObjectId        `bson:"user_id" json:"user_id"`
+	AccountNumber string               `bson:"account_number" json:"account_number"`
+	Month         string               `bson:"month" json:"month"`
+	Amount        Money                `bson:"amount" json:"amount"`
+	State         InterestPostingState `bson:"state" json:"state"`
+	LastModified  time.Time            `bson:"last_modified" json:"last_modified"`
+}
+
+//InterestPreview is model of interest accrued but not posted yet
+type InterestPreview struct {
+	BankAccountID bson.ObjectId     `json:"bank_account_id"`
+	Product       Product           `json:"product"`
+	Accrued       Money             `json:"accrued"`
+	Accruals      []InterestAccrual `json:"accruals"`
+}
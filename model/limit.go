@@ -0,0 +1,43 @@
+package model
+
+import (
+	"github.com/globalsign/mgo/bson"
+)
+
+//Limits is how much User or BankAccount may move, empty field is no limit.
+//Amounts of BankAccount are in its own Currency, amounts of User are in DefaultCurrency
+type Limits struct {
+	//MaxWithdraw is largest amount of one withdraw
+	MaxWithdraw string `bson:"max_withdraw,omitempty" json:"max_withdraw,omitempty" toml:"max_withdraw"`
+	//MaxTranferDaily is largest total of Tranfer in one business date
+	MaxTranferDaily string `bson:"max_tranfer_daily,omitempty" json:"max_tranfer_daily,omitempty" toml:"max_tranfer_daily"`
+	//MaxTranferCount is largest number of Tranfer in one business date
+	MaxTranferCount *int `bson:"max_tranfer_count,omitempty" json:"max_tranfer_count,omitempty" toml:"max_tranfer_count"`
+}
+
+//LimitUsage is Tranfer made by User or BankAccount in one business date,
+//TranferTotal is minor units of Currency its Limits are in
+type LimitUsage struct {
+	OwnerID      bson.ObjectId `bson:"owner_id" json:"owner_id"`
+	BusinessDate string        `bson:"business_date" json:"business_date"`
+	TranferTotal int64         `bson:"tranfer_total" json:"tranfer_total"`
+	TranferCount int           `bson:"tranfer_count" json:"tranfer_count"`
+}
+
+//LimitStatus is Limits of User or BankAccount with what is used of them in the business date,
+//Remaining leaves out what has no limit
+type LimitStatus struct {
+	BankAccountID bson.ObjectId `json:"bank_account_id,omitempty"`
+	AccountNumber string        `json:"account_number,omitempty"`
+	Limits        Limits        `json:"limits"`
+	TranferTotal  Money         `json:"tranfer_total"`
+	TranferCount  int           `json:"tranfer_count"`
+	Remaining     Limits        `json:"remaining"`
+}
+
+//UserLimits is LimitStatus of User and every BankAccount of User in BusinessDate
+type UserLimits struct {
+	BusinessDate string        `json:"business_date"`
+	User         LimitStatus   `json:"user"`
+	BankAccounts []LimitStatus `json:"bank_accounts"`
+}
@@ -0,0 +1,302 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//DefaultCurrency is Currency of Money when it is not given
+const DefaultCurrency = "THB"
+
+//CurrencyDecimals is number of decimals allowed for each ISO-4217 Currency
+var CurrencyDecimals = map[string]int{
+	"THB": 2,
+	"USD": 2,
+	"EUR": 2,
+	"JPY": 0,
+}
+
+var (
+	//ErrMoneyFormat is returned when amount is not a decimal number
+	ErrMoneyFormat = errors.New("amount must be decimal number like \"100.25\"")
+	//ErrMoneyDecimals is returned when amount has more decimals than Currency allows
+	ErrMoneyDecimals = errors.New("amount has too many decimals for currency")
+	//ErrMoneyOverflow is returned when amount does not fit in Money
+	ErrMoneyOverflow = errors.New("amount is too large")
+	//ErrCurrencyUnknown is returned when Currency is not in CurrencyDecimals
+	ErrCurrencyUnknown = errors.New("currency is not supported")
+	//ErrCurrencyMismatch is returned when Money of different Currency are mixed
+	ErrCurrencyMismatch = errors.New("currency does not match")
+)
+
+//Money is fixed-point amount stored as integer minor units of Currency,
+//100.25 THB is Money{Amount: 10025, Currency: "THB"}
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+type moneyBSON struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+//ParseMoney for parse decimal string like "100.25" into Money of currency
+func ParseMoney(value string, currency string) (Money, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	decimals, ok := CurrencyDecimals[currency]
+	if !ok {
+		return Money{}, ErrCurrencyUnknown
+	}
+
+	value = strings.TrimSpace(value)
+	negative := false
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		negative = value[0] == '-'
+		value = value[1:]
+	}
+	whole, fraction := value, ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		whole, fraction = value[:i], value[i+1:]
+	}
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, ErrMoneyFormat
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > decimals {
+		return Money{}, ErrMoneyDecimals
+	}
+	fraction += strings.Repeat("0", decimals-len(fraction))
+
+	var amount int64
+	for _, digit := range whole + fraction {
+		d := int64(digit - '0')
+		if amount > (math.MaxInt64-d)/10 {
+			return Money{}, ErrMoneyOverflow
+		}
+		amount = amount*10 + d
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func isDigits(value string) bool {
+	for _, digit := range value {
+		if digit < '0' || digit > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//String for format Money as decimal string without Currency
+func (m Money) String() string {
+	decimals := m.decimals()
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(absUint64(amount), 10)
+	if decimals == 0 {
+		return sign + digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-decimals] + "." + digits[len(digits)-decimals:]
+}
+
+func absUint64(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+	return uint64(amount)
+}
+
+func (m Money) decimals() int {
+	if decimals, ok := CurrencyDecimals[m.currency()]; ok {
+		return decimals
+	}
+	return 2
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+//IsZero for check Money is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+//IsNegative for check Money is less than zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+//Add for add Money of same Currency without overflow
+func (m Money) Add(o Money) (Money, error) {
+	if m.currency() != o.currency() {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount || o.Amount < 0 && m.Amount < math.MinInt64-o.Amount {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.currency()}, nil
+}
+
+//Sub for subtract Money of same Currency without overflow
+func (m Money) Sub(o Money) (Money, error) {
+	neg, err := o.Neg()
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Add(neg)
+}
+
+//Neg for negate Money without overflow
+func (m Money) Neg() (Money, error) {
+	if m.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: -m.Amount, Currency: m.currency()}, nil
+}
+
+//MarshalJSON for write Money as {"amount":"100.25","currency":"THB"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"amount":   m.String(),
+		"currency": m.currency(),
+	})
+}
+
+//UnmarshalJSON for read Money from "100.25", 100.25 or {"amount":"100.25","currency":"THB"}
+//numbers are read from their text, so they never go through float64.
+//Currency stays empty when it is not given, it is DefaultCurrency until In is used
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	currency := ""
+	if len(data) > 0 && data[0] == '{' {
+		var object moneyJSON
+		if err := json.Unmarshal(data, &object); err != nil {
+			return err
+		}
+		currency = object.Currency
+		data = bytes.TrimSpace(object.Amount)
+	}
+	value := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+	money, err := ParseMoney(value, currency)
+	if err != nil {
+		return err
+	}
+	if currency == "" {
+		//Currency is left empty so In can read it again in Currency of BankAccount
+		money.Currency = ""
+	}
+	*m = money
+	return nil
+}
+
+//In for Money in currency, Money read from JSON without Currency is read again with decimals of currency
+func (m Money) In(currency string) (Money, error) {
+	if _, ok := CurrencyDecimals[currency]; !ok {
+		return Money{}, ErrCurrencyUnknown
+	}
+	if m.Currency == "" {
+		return ParseMoney(m.String(), currency)
+	}
+	if m.Currency != currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return m, nil
+}
+
+//RoundHalfEven for round value to integer, half goes to the even one so rounding has no bias
+func RoundHalfEven(value *big.Rat) (int64, error) {
+	quo, rem := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	//compare 2*|rem| with denominator to find which side of half it is
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(value.Denom())
+	if cmp > 0 || cmp == 0 && quo.Bit(0) == 1 {
+		if value.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	if !quo.IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+	return quo.Int64(), nil
+}
+
+//GetBSON for write Money as {amount: minor units, currency: "THB"}
+func (m Money) GetBSON() (interface{}, error) {
+	return moneyBSON{Amount: m.Amount, Currency: m.currency()}, nil
+}
+
+//SetBSON for read Money, legacy float64 balances are rounded to minor units of DefaultCurrency
+func (m *Money) SetBSON(raw bson.Raw) error {
+	switch raw.Kind {
+	case 0x03:
+		var doc moneyBSON
+		if err := raw.Unmarshal(&doc); err != nil {
+			return err
+		}
+		*m = Money{Amount: doc.Amount, Currency: doc.Currency}
+	case 0x01:
+		var value float64
+		if err := raw.Unmarshal(&value); err != nil {
+			return err
+		}
+		money, err := ParseMoney(strconv.FormatFloat(value, 'f', CurrencyDecimals[DefaultCurrency], 64), DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = money
+	case 0x10, 0x12:
+		var value int64
+		if err := raw.Unmarshal(&value); err != nil {
+			return err
+		}
+		money, err := ParseMoney(strconv.FormatInt(value, 10), DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = money
+	case 0x0A:
+		*m = Money{}
+	default:
+		return ErrMoneyFormat
+	}
+	return nil
+}
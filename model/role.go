@@ -0,0 +1,173 @@
+package model
+
+import "errors"
+
+//ErrRoleUnknown is returned when Role is not one of Roles
+var ErrRoleUnknown = errors.New("role must be admin, teller or customer")
+
+//Role is role of User, it gives Permission
+type Role string
+
+const (
+	//RoleAdmin has every Permission on every User, but moves money out only of their own BankAccount
+	RoleAdmin Role = "admin"
+	//RoleTeller works at the counter, it serves every User but can not change or delete them nor move their money out
+	RoleTeller Role = "teller"
+	//RoleCustomer has Permission only on their own User, User with no Role is RoleCustomer
+	RoleCustomer Role = "customer"
+)
+
+//Roles is every Role
+var Roles = []Role{RoleAdmin, RoleTeller, RoleCustomer}
+
+//Permission is what a route does
+type Permission string
+
+const (
+	//PermissionUserRead for read User
+	PermissionUserRead Permission = "user:read"
+	//PermissionUserUpdate for update User
+	PermissionUserUpdate Permission = "user:update"
+	//PermissionUserDelete for delete User and every BankAccount of them
+	PermissionUserDelete Permission = "user:delete"
+	//PermissionUserImport for import User from CSV
+	PermissionUserImport Permission = "user:import"
+	//PermissionUserSearch for search every User
+	PermissionUserSearch Permission = "user:search"
+	//PermissionRoleAssign for set Roles of User
+	PermissionRoleAssign Permission = "role:assign"
+	//PermissionBankAccountCreate for create BankAccount
+	PermissionBankAccountCreate Permission = "bank_account:create"
+	//PermissionBankAccountRead for read BankAccount, its transactions, statement, interest, fee and limits
+	PermissionBankAccountRead Permission = "bank_account:read"
+	//PermissionBankAccountDelete for delete BankAccount
+	PermissionBankAccountDelete Permission = "bank_account:delete"
+	//PermissionDeposit for deposit into BankAccount
+	PermissionDeposit Permission = "bank_account:deposit"
+	//PermissionWithdraw for withdraw from BankAccount
+	PermissionWithdraw Permission = "bank_account:withdraw"
+	//PermissionLimitUpdate for set limits of User or BankAccount
+	PermissionLimitUpdate Permission = "limit:update"
+	//PermissionTranfer for tranfer from BankAccount
+	PermissionTranfer Permission = "tranfer:create"
+	//PermissionStandingOrder for create, read and change StandingOrder
+	PermissionStandingOrder Permission = "standing_order:write"
+	//PermissionBulkPayment for create and read BulkPayment
+	PermissionBulkPayment Permission = "bulk_payment:write"
+	//PermissionRateRead for read exchange rates
+	PermissionRateRead Permission = "rate:read"
+	//PermissionRateUpdate for set exchange rates
+	PermissionRateUpdate Permission = "rate:update"
+	//PermissionApprove for list, approve and reject Approval asked for by someone else
+	PermissionApprove Permission = "approval:decide"
+)
+
+//Permissions is every Permission
+var Permissions = []Permission{
+	PermissionUserRead, PermissionUserUpdate, PermissionUserDelete, PermissionUserImport, PermissionUserSearch, PermissionRoleAssign,
+	PermissionBankAccountCreate, PermissionBankAccountRead, PermissionBankAccountDelete, PermissionDeposit, PermissionWithdraw,
+	PermissionLimitUpdate, PermissionTranfer, PermissionStandingOrder, PermissionBulkPayment, PermissionRateRead, PermissionRateUpdate,
+	PermissionApprove,
+}
+
+//MoneyOutPermissions is every Permission that moves money out of BankAccount, no Role has them on other User
+//so money leaves only when its owner asks for it
+var MoneyOutPermissions = []Permission{PermissionWithdraw, PermissionTranfer, PermissionStandingOrder, PermissionBulkPayment}
+
+//Scope is which User a Permission is granted on
+type Scope int
+
+const (
+	//ScopeNone is Permission not granted
+	ScopeNone Scope = iota
+	//ScopeOwn is Permission granted only on the User themself
+	ScopeOwn
+	//ScopeAny is Permission granted on every User
+	ScopeAny
+)
+
+//RolePermissions is Scope of every Permission a Role has, Permission not listed is ScopeNone
+var RolePermissions = map[Role]map[Permission]Scope{
+	RoleAdmin: withScope(scopeOfAll(Permissions, ScopeAny), MoneyOutPermissions, ScopeOwn),
+	RoleTeller: {
+		PermissionUserRead:          ScopeAny,
+		PermissionUserSearch:        ScopeAny,
+		PermissionBankAccountCreate: ScopeAny,
+		PermissionBankAccountRead:   ScopeAny,
+		PermissionDeposit:           ScopeAny,
+		PermissionRateRead:          ScopeAny,
+	},
+	RoleCustomer: {
+		PermissionUserRead:          ScopeOwn,
+		PermissionUserUpdate:        ScopeOwn,
+		PermissionBankAccountCreate: ScopeOwn,
+		PermissionBankAccountRead:   ScopeOwn,
+		PermissionBankAccountDelete: ScopeOwn,
+		PermissionDeposit:           ScopeOwn,
+		PermissionWithdraw:          ScopeOwn,
+		PermissionLimitUpdate:       ScopeOwn,
+		PermissionTranfer:           ScopeOwn,
+		PermissionStandingOrder:     ScopeOwn,
+		PermissionBulkPayment:       ScopeOwn,
+	},
+}
+
+func scopeOfAll(permissions []Permission, scope Scope) map[Permission]Scope {
+	scopes := map[Permission]Scope{}
+	for _, permission := range permissions {
+		scopes[permission] = scope
+	}
+	return scopes
+}
+
+//withScope for scopes with every Permission of permissions set to scope
+func withScope(scopes map[Permission]Scope, permissions []Permission, scope Scope) map[Permission]Scope {
+	for _, permission := range permissions {
+		scopes[permission] = scope
+	}
+	return scopes
+}
+
+//ValidateRoles for check every Role of roles is one of Roles
+func ValidateRoles(roles []Role) error {
+	for _, role := range roles {
+		if !HasRole(Roles, role) {
+			return ErrRoleUnknown
+		}
+	}
+	return nil
+}
+
+//HasRole for check role is one of roles
+func HasRole(roles []Role, role Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+//ScopeOf for widest Scope of permission that any Role of roles has
+func ScopeOf(roles []Role, permission Permission) Scope {
+	scope := ScopeNone
+	for _, role := range roles {
+		if s := RolePermissions[role][permission]; s > scope {
+			scope = s
+		}
+	}
+	return scope
+}
+
+//UserRoles for Roles of User, User with no Role is RoleCustomer
+func (u User) UserRoles() []Role {
+	if len(u.Roles) == 0 {
+		return []Role{RoleCustomer}
+	}
+	return u.Roles
+}
+
+//RoleAssignment is Roles given to User by admin
+type RoleAssignment struct {
+	Roles []Role `json:"roles"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "github.com/globalsign/mgo/bson"
+
+//CustomerMatch is User found by customer search with its account numbers, Score is its relevance
+//and Matched is fields the query matched, both are empty in list of User. PII in it is masked for roles that must not see it
+type CustomerMatch struct {
+	ID             bson.ObjectId `json:"id"`
+	Username       string        `json:"username"`
+	FirstName      string        `json:"first_name"`
+	LastName       string        `json:"last_name"`
+	Email          string        `json:"email"`
+	Tel            string        `json:"tel"`
+	IDcard         string        `json:"idcard"`
+	AccountNumbers []string      `json:"account_numbers"`
+	Score          int           `json:"score,omitempty"`
+	Matched        []string      `json:"matched,omitempty"`
+}
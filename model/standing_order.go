@@ -0,0 +1,93 @@
+package model
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//ScheduleKind is how often StandingOrder runs
+type ScheduleKind string
+
+const (
+	//ScheduleOnce is one Tranfer on Date
+	ScheduleOnce ScheduleKind = "once"
+	//ScheduleWeekly is Tranfer every Weekday
+	ScheduleWeekly ScheduleKind = "weekly"
+	//ScheduleMonthly is Tranfer on Day of every month, month shorter than Day uses its last day
+	ScheduleMonthly ScheduleKind = "monthly"
+	//ScheduleLastBusinessDay is Tranfer on the last Monday to Friday of every month
+	ScheduleLastBusinessDay ScheduleKind = "last_business_day"
+)
+
+//Schedule is when StandingOrder runs, dates are business dates like "2006-01-02"
+type Schedule struct {
+	Kind ScheduleKind `bson:"kind" json:"kind"`
+	//Date is business date of ScheduleOnce
+	Date string `bson:"date,omitempty" json:"date,omitempty"`
+	//Weekday is day of ScheduleWeekly like "monday"
+	Weekday string `bson:"weekday,omitempty" json:"weekday,omitempty"`
+	//Day is day of month of ScheduleMonthly from 1 to 31
+	Day int `bson:"day,omitempty" json:"day,omitempty"`
+}
+
+//StandingOrderState is state of StandingOrder
+type StandingOrderState string
+
+const (
+	//StandingOrderActive is StandingOrder run when it is due
+	StandingOrderActive StandingOrderState = "active"
+	//StandingOrderPaused is StandingOrder skipped until it is resumed
+	StandingOrderPaused StandingOrderState = "paused"
+	//StandingOrderCancelled is StandingOrder cancelled by its User
+	StandingOrderCancelled StandingOrderState = "cancelled"
+	//StandingOrderFinished is StandingOrder past its EndDate or Count
+	StandingOrderFinished StandingOrderState = "finished"
+)
+
+//StandingOrder is model of Tranfer of Amount from AccountNumberFrom to AccountNumberTo made on every date of Schedule.
+//It ends after EndDate or after Count dates when they are given. NextRun is when NextDate is tried,
+//Attempts is how many times NextDate failed for insufficient funds
+type StandingOrder struct {
+	ID                bson.ObjectId      `bson:"_id" json:"id"`
+	UserID            bson.ObjectId      `bson:"user_id" json:"user_id"`
+	AccountNumberFrom string             `bson:"account_number_from" json:"from"`
+	AccountNumberTo   string             `bson:"account_number_to" json:"to"`
+	Amount            Money              `bson:"amount" json:"amount"`
+	Schedule          Schedule           `bson:"schedule" json:"schedule"`
+	EndDate           string             `bson:"end_date,omitempty" json:"end_date,omitempty"`
+	Count             int                `bson:"count,omitempty" json:"count,omitempty"`
+	Executed          int                `bson:"executed" json:"executed"`
+	NextDate          string             `bson:"next_date" json:"next_date,omitempty"`
+	NextRun           time.Time          `bson:"next_run" json:"next_run"`
+	Attempts          int                `bson:"attempts" json:"attempts"`
+	State             StandingOrderState `bson:"state" json:"state"`
+	LastError         string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	Version           int64              `bson:"version" json:"version"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	LastModified      time.Time          `bson:"last_modified" json:"last_modified"`
+}
+
+//StandingOrderExecutionStatus is result of one run of StandingOrder
+type StandingOrderExecutionStatus string
+
+const (
+	//StandingOrderExecutionDone is Tranfer made
+	StandingOrderExecutionDone StandingOrderExecutionStatus = "done"
+	//StandingOrderExecutionRetrying is Tranfer failed for insufficient funds and is tried again later
+	StandingOrderExecutionRetrying StandingOrderExecutionStatus = "retrying"
+	//StandingOrderExecutionFailed is Tranfer given up for its BusinessDate
+	StandingOrderExecutionFailed StandingOrderExecutionStatus = "failed"
+)
+
+//StandingOrderExecution is record of one run of StandingOrder for BusinessDate
+type StandingOrderExecution struct {
+	ID              bson.ObjectId                `bson:"_id" json:"id"`
+	StandingOrderID bson.ObjectId                `bson:"standing_order_id" json:"standing_order_id"`
+	BusinessDate    string                       `bson:"business_date" json:"business_date"`
+	Attempt         int                          `bson:"attempt" json:"attempt"`
+	Status          StandingOrderExecutionStatus `bson:"status" json:"status"`
+	TranferID       bson.ObjectId                `bson:"tranfer_id,omitempty" json:"tranfer_id,omitempty"`
+	Error           string                       `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt       time.Time                    `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//Statement is model of ledger of BankAccount from From until before To.
+//OpeningBalance plus Amount of every Transaction is ClosingBalance, TotalIn and TotalOut split them by sign
+type Statement struct {
+	BankAccountID  bson.ObjectId    `json:"bank_account_id"`
+	AccountNumber  string           `json:"account_number"`
+	BankName       string           `json:"bank_name"`
+	Product        Product          `json:"product"`
+	Currency       string           `json:"currency"`
+	UserID         bson.ObjectId    `json:"user_id"`
+	CustomerName   string           `json:"customer_name"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	OpeningBalance Money            `json:"opening_balance"`
+	ClosingBalance Money            `json:"closing_balance"`
+	TotalIn        Money            `json:"total_in"`
+	TotalOut       Money            `json:"total_out"`
+	Transactions   []TransactionLog `json:"transactions"`
+	GeneratedAt    time.Time        `json:"generated_at"`
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//TranferState is state of TranferLog
+type TranferState string
+
+const (
+	//TranferStateInitial is TranferLog created but nothing applied
+	TranferStateInitial TranferState = "initial"
+	//TranferStatePending is TranferLog being applied to both users
+	TranferStatePending TranferState = "pending"
+	//TranferStateApplied is TranferLog applied to both users
+	TranferStateApplied TranferState = "applied"
+	//TranferStateDone is TranferLog finished
+	TranferStateDone TranferState = "done"
+	//TranferStateCanceling is TranferLog being rolled back
+	TranferStateCanceling TranferState = "canceling"
+	//TranferStateCancelled is TranferLog rolled back
+	TranferStateCancelled TranferState = "cancelled"
+)
+
+//TranferKind is what TranferLog moves money for
+type TranferKind string
+
+const (
+	//TranferKindTranfer is Tranfer asked by user
+	TranferKindTranfer TranferKind = ""
+	//TranferKindFee is fee moved from BankAccount to income BankAccount of the bank by TranferLog of its own,
+	//it is only left by TranferLog stored before fee was a leg of what it is charged for
+	TranferKindFee TranferKind = "fee"
+	//TranferKindWithdraw is withdraw, Amount leaves AccountNumberFrom and AccountNumberTo is empty
+	TranferKindWithdraw TranferKind = "withdraw"
+)
+
+//TranferLog is model for two-phase commit of Tranfer. Amount leaves AccountNumberFrom and AmountTo
+//arrives at AccountNumberTo, Rate is exchange rate between them when their Currency is different.
+//Fee leaves AccountNumberFrom with Amount and FeeTo arrives at FeeAccountNumber, both are applied by FeeID
+//so they are kept apart from Amount even on the same BankAccount
+type TranferLog struct {
+	ID                bson.ObjectId `bson:"_id" json:"id"`
+	UserFrom          bson.ObjectId `bson:"user_from" json:"user_from"`
+	UserTo            bson.ObjectId `bson:"user_to" json:"user_to"`
+	AccountNumberFrom string        `bson:"account_number_from" json:"account_number_from"`
+	AccountNumberTo   string        `bson:"account_number_to" json:"account_number_to"`
+	Amount            Money         `bson:"amount" json:"amount"`
+	AmountTo          Money         `bson:"amount_to" json:"amount_to"`
+	Rate              string        `bson:"rate,omitempty" json:"rate,omitempty"`
+	Kind              TranferKind   `bson:"kind,omitempty" json:"kind,omitempty"`
+	Fee               Money         `bson:"fee,omitempty" json:"fee,omitempty"`
+	FeeTo             Money         `bson:"fee_to,omitempty" json:"fee_to,omitempty"`
+	FeeAccountNumber  string        `bson:"fee_account_number,omitempty" json:"fee_account_number,omitempty"`
+	FeeID             bson.ObjectId `bson:"fee_id,omitempty" json:"fee_id,omitempty"`
+	State             TranferState  `bson:"state" json:"state"`
+	LastModified      time.Time     `bson:"last_modified" json:"last_modified"`
+}
+
+//ReceivedAmount for Amount that arrives at AccountNumberTo,
+//TranferLog stored before AmountTo existed moves the same Amount on both sides
+func (t TranferLog) ReceivedAmount() Money {
+	if t.AmountTo.IsZero() {
+		return t.Amount
+	}
+	return t.AmountTo
+}
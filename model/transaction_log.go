@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//TransactionType is type of TransactionLog
+type TransactionType string
+
+const (
+	//TransactionTypeDeposit is money put into BankAccount
+	TransactionTypeDeposit TransactionType = "deposit"
+	//TransactionTypeWithdraw is money taken out of BankAccount
+	TransactionTypeWithdraw TransactionType = "withdraw"
+	//TransactionTypeTranferOut is money sent to another BankAccount
+	TransactionTypeTranferOut TransactionType = "tranfer_out"
+	//TransactionTypeTranferIn is money received from another BankAccount
+	TransactionTypeTranferIn TransactionType = "tranfer_in"
+	//TransactionTypeTranferReversal is a Tranfer rolled back
+	TransactionTypeTranferReversal TransactionType = "tranfer_reversal"
+	//TransactionTypeInterest is interest of one month added to BankAccount
+	TransactionTypeInterest TransactionType = "interest"
+	//TransactionTypeFee is fee taken from BankAccount for withdraw or Tranfer
+	TransactionTypeFee TransactionType = "fee"
+	//TransactionTypeFeeIncome is fee received by income BankAccount of the bank
+	TransactionTypeFeeIncome TransactionType = "fee_income"
+)
+
+//TransactionLog is model of immutable entry in ledger of BankAccount
+type TransactionLog struct {
+	ID                  bson.ObjectId   `bson:"_id" json:"id"`
+	UserID              bson.ObjectId   `bson:"user_id" json:"user_id"`
+	BankAccountID       bson.ObjectId   `bson:"bank_account_id" json:"bank_account_id"`
+	AccountNumber       string          `bson:"account_number" json:"account_number"`
+	Type                TransactionType `bson:"type" json:"type"`
+	Amount              Money           `bson:"amount" json:"amount"` //change of Balance, negative when money goes out
+	BalanceAfter        Money           `bson:"balance_after" json:"balance_after"`
+	CounterpartyAccount string          `bson:"counterparty_account,omitempty" json:"counterparty_account,omitempty"`
+	TranferID           bson.ObjectId   `bson:"tranfer_id,omitempty" json:"tranfer_id,omitempty"`
+	CreatedBy           bson.ObjectId   `bson:"created_by" json:"created_by"`
+	CreatedAt           time.Time       `bson:"created_at" json:"created_at"`
+}
+
+//TransactionFilter is filter for find TransactionLog
+type TransactionFilter struct {
+	From  time.Time
+	To    time.Time
+	Page  int
+	Limit int
+}
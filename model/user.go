@@ -1,6 +1,10 @@
 package model
 
-import "github.com/globalsign/mgo/bson"
+import (
+	"strconv"
+
+	"github.com/globalsign/mgo/bson"
+)
 
 //User is model
 type User struct {
@@ -13,25 +17,144 @@ type User struct {
 	Age             int64         `bson:"age" json:"age" binding:"required"`
 	Email           string        `bson:"email" json:"email" binding:"required"`
 	Tel             string        `bson:"tel" json:"tel" binding:"required"`
-	UserBankAccount []BankAccount `bson:"user_bank_account" json:"user_bank_account,omitempty"`
+	Roles           []Role        `bson:"roles,omitempty" json:"roles,omitempty"`
+	UserBankAccount []BankAccount `bson:"-" json:"user_bank_account,omitempty"`
+	Version         int64         `bson:"version" json:"version"`
 }
 
-//BankAccount is model
+//BankAccount is model, it is stored apart from User and UserID is its owner.
+//Version of User and BankAccount goes up by one every time it is stored
 type BankAccount struct {
-	ID            bson.ObjectId `bson:"_id" json:"id"`
-	BankName      string        `bson:"bank_name" json:"bank_name"`
-	AccountNumber string        `bson:"account_number" json:"account_number"`
-	Balance       float64       `bson:"balance" json:"balance"`
+	ID              bson.ObjectId   `bson:"_id" json:"id"`
+	UserID          bson.ObjectId   `bson:"user_id" json:"user_id"`
+	BankName        string          `bson:"bank_name" json:"bank_name"`
+	AccountNumber   string          `bson:"account_number" json:"account_number"`
+	Currency        string          `bson:"currency" json:"currency"`
+	Product         Product         `bson:"product" json:"product"`
+	Balance         Money           `bson:"balance" json:"balance"`
+	OverdraftLimit  Money           `bson:"overdraft_limit" json:"overdraft_limit"`
+	PendingTranfers []bson.ObjectId `bson:"pending_tranfers,omitempty" json:"-"`
+	Version         int64           `bson:"version" json:"version"`
+}
+
+//AccountCurrency for ISO-4217 Currency of BankAccount, BankAccount stored before Currency existed
+//has Currency of its Balance
+func (b BankAccount) AccountCurrency() string {
+	if b.Currency != "" {
+		return b.Currency
+	}
+	return b.Balance.currency()
+}
+
+//AccountProduct for Product of BankAccount, BankAccount stored before Product existed is ProductCurrent
+func (b BankAccount) AccountProduct() Product {
+	if b.Product != "" {
+		return b.Product
+	}
+	return ProductCurrent
 }
 
 //Transaction is model
 type Transaction struct {
-	Amount float64 `bson:"amount" json:"amount"`
+	Amount Money `bson:"amount" json:"amount"`
 }
 
-//Tranfer is model
+//Tranfer is model, Amount is in Currency of BankAccount From
 type Tranfer struct {
-	Amount float64 `bson:"amount" json:"amount"`
-	From   string  `bson:"from" json:"from"`
-	To     string  `bson:"to" json:"to"`
+	Amount Money  `bson:"amount" json:"amount"`
+	From   string `bson:"from" json:"from"`
+	To     string `bson:"to" json:"to"`
+}
+
+//UserResponse is model of User sent back to client, it never has Password
+type UserResponse struct {
+	ID              bson.ObjectId `json:"id"`
+	FirstName       string        `json:"first_name"`
+	LastName        string        `json:"last_name"`
+	Username        string        `json:"username"`
+	IDcard          string        `json:"idcard"`
+	Age             int64         `json:"age"`
+	Email           string        `json:"email"`
+	Tel             string        `json:"tel"`
+	Roles           []Role        `json:"roles"`
+	UserBankAccount []BankAccount `json:"user_bank_account,omitempty"`
+	Version         int64         `json:"version"`
+}
+
+//NewUserResponse for map User to UserResponse
+func NewUserResponse(user User) UserResponse {
+	return UserResponse{
+		ID:              user.ID,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Username:        user.Username,
+		IDcard:          user.IDcard,
+		Age:             user.Age,
+		Email:           user.Email,
+		Tel:             user.Tel,
+		Roles:           user.UserRoles(),
+		UserBankAccount: user.UserBankAccount,
+		Version:         user.Version,
+	}
+}
+
+//NewUserResponses for map list of User to UserResponse
+func NewUserResponses(users []User) []UserResponse {
+	userResponses := []UserResponse{}
+	for _, user := range users {
+		userResponses = append(userResponses, NewUserResponse(user))
+	}
+	return userResponses
+}
+
+const (
+	//UserSortID sorts User in order they were created
+	UserSortID = "id"
+	//UserSortUsername sorts User by Username
+	UserSortUsername = "username"
+	//UserSortFirstName sorts User by FirstName
+	UserSortFirstName = "first_name"
+	//UserSortLastName sorts User by LastName
+	UserSortLastName = "last_name"
+	//UserSortAge sorts User by Age
+	UserSortAge = "age"
+)
+
+//UserSorts is every field User can be sorted by
+var UserSorts = []string{UserSortID, UserSortUsername, UserSortFirstName, UserSortLastName, UserSortAge}
+
+//UserFilter is filter for find User, empty field matches every User.
+//Users are sorted by Sort then by ID and only Limit of them after After are found
+type UserFilter struct {
+	NamePrefix string //prefix of FirstName or LastName
+	Email      string
+	Tel        string
+	AgeMin     int64
+	AgeMax     int64
+	BankName   string //User has BankAccount at BankName
+	Sort       string
+	Descending bool
+	After      *UserCursor
+	Limit      int
+}
+
+//UserCursor is position after the last User of a page, Value is Sort field of that User
+type UserCursor struct {
+	Value string
+	ID    bson.ObjectId
+}
+
+//SortValue for field sort of User as string, Age is in decimal
+func (u User) SortValue(sort string) string {
+	switch sort {
+	case UserSortUsername:
+		return u.Username
+	case UserSortFirstName:
+		return u.FirstName
+	case UserSortLastName:
+		return u.LastName
+	case UserSortAge:
+		return strconv.FormatInt(u.Age, 10)
+	}
+	return u.ID.Hex()
 }
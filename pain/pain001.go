@@ -0,0 +1,81 @@
+package pain
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	//ErrNotPain001 is returned when document is not XML of CstmrCdtTrfInitn
+	ErrNotPain001 = errors.New("document must be ISO 20022 pain.001 CstmrCdtTrfInitn")
+	//ErrMessageID is returned when GrpHdr of pain.001 has no MsgId
+	ErrMessageID = errors.New("pain.001 GrpHdr must have MsgId")
+)
+
+//Pain001 is what is read from pain.001 customer credit transfer initiation, any version of it
+//with the same element names like pain.001.001.03 and pain.001.001.09 is read
+type Pain001 struct {
+	MessageID            string               `xml:"GrpHdr>MsgId"`
+	CreatedAt            string               `xml:"GrpHdr>CreDtTm"`
+	NumberOfTransactions string               `xml:"GrpHdr>NbOfTxs"`
+	ControlSum           string               `xml:"GrpHdr>CtrlSum"`
+	PaymentInformation   []PaymentInformation `xml:"PmtInf"`
+}
+
+//PaymentInformation is PmtInf of pain.001, every CreditTransfer of it is paid from DebtorAccount
+type PaymentInformation struct {
+	ID                   string           `xml:"PmtInfId"`
+	NumberOfTransactions string           `xml:"NbOfTxs"`
+	ControlSum           string           `xml:"CtrlSum"`
+	DebtorAccount        Account          `xml:"DbtrAcct"`
+	CreditTransfers      []CreditTransfer `xml:"CdtTrfTxInf"`
+}
+
+//CreditTransfer is CdtTrfTxInf of pain.001
+type CreditTransfer struct {
+	InstructionID   string  `xml:"PmtId>InstrId"`
+	EndToEndID      string  `xml:"PmtId>EndToEndId"`
+	Amount          Amount  `xml:"Amt>InstdAmt"`
+	CreditorName    string  `xml:"Cdtr>Nm"`
+	CreditorAccount Account `xml:"CdtrAcct"`
+}
+
+//Amount is InstdAmt of pain.001
+type Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+//Account is DbtrAcct or CdtrAcct of pain.001, it is identified by IBAN or other identification
+type Account struct {
+	IBAN     string `xml:"Id>IBAN"`
+	Other    string `xml:"Id>Othr>Id"`
+	Currency string `xml:"Ccy"`
+}
+
+//Number for account number of Account, other identification when it has no IBAN
+func (a Account) Number() string {
+	if a.IBAN != "" {
+		return strings.TrimSpace(a.IBAN)
+	}
+	return strings.TrimSpace(a.Other)
+}
+
+//ParsePain001 for read pain.001 Document from r
+func ParsePain001(r io.Reader) (Pain001, error) {
+	var document struct {
+		XMLName    xml.Name
+		Initiation *Pain001 `xml:"CstmrCdtTrfInitn"`
+	}
+	if err := xml.NewDecoder(r).Decode(&document); err != nil || document.XMLName.Local != "Document" || document.Initiation == nil {
+		return Pain001{}, ErrNotPain001
+	}
+	initiation := *document.Initiation
+	initiation.MessageID = strings.TrimSpace(initiation.MessageID)
+	if initiation.MessageID == "" {
+		return Pain001{}, ErrMessageID
+	}
+	return initiation, nil
+}
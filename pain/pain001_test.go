@@ -0,0 +1,79 @@
+package pain
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPain001 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03">
+  <CstmrCdtTrfInitn>
+    <GrpHdr>
+      <MsgId> MSG-1 </MsgId>
+      <CreDtTm>2026-10-01T09:00:00</CreDtTm>
+      <NbOfTxs>2</NbOfTxs>
+      <CtrlSum>150.25</CtrlSum>
+    </GrpHdr>
+    <PmtInf>
+      <PmtInfId>PMT-1</PmtInfId>
+      <NbOfTxs>2</NbOfTxs>
+      <DbtrAcct><Id><Othr><Id>111-1</Id></Othr></Id></DbtrAcct>
+      <CdtTrfTxInf>
+        <PmtId><InstrId>INS-1</InstrId><EndToEndId>E2E-1</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="THB">100</InstdAmt></Amt>
+        <Cdtr><Nm>Bob</Nm></Cdtr>
+        <CdtrAcct><Id><Othr><Id>222-2</Id></Othr></Id></CdtrAcct>
+      </CdtTrfTxInf>
+      <CdtTrfTxInf>
+        <PmtId><EndToEndId>E2E-2</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="THB">50.25</InstdAmt></Amt>
+        <CdtrAcct><Id><IBAN> TH0012345678 </IBAN></Id></CdtrAcct>
+      </CdtTrfTxInf>
+    </PmtInf>
+  </CstmrCdtTrfInitn>
+</Document>`
+
+func TestParsePain001(t *testing.T) {
+	initiation, err := ParsePain001(strings.NewReader(testPain001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if initiation.MessageID != "MSG-1" || initiation.NumberOfTransactions != "2" || initiation.ControlSum != "150.25" {
+		t.Fatalf("GrpHdr is %+v", initiation)
+	}
+	if len(initiation.PaymentInformation) != 1 || len(initiation.PaymentInformation[0].CreditTransfers) != 2 {
+		t.Fatalf("PmtInf is %+v, want one with two CdtTrfTxInf", initiation.PaymentInformation)
+	}
+	paymentInformation := initiation.PaymentInformation[0]
+	if paymentInformation.DebtorAccount.Number() != "111-1" {
+		t.Fatalf("DbtrAcct is %q, want 111-1", paymentInformation.DebtorAccount.Number())
+	}
+	first, second := paymentInformation.CreditTransfers[0], paymentInformation.CreditTransfers[1]
+	if first.InstructionID != "INS-1" || first.EndToEndID != "E2E-1" || first.Amount != (Amount{Currency: "THB", Value: "100"}) ||
+		first.CreditorName != "Bob" || first.CreditorAccount.Number() != "222-2" {
+		t.Fatalf("first CdtTrfTxInf is %+v", first)
+	}
+	//IBAN is used before other identification
+	if second.CreditorAccount.Number() != "TH0012345678" {
+		t.Fatalf("CdtrAcct of second CdtTrfTxInf is %q, want TH0012345678", second.CreditorAccount.Number())
+	}
+}
+
+func TestParsePain001Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		document string
+		err      error
+	}{
+		{"not XML", "MsgId,Amount\nMSG-1,100\n", ErrNotPain001},
+		{"XML cut short", testPain001[:len(testPain001)/2], ErrNotPain001},
+		{"root is not Document", `<Invoice><CstmrCdtTrfInitn><GrpHdr><MsgId>MSG-1</MsgId></GrpHdr></CstmrCdtTrfInitn></Invoice>`, ErrNotPain001},
+		{"other ISO 20022 message", `<Document><CstmrPmtStsRpt><GrpHdr><MsgId>MSG-1</MsgId></GrpHdr></CstmrPmtStsRpt></Document>`, ErrNotPain001},
+		{"no MsgId", `<Document><CstmrCdtTrfInitn><GrpHdr><NbOfTxs>1</NbOfTxs></GrpHdr></CstmrCdtTrfInitn></Document>`, ErrMessageID},
+		{"blank MsgId", `<Document><CstmrCdtTrfInitn><GrpHdr><MsgId>  </MsgId></GrpHdr></CstmrCdtTrfInitn></Document>`, ErrMessageID},
+	} {
+		if _, err := ParsePain001(strings.NewReader(tc.document)); err != tc.err {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
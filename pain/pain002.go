@@ -0,0 +1,169 @@
+package pain
+
+import (
+	"bankaccountapi/model"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	//Pain002Namespace is namespace of ISO 20022 CustomerPaymentStatusReport pain.002.001.03
+	Pain002Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.002.001.03"
+	//Pain001MessageName is OrgnlMsgNmId of pain.001 in pain.002
+	Pain001MessageName = "pain.001.001.03"
+
+	//StatusAcceptedTechnicalValidation is line validated and waiting to run
+	StatusAcceptedTechnicalValidation = "ACTC"
+	//StatusPending is line or file still running, or line with unknown outcome
+	StatusPending = "PDNG"
+	//StatusAcceptedSettlementCompleted is line or file done
+	StatusAcceptedSettlementCompleted = "ACSC"
+	//StatusPartiallyAccepted is file with some lines rejected
+	StatusPartiallyAccepted = "PART"
+	//StatusRejected is line or file rejected
+	StatusRejected = "RJCT"
+
+	pain002DateTimeFormat = "2006-01-02T15:04:05.000-07:00"
+	//maxAdditionalInformation is length of Max105Text of AddtlInf
+	maxAdditionalInformation = 105
+)
+
+//lineStatus is TxSts of each BulkPaymentLineStatus
+var lineStatus = map[model.BulkPaymentLineStatus]string{
+	model.BulkPaymentLinePending:   StatusAcceptedTechnicalValidation,
+	model.BulkPaymentLineExecuting: StatusPending,
+	model.BulkPaymentLineDone:      StatusAcceptedSettlementCompleted,
+	model.BulkPaymentLineRejected:  StatusRejected,
+	model.BulkPaymentLineUnknown:   StatusPending,
+}
+
+type statusReason struct {
+	Code        string `xml:"Rsn>Cd,omitempty"`
+	Information string `xml:"AddtlInf,omitempty"`
+}
+
+type statusCount struct {
+	Count  int    `xml:"DtldNbOfTxs"`
+	Status string `xml:"DtldSts"`
+}
+
+type transactionStatus struct {
+	InstructionID string        `xml:"OrgnlInstrId,omitempty"`
+	EndToEndID    string        `xml:"OrgnlEndToEndId"`
+	Status        string        `xml:"TxSts"`
+	Reason        *statusReason `xml:"StsRsnInf"`
+	ServicerRef   string        `xml:"AcctSvcrRef,omitempty"`
+}
+
+type paymentInformationStatus struct {
+	ID           string              `xml:"OrgnlPmtInfId"`
+	Transactions []transactionStatus `xml:"TxInfAndSts"`
+}
+
+type pain002Document struct {
+	XMLName   xml.Name `xml:"Document"`
+	Namespace string   `xml:"xmlns,attr"`
+	Report    struct {
+		MessageID string `xml:"GrpHdr>MsgId"`
+		CreatedAt string `xml:"GrpHdr>CreDtTm"`
+		Original  struct {
+			MessageID            string        `xml:"OrgnlMsgId"`
+			MessageName          string        `xml:"OrgnlMsgNmId"`
+			CreatedAt            string        `xml:"OrgnlCreDtTm,omitempty"`
+			NumberOfTransactions string        `xml:"OrgnlNbOfTxs,omitempty"`
+			ControlSum           string        `xml:"OrgnlCtrlSum,omitempty"`
+			Status               string        `xml:"GrpSts"`
+			Reason               *statusReason `xml:"StsRsnInf"`
+			Counts               []statusCount `xml:"NbOfTxsPerSts"`
+		} `xml:"OrgnlGrpInfAndSts"`
+		PaymentInformation []paymentInformationStatus `xml:"OrgnlPmtInfAndSts"`
+	} `xml:"CstmrPmtStsRpt"`
+}
+
+func reasonOf(code string, reason string) *statusReason {
+	if code == "" && reason == "" {
+		return nil
+	}
+	if runes := []rune(reason); len(runes) > maxAdditionalInformation {
+		reason = string(runes[:maxAdditionalInformation])
+	}
+	return &statusReason{Code: code, Information: reason}
+}
+
+//GroupStatus for GrpSts of bulkPayment, file still running is ACTC until it has a rejected line
+func GroupStatus(bulkPayment model.BulkPayment) string {
+	switch bulkPayment.State {
+	case model.BulkPaymentCompleted:
+		return StatusAcceptedSettlementCompleted
+	case model.BulkPaymentPartiallyCompleted:
+		return StatusPartiallyAccepted
+	case model.BulkPaymentRejected:
+		return StatusRejected
+	}
+	for _, line := range bulkPayment.Lines {
+		if line.Status == model.BulkPaymentLineRejected {
+			return StatusPartiallyAccepted
+		}
+	}
+	if bulkPayment.State == model.BulkPaymentProcessing {
+		return StatusPending
+	}
+	return StatusAcceptedTechnicalValidation
+}
+
+//WritePain002 for write status of bulkPayment and every line of it as pain.002 customer payment status report created at createdAt
+func WritePain002(w io.Writer, bulkPayment model.BulkPayment, createdAt time.Time) error {
+	var document pain002Document
+	document.Namespace = Pain002Namespace
+	report := &document.Report
+	report.MessageID = bulkPayment.ID.Hex() + "-" + strconv.FormatInt(createdAt.Unix(), 36)
+	report.CreatedAt = createdAt.Format(pain002DateTimeFormat)
+	report.Original.MessageID = bulkPayment.MessageID
+	report.Original.MessageName = Pain001MessageName
+	report.Original.CreatedAt = bulkPayment.MessageCreatedAt
+	report.Original.NumberOfTransactions = bulkPayment.NumberOfTransactions
+	report.Original.ControlSum = bulkPayment.ControlSum
+	report.Original.Status = GroupStatus(bulkPayment)
+	report.Original.Reason = reasonOf(bulkPayment.ReasonCode, bulkPayment.Reason)
+
+	counts := map[string]int{}
+	var order []string
+	for _, line := range bulkPayment.Lines {
+		status := lineStatus[line.Status]
+		if counts[status] == 0 {
+			order = append(order, status)
+		}
+		counts[status]++
+
+		if n := len(report.PaymentInformation); n == 0 || report.PaymentInformation[n-1].ID != line.PaymentInformationID {
+			report.PaymentInformation = append(report.PaymentInformation, paymentInformationStatus{ID: line.PaymentInformationID})
+		}
+		paymentInformation := &report.PaymentInformation[len(report.PaymentInformation)-1]
+		transaction := transactionStatus{
+			InstructionID: line.InstructionID,
+			EndToEndID:    line.EndToEndID,
+			Status:        status,
+			Reason:        reasonOf(line.ReasonCode, line.Reason),
+		}
+		if line.Status == model.BulkPaymentLineDone {
+			transaction.ServicerRef = line.TranferID.Hex()
+		}
+		paymentInformation.Transactions = append(paymentInformation.Transactions, transaction)
+	}
+	for _, status := range order {
+		report.Original.Counts = append(report.Original.Counts, statusCount{Count: counts[status], Status: status})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,113 @@
+package pain
+
+import (
+	"bankaccountapi/model"
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestGroupStatus(t *testing.T) {
+	pending := model.BulkPaymentLine{Status: model.BulkPaymentLinePending}
+	rejected := model.BulkPaymentLine{Status: model.BulkPaymentLineRejected}
+	for _, tc := range []struct {
+		state  model.BulkPaymentState
+		lines  []model.BulkPaymentLine
+		status string
+	}{
+		{model.BulkPaymentReceived, []model.BulkPaymentLine{pending}, StatusAcceptedTechnicalValidation},
+		{model.BulkPaymentProcessing, []model.BulkPaymentLine{pending}, StatusPending},
+		//file still running with a rejected line is already only partially accepted
+		{model.BulkPaymentReceived, []model.BulkPaymentLine{pending, rejected}, StatusPartiallyAccepted},
+		{model.BulkPaymentProcessing, []model.BulkPaymentLine{pending, rejected}, StatusPartiallyAccepted},
+		{model.BulkPaymentCompleted, nil, StatusAcceptedSettlementCompleted},
+		{model.BulkPaymentPartiallyCompleted, nil, StatusPartiallyAccepted},
+		{model.BulkPaymentRejected, []model.BulkPaymentLine{rejected}, StatusRejected},
+	} {
+		if status := GroupStatus(model.BulkPayment{State: tc.state, Lines: tc.lines}); status != tc.status {
+			t.Errorf("GrpSts of %s with %d lines is %s, want %s", tc.state, len(tc.lines), status, tc.status)
+		}
+	}
+}
+
+func TestWritePain002(t *testing.T) {
+	tranferID := bson.NewObjectId()
+	line := func(n int, status model.BulkPaymentLineStatus, code string, reason string) model.BulkPaymentLine {
+		line := model.BulkPaymentLine{
+			Line:                 n,
+			PaymentInformationID: "PMT-1",
+			EndToEndID:           "E2E-" + strconv.Itoa(n),
+			Status:               status,
+			ReasonCode:           code,
+			Reason:               reason,
+		}
+		//TranferID is given when line is claimed, only done line has its Tranfer as AcctSvcrRef
+		if status == model.BulkPaymentLineDone || status == model.BulkPaymentLineRejected {
+			line.TranferID = tranferID
+		}
+		return line
+	}
+	bulkPayment := model.BulkPayment{
+		ID:                   bson.NewObjectId(),
+		MessageID:            "MSG-1",
+		NumberOfTransactions: "5",
+		State:                model.BulkPaymentPartiallyCompleted,
+		Lines: []model.BulkPaymentLine{
+			line(1, model.BulkPaymentLineDone, "", ""),
+			line(2, model.BulkPaymentLineRejected, "AM04", strings.Repeat("x", maxAdditionalInformation+10)),
+			line(3, model.BulkPaymentLineDone, "", ""),
+			line(4, model.BulkPaymentLineUnknown, "NARR", "server stopped during Tranfer"),
+			line(5, model.BulkPaymentLinePending, "", ""),
+		},
+	}
+	var body bytes.Buffer
+	if err := WritePain002(&body, bulkPayment, time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	var document pain002Document
+	if err := xml.Unmarshal(body.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	original := document.Report.Original
+	if original.MessageID != "MSG-1" || original.MessageName != Pain001MessageName || original.Status != StatusPartiallyAccepted {
+		t.Fatalf("OrgnlGrpInfAndSts is %+v", original)
+	}
+	//counts are in order each status is first seen
+	wantCounts := []statusCount{{2, StatusAcceptedSettlementCompleted}, {1, StatusRejected}, {1, StatusPending}, {1, StatusAcceptedTechnicalValidation}}
+	if len(original.Counts) != len(wantCounts) {
+		t.Fatalf("NbOfTxsPerSts is %+v, want %+v", original.Counts, wantCounts)
+	}
+	for i := range wantCounts {
+		if original.Counts[i] != wantCounts[i] {
+			t.Fatalf("NbOfTxsPerSts is %+v, want %+v", original.Counts, wantCounts)
+		}
+	}
+
+	if len(document.Report.PaymentInformation) != 1 {
+		t.Fatalf("OrgnlPmtInfAndSts is %+v, want one", document.Report.PaymentInformation)
+	}
+	transactions := document.Report.PaymentInformation[0].Transactions
+	if len(transactions) != len(bulkPayment.Lines) {
+		t.Fatalf("TxInfAndSts has %d, want %d", len(transactions), len(bulkPayment.Lines))
+	}
+	wantStatus := []string{StatusAcceptedSettlementCompleted, StatusRejected, StatusAcceptedSettlementCompleted, StatusPending, StatusAcceptedTechnicalValidation}
+	for i, transaction := range transactions {
+		if transaction.Status != wantStatus[i] {
+			t.Errorf("TxSts of %s is %s, want %s", transaction.EndToEndID, transaction.Status, wantStatus[i])
+		}
+		if done := bulkPayment.Lines[i].Status == model.BulkPaymentLineDone; done != (transaction.ServicerRef == tranferID.Hex()) {
+			t.Errorf("AcctSvcrRef of %s is %q", transaction.EndToEndID, transaction.ServicerRef)
+		}
+	}
+	if reason := transactions[1].Reason; reason == nil || reason.Code != "AM04" || len(reason.Information) != maxAdditionalInformation {
+		t.Fatalf("StsRsnInf of rejected line is %+v, want AM04 with AddtlInf cut to %d", reason, maxAdditionalInformation)
+	}
+	if transactions[0].Reason != nil {
+		t.Fatalf("StsRsnInf of done line is %+v, want none", transactions[0].Reason)
+	}
+}
@@ -0,0 +1,1218 @@
+package repository
+
+import (
+	"bankaccountapi/model"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//MemoryDB is in-memory storage shared by the Memory repositories, it is safe for concurrent use
+type MemoryDB struct {
+	mu            sync.RWMutex
+	users         map[bson.ObjectId]model.User
+	bankAccounts  map[bson.ObjectId]model.BankAccount
+	tranfers      map[bson.ObjectId]model.TranferLog
+	transactions  []model.TransactionLog
+	refreshTokens map[string]model.RefreshToken
+	idempotency   map[string]model.IdempotencyKey
+	accruals      map[bson.ObjectId]model.InterestAccrual
+	postings      map[bson.ObjectId]model.InterestPosting
+	limits        map[bson.ObjectId]model.Limits
+	limitUsage    map[limitUsageKey]model.LimitUsage
+	orders        map[bson.ObjectId]model.StandingOrder
+	executions    []model.StandingOrderExecution
+	bulkPayments  map[bson.ObjectId]model.BulkPayment
+	approvals     map[bson.ObjectId]model.Approval
+	audit         []model.AuditEntry
+}
+
+//limitUsageKey is key of LimitUsage in MemoryDB
+type limitUsageKey struct {
+	ownerID      bson.ObjectId
+	businessDate string
+}
+
+//NewMemoryDB for NewMemoryDB
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		users:         map[bson.ObjectId]model.User{},
+		bankAccounts:  map[bson.ObjectId]model.BankAccount{},
+		tranfers:      map[bson.ObjectId]model.TranferLog{},
+		refreshTokens: map[string]model.RefreshToken{},
+		idempotency:   map[string]model.IdempotencyKey{},
+		accruals:      map[bson.ObjectId]model.InterestAccrual{},
+		postings:      map[bson.ObjectId]model.InterestPosting{},
+		limits:        map[bson.ObjectId]model.Limits{},
+		limitUsage:    map[limitUsageKey]model.LimitUsage{},
+		orders:        map[bson.ObjectId]model.StandingOrder{},
+		bulkPayments:  map[bson.ObjectId]model.BulkPayment{},
+		approvals:     map[bson.ObjectId]model.Approval{},
+	}
+}
+
+//copyBankAccount for copy slices of BankAccount so callers never share them with MemoryDB
+func copyBankAccount(bankAccount model.BankAccount) model.BankAccount {
+	bankAccount.PendingTranfers = append([]bson.ObjectId(nil), bankAccount.PendingTranfers...)
+	return bankAccount
+}
+
+//bankAccountsOf for list BankAccount of user, caller must hold mu
+func (db *MemoryDB) bankAccountsOf(userID bson.ObjectId) []model.BankAccount {
+	var bankAccounts []model.BankAccount
+	for _, bankAccount := range db.bankAccounts {
+		if bankAccount.UserID == userID {
+			bankAccounts = append(bankAccounts, copyBankAccount(bankAccount))
+		}
+	}
+	sort.Slice(bankAccounts, func(i, j int) bool { return bankAccounts[i].ID < bankAccounts[j].ID })
+	return bankAccounts
+}
+
+//bankAccountByNumber for find BankAccount by AccountNumber, caller must hold mu
+func (db *MemoryDB) bankAccountByNumber(accountNumber string) (model.BankAccount, bool) {
+	for _, bankAccount := range db.bankAccounts {
+		if bankAccount.AccountNumber == accountNumber {
+			return copyBankAccount(bankAccount), true
+		}
+	}
+	return model.BankAccount{}, false
+}
+
+func hasTranfer(bankAccount model.BankAccount, tranferID bson.ObjectId) bool {
+	for _, pendingTranfer := range bankAccount.PendingTranfers {
+		if pendingTranfer == tranferID {
+			return true
+		}
+	}
+	return false
+}
+
+//MemoryUserRepository is UserRepository in MemoryDB
+type MemoryUserRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryUserRepository for NewMemoryUserRepository
+func NewMemoryUserRepository(db *MemoryDB) *MemoryUserRepository {
+	return &MemoryUserRepository{db: db}
+}
+
+//FindAll for FindAll
+func (r *MemoryUserRepository) FindAll() ([]model.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var users []model.User
+	for _, user := range r.db.users {
+		user.UserBankAccount = r.db.bankAccountsOf(user.ID)
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+//Find for Find
+func (r *MemoryUserRepository) Find(filter model.UserFilter) ([]model.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var users []model.User
+	for _, user := range r.db.users {
+		if !r.db.matchUser(user, filter) {
+			continue
+		}
+		if filter.After != nil && !userAfter(user, filter.Sort, filter.Descending, *filter.After) {
+			continue
+		}
+		user.UserBankAccount = r.db.bankAccountsOf(user.ID)
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return userAfter(users[j], filter.Sort, filter.Descending, model.UserCursor{Value: users[i].SortValue(filter.Sort), ID: users[i].ID})
+	})
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+//matchUser for check user matches every field of filter except After, caller must hold mu
+func (db *MemoryDB) matchUser(user model.User, filter model.UserFilter) bool {
+	if filter.NamePrefix != "" && !strings.HasPrefix(user.FirstName, filter.NamePrefix) && !strings.HasPrefix(user.LastName, filter.NamePrefix) {
+		return false
+	}
+	if filter.Email != "" && user.Email != filter.Email || filter.Tel != "" && user.Tel != filter.Tel {
+		return false
+	}
+	if filter.AgeMin > 0 && user.Age < filter.AgeMin || filter.AgeMax > 0 && user.Age > filter.AgeMax {
+		return false
+	}
+	if filter.BankName == "" {
+		return true
+	}
+	for _, bankAccount := range db.bankAccounts {
+		if bankAccount.UserID == user.ID && bankAccount.BankName == filter.BankName {
+			return true
+		}
+	}
+	return false
+}
+
+//userAfter for check user comes after cursor in order of sort then ID
+func userAfter(user model.User, sort string, descending bool, cursor model.UserCursor) bool {
+	compare := 0
+	if sort == model.UserSortAge {
+		age, _ := strconv.ParseInt(cursor.Value, 10, 64)
+		if user.Age < age {
+			compare = -1
+		} else if user.Age > age {
+			compare = 1
+		}
+	} else if sort != model.UserSortID {
+		compare = strings.Compare(user.SortValue(sort), cursor.Value)
+	}
+	if compare == 0 {
+		compare = strings.Compare(string(user.ID), string(cursor.ID))
+	}
+	if descending {
+		return compare < 0
+	}
+	return compare > 0
+}
+
+//FindByID for FindByID
+func (r *MemoryUserRepository) FindByID(id bson.ObjectId) (model.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	user, ok := r.db.users[id]
+	if !ok {
+		return model.User{}, ErrNotFound
+	}
+	user.UserBankAccount = r.db.bankAccountsOf(user.ID)
+	return user, nil
+}
+
+//FindByUsername for FindByUsername
+func (r *MemoryUserRepository) FindByUsername(username string) (model.User, error) {
+	users, _ := r.FindAll()
+	for _, user := range users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return model.User{}, ErrNotFound
+}
+
+//Insert for Insert
+func (r *MemoryUserRepository) Insert(user *model.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	stored := *user
+	stored.UserBankAccount = nil
+	r.db.users[user.ID] = stored
+	return nil
+}
+
+//InsertWithBankAccounts for InsertWithBankAccounts
+func (r *MemoryUserRepository) InsertWithBankAccounts(users []model.User, bankAccounts []model.BankAccount) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	accountNumbers := map[string]bool{}
+	for _, bankAccount := range bankAccounts {
+		if _, ok := r.db.bankAccountByNumber(bankAccount.AccountNumber); ok || accountNumbers[bankAccount.AccountNumber] {
+			return ErrDuplicate
+		}
+		accountNumbers[bankAccount.AccountNumber] = true
+	}
+	for _, user := range users {
+		user.UserBankAccount = nil
+		r.db.users[user.ID] = user
+	}
+	for _, bankAccount := range bankAccounts {
+		r.db.bankAccounts[bankAccount.ID] = copyBankAccount(bankAccount)
+	}
+	return nil
+}
+
+//Update for Update
+func (r *MemoryUserRepository) Update(user *model.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	current, ok := r.db.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if current.Version != user.Version {
+		return ErrConflict
+	}
+	stored := *user
+	stored.UserBankAccount = nil
+	stored.Version++
+	r.db.users[user.ID] = stored
+	user.Version = stored.Version
+	return nil
+}
+
+//DeleteWithBankAccounts for DeleteWithBankAccounts
+func (r *MemoryUserRepository) DeleteWithBankAccounts(id bson.ObjectId, check func(bankAccounts []model.BankAccount) error) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if _, ok := r.db.users[id]; !ok {
+		return ErrNotFound
+	}
+	bankAccounts := r.db.bankAccountsOf(id)
+	if err := check(bankAccounts); err != nil {
+		return err
+	}
+	for _, bankAccount := range bankAccounts {
+		delete(r.db.bankAccounts, bankAccount.ID)
+	}
+	delete(r.db.users, id)
+	return nil
+}
+
+//SetPassword for SetPassword
+func (r *MemoryUserRepository) SetPassword(id bson.ObjectId, oldPassword string, newPassword string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	user, ok := r.db.users[id]
+	if !ok || user.Password != oldPassword {
+		return ErrNotFound
+	}
+	user.Password = newPassword
+	user.Version++
+	r.db.users[id] = user
+	return nil
+}
+
+//MemoryBankAccountRepository is BankAccountRepository in MemoryDB
+type MemoryBankAccountRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryBankAccountRepository for NewMemoryBankAccountRepository
+func NewMemoryBankAccountRepository(db *MemoryDB) *MemoryBankAccountRepository {
+	return &MemoryBankAccountRepository{db: db}
+}
+
+//FindAll for FindAll
+func (r *MemoryBankAccountRepository) FindAll() ([]model.BankAccount, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var bankAccounts []model.BankAccount
+	for _, bankAccount := range r.db.bankAccounts {
+		bankAccounts = append(bankAccounts, copyBankAccount(bankAccount))
+	}
+	sort.Slice(bankAccounts, func(i, j int) bool { return bankAccounts[i].ID < bankAccounts[j].ID })
+	return bankAccounts, nil
+}
+
+//FindByID for FindByID
+func (r *MemoryBankAccountRepository) FindByID(id bson.ObjectId) (model.BankAccount, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	bankAccount, ok := r.db.bankAccounts[id]
+	if !ok {
+		return model.BankAccount{}, ErrNotFound
+	}
+	return copyBankAccount(bankAccount), nil
+}
+
+//FindByAccountNumber for FindByAccountNumber
+func (r *MemoryBankAccountRepository) FindByAccountNumber(accountNumber string) (model.BankAccount, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	bankAccount, ok := r.db.bankAccountByNumber(accountNumber)
+	if !ok {
+		return model.BankAccount{}, ErrNotFound
+	}
+	return bankAccount, nil
+}
+
+//FindByUser for FindByUser
+func (r *MemoryBankAccountRepository) FindByUser(userID bson.ObjectId) ([]model.BankAccount, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	return r.db.bankAccountsOf(userID), nil
+}
+
+//Insert for Insert
+func (r *MemoryBankAccountRepository) Insert(bankAccount *model.BankAccount) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if _, ok := r.db.bankAccountByNumber(bankAccount.AccountNumber); ok {
+		return ErrDuplicate
+	}
+	r.db.bankAccounts[bankAccount.ID] = copyBankAccount(*bankAccount)
+	return nil
+}
+
+//Delete for Delete
+func (r *MemoryBankAccountRepository) Delete(userID bson.ObjectId, id bson.ObjectId, check func(bankAccount model.BankAccount) error) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	bankAccount, ok := r.db.bankAccounts[id]
+	if !ok || bankAccount.UserID != userID {
+		return ErrNotFound
+	}
+	if err := check(copyBankAccount(bankAccount)); err != nil {
+		return err
+	}
+	delete(r.db.bankAccounts, id)
+	return nil
+}
+
+//UpdateBalance for UpdateBalance
+func (r *MemoryBankAccountRepository) UpdateBalance(userID bson.ObjectId, bankAccountID bson.ObjectId, update func(bankAccount *model.BankAccount) error) (model.BankAccount, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	bankAccount, ok := r.db.bankAccounts[bankAccountID]
+	if !ok || bankAccount.UserID != userID {
+		return model.BankAccount{}, ErrNotFound
+	}
+	bankAccount = copyBankAccount(bankAccount)
+	if err := update(&bankAccount); err != nil {
+		return model.BankAccount{}, err
+	}
+	stored := r.db.bankAccounts[bankAccountID]
+	stored.Balance = bankAccount.Balance
+	stored.Version++
+	r.db.bankAccounts[bankAccountID] = stored
+	bankAccount.Version = stored.Version
+	return bankAccount, nil
+}
+
+//ApplyTranfer for ApplyTranfer
+func (r *MemoryBankAccountRepository) ApplyTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money, check func(bankAccount model.BankAccount) error) (model.BankAccount, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	bankAccount, ok := r.db.bankAccountByNumber(accountNumber)
+	if !ok {
+		return model.BankAccount{}, ErrNotFound
+	}
+	if hasTranfer(bankAccount, tranferID) {
+		return bankAccount, nil
+	}
+	balance, err := bankAccount.Balance.Add(amount)
+	if err != nil {
+		return model.BankAccount{}, err
+	}
+	bankAccount.Balance = balance
+	if check != nil {
+		if err := check(bankAccount); err != nil {
+			return model.BankAccount{}, err
+		}
+	}
+	bankAccount.Version++
+	bankAccount.PendingTranfers = append(bankAccount.PendingTranfers, tranferID)
+	r.db.bankAccounts[bankAccount.ID] = copyBankAccount(bankAccount)
+	return bankAccount, nil
+}
+
+//RevertTranfer for RevertTranfer
+func (r *MemoryBankAccountRepository) RevertTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money) (model.BankAccount, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	bankAccount, ok := r.db.bankAccountByNumber(accountNumber)
+	if !ok || !hasTranfer(bankAccount, tranferID) {
+		return model.BankAccount{}, ErrNotFound
+	}
+	balance, err := bankAccount.Balance.Add(amount)
+	if err != nil {
+		return model.BankAccount{}, err
+	}
+	bankAccount.Balance = balance
+	bankAccount.Version++
+	bankAccount.PendingTranfers = removeTranfer(bankAccount.PendingTranfers, tranferID)
+	r.db.bankAccounts[bankAccount.ID] = copyBankAccount(bankAccount)
+	return bankAccount, nil
+}
+
+//FinishTranfer for FinishTranfer
+func (r *MemoryBankAccountRepository) FinishTranfer(tranferID bson.ObjectId, accountNumber string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	bankAccount, ok := r.db.bankAccountByNumber(accountNumber)
+	if !ok {
+		return nil
+	}
+	bankAccount.PendingTranfers = removeTranfer(bankAccount.PendingTranfers, tranferID)
+	r.db.bankAccounts[bankAccount.ID] = bankAccount
+	return nil
+}
+
+func removeTranfer(pendingTranfers []bson.ObjectId, tranferID bson.ObjectId) []bson.ObjectId {
+	var result []bson.ObjectId
+	for _, pendingTranfer := range pendingTranfers {
+		if pendingTranfer != tranferID {
+			result = append(result, pendingTranfer)
+		}
+	}
+	return result
+}
+
+//MemoryTranferRepository is TranferRepository in MemoryDB
+type MemoryTranferRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryTranferRepository for NewMemoryTranferRepository
+func NewMemoryTranferRepository(db *MemoryDB) *MemoryTranferRepository {
+	return &MemoryTranferRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MemoryTranferRepository) Insert(tranferLog *model.TranferLog) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.tranfers[tranferLog.ID] = *tranferLog
+	return nil
+}
+
+//SetState for SetState
+func (r *MemoryTranferRepository) SetState(id bson.ObjectId, state model.TranferState, next model.TranferState, lastModified time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	tranferLog, ok := r.db.tranfers[id]
+	if !ok || tranferLog.State != state {
+		return ErrNotFound
+	}
+	tranferLog.State = next
+	tranferLog.LastModified = lastModified
+	r.db.tranfers[id] = tranferLog
+	return nil
+}
+
+//FindByState for FindByState
+func (r *MemoryTranferRepository) FindByState(states ...model.TranferState) ([]model.TranferLog, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var tranferLogs []model.TranferLog
+	for _, tranferLog := range r.db.tranfers {
+		for _, state := range states {
+			if tranferLog.State == state {
+				tranferLogs = append(tranferLogs, tranferLog)
+				break
+			}
+		}
+	}
+	sort.Slice(tranferLogs, func(i, j int) bool { return tranferLogs[i].ID < tranferLogs[j].ID })
+	return tranferLogs, nil
+}
+
+//FindByID for FindByID
+func (r *MemoryTranferRepository) FindByID(id bson.ObjectId) (model.TranferLog, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	tranferLog, ok := r.db.tranfers[id]
+	if !ok {
+		return model.TranferLog{}, ErrNotFound
+	}
+	return tranferLog, nil
+}
+
+//MemoryTransactionRepository is TransactionRepository in MemoryDB
+type MemoryTransactionRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryTransactionRepository for NewMemoryTransactionRepository
+func NewMemoryTransactionRepository(db *MemoryDB) *MemoryTransactionRepository {
+	return &MemoryTransactionRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MemoryTransactionRepository) Insert(transactionLog *model.TransactionLog) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.transactions = append(r.db.transactions, *transactionLog)
+	return nil
+}
+
+//InsertTranfer for InsertTranfer
+func (r *MemoryTransactionRepository) InsertTranfer(transactionLog *model.TransactionLog) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, stored := range r.db.transactions {
+		if stored.TranferID == transactionLog.TranferID && stored.AccountNumber == transactionLog.AccountNumber && stored.Type == transactionLog.Type {
+			return nil
+		}
+	}
+	r.db.transactions = append(r.db.transactions, *transactionLog)
+	return nil
+}
+
+//FindByBankAccount for FindByBankAccount
+func (r *MemoryTransactionRepository) FindByBankAccount(bankAccountID bson.ObjectId, filter model.TransactionFilter) ([]model.TransactionLog, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	transactionLogs := []model.TransactionLog{}
+	for _, transactionLog := range r.db.transactions {
+		if transactionLog.BankAccountID != bankAccountID {
+			continue
+		}
+		if !filter.From.IsZero() && transactionLog.CreatedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !transactionLog.CreatedAt.Before(filter.To) {
+			continue
+		}
+		transactionLogs = append(transactionLogs, transactionLog)
+	}
+	sort.Slice(transactionLogs, func(i, j int) bool {
+		if !transactionLogs[i].CreatedAt.Equal(transactionLogs[j].CreatedAt) {
+			return transactionLogs[i].CreatedAt.After(transactionLogs[j].CreatedAt)
+		}
+		return transactionLogs[i].ID > transactionLogs[j].ID
+	})
+	return paginate(transactionLogs, filter.Page, filter.Limit), nil
+}
+
+//CountByType for CountByType
+func (r *MemoryTransactionRepository) CountByType(bankAccountID bson.ObjectId, transactionType model.TransactionType, from time.Time) (int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	n := 0
+	for _, transactionLog := range r.db.transactions {
+		if transactionLog.BankAccountID == bankAccountID && transactionLog.Type == transactionType && !transactionLog.CreatedAt.Before(from) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func paginate(transactionLogs []model.TransactionLog, page int, limit int) []model.TransactionLog {
+	start := (page - 1) * limit
+	if start >= len(transactionLogs) {
+		return []model.TransactionLog{}
+	}
+	end := start + limit
+	if end > len(transactionLogs) {
+		end = len(transactionLogs)
+	}
+	return transactionLogs[start:end]
+}
+
+//MemoryRefreshTokenRepository is RefreshTokenRepository in MemoryDB
+type MemoryRefreshTokenRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryRefreshTokenRepository for NewMemoryRefreshTokenRepository
+func NewMemoryRefreshTokenRepository(db *MemoryDB) *MemoryRefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MemoryRefreshTokenRepository) Insert(refreshToken *model.RefreshToken) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.refreshTokens[refreshToken.ID] = *refreshToken
+	return nil
+}
+
+//FindByID for FindByID
+func (r *MemoryRefreshTokenRepository) FindByID(id string) (model.RefreshToken, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	refreshToken, ok := r.db.refreshTokens[id]
+	if !ok {
+		return model.RefreshToken{}, ErrNotFound
+	}
+	return refreshToken, nil
+}
+
+//Revoke for Revoke
+func (r *MemoryRefreshTokenRepository) Revoke(id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	refreshToken, ok := r.db.refreshTokens[id]
+	if !ok || refreshToken.Revoked {
+		return ErrNotFound
+	}
+	refreshToken.Revoked = true
+	r.db.refreshTokens[id] = refreshToken
+	return nil
+}
+
+//RevokeFamily for RevokeFamily
+func (r *MemoryRefreshTokenRepository) RevokeFamily(family bson.ObjectId) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for id, refreshToken := range r.db.refreshTokens {
+		if refreshToken.Family == family {
+			refreshToken.Revoked = true
+			r.db.refreshTokens[id] = refreshToken
+		}
+	}
+	return nil
+}
+
+//MemoryIdempotencyKeyRepository is IdempotencyKeyRepository in MemoryDB
+type MemoryIdempotencyKeyRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryIdempotencyKeyRepository for NewMemoryIdempotencyKeyRepository
+func NewMemoryIdempotencyKeyRepository(db *MemoryDB) *MemoryIdempotencyKeyRepository {
+	return &MemoryIdempotencyKeyRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MemoryIdempotencyKeyRepository) Insert(idempotencyKey *model.IdempotencyKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if _, ok := r.db.idempotency[idempotencyKey.ID]; ok {
+		return ErrDuplicate
+	}
+	r.db.idempotency[idempotencyKey.ID] = *idempotencyKey
+	return nil
+}
+
+//FindByID for FindByID
+func (r *MemoryIdempotencyKeyRepository) FindByID(id string) (model.IdempotencyKey, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	idempotencyKey, ok := r.db.idempotency[id]
+	if !ok {
+		return model.IdempotencyKey{}, ErrNotFound
+	}
+	return idempotencyKey, nil
+}
+
+//Complete for Complete
+func (r *MemoryIdempotencyKeyRepository) Complete(id string, status int, contentType string, body []byte) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	idempotencyKey, ok := r.db.idempotency[id]
+	if !ok {
+		return ErrNotFound
+	}
+	idempotencyKey.Completed = true
+	idempotencyKey.Status = status
+	idempotencyKey.ContentType = contentType
+	idempotencyKey.Body = append([]byte(nil), body...)
+	r.db.idempotency[id] = idempotencyKey
+	return nil
+}
+
+//Delete for Delete
+func (r *MemoryIdempotencyKeyRepository) Delete(id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if _, ok := r.db.idempotency[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.db.idempotency, id)
+	return nil
+}
+
+//DeleteExpired for DeleteExpired
+func (r *MemoryIdempotencyKeyRepository) DeleteExpired(now time.Time) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	removed := 0
+	for id, idempotencyKey := range r.db.idempotency {
+		if idempotencyKey.ExpiresAt.Before(now) {
+			delete(r.db.idempotency, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+//MemoryInterestRepository is InterestRepository in MemoryDB
+type MemoryInterestRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryInterestRepository for NewMemoryInterestRepository
+func NewMemoryInterestRepository(db *MemoryDB) *MemoryInterestRepository {
+	return &MemoryInterestRepository{db: db}
+}
+
+//InsertAccrual for InsertAccrual
+func (r *MemoryInterestRepository) InsertAccrual(accrual *model.InterestAccrual) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, other := range r.db.accruals {
+		if other.BankAccountID == accrual.BankAccountID && other.BusinessDate == accrual.BusinessDate {
+			return ErrDuplicate
+		}
+	}
+	r.db.accruals[accrual.ID] = *accrual
+	return nil
+}
+
+//FindAccruals for FindAccruals
+func (r *MemoryInterestRepository) FindAccruals(bankAccountID bson.ObjectId, from string) ([]model.InterestAccrual, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var accruals []model.InterestAccrual
+	for _, accrual := range r.db.accruals {
+		if accrual.BankAccountID == bankAccountID && accrual.BusinessDate >= from {
+			accruals = append(accruals, accrual)
+		}
+	}
+	sort.Slice(accruals, func(i, j int) bool { return accruals[i].BusinessDate < accruals[j].BusinessDate })
+	return accruals, nil
+}
+
+//InsertPosting for InsertPosting
+func (r *MemoryInterestRepository) InsertPosting(posting *model.InterestPosting) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, other := range r.db.postings {
+		if other.BankAccountID == posting.BankAccountID && other.Month == posting.Month {
+			return ErrDuplicate
+		}
+	}
+	r.db.postings[posting.ID] = *posting
+	return nil
+}
+
+//LastAccrualDate for LastAccrualDate
+func (r *MemoryInterestRepository) LastAccrualDate(bankAccountID bson.ObjectId) (string, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	last := ""
+	for _, accrual := range r.db.accruals {
+		if accrual.BankAccountID == bankAccountID && accrual.BusinessDate > last {
+			last = accrual.BusinessDate
+		}
+	}
+	if last == "" {
+		return "", ErrNotFound
+	}
+	return last, nil
+}
+
+//LastPosting for LastPosting
+func (r *MemoryInterestRepository) LastPosting(bankAccountID bson.ObjectId) (model.InterestPosting, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var last model.InterestPosting
+	found := false
+	for _, posting := range r.db.postings {
+		if posting.BankAccountID == bankAccountID && (!found || posting.Month > last.Month) {
+			last, found = posting, true
+		}
+	}
+	if !found {
+		return model.InterestPosting{}, ErrNotFound
+	}
+	return last, nil
+}
+
+//SetPostingState for SetPostingState
+func (r *MemoryInterestRepository) SetPostingState(id bson.ObjectId, state model.InterestPostingState, next model.InterestPostingState, lastModified time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	posting, ok := r.db.postings[id]
+	if !ok || posting.State != state {
+		return ErrNotFound
+	}
+	posting.State = next
+	posting.LastModified = lastModified
+	r.db.postings[id] = posting
+	return nil
+}
+
+//FindPostingsByState for FindPostingsByState
+func (r *MemoryInterestRepository) FindPostingsByState(state model.InterestPostingState) ([]model.InterestPosting, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var postings []model.InterestPosting
+	for _, posting := range r.db.postings {
+		if posting.State == state {
+			postings = append(postings, posting)
+		}
+	}
+	sort.Slice(postings, func(i, j int) bool { return postings[i].ID < postings[j].ID })
+	return postings, nil
+}
+
+//MemoryLimitRepository is LimitRepository in MemoryDB
+type MemoryLimitRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryLimitRepository for NewMemoryLimitRepository
+func NewMemoryLimitRepository(db *MemoryDB) *MemoryLimitRepository {
+	return &MemoryLimitRepository{db: db}
+}
+
+//copyLimits for copy MaxTranferCount so callers never share it with MemoryDB
+func copyLimits(limits model.Limits) model.Limits {
+	if limits.MaxTranferCount != nil {
+		count := *limits.MaxTranferCount
+		limits.MaxTranferCount = &count
+	}
+	return limits
+}
+
+//FindLimits for FindLimits
+func (r *MemoryLimitRepository) FindLimits(ownerID bson.ObjectId) (model.Limits, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	limits, ok := r.db.limits[ownerID]
+	if !ok {
+		return model.Limits{}, ErrNotFound
+	}
+	return copyLimits(limits), nil
+}
+
+//SetLimits for SetLimits
+func (r *MemoryLimitRepository) SetLimits(ownerID bson.ObjectId, limits model.Limits) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.limits[ownerID] = copyLimits(limits)
+	return nil
+}
+
+//FindUsage for FindUsage
+func (r *MemoryLimitRepository) FindUsage(ownerID bson.ObjectId, businessDate string) (model.LimitUsage, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	usage, ok := r.db.limitUsage[limitUsageKey{ownerID, businessDate}]
+	if !ok {
+		return model.LimitUsage{OwnerID: ownerID, BusinessDate: businessDate}, nil
+	}
+	return usage, nil
+}
+
+//AddUsage for AddUsage
+func (r *MemoryLimitRepository) AddUsage(ownerID bson.ObjectId, businessDate string, amount int64, count int) (model.LimitUsage, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	key := limitUsageKey{ownerID, businessDate}
+	usage, ok := r.db.limitUsage[key]
+	if !ok {
+		usage = model.LimitUsage{OwnerID: ownerID, BusinessDate: businessDate}
+	}
+	usage.TranferTotal += amount
+	usage.TranferCount += count
+	r.db.limitUsage[key] = usage
+	return usage, nil
+}
+
+//MemoryStandingOrderRepository is StandingOrderRepository in MemoryDB
+type MemoryStandingOrderRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryStandingOrderRepository for NewMemoryStandingOrderRepository
+func NewMemoryStandingOrderRepository(db *MemoryDB) *MemoryStandingOrderRepository {
+	return &MemoryStandingOrderRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MemoryStandingOrderRepository) Insert(order *model.StandingOrder) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if _, ok := r.db.orders[order.ID]; ok {
+		return ErrDuplicate
+	}
+	r.db.orders[order.ID] = *order
+	return nil
+}
+
+//FindByID for FindByID
+func (r *MemoryStandingOrderRepository) FindByID(id bson.ObjectId) (model.StandingOrder, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	order, ok := r.db.orders[id]
+	if !ok {
+		return model.StandingOrder{}, ErrNotFound
+	}
+	return order, nil
+}
+
+//findOrders for StandingOrder that match, caller must not hold mu
+func (r *MemoryStandingOrderRepository) findOrders(match func(order model.StandingOrder) bool) []model.StandingOrder {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var orders []model.StandingOrder
+	for _, order := range r.db.orders {
+		if match(order) {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+//FindByUser for FindByUser
+func (r *MemoryStandingOrderRepository) FindByUser(userID bson.ObjectId) ([]model.StandingOrder, error) {
+	orders := r.findOrders(func(order model.StandingOrder) bool { return order.UserID == userID })
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders, nil
+}
+
+//FindDue for FindDue
+func (r *MemoryStandingOrderRepository) FindDue(now time.Time) ([]model.StandingOrder, error) {
+	orders := r.findOrders(func(order model.StandingOrder) bool {
+		return order.State == model.StandingOrderActive && !order.NextRun.After(now)
+	})
+	sort.Slice(orders, func(i, j int) bool { return orders[i].NextRun.Before(orders[j].NextRun) })
+	return orders, nil
+}
+
+//Update for Update
+func (r *MemoryStandingOrderRepository) Update(order *model.StandingOrder) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	current, ok := r.db.orders[order.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if current.Version != order.Version {
+		return ErrConflict
+	}
+	stored := *order
+	stored.Version++
+	r.db.orders[order.ID] = stored
+	order.Version = stored.Version
+	return nil
+}
+
+//InsertExecution for InsertExecution
+func (r *MemoryStandingOrderRepository) InsertExecution(execution *model.StandingOrderExecution) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.executions = append(r.db.executions, *execution)
+	return nil
+}
+
+//FindExecutions for FindExecutions
+func (r *MemoryStandingOrderRepository) FindExecutions(standingOrderID bson.ObjectId) ([]model.StandingOrderExecution, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var executions []model.StandingOrderExecution
+	for i := len(r.db.executions) - 1; i >= 0; i-- {
+		if r.db.executions[i].StandingOrderID == standingOrderID {
+			executions = append(executions, r.db.executions[i])
+		}
+	}
+	return executions, nil
+}
+
+//MemoryBulkPaymentRepository is BulkPaymentRepository in MemoryDB
+type MemoryBulkPaymentRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryBulkPaymentRepository for NewMemoryBulkPaymentRepository
+func NewMemoryBulkPaymentRepository(db *MemoryDB) *MemoryBulkPaymentRepository {
+	return &MemoryBulkPaymentRepository{db: db}
+}
+
+//copyBulkPayment for copy Lines of BulkPayment so callers never share them with MemoryDB
+func copyBulkPayment(bulkPayment model.BulkPayment) model.BulkPayment {
+	bulkPayment.Lines = append([]model.BulkPaymentLine(nil), bulkPayment.Lines...)
+	return bulkPayment
+}
+
+//Insert for Insert
+func (r *MemoryBulkPaymentRepository) Insert(bulkPayment *model.BulkPayment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, stored := range r.db.bulkPayments {
+		if stored.ID == bulkPayment.ID || stored.UserID == bulkPayment.UserID && stored.MessageID == bulkPayment.MessageID {
+			return ErrDuplicate
+		}
+	}
+	r.db.bulkPayments[bulkPayment.ID] = copyBulkPayment(*bulkPayment)
+	return nil
+}
+
+//FindByID for FindByID
+func (r *MemoryBulkPaymentRepository) FindByID(id bson.ObjectId) (model.BulkPayment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	bulkPayment, ok := r.db.bulkPayments[id]
+	if !ok {
+		return model.BulkPayment{}, ErrNotFound
+	}
+	return copyBulkPayment(bulkPayment), nil
+}
+
+//findBulkPayments for BulkPayment that match oldest first, caller must not hold mu
+func (r *MemoryBulkPaymentRepository) findBulkPayments(match func(bulkPayment model.BulkPayment) bool) []model.BulkPayment {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var bulkPayments []model.BulkPayment
+	for _, bulkPayment := range r.db.bulkPayments {
+		if match(bulkPayment) {
+			bulkPayments = append(bulkPayments, copyBulkPayment(bulkPayment))
+		}
+	}
+	sort.Slice(bulkPayments, func(i, j int) bool { return bulkPayments[i].ID < bulkPayments[j].ID })
+	return bulkPayments
+}
+
+//FindByUser for FindByUser
+func (r *MemoryBulkPaymentRepository) FindByUser(userID bson.ObjectId) ([]model.BulkPayment, error) {
+	return r.findBulkPayments(func(bulkPayment model.BulkPayment) bool { return bulkPayment.UserID == userID }), nil
+}
+
+//FindByState for FindByState
+func (r *MemoryBulkPaymentRepository) FindByState(states ...model.BulkPaymentState) ([]model.BulkPayment, error) {
+	return r.findBulkPayments(func(bulkPayment model.BulkPayment) bool {
+		for _, state := range states {
+			if bulkPayment.State == state {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+//SetState for SetState
+func (r *MemoryBulkPaymentRepository) SetState(id bson.ObjectId, state model.BulkPaymentState, next model.BulkPaymentState, lastModified time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	bulkPayment, ok := r.db.bulkPayments[id]
+	if !ok || bulkPayment.State != state {
+		return ErrNotFound
+	}
+	bulkPayment.State = next
+	bulkPayment.LastModified = lastModified
+	r.db.bulkPayments[id] = bulkPayment
+	return nil
+}
+
+//SetLine for SetLine
+func (r *MemoryBulkPaymentRepository) SetLine(id bson.ObjectId, line model.BulkPaymentLine, status model.BulkPaymentLineStatus) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	bulkPayment, ok := r.db.bulkPayments[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for i := range bulkPayment.Lines {
+		stored := &bulkPayment.Lines[i]
+		if stored.Line != line.Line {
+			continue
+		}
+		if stored.Status != status {
+			return ErrNotFound
+		}
+		stored.Status = line.Status
+		stored.ReasonCode = line.ReasonCode
+		stored.Reason = line.Reason
+		stored.TranferID = line.TranferID
+		stored.LastModified = line.LastModified
+		return nil
+	}
+	return ErrNotFound
+}
+
+//MemoryApprovalRepository is ApprovalRepository in MemoryDB
+type MemoryApprovalRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryApprovalRepository for NewMemoryApprovalRepository
+func NewMemoryApprovalRepository(db *MemoryDB) *MemoryApprovalRepository {
+	return &MemoryApprovalRepository{db: db}
+}
+
+//copyApproval for copy Tranfer of Approval so callers never share it with MemoryDB
+func copyApproval(approval model.Approval) model.Approval {
+	if approval.Tranfer != nil {
+		tranfer := *approval.Tranfer
+		approval.Tranfer = &tranfer
+	}
+	return approval
+}
+
+//Insert for Insert
+func (r *MemoryApprovalRepository) Insert(approval *model.Approval) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if _, ok := r.db.approvals[approval.ID]; ok {
+		return ErrDuplicate
+	}
+	r.db.approvals[approval.ID] = copyApproval(*approval)
+	return nil
+}
+
+//FindByID for FindByID
+func (r *MemoryApprovalRepository) FindByID(id bson.ObjectId) (model.Approval, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	approval, ok := r.db.approvals[id]
+	if !ok {
+		return model.Approval{}, ErrNotFound
+	}
+	return copyApproval(approval), nil
+}
+
+//FindByState for FindByState
+func (r *MemoryApprovalRepository) FindByState(states ...model.ApprovalState) ([]model.Approval, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var approvals []model.Approval
+	for _, approval := range r.db.approvals {
+		for _, state := range states {
+			if approval.State == state {
+				approvals = append(approvals, copyApproval(approval))
+				break
+			}
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool { return approvals[i].ID < approvals[j].ID })
+	return approvals, nil
+}
+
+//SetState for SetState
+func (r *MemoryApprovalRepository) SetState(approval model.Approval, state model.ApprovalState) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	stored, ok := r.db.approvals[approval.ID]
+	if !ok || stored.State != state {
+		return ErrNotFound
+	}
+	stored.State = approval.State
+	stored.CheckerID = approval.CheckerID
+	stored.TranferID = approval.TranferID
+	stored.Reason = approval.Reason
+	stored.Error = approval.Error
+	stored.LastModified = approval.LastModified
+	r.db.approvals[approval.ID] = stored
+	return nil
+}
+
+//MemoryAuditRepository is AuditRepository in MemoryDB
+type MemoryAuditRepository struct {
+	db *MemoryDB
+}
+
+//NewMemoryAuditRepository for NewMemoryAuditRepository
+func NewMemoryAuditRepository(db *MemoryDB) *MemoryAuditRepository {
+	return &MemoryAuditRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MemoryAuditRepository) Insert(entry *model.AuditEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.audit = append(r.db.audit, *entry)
+	return nil
+}
+
+//FindByApproval for FindByApproval
+func (r *MemoryAuditRepository) FindByApproval(approvalID bson.ObjectId) ([]model.AuditEntry, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	var entries []model.AuditEntry
+	for _, entry := range r.db.audit {
+		if entry.ApprovalID == approvalID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
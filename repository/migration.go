@@ -0,0 +1,454 @@
+package repository
+
+import (
+	"bankaccountapi/model"
+	"database/sql"
+	"fmt"
+	"time"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+//migration is one version of SQL schema, a migration is never changed after it is released
+type migration struct {
+	Version    int
+	Statements []string
+}
+
+//migrations for SQL schema, append a new version to change schema
+var migrations = []migration{
+	{
+		Version: 1,
+		Statements: []string{
+			`CREATE TABLE users (
+				id TEXT PRIMARY KEY,
+				first_name TEXT NOT NULL,
+				last_name TEXT NOT NULL,
+				username TEXT NOT NULL,
+				password TEXT NOT NULL,
+				idcard TEXT NOT NULL,
+				age BIGINT NOT NULL,
+				email TEXT NOT NULL,
+				tel TEXT NOT NULL
+			)`,
+			`CREATE INDEX users_username ON users (username)`,
+			`CREATE TABLE bank_accounts (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users (id),
+				bank_name TEXT NOT NULL,
+				account_number TEXT NOT NULL UNIQUE,
+				balance BIGINT NOT NULL,
+				currency TEXT NOT NULL,
+				overdraft_limit BIGINT NOT NULL
+			)`,
+			`CREATE INDEX bank_accounts_user_id ON bank_accounts (user_id)`,
+			`CREATE TABLE tranfers (
+				id TEXT PRIMARY KEY,
+				user_from TEXT NOT NULL,
+				user_to TEXT NOT NULL,
+				account_number_from TEXT NOT NULL,
+				account_number_to TEXT NOT NULL,
+				amount BIGINT NOT NULL,
+				currency TEXT NOT NULL,
+				state TEXT NOT NULL,
+				last_modified TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX tranfers_state ON tranfers (state)`,
+			`CREATE TABLE pending_tranfers (
+				tranfer_id TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				PRIMARY KEY (tranfer_id, user_id)
+			)`,
+			`CREATE TABLE transactions (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				bank_account_id TEXT NOT NULL,
+				account_number TEXT NOT NULL,
+				type TEXT NOT NULL,
+				amount BIGINT NOT NULL,
+				balance_after BIGINT NOT NULL,
+				currency TEXT NOT NULL,
+				counterparty_account TEXT NOT NULL,
+				tranfer_id TEXT,
+				created_by TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX transactions_bank_account_id ON transactions (bank_account_id, created_at)`,
+			`CREATE UNIQUE INDEX transactions_tranfer ON transactions (tranfer_id, account_number, type)`,
+			`CREATE TABLE refresh_tokens (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				family TEXT NOT NULL,
+				revoked BOOLEAN NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX refresh_tokens_family ON refresh_tokens (family)`,
+		},
+	},
+	{
+		//pending Tranfer belongs to BankAccount instead of User
+		Version: 2,
+		Statements: []string{
+			`CREATE TABLE bank_account_tranfers (
+				tranfer_id TEXT NOT NULL,
+				bank_account_id TEXT NOT NULL,
+				PRIMARY KEY (tranfer_id, bank_account_id)
+			)`,
+			`INSERT INTO bank_account_tranfers (tranfer_id, bank_account_id)
+				SELECT p.tranfer_id, b.id FROM pending_tranfers p
+				JOIN tranfers t ON t.id = p.tranfer_id
+				JOIN bank_accounts b ON b.user_id = p.user_id AND (
+					(t.user_from = p.user_id AND b.account_number = t.account_number_from) OR
+					(t.user_to = p.user_id AND b.account_number = t.account_number_to))`,
+			`DROP TABLE pending_tranfers`,
+		},
+	},
+	{
+		Version: 3,
+		Statements: []string{
+			`CREATE TABLE idempotency_keys (
+				id TEXT PRIMARY KEY,
+				request_hash TEXT NOT NULL,
+				completed BOOLEAN NOT NULL,
+				status INTEGER NOT NULL,
+				content_type TEXT NOT NULL,
+				body BYTEA,
+				expires_at TIMESTAMP NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+		},
+	},
+	{
+		Version: 4,
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE bank_accounts ADD COLUMN version BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		//Tranfer between BankAccount of different Currency
+		Version: 5,
+		Statements: []string{
+			`ALTER TABLE tranfers ADD COLUMN amount_to BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE tranfers ADD COLUMN currency_to TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tranfers ADD COLUMN rate TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		//Product of BankAccount and interest it earns
+		Version: 6,
+		Statements: []string{
+			`ALTER TABLE bank_accounts ADD COLUMN product TEXT NOT NULL DEFAULT ''`,
+			`CREATE TABLE interest_accruals (
+				id TEXT PRIMARY KEY,
+				bank_account_id TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				business_date TEXT NOT NULL,
+				product TEXT NOT NULL,
+				interest_rate TEXT NOT NULL,
+				day_count TEXT NOT NULL,
+				balance BIGINT NOT NULL,
+				currency TEXT NOT NULL,
+				interest_micros BIGINT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX interest_accruals_business_date ON interest_accruals (bank_account_id, business_date)`,
+			`CREATE TABLE interest_postings (
+				id TEXT PRIMARY KEY,
+				bank_account_id TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				account_number TEXT NOT NULL,
+				month TEXT NOT NULL,
+				amount BIGINT NOT NULL,
+				currency TEXT NOT NULL,
+				state TEXT NOT NULL,
+				last_modified TIMESTAMP NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX interest_postings_month ON interest_postings (bank_account_id, month)`,
+			`CREATE INDEX interest_postings_state ON interest_postings (state)`,
+		},
+	},
+	{
+		//fee is moved by TranferLog of its own kind
+		Version: 7,
+		Statements: []string{
+			`ALTER TABLE tranfers ADD COLUMN kind TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX transactions_type ON transactions (bank_account_id, type, created_at)`,
+		},
+	},
+	{
+		//Limits lowered by User or BankAccount and what is used of them each business date
+		Version: 8,
+		Statements: []string{
+			`CREATE TABLE limits (
+				owner_id TEXT PRIMARY KEY,
+				max_withdraw TEXT NOT NULL,
+				max_tranfer_daily TEXT NOT NULL,
+				max_tranfer_count BIGINT
+			)`,
+			`CREATE TABLE limit_usage (
+				owner_id TEXT NOT NULL,
+				business_date TEXT NOT NULL,
+				tranfer_total BIGINT NOT NULL,
+				tranfer_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_id, business_date)
+			)`,
+		},
+	},
+	{
+		//StandingOrder and what each run of it did
+		Version: 9,
+		Statements: []string{
+			`CREATE TABLE standing_orders (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				account_number_from TEXT NOT NULL,
+				account_number_to TEXT NOT NULL,
+				amount BIGINT NOT NULL,
+				currency TEXT NOT NULL,
+				schedule_kind TEXT NOT NULL,
+				schedule_date TEXT NOT NULL,
+				schedule_weekday TEXT NOT NULL,
+				schedule_day BIGINT NOT NULL,
+				end_date TEXT NOT NULL,
+				end_count BIGINT NOT NULL,
+				executed BIGINT NOT NULL,
+				next_date TEXT NOT NULL,
+				next_run TIMESTAMP NOT NULL,
+				attempts BIGINT NOT NULL,
+				state TEXT NOT NULL,
+				last_error TEXT NOT NULL,
+				version BIGINT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				last_modified TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX standing_orders_user_id ON standing_orders (user_id)`,
+			`CREATE INDEX standing_orders_next_run ON standing_orders (state, next_run)`,
+			`CREATE TABLE standing_order_executions (
+				id TEXT PRIMARY KEY,
+				standing_order_id TEXT NOT NULL,
+				business_date TEXT NOT NULL,
+				attempt BIGINT NOT NULL,
+				status TEXT NOT NULL,
+				tranfer_id TEXT,
+				error TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX standing_order_executions_order ON standing_order_executions (standing_order_id, created_at)`,
+		},
+	},
+	{
+		//BulkPayment uploaded as pain.001 and each credit transfer of it
+		Version: 10,
+		Statements: []string{
+			`CREATE TABLE bulk_payments (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				message_id TEXT NOT NULL,
+				message_created_at TEXT NOT NULL,
+				number_of_transactions TEXT NOT NULL,
+				control_sum TEXT NOT NULL,
+				state TEXT NOT NULL,
+				reason_code TEXT NOT NULL,
+				reason TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				last_modified TIMESTAMP NOT NULL,
+				UNIQUE (user_id, message_id)
+			)`,
+			`CREATE INDEX bulk_payments_state ON bulk_payments (state)`,
+			`CREATE TABLE bulk_payment_lines (
+				bulk_payment_id TEXT NOT NULL,
+				line BIGINT NOT NULL,
+				payment_information_id TEXT NOT NULL,
+				instruction_id TEXT NOT NULL,
+				end_to_end_id TEXT NOT NULL,
+				account_number_from TEXT NOT NULL,
+				account_number_to TEXT NOT NULL,
+				amount BIGINT NOT NULL,
+				currency TEXT NOT NULL,
+				status TEXT NOT NULL,
+				reason_code TEXT NOT NULL,
+				reason TEXT NOT NULL,
+				tranfer_id TEXT,
+				last_modified TIMESTAMP NOT NULL,
+				PRIMARY KEY (bulk_payment_id, line)
+			)`,
+		},
+	},
+	{
+		Version: 11,
+		Statements: []string{
+			`CREATE INDEX users_username_id ON users (username, id)`,
+			`CREATE INDEX users_first_name_id ON users (first_name, id)`,
+			`CREATE INDEX users_last_name_id ON users (last_name, id)`,
+			`CREATE INDEX users_age_id ON users (age, id)`,
+			`CREATE INDEX users_email ON users (email)`,
+			`CREATE INDEX users_tel ON users (tel)`,
+			`CREATE INDEX bank_accounts_bank_name ON bank_accounts (bank_name, user_id)`,
+		},
+	},
+	{
+		Version: 12,
+		Statements: []string{
+			`ALTER TABLE users ADD COLUMN roles TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		//Approval of maker-checker and AuditEntry of what happened to it
+		Version: 13,
+		Statements: []string{
+			`CREATE TABLE approvals (
+				id TEXT PRIMARY KEY,
+				kind TEXT NOT NULL,
+				state TEXT NOT NULL,
+				maker_id TEXT NOT NULL,
+				checker_id TEXT,
+				user_id TEXT NOT NULL,
+				bank_account_id TEXT,
+				account_number_from TEXT NOT NULL,
+				account_number_to TEXT NOT NULL,
+				amount BIGINT NOT NULL,
+				currency TEXT NOT NULL,
+				tranfer_id TEXT,
+				reason TEXT NOT NULL,
+				error TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				last_modified TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX approvals_state ON approvals (state)`,
+			`CREATE TABLE audit (
+				id TEXT PRIMARY KEY,
+				approval_id TEXT NOT NULL,
+				action TEXT NOT NULL,
+				actor_id TEXT,
+				detail TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX audit_approval_id ON audit (approval_id, id)`,
+		},
+	},
+	{
+		//fee is a leg of TranferLog of what it is charged for
+		Version: 14,
+		Statements: []string{
+			`ALTER TABLE tranfers ADD COLUMN fee BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE tranfers ADD COLUMN fee_currency TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tranfers ADD COLUMN fee_to BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE tranfers ADD COLUMN fee_currency_to TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tranfers ADD COLUMN fee_account_number TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tranfers ADD COLUMN fee_id TEXT`,
+		},
+	},
+}
+
+//Migrate for bring SQL schema up to the latest version, versions already applied are skipped
+func Migrate(db *sql.DB, dialect Dialect) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		err = withTx(db, func(tx *sql.Tx) error {
+			var applied int
+			err := tx.QueryRow(dialect.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), m.Version).Scan(&applied)
+			if err != nil || applied > 0 {
+				return err
+			}
+			for _, statement := range m.Statements {
+				if _, err := tx.Exec(statement); err != nil {
+					return err
+				}
+			}
+			_, err = tx.Exec(dialect.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), m.Version, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//legacyUser is User as it was stored in mgo when BankAccount was embedded in it
+type legacyUser struct {
+	ID              bson.ObjectId       `bson:"_id"`
+	UserBankAccount []model.BankAccount `bson:"user_bank_account"`
+	PendingTranfers []bson.ObjectId     `bson:"pending_tranfers"`
+}
+
+//NeedMongoBankAccountMigration for check some User in mgo still has BankAccount embedded in it
+func NeedMongoBankAccountMigration(db *mgo.Database) (bool, error) {
+	n, err := db.C(COLLECTIONUser).Find(bson.M{"user_bank_account": bson.M{"$exists": true}}).Count()
+	return n > 0, err
+}
+
+//MigrateMongoBankAccounts for move BankAccount embedded in User to their own collection,
+//it returns number of users migrated and it is safe to run again if it stops half way
+func MigrateMongoBankAccounts(db *mgo.Database) (int, error) {
+	if err := EnsureMongoIndex(db); err != nil {
+		return 0, err
+	}
+	migrated := 0
+	var user legacyUser
+	iter := db.C(COLLECTIONUser).Find(bson.M{"user_bank_account": bson.M{"$exists": true}}).Iter()
+	for iter.Next(&user) {
+		for _, bankAccount := range user.UserBankAccount {
+			bankAccount.UserID = user.ID
+			pendingTranfers, err := legacyPendingTranfers(db, user, bankAccount.AccountNumber)
+			if err != nil {
+				iter.Close()
+				return migrated, err
+			}
+			bankAccount.PendingTranfers = pendingTranfers
+			_, err = db.C(COLLECTIONBankAccount).UpsertId(bankAccount.ID, bankAccount)
+			if mgo.IsDup(err) {
+				iter.Close()
+				return migrated, fmt.Errorf("AccountNumber %s of user %s is duplicate", bankAccount.AccountNumber, user.ID.Hex())
+			}
+			if err != nil {
+				iter.Close()
+				return migrated, err
+			}
+		}
+		err := db.C(COLLECTIONUser).UpdateId(user.ID, bson.M{"$unset": bson.M{"user_bank_account": "", "pending_tranfers": ""}})
+		if err != nil {
+			iter.Close()
+			return migrated, err
+		}
+		migrated++
+		user = legacyUser{}
+	}
+	if err := iter.Close(); err != nil {
+		return migrated, err
+	}
+	//index of embedded AccountNumber is not used anymore
+	db.C(COLLECTIONUser).DropIndex("user_bank_account.account_number")
+	return migrated, nil
+}
+
+//legacyPendingTranfers for find which pending Tranfer of user was applied to accountNumber
+func legacyPendingTranfers(db *mgo.Database, user legacyUser, accountNumber string) ([]bson.ObjectId, error) {
+	var pendingTranfers []bson.ObjectId
+	for _, tranferID := range user.PendingTranfers {
+		var tranferLog model.TranferLog
+		err := db.C(COLLECTIONTranfer).FindId(tranferID).One(&tranferLog)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if (tranferLog.UserFrom == user.ID && tranferLog.AccountNumberFrom == accountNumber) ||
+			(tranferLog.UserTo == user.ID && tranferLog.AccountNumberTo == accountNumber) {
+			pendingTranfers = append(pendingTranfers, tranferID)
+		}
+	}
+	return pendingTranfers, nil
+}
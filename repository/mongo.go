@@ -0,0 +1,1062 @@
+package repository
+
+import (
+	"bankaccountapi/model"
+	"regexp"
+	"strconv"
+	"time"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	//COLLECTIONUser users in mgo
+	COLLECTIONUser = "users"
+	//COLLECTIONBankAccount bank_accounts in mgo
+	COLLECTIONBankAccount = "bank_accounts"
+	//COLLECTIONTranfer tranfers in mgo
+	COLLECTIONTranfer = "tranfers"
+	//COLLECTIONTransaction transactions in mgo
+	COLLECTIONTransaction = "transactions"
+	//COLLECTIONRefreshToken refresh_tokens in mgo
+	COLLECTIONRefreshToken = "refresh_tokens"
+	//COLLECTIONIdempotencyKey idempotency_keys in mgo
+	COLLECTIONIdempotencyKey = "idempotency_keys"
+	//COLLECTIONInterestAccrual interest_accruals in mgo
+	COLLECTIONInterestAccrual = "interest_accruals"
+	//COLLECTIONInterestPosting interest_postings in mgo
+	COLLECTIONInterestPosting = "interest_postings"
+	//COLLECTIONLimit limits in mgo
+	COLLECTIONLimit = "limits"
+	//COLLECTIONLimitUsage limit_usage in mgo
+	COLLECTIONLimitUsage = "limit_usage"
+	//COLLECTIONStandingOrder standing_orders in mgo
+	COLLECTIONStandingOrder = "standing_orders"
+	//COLLECTIONStandingOrderExecution standing_order_executions in mgo
+	COLLECTIONStandingOrderExecution = "standing_order_executions"
+	//COLLECTIONBulkPayment bulk_payments in mgo
+	COLLECTIONBulkPayment = "bulk_payments"
+	//COLLECTIONApproval approvals in mgo
+	COLLECTIONApproval = "approvals"
+	//COLLECTIONAudit audit in mgo
+	COLLECTIONAudit = "audit"
+)
+
+//EnsureMongoIndex for create every index the mgo repositories need
+func EnsureMongoIndex(db *mgo.Database) error {
+	if err := db.C(COLLECTIONTransaction).EnsureIndexKey("bank_account_id", "-created_at"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONUser).EnsureIndexKey("username"); err != nil {
+		return err
+	}
+	//indexes of sort and filter of UserRepository.Find
+	for _, key := range [][]string{{"username", "_id"}, {"first_name", "_id"}, {"last_name", "_id"}, {"age", "_id"}, {"email"}, {"tel"}} {
+		if err := db.C(COLLECTIONUser).EnsureIndexKey(key...); err != nil {
+			return err
+		}
+	}
+	if err := db.C(COLLECTIONBankAccount).EnsureIndex(mgo.Index{Key: []string{"account_number"}, Unique: true}); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONBankAccount).EnsureIndexKey("user_id"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONBankAccount).EnsureIndexKey("bank_name", "user_id"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONIdempotencyKey).EnsureIndexKey("expires_at"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONInterestAccrual).EnsureIndex(mgo.Index{Key: []string{"bank_account_id", "business_date"}, Unique: true}); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONInterestPosting).EnsureIndex(mgo.Index{Key: []string{"bank_account_id", "month"}, Unique: true}); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONInterestPosting).EnsureIndexKey("state"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONLimitUsage).EnsureIndex(mgo.Index{Key: []string{"owner_id", "business_date"}, Unique: true}); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONStandingOrder).EnsureIndexKey("user_id"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONStandingOrder).EnsureIndexKey("state", "next_run"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONStandingOrderExecution).EnsureIndexKey("standing_order_id", "-created_at"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONBulkPayment).EnsureIndex(mgo.Index{Key: []string{"user_id", "message_id"}, Unique: true}); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONBulkPayment).EnsureIndexKey("state"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONApproval).EnsureIndexKey("state"); err != nil {
+		return err
+	}
+	if err := db.C(COLLECTIONAudit).EnsureIndexKey("approval_id", "_id"); err != nil {
+		return err
+	}
+	return db.C(COLLECTIONRefreshToken).EnsureIndex(mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second})
+}
+
+//versionQuery for match Version, documents stored before Version existed have no version field
+func versionQuery(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": []interface{}{0, nil}}
+	}
+	return version
+}
+
+func mongoError(err error) error {
+	if err == mgo.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+//MongoUserRepository is UserRepository in mgo
+type MongoUserRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoUserRepository for NewMongoUserRepository
+func NewMongoUserRepository(db *mgo.Database) *MongoUserRepository {
+	return &MongoUserRepository{db: db}
+}
+
+//loadBankAccounts for fill UserBankAccount of users with one query
+func (r *MongoUserRepository) loadBankAccounts(users []model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	userIDs := make([]bson.ObjectId, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	var bankAccounts []model.BankAccount
+	err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"user_id": bson.M{"$in": userIDs}}).Sort("_id").All(&bankAccounts)
+	if err != nil {
+		return err
+	}
+	byUser := map[bson.ObjectId][]model.BankAccount{}
+	for _, bankAccount := range bankAccounts {
+		byUser[bankAccount.UserID] = append(byUser[bankAccount.UserID], bankAccount)
+	}
+	for i := range users {
+		users[i].UserBankAccount = byUser[users[i].ID]
+	}
+	return nil
+}
+
+func (r *MongoUserRepository) findOne(query bson.M) (model.User, error) {
+	var user model.User
+	err := r.db.C(COLLECTIONUser).Find(query).One(&user)
+	if err != nil {
+		return user, mongoError(err)
+	}
+	users := []model.User{user}
+	err = r.loadBankAccounts(users)
+	return users[0], err
+}
+
+//FindAll for FindAll
+func (r *MongoUserRepository) FindAll() ([]model.User, error) {
+	var users []model.User
+	err := r.db.C(COLLECTIONUser).Find(bson.M{}).All(&users)
+	if err != nil {
+		return nil, err
+	}
+	return users, r.loadBankAccounts(users)
+}
+
+//mongoUserSorts is field of each sort of User
+var mongoUserSorts = map[string]string{
+	model.UserSortID:        "_id",
+	model.UserSortUsername:  "username",
+	model.UserSortFirstName: "first_name",
+	model.UserSortLastName:  "last_name",
+	model.UserSortAge:       "age",
+}
+
+//Find for Find
+func (r *MongoUserRepository) Find(filter model.UserFilter) ([]model.User, error) {
+	var conditions []bson.M
+	if filter.NamePrefix != "" {
+		prefix := bson.RegEx{Pattern: "^" + regexp.QuoteMeta(filter.NamePrefix)}
+		conditions = append(conditions, bson.M{"$or": []bson.M{{"first_name": prefix}, {"last_name": prefix}}})
+	}
+	if filter.Email != "" {
+		conditions = append(conditions, bson.M{"email": filter.Email})
+	}
+	if filter.Tel != "" {
+		conditions = append(conditions, bson.M{"tel": filter.Tel})
+	}
+	if filter.AgeMin > 0 {
+		conditions = append(conditions, bson.M{"age": bson.M{"$gte": filter.AgeMin}})
+	}
+	if filter.AgeMax > 0 {
+		conditions = append(conditions, bson.M{"age": bson.M{"$lte": filter.AgeMax}})
+	}
+	if filter.BankName != "" {
+		var userIDs []bson.ObjectId
+		if err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"bank_name": filter.BankName}).Distinct("user_id", &userIDs); err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, bson.M{"_id": bson.M{"$in": userIDs}})
+	}
+
+	field, ok := mongoUserSorts[filter.Sort]
+	if !ok {
+		field = "_id"
+	}
+	compare, direction := "$gt", ""
+	if filter.Descending {
+		compare, direction = "$lt", "-"
+	}
+	if after := filter.After; after != nil {
+		var value interface{} = after.Value
+		if field == "age" {
+			age, _ := strconv.ParseInt(after.Value, 10, 64)
+			value = age
+		}
+		if field == "_id" {
+			conditions = append(conditions, bson.M{"_id": bson.M{compare: after.ID}})
+		} else {
+			conditions = append(conditions, bson.M{"$or": []bson.M{
+				{field: bson.M{compare: value}},
+				{field: value, "_id": bson.M{compare: after.ID}},
+			}})
+		}
+	}
+
+	query := bson.M{}
+	if len(conditions) > 0 {
+		query["$and"] = conditions
+	}
+	sort := []string{direction + field}
+	if field != "_id" {
+		sort = append(sort, direction+"_id")
+	}
+	var users []model.User
+	err := r.db.C(COLLECTIONUser).Find(query).Sort(sort...).Limit(filter.Limit).All(&users)
+	if err != nil {
+		return nil, err
+	}
+	return users, r.loadBankAccounts(users)
+}
+
+//FindByID for FindByID
+func (r *MongoUserRepository) FindByID(id bson.ObjectId) (model.User, error) {
+	return r.findOne(bson.M{"_id": id})
+}
+
+//FindByUsername for FindByUsername
+func (r *MongoUserRepository) FindByUsername(username string) (model.User, error) {
+	return r.findOne(bson.M{"username": username})
+}
+
+//Insert for Insert
+func (r *MongoUserRepository) Insert(user *model.User) error {
+	return r.db.C(COLLECTIONUser).Insert(user)
+}
+
+//InsertWithBankAccounts for InsertWithBankAccounts, mgo has no transaction so users are removed again
+//when bankAccounts can not be inserted
+func (r *MongoUserRepository) InsertWithBankAccounts(users []model.User, bankAccounts []model.BankAccount) error {
+	var userIDs []bson.ObjectId
+	var docs []interface{}
+	for i := range users {
+		userIDs = append(userIDs, users[i].ID)
+		docs = append(docs, &users[i])
+	}
+	if len(docs) > 0 {
+		if err := r.db.C(COLLECTIONUser).Insert(docs...); err != nil {
+			r.db.C(COLLECTIONUser).RemoveAll(bson.M{"_id": bson.M{"$in": userIDs}})
+			return err
+		}
+	}
+	var bankAccountIDs []bson.ObjectId
+	docs = nil
+	for i := range bankAccounts {
+		bankAccountIDs = append(bankAccountIDs, bankAccounts[i].ID)
+		docs = append(docs, &bankAccounts[i])
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	err := r.db.C(COLLECTIONBankAccount).Insert(docs...)
+	if err == nil {
+		return nil
+	}
+	r.db.C(COLLECTIONBankAccount).RemoveAll(bson.M{"_id": bson.M{"$in": bankAccountIDs}})
+	r.db.C(COLLECTIONUser).RemoveAll(bson.M{"_id": bson.M{"$in": userIDs}})
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//Update for Update
+func (r *MongoUserRepository) Update(user *model.User) error {
+	stored := *user
+	stored.Version++
+	err := r.db.C(COLLECTIONUser).Update(bson.M{"_id": user.ID, "version": versionQuery(user.Version)}, stored)
+	if err == mgo.ErrNotFound {
+		if n, countErr := r.db.C(COLLECTIONUser).FindId(user.ID).Count(); countErr == nil && n > 0 {
+			return ErrConflict
+		}
+	}
+	if err != nil {
+		return mongoError(err)
+	}
+	user.Version = stored.Version
+	return nil
+}
+
+//DeleteWithBankAccounts for DeleteWithBankAccounts, mgo has no transaction so User is removed first and put back
+//when some BankAccount changed after check, BankAccount removed before that had passed check
+func (r *MongoUserRepository) DeleteWithBankAccounts(id bson.ObjectId, check func(bankAccounts []model.BankAccount) error) error {
+	var user model.User
+	if err := r.db.C(COLLECTIONUser).FindId(id).One(&user); err != nil {
+		return mongoError(err)
+	}
+	var bankAccounts []model.BankAccount
+	if err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"user_id": id}).Sort("_id").All(&bankAccounts); err != nil {
+		return err
+	}
+	if err := check(bankAccounts); err != nil {
+		return err
+	}
+	if err := r.db.C(COLLECTIONUser).RemoveId(id); err != nil {
+		return mongoError(err)
+	}
+	for _, bankAccount := range bankAccounts {
+		err := r.db.C(COLLECTIONBankAccount).Remove(bson.M{"_id": bankAccount.ID, "version": versionQuery(bankAccount.Version)})
+		if err == nil {
+			continue
+		}
+		if insertErr := r.db.C(COLLECTIONUser).Insert(&user); insertErr != nil {
+			return insertErr
+		}
+		if err == mgo.ErrNotFound {
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+//SetPassword for SetPassword
+func (r *MongoUserRepository) SetPassword(id bson.ObjectId, oldPassword string, newPassword string) error {
+	err := r.db.C(COLLECTIONUser).Update(
+		bson.M{"_id": id, "password": oldPassword},
+		bson.M{"$set": bson.M{"password": newPassword}, "$inc": bson.M{"version": 1}},
+	)
+	return mongoError(err)
+}
+
+//MongoBankAccountRepository is BankAccountRepository in mgo
+type MongoBankAccountRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoBankAccountRepository for NewMongoBankAccountRepository
+func NewMongoBankAccountRepository(db *mgo.Database) *MongoBankAccountRepository {
+	return &MongoBankAccountRepository{db: db}
+}
+
+//FindAll for FindAll
+func (r *MongoBankAccountRepository) FindAll() ([]model.BankAccount, error) {
+	var bankAccounts []model.BankAccount
+	err := r.db.C(COLLECTIONBankAccount).Find(nil).Sort("_id").All(&bankAccounts)
+	return bankAccounts, err
+}
+
+//FindByID for FindByID
+func (r *MongoBankAccountRepository) FindByID(id bson.ObjectId) (model.BankAccount, error) {
+	var bankAccount model.BankAccount
+	err := r.db.C(COLLECTIONBankAccount).FindId(id).One(&bankAccount)
+	return bankAccount, mongoError(err)
+}
+
+//FindByAccountNumber for FindByAccountNumber
+func (r *MongoBankAccountRepository) FindByAccountNumber(accountNumber string) (model.BankAccount, error) {
+	var bankAccount model.BankAccount
+	err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"account_number": accountNumber}).One(&bankAccount)
+	return bankAccount, mongoError(err)
+}
+
+//FindByUser for FindByUser
+func (r *MongoBankAccountRepository) FindByUser(userID bson.ObjectId) ([]model.BankAccount, error) {
+	var bankAccounts []model.BankAccount
+	err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"user_id": userID}).Sort("_id").All(&bankAccounts)
+	return bankAccounts, err
+}
+
+//Insert for Insert
+func (r *MongoBankAccountRepository) Insert(bankAccount *model.BankAccount) error {
+	err := r.db.C(COLLECTIONBankAccount).Insert(bankAccount)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//Delete for Delete
+func (r *MongoBankAccountRepository) Delete(userID bson.ObjectId, id bson.ObjectId, check func(bankAccount model.BankAccount) error) error {
+	for {
+		var bankAccount model.BankAccount
+		err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"_id": id, "user_id": userID}).One(&bankAccount)
+		if err != nil {
+			return mongoError(err)
+		}
+		if err := check(bankAccount); err != nil {
+			return err
+		}
+		err = r.db.C(COLLECTIONBankAccount).Remove(bson.M{"_id": id, "version": versionQuery(bankAccount.Version)})
+		if err == mgo.ErrNotFound {
+			//BankAccount was changed by someone else, read it again
+			continue
+		}
+		return err
+	}
+}
+
+//UpdateBalance for UpdateBalance, Balance is only stored if Version did not change since it was read
+func (r *MongoBankAccountRepository) UpdateBalance(userID bson.ObjectId, bankAccountID bson.ObjectId, update func(bankAccount *model.BankAccount) error) (model.BankAccount, error) {
+	for {
+		var bankAccount model.BankAccount
+		err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"_id": bankAccountID, "user_id": userID}).One(&bankAccount)
+		if err != nil {
+			return model.BankAccount{}, mongoError(err)
+		}
+		version := bankAccount.Version
+		if err := update(&bankAccount); err != nil {
+			return model.BankAccount{}, err
+		}
+		bankAccount.Version = version + 1
+		err = r.db.C(COLLECTIONBankAccount).Update(
+			bson.M{"_id": bankAccountID, "version": versionQuery(version)},
+			bson.M{"$set": bson.M{"balance": bankAccount.Balance, "version": bankAccount.Version}},
+		)
+		if err == mgo.ErrNotFound {
+			//BankAccount was changed by someone else, read it again
+			continue
+		}
+		return bankAccount, err
+	}
+}
+
+//ApplyTranfer for ApplyTranfer, Balance is only stored if Version did not change since it was read
+//so it is added with the same overflow and Currency check as the other backends
+func (r *MongoBankAccountRepository) ApplyTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money, check func(bankAccount model.BankAccount) error) (model.BankAccount, error) {
+	for {
+		var bankAccount model.BankAccount
+		err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"account_number": accountNumber}).One(&bankAccount)
+		if err != nil {
+			return model.BankAccount{}, mongoError(err)
+		}
+		if hasTranfer(bankAccount, tranferID) {
+			return bankAccount, nil
+		}
+		err = r.changeBalance(&bankAccount, amount, check,
+			bson.M{"pending_tranfers": bson.M{"$ne": tranferID}},
+			bson.M{"$push": bson.M{"pending_tranfers": tranferID}},
+		)
+		if err == mgo.ErrNotFound {
+			//BankAccount was changed by someone else, read it again
+			continue
+		}
+		if err != nil {
+			return model.BankAccount{}, err
+		}
+		bankAccount.PendingTranfers = append(bankAccount.PendingTranfers, tranferID)
+		return bankAccount, nil
+	}
+}
+
+//RevertTranfer for RevertTranfer, Balance is only stored if Version did not change since it was read
+func (r *MongoBankAccountRepository) RevertTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money) (model.BankAccount, error) {
+	for {
+		var bankAccount model.BankAccount
+		err := r.db.C(COLLECTIONBankAccount).Find(bson.M{"account_number": accountNumber, "pending_tranfers": tranferID}).One(&bankAccount)
+		if err != nil {
+			return model.BankAccount{}, mongoError(err)
+		}
+		err = r.changeBalance(&bankAccount, amount, nil,
+			bson.M{"pending_tranfers": tranferID},
+			bson.M{"$pull": bson.M{"pending_tranfers": tranferID}},
+		)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return model.BankAccount{}, err
+		}
+		bankAccount.PendingTranfers = removeTranfer(bankAccount.PendingTranfers, tranferID)
+		return bankAccount, nil
+	}
+}
+
+//changeBalance for add amount to bankAccount and store it with update only if check passes, its Version is still
+//the one it was read with and it matches filter, mgo.ErrNotFound when it does not
+func (r *MongoBankAccountRepository) changeBalance(bankAccount *model.BankAccount, amount model.Money, check func(bankAccount model.BankAccount) error, filter bson.M, update bson.M) error {
+	balance, err := bankAccount.Balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	if check != nil {
+		changed := *bankAccount
+		changed.Balance = balance
+		if err := check(changed); err != nil {
+			return err
+		}
+	}
+	filter["_id"] = bankAccount.ID
+	filter["version"] = versionQuery(bankAccount.Version)
+	update["$set"] = bson.M{"balance": balance, "version": bankAccount.Version + 1}
+	err = r.db.C(COLLECTIONBankAccount).Update(filter, update)
+	if err != nil {
+		return err
+	}
+	bankAccount.Balance = balance
+	bankAccount.Version++
+	return nil
+}
+
+//FinishTranfer for FinishTranfer
+func (r *MongoBankAccountRepository) FinishTranfer(tranferID bson.ObjectId, accountNumber string) error {
+	err := r.db.C(COLLECTIONBankAccount).Update(
+		bson.M{"account_number": accountNumber},
+		bson.M{"$pull": bson.M{"pending_tranfers": tranferID}},
+	)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+//MongoTranferRepository is TranferRepository in mgo
+type MongoTranferRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoTranferRepository for NewMongoTranferRepository
+func NewMongoTranferRepository(db *mgo.Database) *MongoTranferRepository {
+	return &MongoTranferRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MongoTranferRepository) Insert(tranferLog *model.TranferLog) error {
+	return r.db.C(COLLECTIONTranfer).Insert(tranferLog)
+}
+
+//SetState for SetState
+func (r *MongoTranferRepository) SetState(id bson.ObjectId, state model.TranferState, next model.TranferState, lastModified time.Time) error {
+	err := r.db.C(COLLECTIONTranfer).Update(
+		bson.M{"_id": id, "state": state},
+		bson.M{"$set": bson.M{"state": next, "last_modified": lastModified}},
+	)
+	return mongoError(err)
+}
+
+//FindByState for FindByState
+func (r *MongoTranferRepository) FindByState(states ...model.TranferState) ([]model.TranferLog, error) {
+	var tranferLogs []model.TranferLog
+	err := r.db.C(COLLECTIONTranfer).Find(bson.M{"state": bson.M{"$in": states}}).All(&tranferLogs)
+	return tranferLogs, err
+}
+
+//FindByID for FindByID
+func (r *MongoTranferRepository) FindByID(id bson.ObjectId) (model.TranferLog, error) {
+	var tranferLog model.TranferLog
+	err := r.db.C(COLLECTIONTranfer).FindId(id).One(&tranferLog)
+	return tranferLog, mongoError(err)
+}
+
+//MongoTransactionRepository is TransactionRepository in mgo
+type MongoTransactionRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoTransactionRepository for NewMongoTransactionRepository
+func NewMongoTransactionRepository(db *mgo.Database) *MongoTransactionRepository {
+	return &MongoTransactionRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MongoTransactionRepository) Insert(transactionLog *model.TransactionLog) error {
+	return r.db.C(COLLECTIONTransaction).Insert(transactionLog)
+}
+
+//InsertTranfer for InsertTranfer
+func (r *MongoTransactionRepository) InsertTranfer(transactionLog *model.TransactionLog) error {
+	_, err := r.db.C(COLLECTIONTransaction).Upsert(
+		bson.M{
+			"tranfer_id":     transactionLog.TranferID,
+			"account_number": transactionLog.AccountNumber,
+			"type":           transactionLog.Type,
+		},
+		bson.M{"$setOnInsert": transactionLog},
+	)
+	return err
+}
+
+//FindByBankAccount for FindByBankAccount
+func (r *MongoTransactionRepository) FindByBankAccount(bankAccountID bson.ObjectId, filter model.TransactionFilter) ([]model.TransactionLog, error) {
+	query := bson.M{"bank_account_id": bankAccountID}
+	createdAt := bson.M{}
+	if !filter.From.IsZero() {
+		createdAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		createdAt["$lt"] = filter.To
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+
+	transactionLogs := []model.TransactionLog{}
+	err := r.db.C(COLLECTIONTransaction).Find(query).
+		Sort("-created_at", "-_id").
+		Skip((filter.Page - 1) * filter.Limit).
+		Limit(filter.Limit).
+		All(&transactionLogs)
+	return transactionLogs, err
+}
+
+//CountByType for CountByType
+func (r *MongoTransactionRepository) CountByType(bankAccountID bson.ObjectId, transactionType model.TransactionType, from time.Time) (int, error) {
+	return r.db.C(COLLECTIONTransaction).Find(bson.M{
+		"bank_account_id": bankAccountID,
+		"type":            transactionType,
+		"created_at":      bson.M{"$gte": from},
+	}).Count()
+}
+
+//MongoRefreshTokenRepository is RefreshTokenRepository in mgo
+type MongoRefreshTokenRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoRefreshTokenRepository for NewMongoRefreshTokenRepository
+func NewMongoRefreshTokenRepository(db *mgo.Database) *MongoRefreshTokenRepository {
+	return &MongoRefreshTokenRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MongoRefreshTokenRepository) Insert(refreshToken *model.RefreshToken) error {
+	return r.db.C(COLLECTIONRefreshToken).Insert(refreshToken)
+}
+
+//FindByID for FindByID
+func (r *MongoRefreshTokenRepository) FindByID(id string) (model.RefreshToken, error) {
+	var refreshToken model.RefreshToken
+	err := r.db.C(COLLECTIONRefreshToken).FindId(id).One(&refreshToken)
+	return refreshToken, mongoError(err)
+}
+
+//Revoke for Revoke
+func (r *MongoRefreshTokenRepository) Revoke(id string) error {
+	err := r.db.C(COLLECTIONRefreshToken).Update(
+		bson.M{"_id": id, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return mongoError(err)
+}
+
+//RevokeFamily for RevokeFamily
+func (r *MongoRefreshTokenRepository) RevokeFamily(family bson.ObjectId) error {
+	_, err := r.db.C(COLLECTIONRefreshToken).UpdateAll(
+		bson.M{"family": family},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+//MongoIdempotencyKeyRepository is IdempotencyKeyRepository in mgo
+type MongoIdempotencyKeyRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoIdempotencyKeyRepository for NewMongoIdempotencyKeyRepository
+func NewMongoIdempotencyKeyRepository(db *mgo.Database) *MongoIdempotencyKeyRepository {
+	return &MongoIdempotencyKeyRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MongoIdempotencyKeyRepository) Insert(idempotencyKey *model.IdempotencyKey) error {
+	err := r.db.C(COLLECTIONIdempotencyKey).Insert(idempotencyKey)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//FindByID for FindByID
+func (r *MongoIdempotencyKeyRepository) FindByID(id string) (model.IdempotencyKey, error) {
+	var idempotencyKey model.IdempotencyKey
+	err := r.db.C(COLLECTIONIdempotencyKey).FindId(id).One(&idempotencyKey)
+	return idempotencyKey, mongoError(err)
+}
+
+//Complete for Complete
+func (r *MongoIdempotencyKeyRepository) Complete(id string, status int, contentType string, body []byte) error {
+	err := r.db.C(COLLECTIONIdempotencyKey).UpdateId(id, bson.M{"$set": bson.M{
+		"completed":    true,
+		"status":       status,
+		"content_type": contentType,
+		"body":         body,
+	}})
+	return mongoError(err)
+}
+
+//Delete for Delete
+func (r *MongoIdempotencyKeyRepository) Delete(id string) error {
+	return mongoError(r.db.C(COLLECTIONIdempotencyKey).RemoveId(id))
+}
+
+//DeleteExpired for DeleteExpired
+func (r *MongoIdempotencyKeyRepository) DeleteExpired(now time.Time) (int, error) {
+	info, err := r.db.C(COLLECTIONIdempotencyKey).RemoveAll(bson.M{"expires_at": bson.M{"$lt": now}})
+	if err != nil {
+		return 0, err
+	}
+	return info.Removed, nil
+}
+
+//MongoInterestRepository is InterestRepository in mgo
+type MongoInterestRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoInterestRepository for NewMongoInterestRepository
+func NewMongoInterestRepository(db *mgo.Database) *MongoInterestRepository {
+	return &MongoInterestRepository{db: db}
+}
+
+//InsertAccrual for InsertAccrual
+func (r *MongoInterestRepository) InsertAccrual(accrual *model.InterestAccrual) error {
+	err := r.db.C(COLLECTIONInterestAccrual).Insert(accrual)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//FindAccruals for FindAccruals
+func (r *MongoInterestRepository) FindAccruals(bankAccountID bson.ObjectId, from string) ([]model.InterestAccrual, error) {
+	var accruals []model.InterestAccrual
+	err := r.db.C(COLLECTIONInterestAccrual).Find(bson.M{
+		"bank_account_id": bankAccountID,
+		"business_date":   bson.M{"$gte": from},
+	}).Sort("business_date").All(&accruals)
+	return accruals, err
+}
+
+//InsertPosting for InsertPosting
+func (r *MongoInterestRepository) InsertPosting(posting *model.InterestPosting) error {
+	err := r.db.C(COLLECTIONInterestPosting).Insert(posting)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//LastAccrualDate for LastAccrualDate
+func (r *MongoInterestRepository) LastAccrualDate(bankAccountID bson.ObjectId) (string, error) {
+	var accrual model.InterestAccrual
+	err := r.db.C(COLLECTIONInterestAccrual).Find(bson.M{"bank_account_id": bankAccountID}).Sort("-business_date").One(&accrual)
+	return accrual.BusinessDate, mongoError(err)
+}
+
+//LastPosting for LastPosting
+func (r *MongoInterestRepository) LastPosting(bankAccountID bson.ObjectId) (model.InterestPosting, error) {
+	var posting model.InterestPosting
+	err := r.db.C(COLLECTIONInterestPosting).Find(bson.M{"bank_account_id": bankAccountID}).Sort("-month").One(&posting)
+	return posting, mongoError(err)
+}
+
+//SetPostingState for SetPostingState
+func (r *MongoInterestRepository) SetPostingState(id bson.ObjectId, state model.InterestPostingState, next model.InterestPostingState, lastModified time.Time) error {
+	err := r.db.C(COLLECTIONInterestPosting).Update(
+		bson.M{"_id": id, "state": state},
+		bson.M{"$set": bson.M{"state": next, "last_modified": lastModified}},
+	)
+	return mongoError(err)
+}
+
+//FindPostingsByState for FindPostingsByState
+func (r *MongoInterestRepository) FindPostingsByState(state model.InterestPostingState) ([]model.InterestPosting, error) {
+	var postings []model.InterestPosting
+	err := r.db.C(COLLECTIONInterestPosting).Find(bson.M{"state": state}).Sort("_id").All(&postings)
+	return postings, err
+}
+
+//MongoLimitRepository is LimitRepository in mgo, Limits are stored with owner as _id
+type MongoLimitRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoLimitRepository for NewMongoLimitRepository
+func NewMongoLimitRepository(db *mgo.Database) *MongoLimitRepository {
+	return &MongoLimitRepository{db: db}
+}
+
+//FindLimits for FindLimits
+func (r *MongoLimitRepository) FindLimits(ownerID bson.ObjectId) (model.Limits, error) {
+	var limits model.Limits
+	err := r.db.C(COLLECTIONLimit).FindId(ownerID).One(&limits)
+	return limits, mongoError(err)
+}
+
+//SetLimits for SetLimits
+func (r *MongoLimitRepository) SetLimits(ownerID bson.ObjectId, limits model.Limits) error {
+	_, err := r.db.C(COLLECTIONLimit).UpsertId(ownerID, limits)
+	return err
+}
+
+//FindUsage for FindUsage
+func (r *MongoLimitRepository) FindUsage(ownerID bson.ObjectId, businessDate string) (model.LimitUsage, error) {
+	usage := model.LimitUsage{OwnerID: ownerID, BusinessDate: businessDate}
+	err := r.db.C(COLLECTIONLimitUsage).Find(bson.M{"owner_id": ownerID, "business_date": businessDate}).One(&usage)
+	if err == mgo.ErrNotFound {
+		return usage, nil
+	}
+	return usage, err
+}
+
+//AddUsage for AddUsage, first usage of the business date is upserted
+func (r *MongoLimitRepository) AddUsage(ownerID bson.ObjectId, businessDate string, amount int64, count int) (model.LimitUsage, error) {
+	var usage model.LimitUsage
+	change := mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"tranfer_total": amount, "tranfer_count": count}},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	query := r.db.C(COLLECTIONLimitUsage).Find(bson.M{"owner_id": ownerID, "business_date": businessDate})
+	_, err := query.Apply(change, &usage)
+	if mgo.IsDup(err) {
+		//someone else upserted it first, now it is there to update
+		_, err = query.Apply(change, &usage)
+	}
+	return usage, err
+}
+
+//MongoStandingOrderRepository is StandingOrderRepository in mgo
+type MongoStandingOrderRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoStandingOrderRepository for NewMongoStandingOrderRepository
+func NewMongoStandingOrderRepository(db *mgo.Database) *MongoStandingOrderRepository {
+	return &MongoStandingOrderRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MongoStandingOrderRepository) Insert(order *model.StandingOrder) error {
+	err := r.db.C(COLLECTIONStandingOrder).Insert(order)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//FindByID for FindByID
+func (r *MongoStandingOrderRepository) FindByID(id bson.ObjectId) (model.StandingOrder, error) {
+	var order model.StandingOrder
+	err := r.db.C(COLLECTIONStandingOrder).FindId(id).One(&order)
+	return order, mongoError(err)
+}
+
+//FindByUser for FindByUser
+func (r *MongoStandingOrderRepository) FindByUser(userID bson.ObjectId) ([]model.StandingOrder, error) {
+	var orders []model.StandingOrder
+	err := r.db.C(COLLECTIONStandingOrder).Find(bson.M{"user_id": userID}).Sort("_id").All(&orders)
+	return orders, err
+}
+
+//FindDue for FindDue
+func (r *MongoStandingOrderRepository) FindDue(now time.Time) ([]model.StandingOrder, error) {
+	var orders []model.StandingOrder
+	err := r.db.C(COLLECTIONStandingOrder).Find(bson.M{
+		"state":    model.StandingOrderActive,
+		"next_run": bson.M{"$lte": now},
+	}).Sort("next_run").All(&orders)
+	return orders, err
+}
+
+//Update for Update
+func (r *MongoStandingOrderRepository) Update(order *model.StandingOrder) error {
+	stored := *order
+	stored.Version++
+	err := r.db.C(COLLECTIONStandingOrder).Update(bson.M{"_id": order.ID, "version": order.Version}, stored)
+	if err == mgo.ErrNotFound {
+		if n, countErr := r.db.C(COLLECTIONStandingOrder).FindId(order.ID).Count(); countErr == nil && n > 0 {
+			return ErrConflict
+		}
+	}
+	if err != nil {
+		return mongoError(err)
+	}
+	order.Version = stored.Version
+	return nil
+}
+
+//InsertExecution for InsertExecution
+func (r *MongoStandingOrderRepository) InsertExecution(execution *model.StandingOrderExecution) error {
+	return r.db.C(COLLECTIONStandingOrderExecution).Insert(execution)
+}
+
+//FindExecutions for FindExecutions
+func (r *MongoStandingOrderRepository) FindExecutions(standingOrderID bson.ObjectId) ([]model.StandingOrderExecution, error) {
+	var executions []model.StandingOrderExecution
+	err := r.db.C(COLLECTIONStandingOrderExecution).Find(bson.M{"standing_order_id": standingOrderID}).Sort("-created_at", "-_id").All(&executions)
+	return executions, err
+}
+
+//MongoBulkPaymentRepository is BulkPaymentRepository in mgo, Lines are kept inside BulkPayment
+type MongoBulkPaymentRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoBulkPaymentRepository for NewMongoBulkPaymentRepository
+func NewMongoBulkPaymentRepository(db *mgo.Database) *MongoBulkPaymentRepository {
+	return &MongoBulkPaymentRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MongoBulkPaymentRepository) Insert(bulkPayment *model.BulkPayment) error {
+	err := r.db.C(COLLECTIONBulkPayment).Insert(bulkPayment)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//FindByID for FindByID
+func (r *MongoBulkPaymentRepository) FindByID(id bson.ObjectId) (model.BulkPayment, error) {
+	var bulkPayment model.BulkPayment
+	err := r.db.C(COLLECTIONBulkPayment).FindId(id).One(&bulkPayment)
+	return bulkPayment, mongoError(err)
+}
+
+//FindByUser for FindByUser
+func (r *MongoBulkPaymentRepository) FindByUser(userID bson.ObjectId) ([]model.BulkPayment, error) {
+	var bulkPayments []model.BulkPayment
+	err := r.db.C(COLLECTIONBulkPayment).Find(bson.M{"user_id": userID}).Sort("_id").All(&bulkPayments)
+	return bulkPayments, err
+}
+
+//FindByState for FindByState
+func (r *MongoBulkPaymentRepository) FindByState(states ...model.BulkPaymentState) ([]model.BulkPayment, error) {
+	var bulkPayments []model.BulkPayment
+	err := r.db.C(COLLECTIONBulkPayment).Find(bson.M{"state": bson.M{"$in": states}}).Sort("_id").All(&bulkPayments)
+	return bulkPayments, err
+}
+
+//SetState for SetState
+func (r *MongoBulkPaymentRepository) SetState(id bson.ObjectId, state model.BulkPaymentState, next model.BulkPaymentState, lastModified time.Time) error {
+	err := r.db.C(COLLECTIONBulkPayment).Update(
+		bson.M{"_id": id, "state": state},
+		bson.M{"$set": bson.M{"state": next, "last_modified": lastModified}},
+	)
+	return mongoError(err)
+}
+
+//SetLine for SetLine
+func (r *MongoBulkPaymentRepository) SetLine(id bson.ObjectId, line model.BulkPaymentLine, status model.BulkPaymentLineStatus) error {
+	set := bson.M{
+		"lines.$.status":        line.Status,
+		"lines.$.reason_code":   line.ReasonCode,
+		"lines.$.reason":        line.Reason,
+		"lines.$.last_modified": line.LastModified,
+	}
+	if line.TranferID != "" {
+		set["lines.$.tranfer_id"] = line.TranferID
+	}
+	err := r.db.C(COLLECTIONBulkPayment).Update(
+		bson.M{"_id": id, "lines": bson.M{"$elemMatch": bson.M{"line": line.Line, "status": status}}},
+		bson.M{"$set": set},
+	)
+	return mongoError(err)
+}
+
+//MongoApprovalRepository is ApprovalRepository in mgo
+type MongoApprovalRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoApprovalRepository for NewMongoApprovalRepository
+func NewMongoApprovalRepository(db *mgo.Database) *MongoApprovalRepository {
+	return &MongoApprovalRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MongoApprovalRepository) Insert(approval *model.Approval) error {
+	err := r.db.C(COLLECTIONApproval).Insert(approval)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//FindByID for FindByID
+func (r *MongoApprovalRepository) FindByID(id bson.ObjectId) (model.Approval, error) {
+	var approval model.Approval
+	err := r.db.C(COLLECTIONApproval).FindId(id).One(&approval)
+	return approval, mongoError(err)
+}
+
+//FindByState for FindByState
+func (r *MongoApprovalRepository) FindByState(states ...model.ApprovalState) ([]model.Approval, error) {
+	var approvals []model.Approval
+	err := r.db.C(COLLECTIONApproval).Find(bson.M{"state": bson.M{"$in": states}}).Sort("_id").All(&approvals)
+	return approvals, err
+}
+
+//SetState for SetState
+func (r *MongoApprovalRepository) SetState(approval model.Approval, state model.ApprovalState) error {
+	set := bson.M{
+		"state":         approval.State,
+		"reason":        approval.Reason,
+		"error":         approval.Error,
+		"last_modified": approval.LastModified,
+	}
+	if approval.CheckerID != "" {
+		set["checker_id"] = approval.CheckerID
+	}
+	if approval.TranferID != "" {
+		set["tranfer_id"] = approval.TranferID
+	}
+	err := r.db.C(COLLECTIONApproval).Update(bson.M{"_id": approval.ID, "state": state}, bson.M{"$set": set})
+	return mongoError(err)
+}
+
+//MongoAuditRepository is AuditRepository in mgo
+type MongoAuditRepository struct {
+	db *mgo.Database
+}
+
+//NewMongoAuditRepository for NewMongoAuditRepository
+func NewMongoAuditRepository(db *mgo.Database) *MongoAuditRepository {
+	return &MongoAuditRepository{db: db}
+}
+
+//Insert for Insert
+func (r *MongoAuditRepository) Insert(entry *model.AuditEntry) error {
+	return r.db.C(COLLECTIONAudit).Insert(entry)
+}
+
+//FindByApproval for FindByApproval
+func (r *MongoAuditRepository) FindByApproval(approvalID bson.ObjectId) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	err := r.db.C(COLLECTIONAudit).Find(bson.M{"approval_id": approvalID}).Sort("_id").All(&entries)
+	return entries, err
+}
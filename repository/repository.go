@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"bankaccountapi/model"
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+var (
+	//ErrNotFound is returned when nothing matches in repository
+	ErrNotFound = errors.New("not found")
+	//ErrDuplicate is returned when unique field is already taken
+	ErrDuplicate = errors.New("duplicate")
+	//ErrConflict is returned when Version was changed by someone else since it was read
+	ErrConflict = errors.New("version conflict")
+)
+
+//UserRepository is storage of User, UserBankAccount is filled from BankAccountRepository
+//storage when User is read and it is never written by UserRepository
+type UserRepository interface {
+	FindAll() ([]model.User, error)
+	//Find for at most filter.Limit User matching filter, in order of filter.Sort then ID, after filter.After
+	Find(filter model.UserFilter) ([]model.User, error)
+	FindByID(id bson.ObjectId) (model.User, error)
+	FindByUsername(username string) (model.User, error)
+	Insert(user *model.User) error
+	//InsertWithBankAccounts insert users and bankAccounts at once, nothing is stored when it fails.
+	//ErrDuplicate when AccountNumber of any of bankAccounts is taken
+	InsertWithBankAccounts(users []model.User, bankAccounts []model.BankAccount) error
+	//Update store User only if its Version was not changed since it was read, ErrConflict when it was.
+	//Version of user goes up by one after it is stored
+	Update(user *model.User) error
+	//DeleteWithBankAccounts lock every BankAccount of User of id, pass them to check and remove User with them
+	//at once only if check returns nil, nothing is removed when it fails
+	DeleteWithBankAccounts(id bson.ObjectId, check func(bankAccounts []model.BankAccount) error) error
+	//SetPassword replace Password only if it is still oldPassword
+	SetPassword(id bson.ObjectId, oldPassword string, newPassword string) error
+}
+
+//AccountRepository is storage of Balance changed by deposit, withdraw and Tranfer
+type AccountRepository interface {
+	//UpdateBalance lock BankAccount, pass it to update and store its new Balance and Version,
+	//nothing is stored when update returns error
+	UpdateBalance(userID bson.ObjectId, bankAccountID bson.ObjectId, update func(bankAccount *model.BankAccount) error) (model.BankAccount, error)
+	//ApplyTranfer add amount to BankAccount once per tranferID and return BankAccount after it,
+	//BankAccount is returned as it is when tranferID was applied before. check gets BankAccount locked with
+	//amount added and nothing is stored when it returns error, nil check stores it anyway
+	ApplyTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money, check func(bankAccount model.BankAccount) error) (model.BankAccount, error)
+	//RevertTranfer add amount to BankAccount only if tranferID was applied, ErrNotFound when it was not
+	RevertTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money) (model.BankAccount, error)
+	//FinishTranfer forget tranferID of BankAccount after Tranfer is applied to both bank accounts
+	FinishTranfer(tranferID bson.ObjectId, accountNumber string) error
+}
+
+//BankAccountRepository is storage of BankAccount, AccountNumber is unique
+type BankAccountRepository interface {
+	AccountRepository
+	FindAll() ([]model.BankAccount, error)
+	FindByID(id bson.ObjectId) (model.BankAccount, error)
+	FindByAccountNumber(accountNumber string) (model.BankAccount, error)
+	FindByUser(userID bson.ObjectId) ([]model.BankAccount, error)
+	//Insert returns ErrDuplicate when AccountNumber is taken
+	Insert(bankAccount *model.BankAccount) error
+	//Delete remove BankAccount only if it belongs to userID and check passes on it while nothing else can change it
+	Delete(userID bson.ObjectId, id bson.ObjectId, check func(bankAccount model.BankAccount) error) error
+}
+
+//TranferRepository is storage of TranferLog
+type TranferRepository interface {
+	Insert(tranferLog *model.TranferLog) error
+	//SetState move TranferLog to next only if it is still in state, ErrNotFound when it is not
+	SetState(id bson.ObjectId, state model.TranferState, next model.TranferState, lastModified time.Time) error
+	FindByState(states ...model.TranferState) ([]model.TranferLog, error)
+	FindByID(id bson.ObjectId) (model.TranferLog, error)
+}
+
+//TransactionRepository is storage of ledger, TransactionLog is never updated
+type TransactionRepository interface {
+	Insert(transactionLog *model.TransactionLog) error
+	//InsertTranfer insert TransactionLog once per TranferID, AccountNumber and Type
+	InsertTranfer(transactionLog *model.TransactionLog) error
+	FindByBankAccount(bankAccountID bson.ObjectId, filter model.TransactionFilter) ([]model.TransactionLog, error)
+	//CountByType for number of TransactionLog of bankAccountID with Type created from from on
+	CountByType(bankAccountID bson.ObjectId, transactionType model.TransactionType, from time.Time) (int, error)
+}
+
+//InterestRepository is storage of InterestAccrual and InterestPosting
+type InterestRepository interface {
+	//InsertAccrual returns ErrDuplicate when BankAccountID already has InterestAccrual of BusinessDate
+	InsertAccrual(accrual *model.InterestAccrual) error
+	//FindAccruals for InterestAccrual of bankAccountID with BusinessDate from from on, oldest first
+	FindAccruals(bankAccountID bson.ObjectId, from string) ([]model.InterestAccrual, error)
+	//LastAccrualDate for latest BusinessDate bankAccountID has InterestAccrual of, ErrNotFound when there is none
+	LastAccrualDate(bankAccountID bson.ObjectId) (string, error)
+	//InsertPosting returns ErrDuplicate when BankAccountID already has InterestPosting of Month
+	InsertPosting(posting *model.InterestPosting) error
+	//LastPosting for InterestPosting of bankAccountID with latest Month, ErrNotFound when there is none
+	LastPosting(bankAccountID bson.ObjectId) (model.InterestPosting, error)
+	//SetPostingState move InterestPosting to next only if it is still in state, ErrNotFound when it is not
+	SetPostingState(id bson.ObjectId, state model.InterestPostingState, next model.InterestPostingState, lastModified time.Time) error
+	FindPostingsByState(state model.InterestPostingState) ([]model.InterestPosting, error)
+}
+
+//RefreshTokenRepository is storage of RefreshToken
+type RefreshTokenRepository interface {
+	Insert(refreshToken *model.RefreshToken) error
+	FindByID(id string) (model.RefreshToken, error)
+	//Revoke revoke RefreshToken only if it is not revoked yet, ErrNotFound when it was
+	Revoke(id string) error
+	RevokeFamily(family bson.ObjectId) error
+}
+
+//IdempotencyKeyRepository is storage of IdempotencyKey
+type IdempotencyKeyRepository interface {
+	//Insert returns ErrDuplicate when ID is already stored
+	Insert(idempotencyKey *model.IdempotencyKey) error
+	FindByID(id string) (model.IdempotencyKey, error)
+	//Complete store response of IdempotencyKey
+	Complete(id string, status int, contentType string, body []byte) error
+	Delete(id string) error
+	//DeleteExpired remove every IdempotencyKey expired before now and returns how many
+	DeleteExpired(now time.Time) (int, error)
+}
+
+//LimitRepository is storage of Limits lowered by User or BankAccount and their LimitUsage
+type LimitRepository interface {
+	//FindLimits for Limits ownerID set for itself, ErrNotFound when it has not set any
+	FindLimits(ownerID bson.ObjectId) (model.Limits, error)
+	SetLimits(ownerID bson.ObjectId, limits model.Limits) error
+	//FindUsage for LimitUsage of ownerID in businessDate, it is empty when nothing is used
+	FindUsage(ownerID bson.ObjectId, businessDate string) (model.LimitUsage, error)
+	//AddUsage add amount and count to LimitUsage of ownerID in businessDate at once and returns it as it is after
+	AddUsage(ownerID bson.ObjectId, businessDate string, amount int64, count int) (model.LimitUsage, error)
+}
+
+//StandingOrderRepository is storage of StandingOrder and StandingOrderExecution
+type StandingOrderRepository interface {
+	Insert(order *model.StandingOrder) error
+	FindByID(id bson.ObjectId) (model.StandingOrder, error)
+	FindByUser(userID bson.ObjectId) ([]model.StandingOrder, error)
+	//FindDue for active StandingOrder with NextRun not after now, the one due first comes first
+	FindDue(now time.Time) ([]model.StandingOrder, error)
+	//Update replace StandingOrder only if its Version is still order.Version, ErrConflict when it is not.
+	//Version of order goes up by one
+	Update(order *model.StandingOrder) error
+	InsertExecution(execution *model.StandingOrderExecution) error
+	//FindExecutions for StandingOrderExecution of standingOrderID, newest first
+	FindExecutions(standingOrderID bson.ObjectId) ([]model.StandingOrderExecution, error)
+}
+
+//BulkPaymentRepository is storage of BulkPayment with its BulkPaymentLine
+type BulkPaymentRepository interface {
+	//Insert returns ErrDuplicate when User already has BulkPayment of MessageID
+	Insert(bulkPayment *model.BulkPayment) error
+	FindByID(id bson.ObjectId) (model.BulkPayment, error)
+	//FindByUser for BulkPayment of userID, oldest first
+	FindByUser(userID bson.ObjectId) ([]model.BulkPayment, error)
+	FindByState(states ...model.BulkPaymentState) ([]model.BulkPayment, error)
+	//SetState move BulkPayment to next only if it is still in state, ErrNotFound when it is not
+	SetState(id bson.ObjectId, state model.BulkPaymentState, next model.BulkPaymentState, lastModified time.Time) error
+	//SetLine store Status, ReasonCode, Reason, TranferID and LastModified of line only if its Status is still status,
+	//ErrNotFound when it is not
+	SetLine(id bson.ObjectId, line model.BulkPaymentLine, status model.BulkPaymentLineStatus) error
+}
+
+//ApprovalRepository is storage of Approval
+type ApprovalRepository interface {
+	Insert(approval *model.Approval) error
+	FindByID(id bson.ObjectId) (model.Approval, error)
+	//FindByState for Approval in any of states, oldest first
+	FindByState(states ...model.ApprovalState) ([]model.Approval, error)
+	//SetState store State, CheckerID, TranferID, Reason, Error and LastModified of approval only if its State
+	//is still state, ErrNotFound when it is not
+	SetState(approval model.Approval, state model.ApprovalState) error
+}
+
+//AuditRepository is storage of AuditEntry, an AuditEntry is never changed or removed
+type AuditRepository interface {
+	Insert(entry *model.AuditEntry) error
+	//FindByApproval for AuditEntry of approvalID, oldest first
+	FindByApproval(approvalID bson.ObjectId) ([]model.AuditEntry, error)
+}
@@ -0,0 +1,1476 @@
+package repository
+
+import (
+	"bankaccountapi/model"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+//Dialect is SQL database the SQL repositories talk to
+type Dialect string
+
+const (
+	//DialectPostgres is PostgreSQL with github.com/lib/pq
+	DialectPostgres Dialect = "postgres"
+	//DialectSQLite is SQLite with github.com/mattn/go-sqlite3
+	DialectSQLite Dialect = "sqlite3"
+)
+
+//rebind for change ? placeholders to $1, $2, ... for PostgreSQL
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+//forUpdate for lock selected rows until commit, SQLite locks the whole database
+//when transaction begins so it needs no row lock
+func (d Dialect) forUpdate() string {
+	if d == DialectPostgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func sqlError(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+//isDuplicate for check err is violation of UNIQUE constraint
+func isDuplicate(err error) bool {
+	switch err := err.(type) {
+	case *pq.Error:
+		return err.Code == "23505"
+	case sqlite3.Error:
+		return err.ExtendedCode == sqlite3.ErrConstraintUnique || err.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
+
+func rowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func nullID(id bson.ObjectId) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id.Hex()
+}
+
+func objectID(hex string) bson.ObjectId {
+	if !bson.IsObjectIdHex(hex) {
+		return ""
+	}
+	return bson.ObjectIdHex(hex)
+}
+
+//SQLUserRepository is UserRepository in SQL
+type SQLUserRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLUserRepository for NewSQLUserRepository
+func NewSQLUserRepository(db *sql.DB, dialect Dialect) *SQLUserRepository {
+	return &SQLUserRepository{db: db, dialect: dialect}
+}
+
+const sqlUserColumns = `id, first_name, last_name, username, password, idcard, age, email, tel, roles, version`
+
+const sqlBankAccountColumns = `id, user_id, bank_name, account_number, balance, currency, product, overdraft_limit, version`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (model.User, error) {
+	var user model.User
+	var id, roles string
+	err := row.Scan(&id, &user.FirstName, &user.LastName, &user.Username, &user.Password, &user.IDcard, &user.Age, &user.Email, &user.Tel, &roles, &user.Version)
+	user.ID = objectID(id)
+	user.Roles = splitRoles(roles)
+	return user, err
+}
+
+//joinRoles for Roles of User stored in one column separated by comma
+func joinRoles(roles []model.Role) string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return strings.Join(names, ",")
+}
+
+func splitRoles(roles string) []model.Role {
+	if roles == "" {
+		return nil
+	}
+	var split []model.Role
+	for _, role := range strings.Split(roles, ",") {
+		split = append(split, model.Role(role))
+	}
+	return split
+}
+
+func scanBankAccount(row interface{ Scan(...interface{}) error }) (model.BankAccount, error) {
+	var bankAccount model.BankAccount
+	var id, userID string
+	err := row.Scan(&id, &userID, &bankAccount.BankName, &bankAccount.AccountNumber, &bankAccount.Balance.Amount, &bankAccount.Balance.Currency, &bankAccount.Product, &bankAccount.OverdraftLimit.Amount, &bankAccount.Version)
+	bankAccount.ID = objectID(id)
+	bankAccount.UserID = objectID(userID)
+	bankAccount.OverdraftLimit.Currency = bankAccount.Balance.Currency
+	bankAccount.Currency = bankAccount.Balance.Currency
+	return bankAccount, err
+}
+
+func findBankAccounts(q querier, dialect Dialect, where string, args ...interface{}) ([]model.BankAccount, error) {
+	rows, err := q.Query(dialect.rebind(`SELECT `+sqlBankAccountColumns+` FROM bank_accounts WHERE `+where+` ORDER BY id`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var bankAccounts []model.BankAccount
+	for rows.Next() {
+		bankAccount, err := scanBankAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		bankAccounts = append(bankAccounts, bankAccount)
+	}
+	return bankAccounts, rows.Err()
+}
+
+func (r *SQLUserRepository) findUsers(where string, args ...interface{}) ([]model.User, error) {
+	return r.queryUsers(where+` ORDER BY id`, args...)
+}
+
+//queryUsers for User of users with clauses after FROM, UserBankAccount is filled with one more query
+func (r *SQLUserRepository) queryUsers(clauses string, args ...interface{}) ([]model.User, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+sqlUserColumns+` FROM users `+clauses), args...)
+	if err != nil {
+		return nil, err
+	}
+	var users []model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return users, nil
+	}
+
+	//fill UserBankAccount with one query
+	byUser := map[bson.ObjectId]int{}
+	args = []interface{}{}
+	placeholders := []string{}
+	for i, user := range users {
+		byUser[user.ID] = i
+		args = append(args, user.ID.Hex())
+		placeholders = append(placeholders, "?")
+	}
+	bankAccounts, err := findBankAccounts(r.db, r.dialect, `user_id IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, bankAccount := range bankAccounts {
+		i := byUser[bankAccount.UserID]
+		users[i].UserBankAccount = append(users[i].UserBankAccount, bankAccount)
+	}
+	return users, nil
+}
+
+func (r *SQLUserRepository) findUser(where string, args ...interface{}) (model.User, error) {
+	users, err := r.findUsers(where, args...)
+	if err != nil {
+		return model.User{}, err
+	}
+	if len(users) == 0 {
+		return model.User{}, ErrNotFound
+	}
+	return users[0], nil
+}
+
+//FindAll for FindAll
+func (r *SQLUserRepository) FindAll() ([]model.User, error) {
+	return r.findUsers("")
+}
+
+//sqlUserSorts is column of each sort of User
+var sqlUserSorts = map[string]string{
+	model.UserSortID:        "id",
+	model.UserSortUsername:  "username",
+	model.UserSortFirstName: "first_name",
+	model.UserSortLastName:  "last_name",
+	model.UserSortAge:       "age",
+}
+
+//Find for Find
+func (r *SQLUserRepository) Find(filter model.UserFilter) ([]model.User, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.NamePrefix != "" {
+		n := utf8.RuneCountInString(filter.NamePrefix)
+		conditions = append(conditions, `(substr(first_name, 1, ?) = ? OR substr(last_name, 1, ?) = ?)`)
+		args = append(args, n, filter.NamePrefix, n, filter.NamePrefix)
+	}
+	if filter.Email != "" {
+		conditions = append(conditions, `email = ?`)
+		args = append(args, filter.Email)
+	}
+	if filter.Tel != "" {
+		conditions = append(conditions, `tel = ?`)
+		args = append(args, filter.Tel)
+	}
+	if filter.AgeMin > 0 {
+		conditions = append(conditions, `age >= ?`)
+		args = append(args, filter.AgeMin)
+	}
+	if filter.AgeMax > 0 {
+		conditions = append(conditions, `age <= ?`)
+		args = append(args, filter.AgeMax)
+	}
+	if filter.BankName != "" {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM bank_accounts WHERE bank_accounts.user_id = users.id AND bank_accounts.bank_name = ?)`)
+		args = append(args, filter.BankName)
+	}
+
+	column, ok := sqlUserSorts[filter.Sort]
+	if !ok {
+		column = "id"
+	}
+	compare, direction := ">", ""
+	if filter.Descending {
+		compare, direction = "<", " DESC"
+	}
+	if after := filter.After; after != nil {
+		var value interface{} = after.Value
+		if column == "age" {
+			age, _ := strconv.ParseInt(after.Value, 10, 64)
+			value = age
+		}
+		if column == "id" {
+			conditions = append(conditions, `id `+compare+` ?`)
+			args = append(args, after.ID.Hex())
+		} else {
+			conditions = append(conditions, `(`+column+` `+compare+` ? OR `+column+` = ? AND id `+compare+` ?)`)
+			args = append(args, value, value, after.ID.Hex())
+		}
+	}
+
+	clauses := ""
+	if len(conditions) > 0 {
+		clauses = `WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	clauses += ` ORDER BY ` + column + direction
+	if column != "id" {
+		clauses += `, id` + direction
+	}
+	if filter.Limit > 0 {
+		clauses += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	return r.queryUsers(clauses, args...)
+}
+
+//FindByID for FindByID
+func (r *SQLUserRepository) FindByID(id bson.ObjectId) (model.User, error) {
+	return r.findUser(`WHERE id = ?`, id.Hex())
+}
+
+//FindByUsername for FindByUsername
+func (r *SQLUserRepository) FindByUsername(username string) (model.User, error) {
+	return r.findUser(`WHERE username = ?`, username)
+}
+
+//Insert for Insert
+func (r *SQLUserRepository) Insert(user *model.User) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO users (`+sqlUserColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		user.ID.Hex(), user.FirstName, user.LastName, user.Username, user.Password, user.IDcard, user.Age, user.Email, user.Tel, joinRoles(user.Roles), user.Version)
+	return err
+}
+
+//InsertWithBankAccounts for InsertWithBankAccounts
+func (r *SQLUserRepository) InsertWithBankAccounts(users []model.User, bankAccounts []model.BankAccount) error {
+	return withTx(r.db, func(tx *sql.Tx) error {
+		for _, user := range users {
+			if _, err := tx.Exec(r.dialect.rebind(`INSERT INTO users (`+sqlUserColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+				user.ID.Hex(), user.FirstName, user.LastName, user.Username, user.Password, user.IDcard, user.Age, user.Email, user.Tel, joinRoles(user.Roles), user.Version); err != nil {
+				return err
+			}
+		}
+		for _, bankAccount := range bankAccounts {
+			_, err := tx.Exec(r.dialect.rebind(`INSERT INTO bank_accounts (`+sqlBankAccountColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+				bankAccount.ID.Hex(), bankAccount.UserID.Hex(), bankAccount.BankName, bankAccount.AccountNumber, bankAccount.Balance.Amount, bankAccount.AccountCurrency(),
+				string(bankAccount.Product), bankAccount.OverdraftLimit.Amount, bankAccount.Version)
+			if isDuplicate(err) {
+				return ErrDuplicate
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//Update for Update
+func (r *SQLUserRepository) Update(user *model.User) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE users SET first_name = ?, last_name = ?, username = ?, password = ?, idcard = ?, age = ?, email = ?, tel = ?, roles = ?, version = version + 1 WHERE id = ? AND version = ?`),
+		user.FirstName, user.LastName, user.Username, user.Password, user.IDcard, user.Age, user.Email, user.Tel, joinRoles(user.Roles), user.ID.Hex(), user.Version)
+	if err != nil {
+		return err
+	}
+	if err := rowsAffected(result); err != nil {
+		var n int
+		if countErr := r.db.QueryRow(r.dialect.rebind(`SELECT COUNT(*) FROM users WHERE id = ?`), user.ID.Hex()).Scan(&n); countErr == nil && n > 0 {
+			return ErrConflict
+		}
+		return err
+	}
+	user.Version++
+	return nil
+}
+
+//DeleteWithBankAccounts for DeleteWithBankAccounts, the row of User and of every BankAccount of it is locked
+//with SELECT ... FOR UPDATE
+func (r *SQLUserRepository) DeleteWithBankAccounts(id bson.ObjectId, check func(bankAccounts []model.BankAccount) error) error {
+	bankAccountRepository := &SQLBankAccountRepository{db: r.db, dialect: r.dialect}
+	return withTx(r.db, func(tx *sql.Tx) error {
+		var userID string
+		err := tx.QueryRow(r.dialect.rebind(`SELECT id FROM users WHERE id = ?`+r.dialect.forUpdate()), id.Hex()).Scan(&userID)
+		if err != nil {
+			return sqlError(err)
+		}
+		bankAccounts, err := findBankAccounts(tx, r.dialect, `user_id = ?`, id.Hex())
+		if err != nil {
+			return err
+		}
+		for i := range bankAccounts {
+			bankAccounts[i], err = bankAccountRepository.lock(tx, `id = ?`, bankAccounts[i].ID.Hex())
+			if err != nil {
+				return err
+			}
+			bankAccounts[i].PendingTranfers, err = findPendingTranfers(tx, r.dialect, bankAccounts[i].ID)
+			if err != nil {
+				return err
+			}
+		}
+		if err := check(bankAccounts); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(r.dialect.rebind(`DELETE FROM bank_accounts WHERE user_id = ?`), id.Hex()); err != nil {
+			return err
+		}
+		_, err = tx.Exec(r.dialect.rebind(`DELETE FROM users WHERE id = ?`), id.Hex())
+		return err
+	})
+}
+
+//findPendingTranfers for PendingTranfers of BankAccount of id
+func findPendingTranfers(q querier, dialect Dialect, id bson.ObjectId) ([]bson.ObjectId, error) {
+	rows, err := q.Query(dialect.rebind(`SELECT tranfer_id FROM bank_account_tranfers WHERE bank_account_id = ? ORDER BY tranfer_id`), id.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var pendingTranfers []bson.ObjectId
+	for rows.Next() {
+		var tranferID string
+		if err := rows.Scan(&tranferID); err != nil {
+			return nil, err
+		}
+		pendingTranfers = append(pendingTranfers, objectID(tranferID))
+	}
+	return pendingTranfers, rows.Err()
+}
+
+//SetPassword for SetPassword
+func (r *SQLUserRepository) SetPassword(id bson.ObjectId, oldPassword string, newPassword string) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE users SET password = ?, version = version + 1 WHERE id = ? AND password = ?`), newPassword, id.Hex(), oldPassword)
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//SQLBankAccountRepository is BankAccountRepository in SQL
+type SQLBankAccountRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLBankAccountRepository for NewSQLBankAccountRepository
+func NewSQLBankAccountRepository(db *sql.DB, dialect Dialect) *SQLBankAccountRepository {
+	return &SQLBankAccountRepository{db: db, dialect: dialect}
+}
+
+func (r *SQLBankAccountRepository) findOne(q querier, where string, args ...interface{}) (model.BankAccount, error) {
+	row := q.QueryRow(r.dialect.rebind(`SELECT `+sqlBankAccountColumns+` FROM bank_accounts WHERE `+where), args...)
+	bankAccount, err := scanBankAccount(row)
+	return bankAccount, sqlError(err)
+}
+
+//FindAll for FindAll
+func (r *SQLBankAccountRepository) FindAll() ([]model.BankAccount, error) {
+	return findBankAccounts(r.db, r.dialect, `1 = 1`)
+}
+
+//FindByID for FindByID
+func (r *SQLBankAccountRepository) FindByID(id bson.ObjectId) (model.BankAccount, error) {
+	return r.findOne(r.db, `id = ?`, id.Hex())
+}
+
+//FindByAccountNumber for FindByAccountNumber
+func (r *SQLBankAccountRepository) FindByAccountNumber(accountNumber string) (model.BankAccount, error) {
+	return r.findOne(r.db, `account_number = ?`, accountNumber)
+}
+
+//FindByUser for FindByUser
+func (r *SQLBankAccountRepository) FindByUser(userID bson.ObjectId) ([]model.BankAccount, error) {
+	return findBankAccounts(r.db, r.dialect, `user_id = ?`, userID.Hex())
+}
+
+//Insert for Insert
+func (r *SQLBankAccountRepository) Insert(bankAccount *model.BankAccount) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO bank_accounts (`+sqlBankAccountColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		bankAccount.ID.Hex(), bankAccount.UserID.Hex(), bankAccount.BankName, bankAccount.AccountNumber, bankAccount.Balance.Amount, bankAccount.AccountCurrency(),
+		string(bankAccount.Product), bankAccount.OverdraftLimit.Amount, bankAccount.Version)
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//Delete for Delete
+func (r *SQLBankAccountRepository) Delete(userID bson.ObjectId, id bson.ObjectId, check func(bankAccount model.BankAccount) error) error {
+	return withTx(r.db, func(tx *sql.Tx) error {
+		bankAccount, err := r.lock(tx, `id = ? AND user_id = ?`, id.Hex(), userID.Hex())
+		if err != nil {
+			return err
+		}
+		bankAccount.PendingTranfers, err = findPendingTranfers(tx, r.dialect, bankAccount.ID)
+		if err != nil {
+			return err
+		}
+		if err := check(bankAccount); err != nil {
+			return err
+		}
+		_, err = tx.Exec(r.dialect.rebind(`DELETE FROM bank_accounts WHERE id = ?`), id.Hex())
+		return err
+	})
+}
+
+//lock for select BankAccount with row lock
+func (r *SQLBankAccountRepository) lock(tx *sql.Tx, where string, args ...interface{}) (model.BankAccount, error) {
+	return r.findOne(tx, where+r.dialect.forUpdate(), args...)
+}
+
+func (r *SQLBankAccountRepository) setBalance(tx *sql.Tx, bankAccount *model.BankAccount) error {
+	_, err := tx.Exec(r.dialect.rebind(`UPDATE bank_accounts SET balance = ?, version = version + 1 WHERE id = ?`), bankAccount.Balance.Amount, bankAccount.ID.Hex())
+	if err != nil {
+		return err
+	}
+	bankAccount.Version++
+	return nil
+}
+
+//UpdateBalance for UpdateBalance, the row of BankAccount is locked with SELECT ... FOR UPDATE
+func (r *SQLBankAccountRepository) UpdateBalance(userID bson.ObjectId, bankAccountID bson.ObjectId, update func(bankAccount *model.BankAccount) error) (model.BankAccount, error) {
+	var bankAccount model.BankAccount
+	err := withTx(r.db, func(tx *sql.Tx) error {
+		var err error
+		bankAccount, err = r.lock(tx, `id = ? AND user_id = ?`, bankAccountID.Hex(), userID.Hex())
+		if err != nil {
+			return err
+		}
+		if err := update(&bankAccount); err != nil {
+			return err
+		}
+		return r.setBalance(tx, &bankAccount)
+	})
+	return bankAccount, err
+}
+
+//ApplyTranfer for ApplyTranfer, the row of BankAccount is locked with SELECT ... FOR UPDATE
+func (r *SQLBankAccountRepository) ApplyTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money, check func(bankAccount model.BankAccount) error) (model.BankAccount, error) {
+	var bankAccount model.BankAccount
+	err := withTx(r.db, func(tx *sql.Tx) error {
+		var err error
+		bankAccount, err = r.lock(tx, `account_number = ?`, accountNumber)
+		if err != nil {
+			return err
+		}
+		var applied int
+		err = tx.QueryRow(r.dialect.rebind(`SELECT COUNT(*) FROM bank_account_tranfers WHERE tranfer_id = ? AND bank_account_id = ?`), tranferID.Hex(), bankAccount.ID.Hex()).Scan(&applied)
+		if err != nil || applied > 0 {
+			return err
+		}
+		bankAccount.Balance, err = bankAccount.Balance.Add(amount)
+		if err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(bankAccount); err != nil {
+				return err
+			}
+		}
+		if err := r.setBalance(tx, &bankAccount); err != nil {
+			return err
+		}
+		_, err = tx.Exec(r.dialect.rebind(`INSERT INTO bank_account_tranfers (tranfer_id, bank_account_id) VALUES (?, ?)`), tranferID.Hex(), bankAccount.ID.Hex())
+		return err
+	})
+	return bankAccount, err
+}
+
+//RevertTranfer for RevertTranfer, the row of BankAccount is locked with SELECT ... FOR UPDATE
+func (r *SQLBankAccountRepository) RevertTranfer(tranferID bson.ObjectId, accountNumber string, amount model.Money) (model.BankAccount, error) {
+	var bankAccount model.BankAccount
+	err := withTx(r.db, func(tx *sql.Tx) error {
+		var err error
+		bankAccount, err = r.lock(tx, `account_number = ?`, accountNumber)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(r.dialect.rebind(`DELETE FROM bank_account_tranfers WHERE tranfer_id = ? AND bank_account_id = ?`), tranferID.Hex(), bankAccount.ID.Hex())
+		if err != nil {
+			return err
+		}
+		if err := rowsAffected(result); err != nil {
+			return err
+		}
+		bankAccount.Balance, err = bankAccount.Balance.Add(amount)
+		if err != nil {
+			return err
+		}
+		return r.setBalance(tx, &bankAccount)
+	})
+	return bankAccount, err
+}
+
+//FinishTranfer for FinishTranfer
+func (r *SQLBankAccountRepository) FinishTranfer(tranferID bson.ObjectId, accountNumber string) error {
+	_, err := r.db.Exec(r.dialect.rebind(`DELETE FROM bank_account_tranfers WHERE tranfer_id = ? AND bank_account_id = (SELECT id FROM bank_accounts WHERE account_number = ?)`), tranferID.Hex(), accountNumber)
+	return err
+}
+
+const sqlTranferColumns = `id, user_from, user_to, account_number_from, account_number_to, amount, currency, amount_to, currency_to, rate, kind, state, last_modified, ` +
+	`fee, fee_currency, fee_to, fee_currency_to, fee_account_number, fee_id`
+
+//SQLTranferRepository is TranferRepository in SQL
+type SQLTranferRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLTranferRepository for NewSQLTranferRepository
+func NewSQLTranferRepository(db *sql.DB, dialect Dialect) *SQLTranferRepository {
+	return &SQLTranferRepository{db: db, dialect: dialect}
+}
+
+//Insert for Insert
+func (r *SQLTranferRepository) Insert(tranferLog *model.TranferLog) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO tranfers (`+sqlTranferColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		tranferLog.ID.Hex(), tranferLog.UserFrom.Hex(), tranferLog.UserTo.Hex(), tranferLog.AccountNumberFrom, tranferLog.AccountNumberTo,
+		tranferLog.Amount.Amount, tranferLog.Amount.Currency, tranferLog.AmountTo.Amount, tranferLog.AmountTo.Currency, tranferLog.Rate,
+		string(tranferLog.Kind), string(tranferLog.State), tranferLog.LastModified.UTC(),
+		tranferLog.Fee.Amount, tranferLog.Fee.Currency, tranferLog.FeeTo.Amount, tranferLog.FeeTo.Currency, tranferLog.FeeAccountNumber, nullID(tranferLog.FeeID))
+	return err
+}
+
+//SetState for SetState
+func (r *SQLTranferRepository) SetState(id bson.ObjectId, state model.TranferState, next model.TranferState, lastModified time.Time) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE tranfers SET state = ?, last_modified = ? WHERE id = ? AND state = ?`),
+		string(next), lastModified.UTC(), id.Hex(), string(state))
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//FindByState for FindByState
+func (r *SQLTranferRepository) FindByState(states ...model.TranferState) ([]model.TranferLog, error) {
+	if len(states) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{}
+	placeholders := []string{}
+	for _, state := range states {
+		args = append(args, string(state))
+		placeholders = append(placeholders, "?")
+	}
+	return r.findTranfers(`state IN (`+strings.Join(placeholders, ", ")+`) ORDER BY id`, args...)
+}
+
+//FindByID for FindByID
+func (r *SQLTranferRepository) FindByID(id bson.ObjectId) (model.TranferLog, error) {
+	tranferLogs, err := r.findTranfers(`id = ?`, id.Hex())
+	if err != nil {
+		return model.TranferLog{}, err
+	}
+	if len(tranferLogs) == 0 {
+		return model.TranferLog{}, ErrNotFound
+	}
+	return tranferLogs[0], nil
+}
+
+func (r *SQLTranferRepository) findTranfers(where string, args ...interface{}) ([]model.TranferLog, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+sqlTranferColumns+` FROM tranfers WHERE `+where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tranferLogs []model.TranferLog
+	for rows.Next() {
+		var tranferLog model.TranferLog
+		var id, userFrom, userTo, kind, state string
+		var feeID sql.NullString
+		err := rows.Scan(&id, &userFrom, &userTo, &tranferLog.AccountNumberFrom, &tranferLog.AccountNumberTo, &tranferLog.Amount.Amount, &tranferLog.Amount.Currency,
+			&tranferLog.AmountTo.Amount, &tranferLog.AmountTo.Currency, &tranferLog.Rate, &kind, &state, &tranferLog.LastModified,
+			&tranferLog.Fee.Amount, &tranferLog.Fee.Currency, &tranferLog.FeeTo.Amount, &tranferLog.FeeTo.Currency, &tranferLog.FeeAccountNumber, &feeID)
+		if err != nil {
+			return nil, err
+		}
+		tranferLog.ID = objectID(id)
+		tranferLog.FeeID = objectID(feeID.String)
+		tranferLog.UserFrom = objectID(userFrom)
+		tranferLog.UserTo = objectID(userTo)
+		tranferLog.Kind = model.TranferKind(kind)
+		tranferLog.State = model.TranferState(state)
+		tranferLogs = append(tranferLogs, tranferLog)
+	}
+	return tranferLogs, rows.Err()
+}
+
+//SQLTransactionRepository is TransactionRepository in SQL
+type SQLTransactionRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLTransactionRepository for NewSQLTransactionRepository
+func NewSQLTransactionRepository(db *sql.DB, dialect Dialect) *SQLTransactionRepository {
+	return &SQLTransactionRepository{db: db, dialect: dialect}
+}
+
+const sqlTransactionColumns = `id, user_id, bank_account_id, account_number, type, amount, balance_after, currency, counterparty_account, tranfer_id, created_by, created_at`
+
+func (r *SQLTransactionRepository) insert(transactionLog *model.TransactionLog, onConflict string) error {
+	currency := transactionLog.Amount.Currency
+	if currency == "" {
+		currency = model.DefaultCurrency
+	}
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO transactions (`+sqlTransactionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`+onConflict),
+		transactionLog.ID.Hex(), transactionLog.UserID.Hex(), transactionLog.BankAccountID.Hex(), transactionLog.AccountNumber, string(transactionLog.Type),
+		transactionLog.Amount.Amount, transactionLog.BalanceAfter.Amount, currency, transactionLog.CounterpartyAccount,
+		nullID(transactionLog.TranferID), transactionLog.CreatedBy.Hex(), transactionLog.CreatedAt.UTC())
+	return err
+}
+
+//Insert for Insert
+func (r *SQLTransactionRepository) Insert(transactionLog *model.TransactionLog) error {
+	return r.insert(transactionLog, "")
+}
+
+//InsertTranfer for InsertTranfer
+func (r *SQLTransactionRepository) InsertTranfer(transactionLog *model.TransactionLog) error {
+	return r.insert(transactionLog, ` ON CONFLICT DO NOTHING`)
+}
+
+//FindByBankAccount for FindByBankAccount
+func (r *SQLTransactionRepository) FindByBankAccount(bankAccountID bson.ObjectId, filter model.TransactionFilter) ([]model.TransactionLog, error) {
+	where := `bank_account_id = ?`
+	args := []interface{}{bankAccountID.Hex()}
+	if !filter.From.IsZero() {
+		where += ` AND created_at >= ?`
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		where += ` AND created_at < ?`
+		args = append(args, filter.To.UTC())
+	}
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+sqlTransactionColumns+` FROM transactions WHERE `+where+` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transactionLogs := []model.TransactionLog{}
+	for rows.Next() {
+		var transactionLog model.TransactionLog
+		var id, userID, bankAccount, transactionType, createdBy string
+		var tranferID sql.NullString
+		err := rows.Scan(&id, &userID, &bankAccount, &transactionLog.AccountNumber, &transactionType,
+			&transactionLog.Amount.Amount, &transactionLog.BalanceAfter.Amount, &transactionLog.Amount.Currency,
+			&transactionLog.CounterpartyAccount, &tranferID, &createdBy, &transactionLog.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		transactionLog.ID = objectID(id)
+		transactionLog.UserID = objectID(userID)
+		transactionLog.BankAccountID = objectID(bankAccount)
+		transactionLog.Type = model.TransactionType(transactionType)
+		transactionLog.BalanceAfter.Currency = transactionLog.Amount.Currency
+		transactionLog.TranferID = objectID(tranferID.String)
+		transactionLog.CreatedBy = objectID(createdBy)
+		transactionLogs = append(transactionLogs, transactionLog)
+	}
+	return transactionLogs, rows.Err()
+}
+
+//CountByType for CountByType
+func (r *SQLTransactionRepository) CountByType(bankAccountID bson.ObjectId, transactionType model.TransactionType, from time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRow(r.dialect.rebind(`SELECT COUNT(*) FROM transactions WHERE bank_account_id = ? AND type = ? AND created_at >= ?`),
+		bankAccountID.Hex(), string(transactionType), from.UTC()).Scan(&n)
+	return n, err
+}
+
+//SQLRefreshTokenRepository is RefreshTokenRepository in SQL
+type SQLRefreshTokenRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLRefreshTokenRepository for NewSQLRefreshTokenRepository
+func NewSQLRefreshTokenRepository(db *sql.DB, dialect Dialect) *SQLRefreshTokenRepository {
+	return &SQLRefreshTokenRepository{db: db, dialect: dialect}
+}
+
+//Insert for Insert
+func (r *SQLRefreshTokenRepository) Insert(refreshToken *model.RefreshToken) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO refresh_tokens (id, user_id, family, revoked, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`),
+		refreshToken.ID, refreshToken.UserID.Hex(), refreshToken.Family.Hex(), refreshToken.Revoked, refreshToken.ExpiresAt.UTC(), refreshToken.CreatedAt.UTC())
+	return err
+}
+
+//FindByID for FindByID
+func (r *SQLRefreshTokenRepository) FindByID(id string) (model.RefreshToken, error) {
+	var refreshToken model.RefreshToken
+	var userID, family string
+	err := r.db.QueryRow(r.dialect.rebind(`SELECT id, user_id, family, revoked, expires_at, created_at FROM refresh_tokens WHERE id = ?`), id).
+		Scan(&refreshToken.ID, &userID, &family, &refreshToken.Revoked, &refreshToken.ExpiresAt, &refreshToken.CreatedAt)
+	refreshToken.UserID = objectID(userID)
+	refreshToken.Family = objectID(family)
+	return refreshToken, sqlError(err)
+}
+
+//Revoke for Revoke
+func (r *SQLRefreshTokenRepository) Revoke(id string) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE refresh_tokens SET revoked = ? WHERE id = ? AND revoked = ?`), true, id, false)
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//RevokeFamily for RevokeFamily
+func (r *SQLRefreshTokenRepository) RevokeFamily(family bson.ObjectId) error {
+	_, err := r.db.Exec(r.dialect.rebind(`UPDATE refresh_tokens SET revoked = ? WHERE family = ?`), true, family.Hex())
+	return err
+}
+
+//SQLIdempotencyKeyRepository is IdempotencyKeyRepository in SQL
+type SQLIdempotencyKeyRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLIdempotencyKeyRepository for NewSQLIdempotencyKeyRepository
+func NewSQLIdempotencyKeyRepository(db *sql.DB, dialect Dialect) *SQLIdempotencyKeyRepository {
+	return &SQLIdempotencyKeyRepository{db: db, dialect: dialect}
+}
+
+//Insert for Insert
+func (r *SQLIdempotencyKeyRepository) Insert(idempotencyKey *model.IdempotencyKey) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO idempotency_keys (id, request_hash, completed, status, content_type, body, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		idempotencyKey.ID, idempotencyKey.RequestHash, idempotencyKey.Completed, idempotencyKey.Status, idempotencyKey.ContentType,
+		idempotencyKey.Body, idempotencyKey.ExpiresAt.UTC(), idempotencyKey.CreatedAt.UTC())
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//FindByID for FindByID
+func (r *SQLIdempotencyKeyRepository) FindByID(id string) (model.IdempotencyKey, error) {
+	var idempotencyKey model.IdempotencyKey
+	err := r.db.QueryRow(r.dialect.rebind(`SELECT id, request_hash, completed, status, content_type, body, expires_at, created_at FROM idempotency_keys WHERE id = ?`), id).
+		Scan(&idempotencyKey.ID, &idempotencyKey.RequestHash, &idempotencyKey.Completed, &idempotencyKey.Status, &idempotencyKey.ContentType,
+			&idempotencyKey.Body, &idempotencyKey.ExpiresAt, &idempotencyKey.CreatedAt)
+	return idempotencyKey, sqlError(err)
+}
+
+//Complete for Complete
+func (r *SQLIdempotencyKeyRepository) Complete(id string, status int, contentType string, body []byte) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE idempotency_keys SET completed = ?, status = ?, content_type = ?, body = ? WHERE id = ?`),
+		true, status, contentType, body, id)
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//Delete for Delete
+func (r *SQLIdempotencyKeyRepository) Delete(id string) error {
+	result, err := r.db.Exec(r.dialect.rebind(`DELETE FROM idempotency_keys WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//DeleteExpired for DeleteExpired
+func (r *SQLIdempotencyKeyRepository) DeleteExpired(now time.Time) (int, error) {
+	result, err := r.db.Exec(r.dialect.rebind(`DELETE FROM idempotency_keys WHERE expires_at < ?`), now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+//SQLInterestRepository is InterestRepository in SQL
+type SQLInterestRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLInterestRepository for NewSQLInterestRepository
+func NewSQLInterestRepository(db *sql.DB, dialect Dialect) *SQLInterestRepository {
+	return &SQLInterestRepository{db: db, dialect: dialect}
+}
+
+const sqlInterestPostingColumns = `id, bank_account_id, user_id, account_number, month, amount, currency, state, last_modified`
+
+func scanInterestPosting(row interface{ Scan(...interface{}) error }) (model.InterestPosting, error) {
+	var posting model.InterestPosting
+	var id, bankAccountID, userID, state string
+	err := row.Scan(&id, &bankAccountID, &userID, &posting.AccountNumber, &posting.Month, &posting.Amount.Amount, &posting.Amount.Currency, &state, &posting.LastModified)
+	posting.ID = objectID(id)
+	posting.BankAccountID = objectID(bankAccountID)
+	posting.UserID = objectID(userID)
+	posting.State = model.InterestPostingState(state)
+	return posting, err
+}
+
+//InsertAccrual for InsertAccrual
+func (r *SQLInterestRepository) InsertAccrual(accrual *model.InterestAccrual) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO interest_accruals (id, bank_account_id, user_id, business_date, product, interest_rate, day_count, balance, currency, interest_micros, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		accrual.ID.Hex(), accrual.BankAccountID.Hex(), accrual.UserID.Hex(), accrual.BusinessDate, string(accrual.Product), accrual.InterestRate, string(accrual.DayCount),
+		accrual.Balance.Amount, accrual.Balance.Currency, accrual.InterestMicros, accrual.CreatedAt.UTC())
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//FindAccruals for FindAccruals
+func (r *SQLInterestRepository) FindAccruals(bankAccountID bson.ObjectId, from string) ([]model.InterestAccrual, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT id, bank_account_id, user_id, business_date, product, interest_rate, day_count, balance, currency, interest_micros, created_at FROM interest_accruals WHERE bank_account_id = ? AND business_date >= ? ORDER BY business_date`),
+		bankAccountID.Hex(), from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var accruals []model.InterestAccrual
+	for rows.Next() {
+		var accrual model.InterestAccrual
+		var id, accountID, userID, product, dayCount string
+		err := rows.Scan(&id, &accountID, &userID, &accrual.BusinessDate, &product, &accrual.InterestRate, &dayCount,
+			&accrual.Balance.Amount, &accrual.Balance.Currency, &accrual.InterestMicros, &accrual.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		accrual.ID = objectID(id)
+		accrual.BankAccountID = objectID(accountID)
+		accrual.UserID = objectID(userID)
+		accrual.Product = model.Product(product)
+		accrual.DayCount = model.DayCount(dayCount)
+		accruals = append(accruals, accrual)
+	}
+	return accruals, rows.Err()
+}
+
+//InsertPosting for InsertPosting
+func (r *SQLInterestRepository) InsertPosting(posting *model.InterestPosting) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO interest_postings (`+sqlInterestPostingColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		posting.ID.Hex(), posting.BankAccountID.Hex(), posting.UserID.Hex(), posting.AccountNumber, posting.Month,
+		posting.Amount.Amount, posting.Amount.Currency, string(posting.State), posting.LastModified.UTC())
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//LastAccrualDate for LastAccrualDate
+func (r *SQLInterestRepository) LastAccrualDate(bankAccountID bson.ObjectId) (string, error) {
+	var businessDate string
+	err := r.db.QueryRow(r.dialect.rebind(`SELECT business_date FROM interest_accruals WHERE bank_account_id = ? ORDER BY business_date DESC LIMIT 1`),
+		bankAccountID.Hex()).Scan(&businessDate)
+	return businessDate, sqlError(err)
+}
+
+//LastPosting for LastPosting
+func (r *SQLInterestRepository) LastPosting(bankAccountID bson.ObjectId) (model.InterestPosting, error) {
+	row := r.db.QueryRow(r.dialect.rebind(`SELECT `+sqlInterestPostingColumns+` FROM interest_postings WHERE bank_account_id = ? ORDER BY month DESC LIMIT 1`), bankAccountID.Hex())
+	posting, err := scanInterestPosting(row)
+	return posting, sqlError(err)
+}
+
+//SetPostingState for SetPostingState
+func (r *SQLInterestRepository) SetPostingState(id bson.ObjectId, state model.InterestPostingState, next model.InterestPostingState, lastModified time.Time) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE interest_postings SET state = ?, last_modified = ? WHERE id = ? AND state = ?`),
+		string(next), lastModified.UTC(), id.Hex(), string(state))
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//FindPostingsByState for FindPostingsByState
+func (r *SQLInterestRepository) FindPostingsByState(state model.InterestPostingState) ([]model.InterestPosting, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+sqlInterestPostingColumns+` FROM interest_postings WHERE state = ? ORDER BY id`), string(state))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var postings []model.InterestPosting
+	for rows.Next() {
+		posting, err := scanInterestPosting(rows)
+		if err != nil {
+			return nil, err
+		}
+		postings = append(postings, posting)
+	}
+	return postings, rows.Err()
+}
+
+//SQLLimitRepository is LimitRepository in SQL
+type SQLLimitRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLLimitRepository for NewSQLLimitRepository
+func NewSQLLimitRepository(db *sql.DB, dialect Dialect) *SQLLimitRepository {
+	return &SQLLimitRepository{db: db, dialect: dialect}
+}
+
+//FindLimits for FindLimits
+func (r *SQLLimitRepository) FindLimits(ownerID bson.ObjectId) (model.Limits, error) {
+	var limits model.Limits
+	var maxTranferCount sql.NullInt64
+	err := r.db.QueryRow(r.dialect.rebind(`SELECT max_withdraw, max_tranfer_daily, max_tranfer_count FROM limits WHERE owner_id = ?`), ownerID.Hex()).
+		Scan(&limits.MaxWithdraw, &limits.MaxTranferDaily, &maxTranferCount)
+	if maxTranferCount.Valid {
+		count := int(maxTranferCount.Int64)
+		limits.MaxTranferCount = &count
+	}
+	return limits, sqlError(err)
+}
+
+//SetLimits for SetLimits
+func (r *SQLLimitRepository) SetLimits(ownerID bson.ObjectId, limits model.Limits) error {
+	var maxTranferCount interface{}
+	if limits.MaxTranferCount != nil {
+		maxTranferCount = *limits.MaxTranferCount
+	}
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO limits (owner_id, max_withdraw, max_tranfer_daily, max_tranfer_count) VALUES (?, ?, ?, ?)
+		ON CONFLICT (owner_id) DO UPDATE SET max_withdraw = excluded.max_withdraw, max_tranfer_daily = excluded.max_tranfer_daily, max_tranfer_count = excluded.max_tranfer_count`),
+		ownerID.Hex(), limits.MaxWithdraw, limits.MaxTranferDaily, maxTranferCount)
+	return err
+}
+
+func (r *SQLLimitRepository) findUsage(q querier, ownerID bson.ObjectId, businessDate string) (model.LimitUsage, error) {
+	usage := model.LimitUsage{OwnerID: ownerID, BusinessDate: businessDate}
+	err := q.QueryRow(r.dialect.rebind(`SELECT tranfer_total, tranfer_count FROM limit_usage WHERE owner_id = ? AND business_date = ?`), ownerID.Hex(), businessDate).
+		Scan(&usage.TranferTotal, &usage.TranferCount)
+	if err == sql.ErrNoRows {
+		return usage, nil
+	}
+	return usage, err
+}
+
+//FindUsage for FindUsage
+func (r *SQLLimitRepository) FindUsage(ownerID bson.ObjectId, businessDate string) (model.LimitUsage, error) {
+	return r.findUsage(r.db, ownerID, businessDate)
+}
+
+//AddUsage for AddUsage, first usage of the business date is inserted
+func (r *SQLLimitRepository) AddUsage(ownerID bson.ObjectId, businessDate string, amount int64, count int) (model.LimitUsage, error) {
+	var usage model.LimitUsage
+	err := withTx(r.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(r.dialect.rebind(`INSERT INTO limit_usage (owner_id, business_date, tranfer_total, tranfer_count) VALUES (?, ?, ?, ?)
+			ON CONFLICT (owner_id, business_date) DO UPDATE SET tranfer_total = limit_usage.tranfer_total + excluded.tranfer_total, tranfer_count = limit_usage.tranfer_count + excluded.tranfer_count`),
+			ownerID.Hex(), businessDate, amount, count)
+		if err != nil {
+			return err
+		}
+		usage, err = r.findUsage(tx, ownerID, businessDate)
+		return err
+	})
+	return usage, err
+}
+
+//SQLStandingOrderRepository is StandingOrderRepository in SQL
+type SQLStandingOrderRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLStandingOrderRepository for NewSQLStandingOrderRepository
+func NewSQLStandingOrderRepository(db *sql.DB, dialect Dialect) *SQLStandingOrderRepository {
+	return &SQLStandingOrderRepository{db: db, dialect: dialect}
+}
+
+const sqlStandingOrderColumns = `id, user_id, account_number_from, account_number_to, amount, currency, schedule_kind, schedule_date, schedule_weekday, schedule_day, end_date, end_count, executed, next_date, next_run, attempts, state, last_error, version, created_at, last_modified`
+
+func scanStandingOrder(row interface{ Scan(...interface{}) error }) (model.StandingOrder, error) {
+	var order model.StandingOrder
+	var id, userID, kind, state string
+	err := row.Scan(&id, &userID, &order.AccountNumberFrom, &order.AccountNumberTo, &order.Amount.Amount, &order.Amount.Currency,
+		&kind, &order.Schedule.Date, &order.Schedule.Weekday, &order.Schedule.Day, &order.EndDate, &order.Count, &order.Executed,
+		&order.NextDate, &order.NextRun, &order.Attempts, &state, &order.LastError, &order.Version, &order.CreatedAt, &order.LastModified)
+	order.ID = objectID(id)
+	order.UserID = objectID(userID)
+	order.Schedule.Kind = model.ScheduleKind(kind)
+	order.State = model.StandingOrderState(state)
+	return order, err
+}
+
+func (r *SQLStandingOrderRepository) findOrders(where string, args ...interface{}) ([]model.StandingOrder, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+sqlStandingOrderColumns+` FROM standing_orders WHERE `+where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var orders []model.StandingOrder
+	for rows.Next() {
+		order, err := scanStandingOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+//Insert for Insert
+func (r *SQLStandingOrderRepository) Insert(order *model.StandingOrder) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO standing_orders (`+sqlStandingOrderColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		order.ID.Hex(), order.UserID.Hex(), order.AccountNumberFrom, order.AccountNumberTo, order.Amount.Amount, order.Amount.Currency,
+		string(order.Schedule.Kind), order.Schedule.Date, order.Schedule.Weekday, order.Schedule.Day, order.EndDate, order.Count, order.Executed,
+		order.NextDate, order.NextRun.UTC(), order.Attempts, string(order.State), order.LastError, order.Version, order.CreatedAt.UTC(), order.LastModified.UTC())
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+//FindByID for FindByID
+func (r *SQLStandingOrderRepository) FindByID(id bson.ObjectId) (model.StandingOrder, error) {
+	row := r.db.QueryRow(r.dialect.rebind(`SELECT `+sqlStandingOrderColumns+` FROM standing_orders WHERE id = ?`), id.Hex())
+	order, err := scanStandingOrder(row)
+	return order, sqlError(err)
+}
+
+//FindByUser for FindByUser
+func (r *SQLStandingOrderRepository) FindByUser(userID bson.ObjectId) ([]model.StandingOrder, error) {
+	return r.findOrders(`user_id = ? ORDER BY id`, userID.Hex())
+}
+
+//FindDue for FindDue
+func (r *SQLStandingOrderRepository) FindDue(now time.Time) ([]model.StandingOrder, error) {
+	return r.findOrders(`state = ? AND next_run <= ? ORDER BY next_run`, string(model.StandingOrderActive), now.UTC())
+}
+
+//Update for Update
+func (r *SQLStandingOrderRepository) Update(order *model.StandingOrder) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE standing_orders SET executed = ?, next_date = ?, next_run = ?, attempts = ?, state = ?, last_error = ?, last_modified = ?, version = version + 1 WHERE id = ? AND version = ?`),
+		order.Executed, order.NextDate, order.NextRun.UTC(), order.Attempts, string(order.State), order.LastError, order.LastModified.UTC(), order.ID.Hex(), order.Version)
+	if err != nil {
+		return err
+	}
+	if err := rowsAffected(result); err != nil {
+		var n int
+		if countErr := r.db.QueryRow(r.dialect.rebind(`SELECT COUNT(*) FROM standing_orders WHERE id = ?`), order.ID.Hex()).Scan(&n); countErr == nil && n > 0 {
+			return ErrConflict
+		}
+		return err
+	}
+	order.Version++
+	return nil
+}
+
+//InsertExecution for InsertExecution
+func (r *SQLStandingOrderRepository) InsertExecution(execution *model.StandingOrderExecution) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO standing_order_executions (id, standing_order_id, business_date, attempt, status, tranfer_id, error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		execution.ID.Hex(), execution.StandingOrderID.Hex(), execution.BusinessDate, execution.Attempt, string(execution.Status),
+		nullID(execution.TranferID), execution.Error, execution.CreatedAt.UTC())
+	return err
+}
+
+//FindExecutions for FindExecutions
+func (r *SQLStandingOrderRepository) FindExecutions(standingOrderID bson.ObjectId) ([]model.StandingOrderExecution, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT id, standing_order_id, business_date, attempt, status, tranfer_id, error, created_at FROM standing_order_executions WHERE standing_order_id = ? ORDER BY created_at DESC, id DESC`),
+		standingOrderID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var executions []model.StandingOrderExecution
+	for rows.Next() {
+		var execution model.StandingOrderExecution
+		var id, orderID, status string
+		var tranferID sql.NullString
+		err := rows.Scan(&id, &orderID, &execution.BusinessDate, &execution.Attempt, &status, &tranferID, &execution.Error, &execution.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		execution.ID = objectID(id)
+		execution.StandingOrderID = objectID(orderID)
+		execution.Status = model.StandingOrderExecutionStatus(status)
+		execution.TranferID = objectID(tranferID.String)
+		executions = append(executions, execution)
+	}
+	return executions, rows.Err()
+}
+
+//SQLBulkPaymentRepository is BulkPaymentRepository in SQL, Lines are kept in bulk_payment_lines
+type SQLBulkPaymentRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLBulkPaymentRepository for NewSQLBulkPaymentRepository
+func NewSQLBulkPaymentRepository(db *sql.DB, dialect Dialect) *SQLBulkPaymentRepository {
+	return &SQLBulkPaymentRepository{db: db, dialect: dialect}
+}
+
+const sqlBulkPaymentColumns = `id, user_id, message_id, message_created_at, number_of_transactions, control_sum, state, reason_code, reason, created_at, last_modified`
+
+const sqlBulkPaymentLineColumns = `line, payment_information_id, instruction_id, end_to_end_id, account_number_from, account_number_to, amount, currency, status, reason_code, reason, tranfer_id, last_modified`
+
+//Insert for Insert, BulkPayment and its Lines are inserted in one transaction
+func (r *SQLBulkPaymentRepository) Insert(bulkPayment *model.BulkPayment) error {
+	err := withTx(r.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(r.dialect.rebind(`INSERT INTO bulk_payments (`+sqlBulkPaymentColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			bulkPayment.ID.Hex(), bulkPayment.UserID.Hex(), bulkPayment.MessageID, bulkPayment.MessageCreatedAt, bulkPayment.NumberOfTransactions,
+			bulkPayment.ControlSum, string(bulkPayment.State), bulkPayment.ReasonCode, bulkPayment.Reason, bulkPayment.CreatedAt.UTC(), bulkPayment.LastModified.UTC())
+		if err != nil {
+			return err
+		}
+		for _, line := range bulkPayment.Lines {
+			_, err := tx.Exec(r.dialect.rebind(`INSERT INTO bulk_payment_lines (bulk_payment_id, `+sqlBulkPaymentLineColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+				bulkPayment.ID.Hex(), line.Line, line.PaymentInformationID, line.InstructionID, line.EndToEndID, line.AccountNumberFrom, line.AccountNumberTo,
+				line.Amount.Amount, line.Amount.Currency, string(line.Status), line.ReasonCode, line.Reason, nullID(line.TranferID), line.LastModified.UTC())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *SQLBulkPaymentRepository) findLines(bulkPayment *model.BulkPayment) error {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+sqlBulkPaymentLineColumns+` FROM bulk_payment_lines WHERE bulk_payment_id = ? ORDER BY line`), bulkPayment.ID.Hex())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	bulkPayment.Lines = []model.BulkPaymentLine{}
+	for rows.Next() {
+		var line model.BulkPaymentLine
+		var status string
+		var tranferID sql.NullString
+		err := rows.Scan(&line.Line, &line.PaymentInformationID, &line.InstructionID, &line.EndToEndID, &line.AccountNumberFrom, &line.AccountNumberTo,
+			&line.Amount.Amount, &line.Amount.Currency, &status, &line.ReasonCode, &line.Reason, &tranferID, &line.LastModified)
+		if err != nil {
+			return err
+		}
+		line.Status = model.BulkPaymentLineStatus(status)
+		line.TranferID = objectID(tranferID.String)
+		bulkPayment.Lines = append(bulkPayment.Lines, line)
+	}
+	return rows.Err()
+}
+
+func (r *SQLBulkPaymentRepository) findBulkPayments(where string, args ...interface{}) ([]model.BulkPayment, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+sqlBulkPaymentColumns+` FROM bulk_payments WHERE `+where), args...)
+	if err != nil {
+		return nil, err
+	}
+	var bulkPayments []model.BulkPayment
+	for rows.Next() {
+		var bulkPayment model.BulkPayment
+		var id, userID, state string
+		err := rows.Scan(&id, &userID, &bulkPayment.MessageID, &bulkPayment.MessageCreatedAt, &bulkPayment.NumberOfTransactions, &bulkPayment.ControlSum,
+			&state, &bulkPayment.ReasonCode, &bulkPayment.Reason, &bulkPayment.CreatedAt, &bulkPayment.LastModified)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		bulkPayment.ID = objectID(id)
+		bulkPayment.UserID = objectID(userID)
+		bulkPayment.State = model.BulkPaymentState(state)
+		bulkPayments = append(bulkPayments, bulkPayment)
+	}
+	//rows are closed before Lines are read, SQLite has only one connection
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range bulkPayments {
+		if err := r.findLines(&bulkPayments[i]); err != nil {
+			return nil, err
+		}
+	}
+	return bulkPayments, nil
+}
+
+//FindByID for FindByID
+func (r *SQLBulkPaymentRepository) FindByID(id bson.ObjectId) (model.BulkPayment, error) {
+	bulkPayments, err := r.findBulkPayments(`id = ?`, id.Hex())
+	if err != nil {
+		return model.BulkPayment{}, err
+	}
+	if len(bulkPayments) == 0 {
+		return model.BulkPayment{}, ErrNotFound
+	}
+	return bulkPayments[0], nil
+}
+
+//FindByUser for FindByUser
+func (r *SQLBulkPaymentRepository) FindByUser(userID bson.ObjectId) ([]model.BulkPayment, error) {
+	return r.findBulkPayments(`user_id = ? ORDER BY id`, userID.Hex())
+}
+
+//FindByState for FindByState
+func (r *SQLBulkPaymentRepository) FindByState(states ...model.BulkPaymentState) ([]model.BulkPayment, error) {
+	if len(states) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{}
+	placeholders := []string{}
+	for _, state := range states {
+		args = append(args, string(state))
+		placeholders = append(placeholders, "?")
+	}
+	return r.findBulkPayments(`state IN (`+strings.Join(placeholders, ", ")+`) ORDER BY id`, args...)
+}
+
+//SetState for SetState
+func (r *SQLBulkPaymentRepository) SetState(id bson.ObjectId, state model.BulkPaymentState, next model.BulkPaymentState, lastModified time.Time) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE bulk_payments SET state = ?, last_modified = ? WHERE id = ? AND state = ?`),
+		string(next), lastModified.UTC(), id.Hex(), string(state))
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//SetLine for SetLine
+func (r *SQLBulkPaymentRepository) SetLine(id bson.ObjectId, line model.BulkPaymentLine, status model.BulkPaymentLineStatus) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE bulk_payment_lines SET status = ?, reason_code = ?, reason = ?, tranfer_id = ?, last_modified = ? WHERE bulk_payment_id = ? AND line = ? AND status = ?`),
+		string(line.Status), line.ReasonCode, line.Reason, nullID(line.TranferID), line.LastModified.UTC(), id.Hex(), line.Line, string(status))
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//SQLApprovalRepository is ApprovalRepository in SQL, Tranfer is kept in columns of approvals
+type SQLApprovalRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLApprovalRepository for NewSQLApprovalRepository
+func NewSQLApprovalRepository(db *sql.DB, dialect Dialect) *SQLApprovalRepository {
+	return &SQLApprovalRepository{db: db, dialect: dialect}
+}
+
+const sqlApprovalColumns = `id, kind, state, maker_id, checker_id, user_id, bank_account_id, account_number_from, account_number_to, amount, currency, tranfer_id, reason, error, created_at, expires_at, last_modified`
+
+//Insert for Insert
+func (r *SQLApprovalRepository) Insert(approval *model.Approval) error {
+	var tranfer model.Tranfer
+	if approval.Tranfer != nil {
+		tranfer = *approval.Tranfer
+	}
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO approvals (`+sqlApprovalColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		approval.ID.Hex(), string(approval.Kind), string(approval.State), approval.MakerID.Hex(), nullID(approval.CheckerID), approval.UserID.Hex(),
+		nullID(approval.BankAccountID), tranfer.From, tranfer.To, tranfer.Amount.Amount, tranfer.Amount.Currency, nullID(approval.TranferID),
+		approval.Reason, approval.Error, approval.CreatedAt.UTC(), approval.ExpiresAt.UTC(), approval.LastModified.UTC())
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *SQLApprovalRepository) findApprovals(where string, args ...interface{}) ([]model.Approval, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+sqlApprovalColumns+` FROM approvals WHERE `+where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var approvals []model.Approval
+	for rows.Next() {
+		var approval model.Approval
+		var tranfer model.Tranfer
+		var id, kind, state, makerID, userID string
+		var checkerID, bankAccountID, tranferID sql.NullString
+		err := rows.Scan(&id, &kind, &state, &makerID, &checkerID, &userID, &bankAccountID, &tranfer.From, &tranfer.To, &tranfer.Amount.Amount,
+			&tranfer.Amount.Currency, &tranferID, &approval.Reason, &approval.Error, &approval.CreatedAt, &approval.ExpiresAt, &approval.LastModified)
+		if err != nil {
+			return nil, err
+		}
+		approval.ID = objectID(id)
+		approval.Kind = model.ApprovalKind(kind)
+		approval.State = model.ApprovalState(state)
+		approval.MakerID = objectID(makerID)
+		approval.CheckerID = objectID(checkerID.String)
+		approval.UserID = objectID(userID)
+		approval.BankAccountID = objectID(bankAccountID.String)
+		approval.TranferID = objectID(tranferID.String)
+		if approval.Kind == model.ApprovalTranfer {
+			approval.Tranfer = &tranfer
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals, rows.Err()
+}
+
+//FindByID for FindByID
+func (r *SQLApprovalRepository) FindByID(id bson.ObjectId) (model.Approval, error) {
+	approvals, err := r.findApprovals(`id = ?`, id.Hex())
+	if err != nil {
+		return model.Approval{}, err
+	}
+	if len(approvals) == 0 {
+		return model.Approval{}, ErrNotFound
+	}
+	return approvals[0], nil
+}
+
+//FindByState for FindByState
+func (r *SQLApprovalRepository) FindByState(states ...model.ApprovalState) ([]model.Approval, error) {
+	if len(states) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{}
+	placeholders := []string{}
+	for _, state := range states {
+		args = append(args, string(state))
+		placeholders = append(placeholders, "?")
+	}
+	return r.findApprovals(`state IN (`+strings.Join(placeholders, ", ")+`) ORDER BY id`, args...)
+}
+
+//SetState for SetState
+func (r *SQLApprovalRepository) SetState(approval model.Approval, state model.ApprovalState) error {
+	result, err := r.db.Exec(r.dialect.rebind(`UPDATE approvals SET state = ?, checker_id = ?, tranfer_id = ?, reason = ?, error = ?, last_modified = ? WHERE id = ? AND state = ?`),
+		string(approval.State), nullID(approval.CheckerID), nullID(approval.TranferID), approval.Reason, approval.Error, approval.LastModified.UTC(),
+		approval.ID.Hex(), string(state))
+	if err != nil {
+		return err
+	}
+	return rowsAffected(result)
+}
+
+//SQLAuditRepository is AuditRepository in SQL
+type SQLAuditRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+//NewSQLAuditRepository for NewSQLAuditRepository
+func NewSQLAuditRepository(db *sql.DB, dialect Dialect) *SQLAuditRepository {
+	return &SQLAuditRepository{db: db, dialect: dialect}
+}
+
+//Insert for Insert
+func (r *SQLAuditRepository) Insert(entry *model.AuditEntry) error {
+	_, err := r.db.Exec(r.dialect.rebind(`INSERT INTO audit (id, approval_id, action, actor_id, detail, created_at) VALUES (?, ?, ?, ?, ?, ?)`),
+		entry.ID.Hex(), entry.ApprovalID.Hex(), string(entry.Action), nullID(entry.ActorID), entry.Detail, entry.CreatedAt.UTC())
+	return err
+}
+
+//FindByApproval for FindByApproval
+func (r *SQLAuditRepository) FindByApproval(approvalID bson.ObjectId) ([]model.AuditEntry, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT id, action, actor_id, detail, created_at FROM audit WHERE approval_id = ? ORDER BY id`), approvalID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []model.AuditEntry
+	for rows.Next() {
+		entry := model.AuditEntry{ApprovalID: approvalID}
+		var id, action string
+		var actorID sql.NullString
+		if err := rows.Scan(&id, &action, &actorID, &entry.Detail, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.ID = objectID(id)
+		entry.Action = model.AuditAction(action)
+		entry.ActorID = objectID(actorID.String)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
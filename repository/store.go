@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"database/sql"
+
+	mgo "github.com/globalsign/mgo"
+)
+
+//Store is every repository of one backend
+type Store struct {
+	Users           UserRepository
+	BankAccounts    BankAccountRepository
+	Tranfers        TranferRepository
+	Transactions    TransactionRepository
+	Interest        InterestRepository
+	Limits          LimitRepository
+	StandingOrders  StandingOrderRepository
+	BulkPayments    BulkPaymentRepository
+	Approvals       ApprovalRepository
+	Audit           AuditRepository
+	RefreshTokens   RefreshTokenRepository
+	IdempotencyKeys IdempotencyKeyRepository
+}
+
+//NewMongoStore for Store in mgo
+func NewMongoStore(db *mgo.Database) *Store {
+	return &Store{
+		Users:           NewMongoUserRepository(db),
+		BankAccounts:    NewMongoBankAccountRepository(db),
+		Tranfers:        NewMongoTranferRepository(db),
+		Transactions:    NewMongoTransactionRepository(db),
+		Interest:        NewMongoInterestRepository(db),
+		Limits:          NewMongoLimitRepository(db),
+		StandingOrders:  NewMongoStandingOrderRepository(db),
+		BulkPayments:    NewMongoBulkPaymentRepository(db),
+		Approvals:       NewMongoApprovalRepository(db),
+		Audit:           NewMongoAuditRepository(db),
+		RefreshTokens:   NewMongoRefreshTokenRepository(db),
+		IdempotencyKeys: NewMongoIdempotencyKeyRepository(db),
+	}
+}
+
+//NewSQLStore for Store in PostgreSQL or SQLite, db must be migrated with Migrate first
+func NewSQLStore(db *sql.DB, dialect Dialect) *Store {
+	return &Store{
+		Users:           NewSQLUserRepository(db, dialect),
+		BankAccounts:    NewSQLBankAccountRepository(db, dialect),
+		Tranfers:        NewSQLTranferRepository(db, dialect),
+		Transactions:    NewSQLTransactionRepository(db, dialect),
+		Interest:        NewSQLInterestRepository(db, dialect),
+		Limits:          NewSQLLimitRepository(db, dialect),
+		StandingOrders:  NewSQLStandingOrderRepository(db, dialect),
+		BulkPayments:    NewSQLBulkPaymentRepository(db, dialect),
+		Approvals:       NewSQLApprovalRepository(db, dialect),
+		Audit:           NewSQLAuditRepository(db, dialect),
+		RefreshTokens:   NewSQLRefreshTokenRepository(db, dialect),
+		IdempotencyKeys: NewSQLIdempotencyKeyRepository(db, dialect),
+	}
+}
+
+//NewMemoryStore for Store in MemoryDB
+func NewMemoryStore() *Store {
+	db := NewMemoryDB()
+	return &Store{
+		Users:           NewMemoryUserRepository(db),
+		BankAccounts:    NewMemoryBankAccountRepository(db),
+		Tranfers:        NewMemoryTranferRepository(db),
+		Transactions:    NewMemoryTransactionRepository(db),
+		Interest:        NewMemoryInterestRepository(db),
+		Limits:          NewMemoryLimitRepository(db),
+		StandingOrders:  NewMemoryStandingOrderRepository(db),
+		BulkPayments:    NewMemoryBulkPaymentRepository(db),
+		Approvals:       NewMemoryApprovalRepository(db),
+		Audit:           NewMemoryAuditRepository(db),
+		RefreshTokens:   NewMemoryRefreshTokenRepository(db),
+		IdempotencyKeys: NewMemoryIdempotencyKeyRepository(db),
+	}
+}
@@ -0,0 +1,112 @@
+package schedule
+
+import (
+	"bankaccountapi/model"
+	"errors"
+	"strings"
+	"time"
+)
+
+//DateFormat is format of business dates of Schedule
+const DateFormat = "2006-01-02"
+
+var (
+	//ErrKind is returned when Kind of Schedule is not supported
+	ErrKind = errors.New("schedule kind must be once, weekly, monthly or last_business_day")
+	//ErrDate is returned when Date of ScheduleOnce is not a business date
+	ErrDate = errors.New("schedule date must be like \"2006-01-02\"")
+	//ErrWeekday is returned when Weekday of ScheduleWeekly is not a day of week
+	ErrWeekday = errors.New("schedule weekday must be like \"monday\"")
+	//ErrDay is returned when Day of ScheduleMonthly is not from 1 to 31
+	ErrDay = errors.New("schedule day must be from 1 to 31")
+)
+
+//Validate for check schedule has what its Kind needs
+func Validate(schedule model.Schedule) error {
+	switch schedule.Kind {
+	case model.ScheduleOnce:
+		if _, err := time.Parse(DateFormat, schedule.Date); err != nil {
+			return ErrDate
+		}
+	case model.ScheduleWeekly:
+		if _, err := weekday(schedule.Weekday); err != nil {
+			return err
+		}
+	case model.ScheduleMonthly:
+		if schedule.Day < 1 || schedule.Day > 31 {
+			return ErrDay
+		}
+	case model.ScheduleLastBusinessDay:
+	default:
+		return ErrKind
+	}
+	return nil
+}
+
+func weekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, nil
+		}
+	}
+	return 0, ErrWeekday
+}
+
+//Next for the first date of schedule after date, ok is false when schedule has no date after it.
+//date is midnight of a business date and the date returned is midnight in the same Location
+func Next(schedule model.Schedule, after time.Time) (next time.Time, ok bool, err error) {
+	if err := Validate(schedule); err != nil {
+		return time.Time{}, false, err
+	}
+	after = midnight(after)
+	switch schedule.Kind {
+	case model.ScheduleOnce:
+		date, _ := time.ParseInLocation(DateFormat, schedule.Date, after.Location())
+		return date, date.After(after), nil
+	case model.ScheduleWeekly:
+		day, _ := weekday(schedule.Weekday)
+		next = after.AddDate(0, 0, 1)
+		for next.Weekday() != day {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next, true, nil
+	case model.ScheduleMonthly:
+		next = dayOfMonth(after.Year(), after.Month(), schedule.Day, after.Location())
+		if !next.After(after) {
+			next = dayOfMonth(after.Year(), after.Month()+1, schedule.Day, after.Location())
+		}
+		return next, true, nil
+	}
+	next = lastBusinessDay(after.Year(), after.Month(), after.Location())
+	if !next.After(after) {
+		next = lastBusinessDay(after.Year(), after.Month()+1, after.Location())
+	}
+	return next, true, nil
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+//lastDay for the last day of month, time.Date moves day 0 of the next month back to it
+func lastDay(year int, month time.Month, location *time.Location) time.Time {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, location)
+}
+
+//dayOfMonth for day of month, or the last day of month when it is shorter than day
+func dayOfMonth(year int, month time.Month, day int, location *time.Location) time.Time {
+	last := lastDay(year, month, location)
+	if day > last.Day() {
+		return last
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+//lastBusinessDay for the last Monday to Friday of month
+func lastBusinessDay(year int, month time.Month, location *time.Location) time.Time {
+	day := lastDay(year, month, location)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"bankaccountapi/search"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	//SearchLimit is default number of CustomerMatch
+	SearchLimit = 20
+	//SearchLimitMax is max number of CustomerMatch
+	SearchLimitMax = 100
+	//searchBatch is User read at a time while searching
+	searchBatch = 500
+)
+
+//ErrSearchQuery is returned when search has nothing to find
+var ErrSearchQuery = errors.New("please require q")
+
+//searchUnmaskedRoles is roles that see PII of CustomerMatch as it is, every other role sees it masked
+var searchUnmaskedRoles = map[model.Role]bool{
+	model.RoleAdmin: true,
+}
+
+//SearchService is interface
+type SearchService interface {
+	SearchCustomers(q string, limit int, roles []model.Role) ([]model.CustomerMatch, error)
+}
+
+//SearchServiceImplement is struct
+type SearchServiceImplement struct {
+	users repository.UserRepository
+}
+
+//customerFields for fields of user searched and their weight
+func customerFields(user model.User) []search.Field {
+	fields := []search.Field{
+		{Name: "username", Value: user.Username, Kind: search.Text, Weight: 4},
+		{Name: "first_name", Value: user.FirstName, Kind: search.Text, Weight: 3},
+		{Name: "last_name", Value: user.LastName, Kind: search.Text, Weight: 3},
+		{Name: "email", Value: user.Email, Kind: search.Text, Weight: 3},
+		{Name: "tel", Value: user.Tel, Kind: search.PhoneNumber, Weight: 4},
+		{Name: "idcard", Value: user.IDcard, Kind: search.Identifier, Weight: 5},
+	}
+	for _, bankAccount := range user.UserBankAccount {
+		fields = append(fields, search.Field{Name: "account_number", Value: bankAccount.AccountNumber, Kind: search.Identifier, Weight: 5})
+	}
+	return fields
+}
+
+//SearchCustomers for at most limit User matching every word of q in name, username, email, tel, ID card or
+//account number, most relevant first. Every User is read searchBatch at a time so only the best limit are kept
+func (s *SearchServiceImplement) SearchCustomers(q string, limit int, roles []model.Role) ([]model.CustomerMatch, error) {
+	query := search.ParseQuery(q)
+	if query.Empty() {
+		return nil, ErrSearchQuery
+	}
+	matches := []model.CustomerMatch{}
+	filter := model.UserFilter{Sort: model.UserSortID, Limit: searchBatch}
+	for {
+		users, err := s.users.Find(filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			score, matched := query.Score(customerFields(user))
+			if score == 0 {
+				continue
+			}
+			match := newCustomerMatch(user)
+			match.Score, match.Matched = score, matched
+			matches = keepBest(matches, match, limit)
+		}
+		if len(users) < filter.Limit {
+			break
+		}
+		last := users[len(users)-1]
+		filter.After = &model.UserCursor{Value: last.SortValue(filter.Sort), ID: last.ID}
+	}
+	if !unmaskedRoles(roles) {
+		for i := range matches {
+			maskCustomerMatch(&matches[i])
+		}
+	}
+	return matches, nil
+}
+
+//unmaskedRoles for check any Role of roles sees PII as it is
+func unmaskedRoles(roles []model.Role) bool {
+	for _, role := range roles {
+		if searchUnmaskedRoles[role] {
+			return true
+		}
+	}
+	return false
+}
+
+//newCustomerMatch for CustomerMatch of user with no Score
+func newCustomerMatch(user model.User) model.CustomerMatch {
+	match := model.CustomerMatch{
+		ID:             user.ID,
+		Username:       user.Username,
+		FirstName:      user.FirstName,
+		LastName:       user.LastName,
+		Email:          user.Email,
+		Tel:            user.Tel,
+		IDcard:         user.IDcard,
+		AccountNumbers: []string{},
+	}
+	for _, bankAccount := range user.UserBankAccount {
+		match.AccountNumbers = append(match.AccountNumbers, bankAccount.AccountNumber)
+	}
+	return match
+}
+
+//maskedCustomers for users as CustomerMatch masked like customer search, list of User uses it for roles that
+//must not see PII
+func maskedCustomers(users []model.User) []model.CustomerMatch {
+	matches := []model.CustomerMatch{}
+	for _, user := range users {
+		match := newCustomerMatch(user)
+		maskCustomerMatch(&match)
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+//keepBest for add match to matches kept in order of Score then Username, only the first limit are kept
+func keepBest(matches []model.CustomerMatch, match model.CustomerMatch, limit int) []model.CustomerMatch {
+	i := sort.Search(len(matches), func(i int) bool {
+		return matches[i].Score < match.Score || matches[i].Score == match.Score && matches[i].Username > match.Username
+	})
+	if i >= limit {
+		return matches
+	}
+	matches = append(matches, model.CustomerMatch{})
+	copy(matches[i+1:], matches[i:])
+	matches[i] = match
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+//maskCustomerMatch for hide PII of match, enough is left to tell customers apart on the phone
+func maskCustomerMatch(match *model.CustomerMatch) {
+	match.LastName = search.MaskName(match.LastName)
+	match.Email = search.MaskEmail(match.Email)
+	match.Tel = search.MaskTail(match.Tel, 4)
+	match.IDcard = search.MaskTail(match.IDcard, 4)
+	for i, accountNumber := range match.AccountNumbers {
+		match.AccountNumbers[i] = search.MaskTail(accountNumber, 4)
+	}
+}
+
+//SearchCustomersEndPoint is SearchCustomersEndPoint, q is words to find and limit is number of CustomerMatch
+func (m *DataObjectAccess) SearchCustomersEndPoint(c echo.Context) (err error) {
+	limit := SearchLimit
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > SearchLimitMax {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(SearchLimitMax))
+		}
+	}
+	matches, err := m.searchService.SearchCustomers(c.QueryParam("q"), limit, AuthRoles(c))
+	if err == ErrSearchQuery {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(matches)
+	return c.JSON(http.StatusOK, MapJSONCustomer(matches))
+}
+
+//MapJSONCustomer for MapJSONCustomer
+func MapJSONCustomer(customer interface{}) interface{} {
+	dataJSON := map[string]interface{}{
+		"customers": customer,
+	}
+	return dataJSON
+}
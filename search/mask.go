@@ -0,0 +1,45 @@
+package search
+
+import "strings"
+
+//maskRune is what hidden letters of PII are replaced with
+const maskRune = '*'
+
+//MaskTail for value with every letter hidden except the last visible, spaces and - are kept and not counted.
+//Value not longer than visible is hidden whole
+func MaskTail(value string, visible int) string {
+	runes := []rune(value)
+	letters := len(runes) - strings.Count(value, " ") - strings.Count(value, "-")
+	if letters <= visible {
+		visible = 0
+	}
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == ' ' || runes[i] == '-' {
+			continue
+		}
+		if visible > 0 {
+			visible--
+			continue
+		}
+		runes[i] = maskRune
+	}
+	return string(runes)
+}
+
+//MaskName for name with only its first letter shown
+func MaskName(name string) string {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return name
+	}
+	return string(runes[0]) + strings.Repeat(string(maskRune), len(runes)-1)
+}
+
+//MaskEmail for email with only first letter of local part and its domain shown
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return MaskName(email)
+	}
+	return MaskName(email[:at]) + email[at:]
+}
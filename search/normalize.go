@@ -0,0 +1,105 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	thaiNikhahit = '\u0e4d'
+	thaiSaraAa   = '\u0e32'
+	thaiSaraAm   = '\u0e33'
+)
+
+//latinFold is Latin letter with diacritic and the letter without it
+var latinFold = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+//isThaiToneMark for mai ek, mai tho, mai tri and mai chattawa, they are dropped so a name matches whatever tone was typed
+func isThaiToneMark(r rune) bool {
+	return r >= '\u0e48' && r <= '\u0e4b'
+}
+
+//isThaiMark for Thai vowel or sign written above or below a consonant, mai han akat, sara i to phinthu
+//and maitaikhu to yamakkan
+func isThaiMark(r rune) bool {
+	return r == '\u0e31' || r >= '\u0e34' && r <= '\u0e3a' || r >= '\u0e47' && r <= '\u0e4e'
+}
+
+//Normalize for text to compare in search. Latin is lower case without diacritics, full width letters and Thai digits
+//are ASCII, Thai has no tone marks, no repeated marks and sara am is one letter. Spaces are collapsed to one
+func Normalize(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	space := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r >= '\uff01' && r <= '\uff5e':
+			//full width ASCII
+			r -= 0xfee0
+		case r >= '\u0e50' && r <= '\u0e59':
+			//Thai digits
+			r = '0' + r - '\u0e50'
+		case r == '\u200b' || r == '\u200c' || r == '\u200d' || r == '\ufeff' || isThaiToneMark(r):
+			//zero width space, joiners and byte order mark
+			continue
+		case r == thaiNikhahit:
+			//nikhahit and sara aa, maybe with tone mark between them, is sara am
+			j := i + 1
+			for j < len(runes) && isThaiToneMark(runes[j]) {
+				j++
+			}
+			if j < len(runes) && runes[j] == thaiSaraAa {
+				r, i = thaiSaraAm, j
+			}
+		}
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+			continue
+		}
+		if isThaiMark(r) && i > 0 && runes[i-1] == r {
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		r = unicode.ToLower(r)
+		if folded, ok := latinFold[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+//Compact for identifier like phone, ID card or account number to compare in search, it is Normalize with only letters and digits
+func Compact(s string) string {
+	var b strings.Builder
+	for _, r := range Normalize(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || isThaiMark(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+//Phone for Compact of Thai phone number, +66 in front is 0
+func Phone(s string) string {
+	phone := Compact(s)
+	if strings.HasPrefix(phone, "66") && len(phone) == 11 {
+		return "0" + phone[2:]
+	}
+	return phone
+}
@@ -0,0 +1,100 @@
+package search
+
+import "strings"
+
+//minContains is shortest term matched in the middle of a value, shorter terms only match start of a word or end of identifier
+const minContains = 3
+
+//Kind is how value of Field is compared
+type Kind int
+
+const (
+	//Text is compared with Normalize
+	Text Kind = iota
+	//Identifier like ID card or account number is compared with Compact
+	Identifier
+	//PhoneNumber is compared with Phone
+	PhoneNumber
+)
+
+//Field is one value of a record that is searched
+type Field struct {
+	Name   string
+	Value  string
+	Kind   Kind
+	Weight int
+}
+
+//term is one word of Query in form of each Kind
+type term [3]string
+
+//normalizers is how value of each Kind is compared
+var normalizers = [3]func(string) string{Normalize, Compact, Phone}
+
+//Query is words to find, every word must match some Field
+type Query struct {
+	terms []term
+}
+
+//ParseQuery for Query of words of q
+func ParseQuery(q string) Query {
+	var query Query
+	for _, word := range strings.Fields(Normalize(q)) {
+		query.terms = append(query.terms, term{word, Compact(word), Phone(word)})
+	}
+	return query
+}
+
+//Empty for check Query has no word
+func (q Query) Empty() bool {
+	return len(q.terms) == 0
+}
+
+//Score for relevance of fields to Query and names of fields that matched, score is 0 when some word matches nothing.
+//A word scores Weight of its best Field times 4 for whole value, 3 for start of value, 2 for start of a word or end
+//of identifier and 1 for anywhere else
+func (q Query) Score(fields []Field) (int, []string) {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = normalizers[field.Kind](field.Value)
+	}
+	total := 0
+	matched := map[string]bool{}
+	var names []string
+	for _, t := range q.terms {
+		best, bestField := 0, ""
+		for i, field := range fields {
+			if score := match(values[i], t[field.Kind], field.Kind != Text) * field.Weight; score > best {
+				best, bestField = score, field.Name
+			}
+		}
+		if best == 0 {
+			return 0, nil
+		}
+		total += best
+		if !matched[bestField] {
+			matched[bestField] = true
+			names = append(names, bestField)
+		}
+	}
+	return total, names
+}
+
+func match(value string, word string, identifier bool) int {
+	switch {
+	case word == "" || value == "":
+		return 0
+	case value == word:
+		return 4
+	case strings.HasPrefix(value, word):
+		return 3
+	case identifier && strings.HasSuffix(value, word):
+		//last digits of phone or account number
+		return 2
+	case !identifier && strings.Contains(" "+value, " "+word):
+		return 2
+	case len([]rune(word)) >= minContains && strings.Contains(value, word):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package search
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  string
+	}{
+		{"  José   MÜLLER ", "jose muller"},
+		{"Straße Łódź", "strasse lodz"},
+		{"ＡＢＣ１２３", "abc123"},
+		{"๑๒๓", "123"},
+		{"a\u200bb\ufeffc", "abc"},
+		//tone mark is dropped and nikhahit with sara aa is sara am whatever order they were typed in
+		{"น้ำ", "นำ"},
+		{"นํ้า", "นำ"},
+		{"ไม่", "ไม"},
+		//repeated vowel mark is typed once
+		{"กิิจ", "กิจ"},
+	} {
+		if got := Normalize(tc.value); got != tc.want {
+			t.Errorf("Normalize(%q) is %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestCompactAndPhone(t *testing.T) {
+	for _, tc := range []struct {
+		value, compact, phone string
+	}{
+		{"081-234 5678", "0812345678", "0812345678"},
+		{"+66 81 234 5678", "66812345678", "0812345678"},
+		{"๐๘๑-๒๓๔-๕๖๗๘", "0812345678", "0812345678"},
+		{"1-1007-00000-00-0", "1100700000000", "1100700000000"},
+		{"AB-12/34", "ab1234", "ab1234"},
+	} {
+		if got := Compact(tc.value); got != tc.compact {
+			t.Errorf("Compact(%q) is %q, want %q", tc.value, got, tc.compact)
+		}
+		if got := Phone(tc.value); got != tc.phone {
+			t.Errorf("Phone(%q) is %q, want %q", tc.value, got, tc.phone)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	for _, tc := range []struct {
+		got, want string
+	}{
+		{MaskTail("081-234-5678", 4), "***-***-5678"},
+		{MaskTail("1100700000001", 4), "*********0001"},
+		{MaskTail("1234", 4), "****"},
+		{MaskTail("", 4), ""},
+		{MaskName("Smith"), "S****"},
+		{MaskName("สมศรี"), "ส****"},
+		{MaskName(""), ""},
+		{MaskEmail("alice@example.com"), "a****@example.com"},
+		{MaskEmail("not an email"), "n***********"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("masked is %q, want %q", tc.got, tc.want)
+		}
+	}
+}
+
+func TestQueryScore(t *testing.T) {
+	fields := []Field{
+		{Name: "first_name", Value: "José", Kind: Text, Weight: 3},
+		{Name: "last_name", Value: "Müller", Kind: Text, Weight: 3},
+		{Name: "tel", Value: "081-234-5678", Kind: PhoneNumber, Weight: 4},
+	}
+	for _, tc := range []struct {
+		q       string
+		matched bool
+	}{
+		{"jose", true},
+		{"JOSÉ muller", true},
+		{"+66812345678", true},
+		{"5678", true},
+		{"jose smith", false},
+	} {
+		score, matched := ParseQuery(tc.q).Score(fields)
+		if (score > 0) != tc.matched {
+			t.Errorf("%q scores %d with %v, want match %v", tc.q, score, matched, tc.matched)
+		}
+	}
+	//whole value scores above start of it
+	whole, _ := ParseQuery("jose").Score(fields)
+	start, _ := ParseQuery("jos").Score(fields)
+	if whole <= start {
+		t.Errorf("whole value scores %d, start of it %d", whole, start)
+	}
+	if !ParseQuery(" \u200b ").Empty() {
+		t.Error("query of spaces is not empty")
+	}
+}
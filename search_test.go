@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestSearchCustomersMasksPIIForTeller(t *testing.T) {
+	d := newTestDAO(t, repository.NewMemoryStore())
+	user, err := d.userService.InsertUser(&model.User{
+		FirstName: "José",
+		LastName:  "Müller",
+		Username:  "jose",
+		Password:  "password of jose",
+		IDcard:    "1100700000001",
+		Age:       30,
+		Email:     "jose@example.com",
+		Tel:       "081-234-9999",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestBankAccount(t, d, user, "123-4-56789-0", "1")
+	createTestUser(t, d, "alice")
+	e := echo.New()
+
+	search := func(roles []model.Role, q string) (int, []model.CustomerMatch) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/v1/customers/search?q="+url.QueryEscape(q), nil), rec)
+		c.Set(ContextRoles, roles)
+		err := d.SearchCustomersEndPoint(c)
+		if he, ok := err.(*echo.HTTPError); ok {
+			return he.Code, nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Customers []model.CustomerMatch `json:"customers"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, body.Customers
+	}
+
+	//query is compared like the value, without case, diacritics or separators
+	for _, q := range []string{"JOSE muller", "jose@example.com", "+66812349999", "1234567890", "0001"} {
+		if code, matches := search([]model.Role{model.RoleAdmin}, q); code != http.StatusOK || len(matches) != 1 || matches[0].Username != "jose" {
+			t.Errorf("%q found %d %+v, want only jose", q, code, matches)
+		}
+	}
+	if code, _ := search([]model.Role{model.RoleAdmin}, " "); code != http.StatusBadRequest {
+		t.Errorf("empty query got %d, want %d", code, http.StatusBadRequest)
+	}
+
+	_, matches := search([]model.Role{model.RoleAdmin}, "jose")
+	if match := matches[0]; match.LastName != "Müller" || match.Email != "jose@example.com" || match.Tel != "081-234-9999" ||
+		match.IDcard != "1100700000001" || match.AccountNumbers[0] != "123-4-56789-0" {
+		t.Fatalf("admin sees %+v, want PII as it is", match)
+	}
+	_, matches = search([]model.Role{model.RoleTeller}, "jose")
+	if len(matches) != 1 {
+		t.Fatalf("teller found %+v, want jose", matches)
+	}
+	if match := matches[0]; match.FirstName != "José" || match.LastName != "M*****" || match.Email != "j***@example.com" || match.Tel != "***-***-9999" ||
+		match.IDcard != "*********0001" || match.AccountNumbers[0] != "***-*-**789-0" {
+		t.Fatalf("teller sees %+v, want last name, email, tel, ID card and account number masked", match)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"testing"
+	"time"
+)
+
+func TestUserServiceOnMemoryStore(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	alice := createTestUser(t, d, "alice")
+
+	found, err := d.userService.FindByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != alice.ID {
+		t.Fatalf("FindByUsername found %s, want %s", found.ID.Hex(), alice.ID.Hex())
+	}
+	if ok, err := d.userService.CheckPassword(found, "password of alice"); err != nil || !ok {
+		t.Fatalf("CheckPassword is %v, %v, want true", ok, err)
+	}
+
+	if _, err := d.userService.UpdateUser(&model.User{FirstName: "Alicia"}, alice, alice.Version); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.userService.UpdateUser(&model.User{FirstName: "Stale"}, alice, alice.Version); err != ErrPreconditionFailed {
+		t.Fatalf("update of stale version got %v, want %v", err, ErrPreconditionFailed)
+	}
+	if updated := findTestUser(t, d, alice.ID.Hex()); updated.FirstName != "Alicia" {
+		t.Fatalf("FirstName is %s, want Alicia", updated.FirstName)
+	}
+
+	createTestBankAccount(t, d, &alice, "111-1", "10")
+	if _, err := d.userService.DeleteUser(alice); err != ErrBankAccountNotEmpty {
+		t.Fatalf("delete of user with money got %v, want %v", err, ErrBankAccountNotEmpty)
+	}
+	if _, err := d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "10")}, alice, alice.UserBankAccount[0].ID.Hex(), AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	alice = findTestUser(t, d, alice.ID.Hex())
+	if _, err := d.userService.DeleteUser(alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.userService.FindByIDUser(alice.ID.Hex()); err != repository.ErrNotFound {
+		t.Fatalf("FindByIDUser of deleted user got %v, want %v", err, repository.ErrNotFound)
+	}
+	if _, err := store.BankAccounts.FindByAccountNumber("111-1"); err != repository.ErrNotFound {
+		t.Fatalf("bank account of deleted user got %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+func TestBankAccountServiceOnMemoryStore(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	alice := createTestUser(t, d, "alice")
+	bankAccount := createTestBankAccount(t, d, &alice, "111-1", "100")
+	if _, err := d.bankAccountService.CreateBankAccount(&model.BankAccount{BankName: "KBank", AccountNumber: "111-1", Balance: testMoney(t, "1")}, alice); err == nil {
+		t.Fatal("second 111-1 is stored, want account number taken")
+	}
+	if bankAccounts := d.bankAccountService.FindAllBankAccount(alice); len(bankAccounts) != 1 || bankAccounts[0].ID != bankAccount.ID {
+		t.Fatalf("FindAllBankAccount is %+v, want only 111-1", bankAccounts)
+	}
+
+	if _, err := d.bankAccountService.DepositBankAccount(&model.Transaction{Amount: testMoney(t, "50")}, alice, bankAccount.ID.Hex(), AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "30")}, alice, bankAccount.ID.Hex(), AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "1000")}, alice, bankAccount.ID.Hex(), AnyVersion); err != ErrInsufficientFunds {
+		t.Fatalf("withdraw above balance got %v, want %v", err, ErrInsufficientFunds)
+	}
+	if balance := balanceOf(t, store, "111-1"); balance != "120.00" {
+		t.Fatalf("balance of 111-1 is %s, want 120.00", balance)
+	}
+	transactions, err := d.bankAccountService.FindAllTransaction(alice, bankAccount.ID.Hex(), model.TransactionFilter{Page: 1, Limit: TransactionPageLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[model.TransactionType]int{}
+	for _, transaction := range transactions {
+		types[transaction.Type]++
+	}
+	if types[model.TransactionTypeDeposit] != 1 || types[model.TransactionTypeWithdraw] != 1 {
+		t.Fatalf("ledger of 111-1 is %+v, want one deposit and one withdraw", types)
+	}
+
+	alice = findTestUser(t, d, alice.ID.Hex())
+	if _, err := d.bankAccountService.DeleteBankAccount(alice, bankAccount.ID.Hex()); err != ErrBankAccountNotEmpty {
+		t.Fatalf("delete of bank account with money got %v, want %v", err, ErrBankAccountNotEmpty)
+	}
+	if _, err := d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "120")}, alice, bankAccount.ID.Hex(), AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	alice = findTestUser(t, d, alice.ID.Hex())
+	if _, err := d.bankAccountService.DeleteBankAccount(alice, bankAccount.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.BankAccounts.FindByAccountNumber("111-1"); err != repository.ErrNotFound {
+		t.Fatalf("deleted bank account got %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+func TestBankAccountIDNotObjectID(t *testing.T) {
+	d := newTestDAO(t, repository.NewMemoryStore())
+	alice := createTestUser(t, d, "alice")
+	createTestBankAccount(t, d, &alice, "111-1", "100")
+	//id of path is checked before it is read as ObjectId, which panics on anything but 24 hex digits
+	const id = "111-1"
+	amount := &model.Transaction{Amount: testMoney(t, "10")}
+	for name, call := range map[string]func() error{
+		"find":   func() error { _, err := d.bankAccountService.FindBankAccount(alice, id); return err },
+		"delete": func() error { _, err := d.bankAccountService.DeleteBankAccount(alice, id); return err },
+		"deposit": func() error {
+			_, err := d.bankAccountService.DepositBankAccount(amount, alice, id, AnyVersion)
+			return err
+		},
+		"withdraw": func() error {
+			_, err := d.bankAccountService.WithdrawBankAccount(amount, alice, id, AnyVersion)
+			return err
+		},
+		"transactions": func() error {
+			_, err := d.bankAccountService.FindAllTransaction(alice, id, model.TransactionFilter{Page: 1, Limit: TransactionPageLimit})
+			return err
+		},
+		"statement": func() error {
+			_, err := d.bankAccountService.Statement(alice, id, time.Now().Add(-time.Hour), time.Now())
+			return err
+		},
+		"limits":   func() error { _, err := d.limitService.SetBankAccountLimits(alice, id, model.Limits{}); return err },
+		"interest": func() error { _, err := d.interestService.PreviewInterest(alice, id); return err },
+		"fee": func() error {
+			_, err := d.feeService.QuoteFee(alice, id, model.FeeKindWithdraw, testMoney(t, "10"))
+			return err
+		},
+	} {
+		if err := call(); err != ErrBankAccountNotFound {
+			t.Errorf("%s got %v, want %v", name, err, ErrBankAccountNotFound)
+		}
+	}
+	if _, err := d.userService.FindByIDUser(id); err != repository.ErrNotFound {
+		t.Errorf("FindByIDUser got %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+func TestTranferServiceOnMemoryStore(t *testing.T) {
+	store := repository.NewMemoryStore()
+	d := newTestDAO(t, store)
+	alice := createTestUser(t, d, "alice")
+	bob := createTestUser(t, d, "bob")
+	createTestBankAccount(t, d, &alice, "111-1", "100")
+	createTestBankAccount(t, d, &bob, "222-2", "1")
+
+	tranferLog, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "40"), From: "111-1", To: "222-2"}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tranferLog.State != model.TranferStateDone {
+		t.Fatalf("tranfer is %s, want %s", tranferLog.State, model.TranferStateDone)
+	}
+	alice = findTestUser(t, d, alice.ID.Hex())
+	if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "1000"), From: "111-1", To: "222-2"}, alice); err != ErrInsufficientFunds {
+		t.Fatalf("tranfer above balance got %v, want %v", err, ErrInsufficientFunds)
+	}
+	if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "1"), From: "111-1", To: "999-0"}, alice); err != ErrBankAccountToNotFound {
+		t.Fatalf("tranfer to unknown account got %v, want %v", err, ErrBankAccountToNotFound)
+	}
+	if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "1"), From: "222-2", To: "111-1"}, alice); err == nil {
+		t.Fatal("alice moved money out of 222-2 of bob")
+	}
+
+	if balance := balanceOf(t, store, "111-1"); balance != "60.00" {
+		t.Fatalf("balance of 111-1 is %s, want 60.00", balance)
+	}
+	if balance := balanceOf(t, store, "222-2"); balance != "41.00" {
+		t.Fatalf("balance of 222-2 is %s, want 41.00", balance)
+	}
+	pending, err := store.Tranfers.FindByState(model.TranferStateInitial, model.TranferStatePending, model.TranferStateApplied, model.TranferStateCanceling)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("%d tranfers left unfinished, want 0", len(pending))
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"bankaccountapi/schedule"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+//StandingOrderInterval is how often due StandingOrder are run
+const StandingOrderInterval = time.Minute
+
+var (
+	//ErrScheduleEnded is returned when StandingOrder has no date after today
+	ErrScheduleEnded = errors.New("schedule has no date after today")
+	//ErrStandingOrderCount is returned when Count of StandingOrder is negative
+	ErrStandingOrderCount = errors.New("count must not be negative")
+	//ErrStandingOrderState is returned when StandingOrder can not be paused, resumed or cancelled in its State
+	ErrStandingOrderState = errors.New("standing order can not do that in its state")
+)
+
+//StandingOrderService is interface
+type StandingOrderService interface {
+	CreateStandingOrder(order *model.StandingOrder, user model.User) (*model.StandingOrder, error)
+	FindAllStandingOrder(user model.User) ([]model.StandingOrder, error)
+	PauseStandingOrder(user model.User, id string) (*model.StandingOrder, error)
+	ResumeStandingOrder(user model.User, id string) (*model.StandingOrder, error)
+	CancelStandingOrder(user model.User, id string) (*model.StandingOrder, error)
+	FindAllStandingOrderExecution(user model.User, id string) ([]model.StandingOrderExecution, error)
+	RunStandingOrders(now time.Time) error
+}
+
+//StandingOrderServiceImplement is struct
+type StandingOrderServiceImplement struct {
+	orders        repository.StandingOrderRepository
+	bankAccounts  repository.BankAccountRepository
+	userService   UserService
+	tranfers      TranferService
+	retries       int
+	retryInterval time.Duration
+}
+
+//nextDate for the date of order after date, ok is false when order has ended by then
+func nextDate(order model.StandingOrder, after time.Time) (time.Time, bool, error) {
+	if order.Count > 0 && order.Executed >= order.Count {
+		return time.Time{}, false, nil
+	}
+	next, ok, err := schedule.Next(order.Schedule, after)
+	if err != nil || !ok {
+		return time.Time{}, false, err
+	}
+	if order.EndDate != "" && next.Format(BusinessDateFormat) > order.EndDate {
+		return time.Time{}, false, nil
+	}
+	return next, true, nil
+}
+
+//scheduleAt for run order when business date of date starts
+func scheduleAt(order *model.StandingOrder, date time.Time) {
+	order.NextDate = date.Format(BusinessDateFormat)
+	order.NextRun = businessDayStart(date)
+	order.Attempts = 0
+}
+
+//moveAfter for move order to its first date after date, it is finished when there is none
+func moveAfter(order *model.StandingOrder, date time.Time) error {
+	next, ok, err := nextDate(*order, date)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		order.State = model.StandingOrderFinished
+		order.NextDate = ""
+		return nil
+	}
+	scheduleAt(order, next)
+	return nil
+}
+
+//advance for move order to the date after NextDate, Executed is counted by caller.
+//Dates missed while the server was down are run once, not once for each of them
+func advance(order *model.StandingOrder, now time.Time) error {
+	order.Attempts = 0
+	after := BusinessDay(now)
+	date, err := time.ParseInLocation(BusinessDateFormat, order.NextDate, after.Location())
+	if err != nil {
+		return err
+	}
+	if date.After(after) {
+		after = date
+	}
+	return moveAfter(order, after)
+}
+
+//CreateStandingOrder for CreateStandingOrder, Amount is in Currency of From
+func (s *StandingOrderServiceImplement) CreateStandingOrder(order *model.StandingOrder, user model.User) (*model.StandingOrder, error) {
+	if order.Amount.IsZero() {
+		return nil, errors.New("please require Amount")
+	}
+	if order.Amount.IsNegative() {
+		return nil, ErrInvalidAmount
+	}
+	if order.AccountNumberFrom == "" {
+		return nil, errors.New("please require AccountNumberFrom")
+	}
+	if order.AccountNumberTo == "" {
+		return nil, errors.New("please require AccountNumberTo")
+	}
+	bankAccountFrom, ok := findBankAccountByNumber(user, order.AccountNumberFrom)
+	if !ok {
+		return nil, errors.New("Not Have BankAccountID From")
+	}
+	amount, err := order.Amount.In(bankAccountFrom.AccountCurrency())
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.bankAccounts.FindByAccountNumber(order.AccountNumberTo)
+	if err == repository.ErrNotFound {
+		return nil, ErrBankAccountToNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := schedule.Validate(order.Schedule); err != nil {
+		return nil, err
+	}
+	if order.EndDate != "" {
+		if _, err := time.Parse(BusinessDateFormat, order.EndDate); err != nil {
+			return nil, schedule.ErrDate
+		}
+	}
+	if order.Count < 0 {
+		return nil, ErrStandingOrderCount
+	}
+
+	now := time.Now()
+	created := model.StandingOrder{
+		ID:                bson.NewObjectId(),
+		UserID:            user.ID,
+		AccountNumberFrom: order.AccountNumberFrom,
+		AccountNumberTo:   order.AccountNumberTo,
+		Amount:            amount,
+		Schedule:          order.Schedule,
+		EndDate:           order.EndDate,
+		Count:             order.Count,
+		State:             model.StandingOrderActive,
+		CreatedAt:         now,
+		LastModified:      now,
+	}
+	created.Schedule.Weekday = strings.ToLower(created.Schedule.Weekday)
+	err = moveAfter(&created, BusinessDay(now))
+	if err != nil {
+		return nil, err
+	}
+	if created.State == model.StandingOrderFinished {
+		return nil, ErrScheduleEnded
+	}
+	err = s.orders.Insert(&created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+//FindAllStandingOrder for FindAllStandingOrder
+func (s *StandingOrderServiceImplement) FindAllStandingOrder(user model.User) ([]model.StandingOrder, error) {
+	orders, err := s.orders.FindByUser(user.ID)
+	if orders == nil {
+		orders = []model.StandingOrder{}
+	}
+	return orders, err
+}
+
+//findStandingOrder for StandingOrder id of user
+func (s *StandingOrderServiceImplement) findStandingOrder(user model.User, id string) (model.StandingOrder, error) {
+	if !bson.IsObjectIdHex(id) {
+		return model.StandingOrder{}, errors.New("Not Have StandingOrderID")
+	}
+	order, err := s.orders.FindByID(bson.ObjectIdHex(id))
+	if err == repository.ErrNotFound || err == nil && order.UserID != user.ID {
+		return model.StandingOrder{}, errors.New("Not Have StandingOrderID")
+	}
+	return order, err
+}
+
+//setState for move StandingOrder id of user from one of states to next, change may move it further
+func (s *StandingOrderServiceImplement) setState(user model.User, id string, states []model.StandingOrderState, next model.StandingOrderState, change func(order *model.StandingOrder) error) (*model.StandingOrder, error) {
+	order, err := s.findStandingOrder(user, id)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, state := range states {
+		allowed = allowed || order.State == state
+	}
+	if !allowed {
+		return nil, ErrStandingOrderState
+	}
+	order.State = next
+	order.LastModified = time.Now()
+	if change != nil {
+		if err := change(&order); err != nil {
+			return nil, err
+		}
+	}
+	err = s.orders.Update(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+//PauseStandingOrder for PauseStandingOrder
+func (s *StandingOrderServiceImplement) PauseStandingOrder(user model.User, id string) (*model.StandingOrder, error) {
+	return s.setState(user, id, []model.StandingOrderState{model.StandingOrderActive}, model.StandingOrderPaused, nil)
+}
+
+//ResumeStandingOrder for ResumeStandingOrder, dates missed while it was paused are skipped
+func (s *StandingOrderServiceImplement) ResumeStandingOrder(user model.User, id string) (*model.StandingOrder, error) {
+	return s.setState(user, id, []model.StandingOrderState{model.StandingOrderPaused}, model.StandingOrderActive, func(order *model.StandingOrder) error {
+		today := BusinessDay(time.Now())
+		if order.NextDate >= today.Format(BusinessDateFormat) {
+			return nil
+		}
+		return moveAfter(order, today.AddDate(0, 0, -1))
+	})
+}
+
+//CancelStandingOrder for CancelStandingOrder, it is kept with its StandingOrderExecution
+func (s *StandingOrderServiceImplement) CancelStandingOrder(user model.User, id string) (*model.StandingOrder, error) {
+	return s.setState(user, id, []model.StandingOrderState{model.StandingOrderActive, model.StandingOrderPaused}, model.StandingOrderCancelled, nil)
+}
+
+//FindAllStandingOrderExecution for FindAllStandingOrderExecution
+func (s *StandingOrderServiceImplement) FindAllStandingOrderExecution(user model.User, id string) ([]model.StandingOrderExecution, error) {
+	order, err := s.findStandingOrder(user, id)
+	if err != nil {
+		return nil, err
+	}
+	executions, err := s.orders.FindExecutions(order.ID)
+	if executions == nil {
+		executions = []model.StandingOrderExecution{}
+	}
+	return executions, err
+}
+
+//RunStandingOrders for run every StandingOrder due at now
+func (s *StandingOrderServiceImplement) RunStandingOrders(now time.Time) error {
+	orders, err := s.orders.FindDue(now)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for i := range orders {
+		if err := s.execute(&orders[i], now); err != nil {
+			//one StandingOrder must not stop the others
+			log.Printf("standing order %s: %s", orders[i].ID.Hex(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+//execute for make Tranfer of order for its NextDate and record it.
+//order is moved past NextDate and counted in Executed before Tranfer, so a crash or a second server
+//never makes it twice, insufficient funds puts it back on the same NextDate until retries are used up.
+//Only StandingOrderExecutionDone stays counted, NextDate that failed is taken back out of Executed
+func (s *StandingOrderServiceImplement) execute(order *model.StandingOrder, now time.Time) error {
+	claimed := *order
+	claimed.Executed++
+	err := advance(&claimed, now)
+	if err != nil {
+		return err
+	}
+	claimed.LastError = ""
+	claimed.LastModified = now
+	err = s.orders.Update(&claimed)
+	if err == repository.ErrConflict {
+		//paused, cancelled or run by someone else since it was found
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	execution := model.StandingOrderExecution{
+		ID:              bson.NewObjectId(),
+		StandingOrderID: order.ID,
+		BusinessDate:    order.NextDate,
+		Attempt:         order.Attempts + 1,
+		CreatedAt:       now,
+	}
+	tranferLog, err := s.tranfer(order)
+	switch {
+	case err == nil:
+		execution.Status = model.StandingOrderExecutionDone
+		execution.TranferID = tranferLog.ID
+	case err == ErrInsufficientFunds && execution.Attempt <= s.retries:
+		execution.Status = model.StandingOrderExecutionRetrying
+		execution.Error = err.Error()
+		retry := *order
+		retry.Version = claimed.Version
+		retry.Attempts = execution.Attempt
+		retry.NextRun = now.Add(s.retryInterval)
+		retry.LastError = err.Error()
+		retry.LastModified = now
+		err = s.orders.Update(&retry)
+	default:
+		execution.Status = model.StandingOrderExecutionFailed
+		execution.Error = err.Error()
+		//moved past NextDate again without counting it, so Count is still reached by runs that were made
+		failed := *order
+		failed.Version = claimed.Version
+		failed.LastError = err.Error()
+		failed.LastModified = now
+		err = advance(&failed, now)
+		if err == nil {
+			err = s.orders.Update(&failed)
+		}
+	}
+	if insertErr := s.orders.InsertExecution(&execution); insertErr != nil {
+		return insertErr
+	}
+	return err
+}
+
+//tranfer for make Tranfer of order as its User
+func (s *StandingOrderServiceImplement) tranfer(order *model.StandingOrder) (*model.TranferLog, error) {
+	user, err := s.userService.FindByIDUser(order.UserID.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("user %s: %s", order.UserID.Hex(), err)
+	}
+	return s.tranfers.Tranfer(&model.Tranfer{
+		Amount: order.Amount,
+		From:   order.AccountNumberFrom,
+		To:     order.AccountNumberTo,
+	}, user)
+}
+
+//ScheduleStandingOrders for RunStandingOrders every interval, it never returns
+func ScheduleStandingOrders(standingOrderService StandingOrderService, interval time.Duration) {
+	for {
+		if err := standingOrderService.RunStandingOrders(time.Now()); err != nil {
+			log.Println("standing orders:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+//CreateStandingOrderEndPoint is CreateStandingOrderEndPoint
+func (m *DataObjectAccess) CreateStandingOrderEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	order := new(model.StandingOrder)
+	if err := c.Bind(order); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("json: wrong params: %s", err))
+	}
+	orderResp, err := m.standingOrderService.CreateStandingOrder(order, user)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(orderResp)
+	return c.JSON(http.StatusCreated, MapJSONStandingOrder(orderResp))
+}
+
+//FindAllStandingOrderEndPoint is FindAllStandingOrderEndPoint
+func (m *DataObjectAccess) FindAllStandingOrderEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	orders, err := m.standingOrderService.FindAllStandingOrder(user)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(orders)
+	return c.JSON(http.StatusOK, MapJSONStandingOrder(orders))
+}
+
+//standingOrderAction for endpoint that moves StandingOrder of the path to another State
+func (m *DataObjectAccess) standingOrderAction(c echo.Context, action func(user model.User, id string) (*model.StandingOrder, error)) error {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	orderResp, err := action(user, c.Param("idStandingOrder"))
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(orderResp)
+	return c.JSON(http.StatusOK, MapJSONStandingOrder(orderResp))
+}
+
+//PauseStandingOrderEndPoint is PauseStandingOrderEndPoint
+func (m *DataObjectAccess) PauseStandingOrderEndPoint(c echo.Context) (err error) {
+	return m.standingOrderAction(c, m.standingOrderService.PauseStandingOrder)
+}
+
+//ResumeStandingOrderEndPoint is ResumeStandingOrderEndPoint
+func (m *DataObjectAccess) ResumeStandingOrderEndPoint(c echo.Context) (err error) {
+	return m.standingOrderAction(c, m.standingOrderService.ResumeStandingOrder)
+}
+
+//CancelStandingOrderEndPoint is CancelStandingOrderEndPoint
+func (m *DataObjectAccess) CancelStandingOrderEndPoint(c echo.Context) (err error) {
+	return m.standingOrderAction(c, m.standingOrderService.CancelStandingOrder)
+}
+
+//FindAllStandingOrderExecutionEndPoint is FindAllStandingOrderExecutionEndPoint
+func (m *DataObjectAccess) FindAllStandingOrderExecutionEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	executions, err := m.standingOrderService.FindAllStandingOrderExecution(user, c.Param("idStandingOrder"))
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(executions)
+	return c.JSON(http.StatusOK, map[string]interface{}{"executions": executions})
+}
+
+//MapJSONStandingOrder for MapJSONStandingOrder
+func MapJSONStandingOrder(order interface{}) interface{} {
+	dataJSON := map[string]interface{}{
+		"standing_order": order,
+	}
+	return dataJSON
+}
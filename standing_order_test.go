@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"errors"
+	"testing"
+)
+
+func TestStandingOrderCountsOnlyDoneRuns(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			faulty := newFaultyBankAccounts(store)
+			d := newTestDAO(t, store)
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			createTestBankAccount(t, d, &alice, "111-1", "100")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+			order, err := d.standingOrderService.CreateStandingOrder(&model.StandingOrder{
+				AccountNumberFrom: "111-1",
+				AccountNumberTo:   "222-2",
+				Amount:            testMoney(t, "10"),
+				Schedule:          model.Schedule{Kind: model.ScheduleWeekly, Weekday: "monday"},
+				Count:             2,
+			}, alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			//first Monday fails, it is not one of the 2 runs
+			faulty.fail(faulty.applyErr, "222-2", errors.New("disk is full"))
+			for run := 0; run < 3; run++ {
+				if run == 1 {
+					faulty.fail(faulty.applyErr, "222-2", nil)
+				}
+				stored, err := store.StandingOrders.FindByID(order.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stored.State != model.StandingOrderActive {
+					t.Fatalf("standing order is %s before run %d, want %s", stored.State, run+1, model.StandingOrderActive)
+				}
+				if err := d.standingOrderService.RunStandingOrders(stored.NextRun); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stored, err := store.StandingOrders.FindByID(order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			executions, err := store.StandingOrders.FindExecutions(order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(executions) != 3 {
+				t.Fatalf("%d executions, want 3", len(executions))
+			}
+			if stored.Executed != 2 || stored.State != model.StandingOrderFinished {
+				t.Fatalf("standing order is %s with %d executed, want %s with 2", stored.State, stored.Executed, model.StandingOrderFinished)
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "80.00" {
+				t.Fatalf("balance of 111-1 is %s, want 80.00", balance)
+			}
+		})
+	}
+}
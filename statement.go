@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"bankaccountapi/statement"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+var (
+	//ErrStatementFormat is returned when format of statement is not in StatementFormats
+	ErrStatementFormat = errors.New("format must be csv, pdf, ofx, camt053 or camt052")
+	//ErrStatementPeriod is returned when from of statement is not before to
+	ErrStatementPeriod = errors.New("from must be before to")
+	//ErrStatementReconcile is returned when opening balance and ledger of period do not add up to closing balance
+	ErrStatementReconcile = errors.New("statement does not reconcile with ledger")
+)
+
+//StatementFormat is writer, Content-Type and file extension of one format of statement
+type StatementFormat struct {
+	Write       func(w io.Writer, statement model.Statement) error
+	ContentType string
+	Extension   string
+}
+
+//StatementFormats is every format of statement by name of format in query string
+var StatementFormats = map[string]StatementFormat{
+	"csv":     {statement.WriteCSV, "text/csv; charset=utf-8", "csv"},
+	"pdf":     {statement.WritePDF, "application/pdf", "pdf"},
+	"ofx":     {statement.WriteOFX, "application/x-ofx", "ofx"},
+	"camt053": {statement.WriteCamt053, "application/xml", "xml"},
+	"camt052": {statement.WriteCamt052, "application/xml", "xml"},
+}
+
+//findTransactionLogs for every TransactionLog of BankAccount from from until before to, oldest first
+func findTransactionLogs(transactions repository.TransactionRepository, id bson.ObjectId, from time.Time, to time.Time) ([]model.TransactionLog, error) {
+	transactionLogs := []model.TransactionLog{}
+	filter := model.TransactionFilter{From: from, To: to, Page: 1, Limit: TransactionPageLimitMax}
+	for {
+		page, err := transactions.FindByBankAccount(id, filter)
+		if err != nil {
+			return nil, err
+		}
+		transactionLogs = append(transactionLogs, page...)
+		if len(page) < filter.Limit {
+			break
+		}
+		filter.Page++
+	}
+	for i, j := 0, len(transactionLogs)-1; i < j; i, j = i+1, j-1 {
+		transactionLogs[i], transactionLogs[j] = transactionLogs[j], transactionLogs[i]
+	}
+	return transactionLogs, nil
+}
+
+//balanceAt for Balance of BankAccount just before t, it is BalanceAfter of the last TransactionLog before t.
+//BankAccount without TransactionLog before t has Balance now less every TransactionLog since, that is its opening Balance
+func balanceAt(transactions repository.TransactionRepository, bankAccount model.BankAccount, t time.Time) (model.Money, error) {
+	last, err := transactions.FindByBankAccount(bankAccount.ID, model.TransactionFilter{To: t, Page: 1, Limit: 1})
+	if err != nil {
+		return model.Money{}, err
+	}
+	if len(last) > 0 {
+		return last[0].BalanceAfter, nil
+	}
+	transactionLogs, err := findTransactionLogs(transactions, bankAccount.ID, t, time.Time{})
+	if err != nil {
+		return model.Money{}, err
+	}
+	balance := bankAccount.Balance
+	for _, transactionLog := range transactionLogs {
+		if balance, err = balance.Sub(transactionLog.Amount); err != nil {
+			return model.Money{}, err
+		}
+	}
+	return balance, nil
+}
+
+//Statement for Statement of BankAccount of user from from until before to
+func (b *BankAccountServiceImplement) Statement(user model.User, id string, from time.Time, to time.Time) (model.Statement, error) {
+	if !bson.IsObjectIdHex(id) {
+		return model.Statement{}, ErrBankAccountNotFound
+	}
+	if _, ok := findBankAccount(user, bson.ObjectIdHex(id)); !ok {
+		return model.Statement{}, ErrBankAccountNotFound
+	}
+	if !from.Before(to) {
+		return model.Statement{}, ErrStatementPeriod
+	}
+	bankAccount, err := b.bankAccounts.FindByID(bson.ObjectIdHex(id))
+	if err != nil {
+		return model.Statement{}, err
+	}
+
+	statementResp := model.Statement{
+		BankAccountID: bankAccount.ID,
+		AccountNumber: bankAccount.AccountNumber,
+		BankName:      bankAccount.BankName,
+		Product:       bankAccount.AccountProduct(),
+		Currency:      bankAccount.AccountCurrency(),
+		UserID:        user.ID,
+		CustomerName:  user.FirstName + " " + user.LastName,
+		From:          from,
+		To:            to,
+		TotalIn:       model.Money{Currency: bankAccount.AccountCurrency()},
+		TotalOut:      model.Money{Currency: bankAccount.AccountCurrency()},
+		GeneratedAt:   time.Now(),
+	}
+	if statementResp.OpeningBalance, err = balanceAt(b.transactions, bankAccount, from); err != nil {
+		return model.Statement{}, err
+	}
+	if statementResp.ClosingBalance, err = balanceAt(b.transactions, bankAccount, to); err != nil {
+		return model.Statement{}, err
+	}
+	if statementResp.Transactions, err = findTransactionLogs(b.transactions, bankAccount.ID, from, to); err != nil {
+		return model.Statement{}, err
+	}
+
+	balance := statementResp.OpeningBalance
+	for _, transactionLog := range statementResp.Transactions {
+		if balance, err = balance.Add(transactionLog.Amount); err != nil {
+			return model.Statement{}, err
+		}
+		if transactionLog.Amount.IsNegative() {
+			statementResp.TotalOut, err = statementResp.TotalOut.Sub(transactionLog.Amount)
+		} else {
+			statementResp.TotalIn, err = statementResp.TotalIn.Add(transactionLog.Amount)
+		}
+		if err != nil {
+			return model.Statement{}, err
+		}
+	}
+	if balance.Amount != statementResp.ClosingBalance.Amount {
+		return model.Statement{}, ErrStatementReconcile
+	}
+	return statementResp, nil
+}
+
+//ParseStatementPeriod for read from and to, a date is business date so to as date is include whole day.
+//from is start of this business month and to is now when they are empty
+func ParseStatementPeriod(fromValue string, toValue string) (from time.Time, to time.Time, err error) {
+	now := time.Now()
+	today := BusinessDay(now)
+	from = businessDayStart(today.AddDate(0, 0, 1-today.Day()))
+	to = now.In(config.BusinessTimeZone.Location)
+	if fromValue != "" {
+		if from, err = parseStatementTime(fromValue, false); err != nil {
+			return from, to, errors.New("from must be RFC3339 or YYYY-MM-DD")
+		}
+	}
+	if toValue != "" {
+		if to, err = parseStatementTime(toValue, true); err != nil {
+			return from, to, errors.New("to must be RFC3339 or YYYY-MM-DD")
+		}
+	}
+	return from, to, nil
+}
+
+func parseStatementTime(value string, endOfDay bool) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t.In(config.BusinessTimeZone.Location), nil
+	}
+	t, err = time.ParseInLocation("2006-01-02", value, config.BusinessTimeZone.Location)
+	if err != nil {
+		return t, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return businessDayStart(t), nil
+}
+
+//StatementEndPoint is StatementEndPoint
+func (m *DataObjectAccess) StatementEndPoint(c echo.Context) (err error) {
+	user, err := m.userService.FindByIDUser(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "csv"
+	}
+	statementFormat, ok := StatementFormats[format]
+	if !ok {
+		return MapHTTPError(ErrStatementFormat)
+	}
+	from, to, err := ParseStatementPeriod(c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	statementResp, err := m.bankAccountService.Statement(user, c.Param("idBankAccount"), from, to)
+	if err != nil {
+		return MapHTTPError(err)
+	}
+	var body bytes.Buffer
+	if err := statementFormat.Write(&body, statementResp); err != nil {
+		return MapHTTPError(err)
+	}
+	PrintLog(statementResp)
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=\""+StatementFilename(statementResp, statementFormat)+"\"")
+	return c.Blob(http.StatusOK, statementFormat.ContentType, body.Bytes())
+}
+
+//StatementFilename for name of file of statement like statement-1234567890-20261001-20261031.csv
+func StatementFilename(statementResp model.Statement, statementFormat StatementFormat) string {
+	return "statement-" + statementResp.AccountNumber + "-" + statementResp.From.Format("20060102") + "-" +
+		statementResp.To.Add(-time.Nanosecond).Format("20060102") + "." + statementFormat.Extension
+}
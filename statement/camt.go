@@ -0,0 +1,284 @@
+package statement
+
+import (
+	"bankaccountapi/model"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	//Camt053Namespace is namespace of ISO 20022 BankToCustomerStatement camt.053.001.02
+	Camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+	//Camt052Namespace is namespace of ISO 20022 BankToCustomerAccountReport camt.052.001.02
+	Camt052Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.052.001.02"
+
+	camtDateFormat     = "2006-01-02"
+	camtDateTimeFormat = "2006-01-02T15:04:05.000-07:00"
+	camtCredit         = "CRDT"
+	camtDebit          = "DBIT"
+)
+
+//camtDomain is ISO bank transaction code of domain, family and sub-family of each TransactionType
+var camtDomain = map[model.TransactionType][3]string{
+	model.TransactionTypeDeposit:         {"PMNT", "CNTR", "CDPT"},
+	model.TransactionTypeWithdraw:        {"PMNT", "CNTR", "CWDL"},
+	model.TransactionTypeTranferOut:      {"PMNT", "ICDT", "BOOK"},
+	model.TransactionTypeTranferIn:       {"PMNT", "RCDT", "BOOK"},
+	model.TransactionTypeTranferReversal: {"PMNT", "ICDT", "RRTN"},
+	model.TransactionTypeInterest:        {"ACMT", "MCOP", "INTR"},
+	model.TransactionTypeFee:             {"ACMT", "MDOP", "CHRG"},
+	model.TransactionTypeFeeIncome:       {"ACMT", "MCOP", "CHRG"},
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt,omitempty"`
+	DateTime string `xml:"DtTm,omitempty"`
+}
+
+type camtCode struct {
+	Code string `xml:"Cd"`
+}
+
+type camtAccount struct {
+	ID string `xml:"Id>Othr>Id"`
+}
+
+type camtBalance struct {
+	Type      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      camtDate   `xml:"Dt"`
+}
+
+type camtSum struct {
+	Count     int    `xml:"NbOfNtries"`
+	Sum       string `xml:"Sum"`
+	Net       string `xml:"TtlNetNtryAmt,omitempty"`
+	Indicator string `xml:"CdtDbtInd,omitempty"`
+}
+
+type camtSummary struct {
+	Entries camtSum `xml:"TtlNtries"`
+	Credit  camtSum `xml:"TtlCdtNtries"`
+	Debit   camtSum `xml:"TtlDbtNtries"`
+}
+
+type camtDomainCode struct {
+	Code      string `xml:"Cd"`
+	Family    string `xml:"Fmly>Cd"`
+	SubFamily string `xml:"Fmly>SubFmlyCd"`
+}
+
+type camtTransactionCode struct {
+	Domain      *camtDomainCode `xml:"Domn"`
+	Proprietary camtCode        `xml:"Prtry"`
+}
+
+type camtParties struct {
+	DebtorAccount   *camtAccount `xml:"DbtrAcct"`
+	CreditorAccount *camtAccount `xml:"CdtrAcct"`
+}
+
+type camtTransaction struct {
+	Reference     string       `xml:"Refs>AcctSvcrRef"`
+	TransactionID string       `xml:"Refs>TxId,omitempty"`
+	Parties       *camtParties `xml:"RltdPties"`
+}
+
+type camtEntry struct {
+	Reference       string              `xml:"NtryRef"`
+	Amount          camtAmount          `xml:"Amt"`
+	Indicator       string              `xml:"CdtDbtInd"`
+	Status          string              `xml:"Sts"`
+	BookingDate     camtDate            `xml:"BookgDt"`
+	ValueDate       camtDate            `xml:"ValDt"`
+	ServicerRef     string              `xml:"AcctSvcrRef"`
+	TransactionCode camtTransactionCode `xml:"BkTxCd"`
+	Details         camtTransaction     `xml:"NtryDtls>TxDtls"`
+	Information     string              `xml:"AddtlNtryInf"`
+}
+
+type camtStatement struct {
+	ID        string `xml:"Id"`
+	CreatedAt string `xml:"CreDtTm"`
+	From      string `xml:"FrToDt>FrDtTm"`
+	To        string `xml:"FrToDt>ToDtTm"`
+	Account   struct {
+		ID       string `xml:"Id>Othr>Id"`
+		Currency string `xml:"Ccy"`
+		Owner    string `xml:"Ownr>Nm,omitempty"`
+		Servicer string `xml:"Svcr>FinInstnId>Nm,omitempty"`
+	} `xml:"Acct"`
+	Balances []camtBalance `xml:"Bal"`
+	Summary  camtSummary   `xml:"TxsSummry"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtHeader struct {
+	MessageID string `xml:"MsgId"`
+	CreatedAt string `xml:"CreDtTm"`
+}
+
+type camt053Document struct {
+	XMLName   xml.Name      `xml:"Document"`
+	Namespace string        `xml:"xmlns,attr"`
+	Header    camtHeader    `xml:"BkToCstmrStmt>GrpHdr"`
+	Statement camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camt052Document struct {
+	XMLName   xml.Name      `xml:"Document"`
+	Namespace string        `xml:"xmlns,attr"`
+	Header    camtHeader    `xml:"BkToCstmrAcctRpt>GrpHdr"`
+	Report    camtStatement `xml:"BkToCstmrAcctRpt>Rpt"`
+}
+
+//camtMoney for amount of ISO 20022 that is never negative, and CRDT or DBIT for its sign
+func camtMoney(m model.Money) (string, string) {
+	if m.IsNegative() {
+		m, _ = m.Neg()
+		return m.String(), camtDebit
+	}
+	return m.String(), camtCredit
+}
+
+//camtMessageID for MsgId that is unique per BankAccount and second, it fits Max35Text
+func camtMessageID(statement model.Statement) string {
+	return statement.BankAccountID.Hex() + "-" + strconv.FormatInt(statement.GeneratedAt.Unix(), 36)
+}
+
+func camtBalanceOf(balanceType string, m model.Money, currency string, date camtDate) camtBalance {
+	amount, indicator := camtMoney(m)
+	return camtBalance{
+		Type:      balanceType,
+		Amount:    camtAmount{Currency: currency, Value: amount},
+		Indicator: indicator,
+		Date:      date,
+	}
+}
+
+//newCamtStatement for Stmt of camt.053 or Rpt of camt.052 of statement, balances come after Acct
+func newCamtStatement(statement model.Statement, balances ...camtBalance) (camtStatement, error) {
+	location := statement.From.Location()
+	var camt camtStatement
+	camt.ID = camtMessageID(statement)
+	camt.CreatedAt = statement.GeneratedAt.In(location).Format(camtDateTimeFormat)
+	camt.From = statement.From.Format(camtDateTimeFormat)
+	camt.To = statement.To.Add(-time.Millisecond).Format(camtDateTimeFormat)
+	camt.Account.ID = statement.AccountNumber
+	camt.Account.Currency = statement.Currency
+	camt.Account.Owner = statement.CustomerName
+	camt.Account.Servicer = statement.BankName
+	camt.Balances = balances
+
+	credit, debit := 0, 0
+	for _, transactionLog := range statement.Transactions {
+		amount, indicator := camtMoney(transactionLog.Amount)
+		if indicator == camtCredit {
+			credit++
+		} else {
+			debit++
+		}
+		entry := camtEntry{
+			Reference:   transactionLog.ID.Hex(),
+			Amount:      camtAmount{Currency: statement.Currency, Value: amount},
+			Indicator:   indicator,
+			Status:      "BOOK",
+			BookingDate: camtDate{DateTime: transactionLog.CreatedAt.In(location).Format(camtDateTimeFormat)},
+			ValueDate:   camtDate{Date: transactionLog.CreatedAt.In(location).Format(camtDateFormat)},
+			ServicerRef: transactionLog.ID.Hex(),
+			Details:     camtTransaction{Reference: transactionLog.ID.Hex()},
+			Information: string(transactionLog.Type),
+		}
+		entry.TransactionCode.Proprietary.Code = string(transactionLog.Type)
+		if domain, ok := camtDomain[transactionLog.Type]; ok {
+			entry.TransactionCode.Domain = &camtDomainCode{Code: domain[0], Family: domain[1], SubFamily: domain[2]}
+		}
+		if transactionLog.TranferID != "" {
+			entry.Details.TransactionID = transactionLog.TranferID.Hex()
+		}
+		if transactionLog.CounterpartyAccount != "" {
+			counterparty := &camtAccount{ID: transactionLog.CounterpartyAccount}
+			if indicator == camtCredit {
+				entry.Details.Parties = &camtParties{DebtorAccount: counterparty}
+			} else {
+				entry.Details.Parties = &camtParties{CreditorAccount: counterparty}
+			}
+		}
+		camt.Entries = append(camt.Entries, entry)
+	}
+
+	total, err := statement.TotalIn.Add(statement.TotalOut)
+	if err != nil {
+		return camt, err
+	}
+	net, err := statement.TotalIn.Sub(statement.TotalOut)
+	if err != nil {
+		return camt, err
+	}
+	camt.Summary.Entries = camtSum{Count: credit + debit, Sum: total.String()}
+	camt.Summary.Entries.Net, camt.Summary.Entries.Indicator = camtMoney(net)
+	camt.Summary.Credit = camtSum{Count: credit, Sum: statement.TotalIn.String()}
+	camt.Summary.Debit = camtSum{Count: debit, Sum: statement.TotalOut.String()}
+	return camt, nil
+}
+
+func writeCamt(w io.Writer, document interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+//WriteCamt053 for write statement as ISO 20022 camt.053 end of day statement,
+//it has opening booked balance at From and closing booked balance at To
+func WriteCamt053(w io.Writer, statement model.Statement) error {
+	location := statement.From.Location()
+	camt, err := newCamtStatement(statement,
+		camtBalanceOf("OPBD", statement.OpeningBalance, statement.Currency, camtDate{Date: statement.From.Format(camtDateFormat)}),
+		camtBalanceOf("CLBD", statement.ClosingBalance, statement.Currency, camtDate{Date: statement.To.Add(-time.Nanosecond).In(location).Format(camtDateFormat)}),
+	)
+	if err != nil {
+		return err
+	}
+	return writeCamt(w, camt053Document{
+		Namespace: Camt053Namespace,
+		Header:    camtHeader{MessageID: camt.ID, CreatedAt: camt.CreatedAt},
+		Statement: camt,
+	})
+}
+
+//WriteCamt052 for write statement as ISO 20022 camt.052 intraday account report,
+//it has opening booked balance at From and interim booked balance at To, or at GeneratedAt when To is later
+func WriteCamt052(w io.Writer, statement model.Statement) error {
+	location := statement.From.Location()
+	interim := statement.To
+	if interim.After(statement.GeneratedAt) {
+		interim = statement.GeneratedAt
+	}
+	camt, err := newCamtStatement(statement,
+		camtBalanceOf("OPBD", statement.OpeningBalance, statement.Currency, camtDate{Date: statement.From.Format(camtDateFormat)}),
+		camtBalanceOf("ITBD", statement.ClosingBalance, statement.Currency, camtDate{DateTime: interim.In(location).Format(camtDateTimeFormat)}),
+	)
+	if err != nil {
+		return err
+	}
+	return writeCamt(w, camt052Document{
+		Namespace: Camt052Namespace,
+		Header:    camtHeader{MessageID: camt.ID, CreatedAt: camt.CreatedAt},
+		Report:    camt,
+	})
+}
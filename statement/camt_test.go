@@ -0,0 +1,130 @@
+package statement
+
+import (
+	"bankaccountapi/model"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func testMoney(t *testing.T, amount string) model.Money {
+	t.Helper()
+	m, err := model.ParseMoney(amount, model.DefaultCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+//testStatement for Statement of one business day with every TransactionType camt has a code for
+func testStatement(t *testing.T) model.Statement {
+	location := time.FixedZone("ICT", 7*60*60)
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, location)
+	statement := model.Statement{
+		BankAccountID:  bson.NewObjectId(),
+		AccountNumber:  "1234567890",
+		BankName:       "KBank",
+		Currency:       model.DefaultCurrency,
+		UserID:         bson.NewObjectId(),
+		CustomerName:   "Alice Example",
+		From:           from,
+		To:             from.AddDate(0, 0, 1),
+		OpeningBalance: testMoney(t, "-5.50"),
+		GeneratedAt:    from.Add(30 * time.Hour),
+	}
+	entries := []struct {
+		transactionType model.TransactionType
+		amount          string
+		counterparty    string
+	}{
+		{model.TransactionTypeDeposit, "100", ""},
+		{model.TransactionTypeTranferOut, "-40", "222-2"},
+		{model.TransactionTypeTranferIn, "12.25", "333-3"},
+		{model.TransactionTypeFee, "-10", ""},
+		{model.TransactionTypeInterest, "0.01", ""},
+	}
+	balance := statement.OpeningBalance
+	statement.TotalIn = model.Money{Currency: statement.Currency}
+	statement.TotalOut = model.Money{Currency: statement.Currency}
+	for i, entry := range entries {
+		amount := testMoney(t, entry.amount)
+		var err error
+		if balance, err = balance.Add(amount); err != nil {
+			t.Fatal(err)
+		}
+		if amount.IsNegative() {
+			statement.TotalOut, err = statement.TotalOut.Sub(amount)
+		} else {
+			statement.TotalIn, err = statement.TotalIn.Add(amount)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		transactionLog := model.TransactionLog{
+			ID:                  bson.NewObjectId(),
+			AccountNumber:       statement.AccountNumber,
+			Type:                entry.transactionType,
+			Amount:              amount,
+			BalanceAfter:        balance,
+			CounterpartyAccount: entry.counterparty,
+			CreatedAt:           from.Add(time.Duration(i+1) * time.Hour),
+		}
+		if entry.counterparty != "" {
+			transactionLog.TranferID = bson.NewObjectId()
+		}
+		statement.Transactions = append(statement.Transactions, transactionLog)
+	}
+	statement.ClosingBalance = balance
+	return statement
+}
+
+//validate for check document against ISO 20022 schema in testdata with xmllint
+func validate(t *testing.T, schema string, document []byte) error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "document.xml")
+	if err := ioutil.WriteFile(path, document, 0600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("xmllint", "--noout", "--schema", filepath.Join("testdata", schema), path).CombinedOutput()
+	if err != nil {
+		return errors.New(string(out))
+	}
+	return nil
+}
+
+func TestCamtIsValidAgainstSchema(t *testing.T) {
+	if _, err := exec.LookPath("xmllint"); err != nil {
+		t.Skip("xmllint is needed to validate against XSD")
+	}
+	statement := testStatement(t)
+	for _, tc := range []struct {
+		schema string
+		write  func(w io.Writer, statement model.Statement) error
+	}{
+		{"camt.053.001.02.xsd", WriteCamt053},
+		{"camt.052.001.02.xsd", WriteCamt052},
+	} {
+		t.Run(tc.schema, func(t *testing.T) {
+			var body bytes.Buffer
+			if err := tc.write(&body, statement); err != nil {
+				t.Fatal(err)
+			}
+			if err := validate(t, tc.schema, body.Bytes()); err != nil {
+				t.Fatalf("%s\n%s", err, body.String())
+			}
+			//schema is really checked, entry status that is not EntryStatus2Code is refused
+			invalid := strings.Replace(body.String(), "<Sts>BOOK</Sts>", "<Sts>DONE</Sts>", 1)
+			if err := validate(t, tc.schema, []byte(invalid)); err == nil {
+				t.Fatal("entry with Sts DONE is valid")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package statement
+
+import (
+	"bankaccountapi/model"
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+//csvTimeFormat is format of time in CSV, spreadsheets read it as date and time
+const csvTimeFormat = "2006-01-02 15:04:05"
+
+//WriteCSV for write statement as CSV, the first row is opening balance and the last row is closing balance
+func WriteCSV(w io.Writer, statement model.Statement) error {
+	writer := csv.NewWriter(w)
+	location := statement.From.Location()
+	rows := [][]string{
+		{"date", "type", "counterparty_account", "amount", "balance", "currency", "id"},
+		{statement.From.Format(csvTimeFormat), "opening_balance", "", "", statement.OpeningBalance.String(), statement.Currency, ""},
+	}
+	for _, transactionLog := range statement.Transactions {
+		rows = append(rows, []string{
+			transactionLog.CreatedAt.In(location).Format(csvTimeFormat),
+			string(transactionLog.Type),
+			transactionLog.CounterpartyAccount,
+			transactionLog.Amount.String(),
+			transactionLog.BalanceAfter.String(),
+			statement.Currency,
+			transactionLog.ID.Hex(),
+		})
+	}
+	rows = append(rows, []string{statement.To.Add(-time.Nanosecond).Format(csvTimeFormat), "closing_balance", "", "", statement.ClosingBalance.String(), statement.Currency, ""})
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
@@ -0,0 +1,136 @@
+package statement
+
+import (
+	"bankaccountapi/model"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+//ofxHeader is XML declaration and OFX 2.1.1 processing instruction that starts every OFX file
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+`
+
+//ofxTranferType is TRNTYPE of each TransactionType, types not in it are CREDIT or DEBIT by sign of Amount
+var ofxTranferType = map[model.TransactionType]string{
+	model.TransactionTypeDeposit:    "DEP",
+	model.TransactionTypeWithdraw:   "CASH",
+	model.TransactionTypeTranferOut: "XFER",
+	model.TransactionTypeTranferIn:  "XFER",
+	model.TransactionTypeInterest:   "INT",
+	model.TransactionTypeFee:        "FEE",
+}
+
+//ofxAccountType is ACCTTYPE of each Product
+var ofxAccountType = map[model.Product]string{
+	model.ProductSavings:      "SAVINGS",
+	model.ProductCurrent:      "CHECKING",
+	model.ProductFixedDeposit: "CD",
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxTransaction struct {
+	Type     string `xml:"TRNTYPE"`
+	Posted   string `xml:"DTPOSTED"`
+	Amount   string `xml:"TRNAMT"`
+	FITID    string `xml:"FITID"`
+	Name     string `xml:"NAME,omitempty"`
+	Memo     string `xml:"MEMO"`
+	Currency string `xml:"CURRENCY>CURSYM,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	SignOn  struct {
+		Status   ofxStatus `xml:"STATUS"`
+		Server   string    `xml:"DTSERVER"`
+		Language string    `xml:"LANGUAGE"`
+	} `xml:"SIGNONMSGSRSV1>SONRS"`
+	Statement struct {
+		TransactionID string    `xml:"TRNUID"`
+		Status        ofxStatus `xml:"STATUS"`
+		Response      struct {
+			Currency string `xml:"CURDEF"`
+			Account  struct {
+				BankID    string `xml:"BANKID"`
+				AccountID string `xml:"ACCTID"`
+				Type      string `xml:"ACCTTYPE"`
+			} `xml:"BANKACCTFROM"`
+			Start        string           `xml:"BANKTRANLIST>DTSTART"`
+			End          string           `xml:"BANKTRANLIST>DTEND"`
+			Transactions []ofxTransaction `xml:"BANKTRANLIST>STMTTRN"`
+			Ledger       ofxBalance       `xml:"LEDGERBAL"`
+		} `xml:"STMTRS"`
+	} `xml:"BANKMSGSRSV1>STMTTRNRS"`
+}
+
+//ofxTime for time in OFX like 20061018120000.000[+7:ICT], zone without a name like "+07" has only offset
+func ofxTime(t time.Time) string {
+	name, offset := t.Zone()
+	zone := strconv.FormatFloat(float64(offset)/3600, 'f', -1, 64)
+	if offset >= 0 {
+		zone = "+" + zone
+	}
+	if name != "" && name[0] != '+' && name[0] != '-' {
+		zone += ":" + name
+	}
+	return fmt.Sprintf("%s[%s]", t.Format("20060102150405.000"), zone)
+}
+
+//WriteOFX for write statement as OFX 2.1.1 bank statement response, LEDGERBAL is ClosingBalance
+func WriteOFX(w io.Writer, statement model.Statement) error {
+	location := statement.From.Location()
+	var document ofxDocument
+	document.SignOn.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	document.SignOn.Server = ofxTime(statement.GeneratedAt.In(location))
+	document.SignOn.Language = "ENG"
+	document.Statement.TransactionID = statement.BankAccountID.Hex()
+	document.Statement.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	response := &document.Statement.Response
+	response.Currency = statement.Currency
+	response.Account.BankID = statement.BankName
+	response.Account.AccountID = statement.AccountNumber
+	response.Account.Type = ofxAccountType[statement.Product]
+	if response.Account.Type == "" {
+		response.Account.Type = "CHECKING"
+	}
+	response.Start = ofxTime(statement.From)
+	response.End = ofxTime(statement.To)
+	for _, transactionLog := range statement.Transactions {
+		transactionType, ok := ofxTranferType[transactionLog.Type]
+		if !ok {
+			transactionType = "CREDIT"
+			if transactionLog.Amount.IsNegative() {
+				transactionType = "DEBIT"
+			}
+		}
+		response.Transactions = append(response.Transactions, ofxTransaction{
+			Type:   transactionType,
+			Posted: ofxTime(transactionLog.CreatedAt.In(location)),
+			Amount: transactionLog.Amount.String(),
+			FITID:  transactionLog.ID.Hex(),
+			Name:   transactionLog.CounterpartyAccount,
+			Memo:   string(transactionLog.Type),
+		})
+	}
+	response.Ledger = ofxBalance{Amount: statement.ClosingBalance.String(), AsOf: ofxTime(statement.To)}
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(document)
+}
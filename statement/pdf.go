@@ -0,0 +1,134 @@
+package statement
+
+import (
+	"bankaccountapi/model"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	pdfPageWidth  = 595 //A4 in points
+	pdfPageHeight = 842
+	pdfMargin     = 40
+	pdfLineHeight = 13
+	//pdfRowsPerPage is number of transaction rows on each page under the header
+	pdfRowsPerPage = 45
+	pdfDateFormat  = "2006-01-02"
+)
+
+//pdfText for escape text for a PDF string, the standard fonts only have Latin-1
+//so other characters like Thai are written as '?'
+func pdfText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 255:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+//pdfPage is content stream of one page
+type pdfPage struct {
+	content bytes.Buffer
+	y       int
+}
+
+func (p *pdfPage) line(font string, size int, text string) {
+	fmt.Fprintf(&p.content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, pdfMargin, p.y, pdfText(text))
+	p.y -= pdfLineHeight
+}
+
+func (p *pdfPage) rule() {
+	fmt.Fprintf(&p.content, "%d %d m %d %d l S\n", pdfMargin, p.y+pdfLineHeight/2, pdfPageWidth-pdfMargin, p.y+pdfLineHeight/2)
+	p.y -= pdfLineHeight / 2
+}
+
+//pdfRow for one fixed width row of the transaction table
+func pdfRow(date, transactionType, counterparty, amount, balance string) string {
+	return fmt.Sprintf("%-19s %-16s %-14s %16s %16s", date, transactionType, counterparty, amount, balance)
+}
+
+//WritePDF for write statement as PDF with customer header on every page, opening balance on the first page
+//and closing balance with totals on the last page
+func WritePDF(w io.Writer, statement model.Statement) error {
+	location := statement.From.Location()
+	total := (len(statement.Transactions) + pdfRowsPerPage - 1) / pdfRowsPerPage
+	if total == 0 {
+		total = 1
+	}
+	pages := make([]*pdfPage, total)
+	for i := range pages {
+		page := &pdfPage{y: pdfPageHeight - pdfMargin}
+		page.line("F2", 14, statement.BankName+" - Statement of Account")
+		page.line("F1", 9, "Customer: "+statement.CustomerName)
+		page.line("F1", 9, "Account:  "+statement.AccountNumber+" ("+string(statement.Product)+", "+statement.Currency+")")
+		page.line("F1", 9, fmt.Sprintf("Period:   %s to %s", statement.From.Format(pdfDateFormat), statement.To.Add(-time.Nanosecond).Format(pdfDateFormat)))
+		page.line("F1", 9, fmt.Sprintf("Page %d of %d", i+1, total))
+		page.rule()
+		page.line("F2", 9, pdfRow("Date", "Type", "Counterparty", "Amount", "Balance"))
+		page.rule()
+		if i == 0 {
+			page.line("F1", 9, pdfRow(statement.From.Format(csvTimeFormat), "Opening balance", "", "", statement.OpeningBalance.String()))
+		}
+		pages[i] = page
+	}
+	for i, transactionLog := range statement.Transactions {
+		pages[i/pdfRowsPerPage].line("F1", 9, pdfRow(
+			transactionLog.CreatedAt.In(location).Format(csvTimeFormat),
+			string(transactionLog.Type),
+			transactionLog.CounterpartyAccount,
+			transactionLog.Amount.String(),
+			transactionLog.BalanceAfter.String(),
+		))
+	}
+	last := pages[total-1]
+	last.rule()
+	last.line("F2", 9, pdfRow(statement.To.Add(-time.Nanosecond).In(location).Format(csvTimeFormat), "Closing balance", "", "", statement.ClosingBalance.String()))
+	last.line("F1", 9, "Total in:  "+statement.TotalIn.String()+" "+statement.Currency)
+	last.line("F1", 9, "Total out: "+statement.TotalOut.String()+" "+statement.Currency)
+	last.line("F1", 9, "Generated: "+statement.GeneratedAt.In(location).Format(csvTimeFormat))
+
+	//objects 1 catalog, 2 pages, 3 and 4 fonts, then page and content of each page
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>",
+	}
+	kids := make([]string, total)
+	for i, page := range pages {
+		id := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, id+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), total)
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err := b.WriteTo(w)
+	return err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"testing"
+	"time"
+)
+
+func TestStatementReconciles(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			start := time.Now()
+			time.Sleep(5 * time.Millisecond)
+			bankAccount := createTestBankAccount(t, d, &alice, "111-1", "100")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+			if _, err := d.bankAccountService.DepositBankAccount(&model.Transaction{Amount: testMoney(t, "50")}, alice, bankAccount.ID.Hex(), AnyVersion); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(5 * time.Millisecond)
+			middle := time.Now()
+			time.Sleep(5 * time.Millisecond)
+			if _, err := d.bankAccountService.WithdrawBankAccount(&model.Transaction{Amount: testMoney(t, "30")}, alice, bankAccount.ID.Hex(), AnyVersion); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "20"), From: "111-1", To: "222-2"}, alice); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(5 * time.Millisecond)
+			end := time.Now()
+
+			for _, tc := range []struct {
+				name                      string
+				from, to                  time.Time
+				opening, closing, in, out string
+				transactions              int
+			}{
+				//Balance given at opening has no TransactionLog, it is opening Balance of the first period
+				{"from before the account was opened", start, middle, "100.00", "150.00", "50.00", "0.00", 1},
+				{"after the deposit", middle, end, "150.00", "100.00", "0.00", "50.00", 2},
+				{"whole life of the account", start, end, "100.00", "100.00", "50.00", "50.00", 3},
+			} {
+				statementResp, err := d.bankAccountService.Statement(alice, bankAccount.ID.Hex(), tc.from, tc.to)
+				if err != nil {
+					t.Fatalf("%s: %v", tc.name, err)
+				}
+				balance := statementResp.OpeningBalance
+				for _, transactionLog := range statementResp.Transactions {
+					if balance, err = balance.Add(transactionLog.Amount); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if balance.String() != statementResp.ClosingBalance.String() {
+					t.Fatalf("%s: opening %s plus entries is %s, closing is %s", tc.name, statementResp.OpeningBalance, balance, statementResp.ClosingBalance)
+				}
+				if statementResp.OpeningBalance.String() != tc.opening || statementResp.ClosingBalance.String() != tc.closing ||
+					statementResp.TotalIn.String() != tc.in || statementResp.TotalOut.String() != tc.out || len(statementResp.Transactions) != tc.transactions {
+					t.Fatalf("%s: statement is opening %s closing %s in %s out %s with %d entries, want %s %s %s %s with %d", tc.name,
+						statementResp.OpeningBalance, statementResp.ClosingBalance, statementResp.TotalIn, statementResp.TotalOut, len(statementResp.Transactions),
+						tc.opening, tc.closing, tc.in, tc.out, tc.transactions)
+				}
+			}
+
+			if _, err := d.bankAccountService.Statement(alice, bankAccount.ID.Hex(), end, start); err != ErrStatementPeriod {
+				t.Fatalf("statement from after to got %v, want %v", err, ErrStatementPeriod)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bankaccountapi/internal"
+	"bankaccountapi/repository"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//OpenStore for open repository.Store of Backend in config.toml
+func OpenStore(config internal.Config) (*repository.Store, error) {
+	switch config.Backend {
+	case internal.BackendMongo:
+		s.Server = config.Server
+		s.Database = config.Database
+		s.Connect()
+		migrate, err := repository.NeedMongoBankAccountMigration(dbs)
+		if err != nil {
+			return nil, err
+		}
+		if migrate {
+			return nil, errors.New("users still have bank accounts inside, run `bankaccountapi migrate-bank-accounts` first")
+		}
+		if err := repository.EnsureMongoIndex(dbs); err != nil {
+			return nil, err
+		}
+		return repository.NewMongoStore(dbs), nil
+	case internal.BackendPostgres:
+		store, _, err := openSQL(repository.DialectPostgres, config.DSN)
+		return store, err
+	case internal.BackendSQLite:
+		//SQLite has one writer, take the write lock when transaction begins so
+		//SELECT then UPDATE in one transaction can not deadlock
+		dsn := config.DSN
+		if strings.Contains(dsn, "?") {
+			dsn += "&_txlock=immediate"
+		} else {
+			dsn += "?_txlock=immediate"
+		}
+		store, db, err := openSQL(repository.DialectSQLite, dsn)
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(1)
+		return store, nil
+	}
+	return nil, fmt.Errorf("config.toml: unknown backend %q", config.Backend)
+}
+
+func openSQL(dialect repository.Dialect, dsn string) (*repository.Store, *sql.DB, error) {
+	db, err := sql.Open(string(dialect), dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, nil, err
+	}
+	if err := repository.Migrate(db, dialect); err != nil {
+		return nil, nil, err
+	}
+	return repository.NewSQLStore(db, dialect), db, nil
+}
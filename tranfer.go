@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//tranferLeg is one balance change of TranferLog, id is what ledger writes it under and key is what
+//BankAccount keeps it pending by, so legs of the same BankAccount are kept apart by key
+type tranferLeg struct {
+	id              bson.ObjectId
+	key             bson.ObjectId
+	accountNumber   string
+	counterparty    string
+	amount          model.Money
+	transactionType model.TransactionType
+	check           func(bankAccount model.BankAccount) error
+}
+
+//creditKey for key of leg that arrives by id, it is derived from id so it is the same every time apply resumes
+//TranferLog and never the key of the leg that leaves by id
+func creditKey(id bson.ObjectId) bson.ObjectId {
+	sum := sha256.Sum256([]byte(string(id) + "credit"))
+	return bson.ObjectId(sum[:12])
+}
+
+//legs for every balance change of TranferLog in the order they are applied, checkFrom is checked
+//with checkFunds on the first leg that leaves AccountNumberFrom
+func legs(tranferLog *model.TranferLog, checkFrom func(bankAccount model.BankAccount) error) ([]tranferLeg, error) {
+	amount, err := tranferLog.Amount.Neg()
+	if err != nil {
+		return nil, err
+	}
+	if tranferLog.Kind == model.TranferKindFee {
+		//fee was covered when what it is charged for was checked, From and To of it are never the same
+		//so both legs keep the key they were applied by before
+		return []tranferLeg{
+			{tranferLog.ID, tranferLog.ID, tranferLog.AccountNumberFrom, tranferLog.AccountNumberTo, amount, model.TransactionTypeFee, nil},
+			{tranferLog.ID, tranferLog.ID, tranferLog.AccountNumberTo, tranferLog.AccountNumberFrom, tranferLog.ReceivedAmount(), model.TransactionTypeFeeIncome, nil},
+		}, nil
+	}
+	//From is checked while it is locked, so it can not be overdrawn by anything racing it
+	checkOut := checkFunds
+	if checkFrom != nil {
+		checkOut = func(bankAccount model.BankAccount) error {
+			if err := checkFrom(bankAccount); err != nil {
+				return err
+			}
+			return checkFunds(bankAccount)
+		}
+	}
+	typeOut := model.TransactionTypeTranferOut
+	if tranferLog.Kind == model.TranferKindWithdraw {
+		typeOut = model.TransactionTypeWithdraw
+	}
+	result := []tranferLeg{{tranferLog.ID, tranferLog.ID, tranferLog.AccountNumberFrom, tranferLog.AccountNumberTo, amount, typeOut, checkOut}}
+	if tranferLog.FeeID != "" {
+		fee, err := tranferLog.Fee.Neg()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tranferLeg{tranferLog.FeeID, tranferLog.FeeID, tranferLog.AccountNumberFrom, tranferLog.FeeAccountNumber, fee, model.TransactionTypeFee, checkFunds})
+	}
+	if tranferLog.AccountNumberTo != "" {
+		result = append(result, tranferLeg{tranferLog.ID, creditKey(tranferLog.ID), tranferLog.AccountNumberTo, tranferLog.AccountNumberFrom, tranferLog.ReceivedAmount(), model.TransactionTypeTranferIn, nil})
+	}
+	if tranferLog.FeeID != "" {
+		result = append(result, tranferLeg{tranferLog.FeeID, creditKey(tranferLog.FeeID), tranferLog.FeeAccountNumber, tranferLog.AccountNumberFrom, tranferLog.FeeTo, model.TransactionTypeFeeIncome, nil})
+	}
+	return result, nil
+}
+
+//apply runs TranferLog through the two-phase commit until it is done, checkFrom is checked on From
+//with its Balance after Amount left and may be nil. Each step is safe to repeat, so apply can resume
+//a TranferLog from any state. Fee leaves with Amount in the same step, so either both moved or none.
+func (t *TranferServiceImplement) apply(tranferLog *model.TranferLog, checkFrom func(bankAccount model.BankAccount) error) error {
+	tranferLegs, err := legs(tranferLog, checkFrom)
+	if err != nil {
+		return err
+	}
+	if tranferLog.State == model.TranferStateInitial {
+		err = t.setState(tranferLog, model.TranferStateInitial, model.TranferStatePending)
+		if err != nil {
+			return err
+		}
+	}
+	if tranferLog.State == model.TranferStatePending {
+		for _, leg := range tranferLegs {
+			err = t.changeBalance(tranferLog, leg)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if rollbackErr := t.rollback(tranferLog); rollbackErr != nil {
+				return fmt.Errorf("%s (rollback: %s)", err, rollbackErr)
+			}
+			return err
+		}
+		err = t.setState(tranferLog, model.TranferStatePending, model.TranferStateApplied)
+		if err != nil {
+			return err
+		}
+	}
+	if tranferLog.State == model.TranferStateApplied {
+		for _, leg := range tranferLegs {
+			err = t.bankAccounts.FinishTranfer(leg.key, leg.accountNumber)
+			if err != nil {
+				return err
+			}
+		}
+		return t.setState(tranferLog, model.TranferStateApplied, model.TranferStateDone)
+	}
+	return nil
+}
+
+//rollback reverts every balance change of TranferLog and marks it cancelled
+func (t *TranferServiceImplement) rollback(tranferLog *model.TranferLog) error {
+	var err error
+	if tranferLog.State == model.TranferStateInitial {
+		return t.setState(tranferLog, model.TranferStateInitial, model.TranferStateCancelled)
+	}
+	if tranferLog.State == model.TranferStatePending {
+		err = t.setState(tranferLog, model.TranferStatePending, model.TranferStateCanceling)
+		if err != nil {
+			return err
+		}
+	}
+	if tranferLog.State != model.TranferStateCanceling {
+		return fmt.Errorf("tranfer %s can not rollback in state %s", tranferLog.ID.Hex(), tranferLog.State)
+	}
+	tranferLegs, err := legs(tranferLog, nil)
+	if err != nil {
+		return err
+	}
+	for _, leg := range tranferLegs {
+		amount, err := leg.amount.Neg()
+		if err != nil {
+			return err
+		}
+		err = t.revertBalance(tranferLog, leg, amount)
+		if err != nil {
+			return err
+		}
+	}
+	return t.setState(tranferLog, model.TranferStateCanceling, model.TranferStateCancelled)
+}
+
+//setState moves TranferLog from state to next only if nobody else moved it first
+func (t *TranferServiceImplement) setState(tranferLog *model.TranferLog, state model.TranferState, next model.TranferState) error {
+	now := time.Now()
+	err := t.tranfers.SetState(tranferLog.ID, state, next, now)
+	if err != nil {
+		return err
+	}
+	tranferLog.State = next
+	tranferLog.LastModified = now
+	return nil
+}
+
+//changeBalance adds amount of leg to its bank account once if its check passes and writes it to ledger
+func (t *TranferServiceImplement) changeBalance(tranferLog *model.TranferLog, leg tranferLeg) error {
+	//when it was applied before crash, ledger gets the balance as it is now if it was not written yet
+	bankAccount, err := t.bankAccounts.ApplyTranfer(leg.key, leg.accountNumber, leg.amount, leg.check)
+	if err == repository.ErrNotFound {
+		return fmt.Errorf("Not Have BankAccount %s", leg.accountNumber)
+	}
+	if err != nil {
+		return err
+	}
+	return t.insertTransactionLog(tranferLog, leg, bankAccount, leg.amount, leg.transactionType)
+}
+
+//revertBalance undoes changeBalance of leg if it was applied
+func (t *TranferServiceImplement) revertBalance(tranferLog *model.TranferLog, leg tranferLeg, amount model.Money) error {
+	bankAccount, err := t.bankAccounts.RevertTranfer(leg.key, leg.accountNumber, amount)
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return t.insertTransactionLog(tranferLog, leg, bankAccount, amount, model.TransactionTypeTranferReversal)
+}
+
+//insertTransactionLog writes TransactionLog once per leg and type,
+//so a Tranfer resumed by RecoverTranfer does not write it twice
+func (t *TranferServiceImplement) insertTransactionLog(tranferLog *model.TranferLog, leg tranferLeg, bankAccount model.BankAccount, amount model.Money, transactionType model.TransactionType) error {
+	return t.transactions.InsertTranfer(&model.TransactionLog{
+		ID:                  bson.NewObjectId(),
+		UserID:              bankAccount.UserID,
+		BankAccountID:       bankAccount.ID,
+		AccountNumber:       bankAccount.AccountNumber,
+		Type:                transactionType,
+		Amount:              amount,
+		BalanceAfter:        bankAccount.Balance,
+		CounterpartyAccount: leg.counterparty,
+		TranferID:           leg.id,
+		CreatedBy:           tranferLog.UserFrom,
+		CreatedAt:           time.Now(),
+	})
+}
+
+//RecoverTranfer finishes or rolls back every Tranfer left behind by a crash,
+//TranferLog of TranferKindFee is only stored after what it is charged for is done so it is finished even from initial
+func (t *TranferServiceImplement) RecoverTranfer() error {
+	tranferLogs, err := t.tranfers.FindByState(
+		model.TranferStateInitial,
+		model.TranferStatePending,
+		model.TranferStateApplied,
+		model.TranferStateCanceling,
+	)
+	if err != nil {
+		return err
+	}
+	for i := range tranferLogs {
+		tranferLog := &tranferLogs[i]
+		switch {
+		case tranferLog.State == model.TranferStateInitial && tranferLog.Kind == model.TranferKindFee:
+			err = t.apply(tranferLog, nil)
+		case tranferLog.State == model.TranferStateInitial, tranferLog.State == model.TranferStateCanceling:
+			err = t.rollback(tranferLog)
+		default:
+			err = t.apply(tranferLog, nil)
+		}
+		if err != nil && tranferLog.State != model.TranferStateCancelled {
+			return fmt.Errorf("recover tranfer %s: %s", tranferLog.ID.Hex(), err)
+		}
+	}
+	return nil
+}
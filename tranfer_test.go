@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bankaccountapi/fx"
+	"bankaccountapi/model"
+	"bankaccountapi/repository"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//unfinishedTranfers for TranferLog RecoverTranfer has to finish or roll back
+func unfinishedTranfers(t *testing.T, store *repository.Store) []model.TranferLog {
+	t.Helper()
+	tranferLogs, err := store.Tranfers.FindByState(model.TranferStateInitial, model.TranferStatePending, model.TranferStateApplied, model.TranferStateCanceling)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tranferLogs
+}
+
+func pendingTranfersOf(t *testing.T, store *repository.Store, accountNumber string) int {
+	t.Helper()
+	bankAccount, err := store.BankAccounts.FindByAccountNumber(accountNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(bankAccount.PendingTranfers)
+}
+
+func TestRecoverTranferAfterCrash(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		//errs is ApplyTranfer or FinishTranfer the server stops in
+		errs       func(faulty *faultyBankAccounts) map[string]error
+		state      model.TranferState
+		balanceTo  string
+		pendingTo  int
+		pendingOut int
+	}{
+		//From is debited, To is not credited yet
+		{"between debit and credit", func(faulty *faultyBankAccounts) map[string]error { return faulty.applyErr }, model.TranferStatePending, "1.00", 0, 1},
+		//both moved, From is finished and To is not
+		{"between finish of debit and credit", func(faulty *faultyBankAccounts) map[string]error { return faulty.finishErr }, model.TranferStateApplied, "41.00", 1, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := repository.NewMemoryStore()
+			faulty := newFaultyBankAccounts(store)
+			d := newTestDAO(t, store)
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			createTestBankAccount(t, d, &alice, "111-1", "100")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+
+			faulty.fail(tc.errs(faulty), "222-2", errCrash)
+			crash(t, func() {
+				d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "40"), From: "111-1", To: "222-2"}, alice)
+			})
+			faulty.fail(tc.errs(faulty), "222-2", nil)
+
+			unfinished := unfinishedTranfers(t, store)
+			if len(unfinished) != 1 || unfinished[0].State != tc.state {
+				t.Fatalf("unfinished tranfers %+v, want one %s", unfinished, tc.state)
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "60.00" {
+				t.Fatalf("balance of 111-1 is %s after crash, want 60.00", balance)
+			}
+			if balance := balanceOf(t, store, "222-2"); balance != tc.balanceTo {
+				t.Fatalf("balance of 222-2 is %s after crash, want %s", balance, tc.balanceTo)
+			}
+			if pending := pendingTranfersOf(t, store, "111-1"); pending != tc.pendingOut {
+				t.Fatalf("111-1 has %d PendingTranfers after crash, want %d", pending, tc.pendingOut)
+			}
+			if pending := pendingTranfersOf(t, store, "222-2"); pending != tc.pendingTo {
+				t.Fatalf("222-2 has %d PendingTranfers after crash, want %d", pending, tc.pendingTo)
+			}
+
+			//server starts again, recovering twice is the same as once
+			for run := 0; run < 2; run++ {
+				if err := d.tranferService.RecoverTranfer(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if unfinished := unfinishedTranfers(t, store); len(unfinished) != 0 {
+				t.Fatalf("%d tranfers left unfinished after recovery, want 0", len(unfinished))
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "60.00" {
+				t.Fatalf("balance of 111-1 is %s, want 60.00", balance)
+			}
+			if balance := balanceOf(t, store, "222-2"); balance != "41.00" {
+				t.Fatalf("balance of 222-2 is %s, want 41.00", balance)
+			}
+			for _, accountNumber := range []string{"111-1", "222-2"} {
+				if pending := pendingTranfersOf(t, store, accountNumber); pending != 0 {
+					t.Fatalf("%s has %d PendingTranfers after recovery, want 0", accountNumber, pending)
+				}
+			}
+			bob = findTestUser(t, d, bob.ID.Hex())
+			transactions, err := d.bankAccountService.FindAllTransaction(bob, bob.UserBankAccount[0].ID.Hex(), model.TransactionFilter{Page: 1, Limit: TransactionPageLimit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			credits := 0
+			for _, transaction := range transactions {
+				if transaction.Type == model.TransactionTypeTranferIn {
+					credits++
+				}
+			}
+			if credits != 1 {
+				t.Fatalf("ledger of 222-2 has %d tranfer in, want 1", credits)
+			}
+		})
+	}
+}
+
+func TestTranferToItself(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			tranferService := d.tranferService.(*TranferServiceImplement)
+			alice := createTestUser(t, d, "alice")
+			createTestBankAccount(t, d, &alice, "111-1", "100")
+
+			if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "40"), From: "111-1", To: "111-1"}, alice); err != ErrTranferSameAccount {
+				t.Fatalf("tranfer from 111-1 to itself got %v, want %v", err, ErrTranferSameAccount)
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "100.00" {
+				t.Fatalf("balance of 111-1 is %s, want 100.00", balance)
+			}
+
+			//legs of one TranferLog on the same BankAccount do not hide each other
+			tranferLog := model.TranferLog{
+				ID:                bson.NewObjectId(),
+				UserFrom:          alice.ID,
+				UserTo:            alice.ID,
+				AccountNumberFrom: "111-1",
+				AccountNumberTo:   "111-1",
+				Amount:            testMoney(t, "40"),
+				AmountTo:          testMoney(t, "40"),
+				State:             model.TranferStateInitial,
+				LastModified:      time.Now(),
+			}
+			if err := store.Tranfers.Insert(&tranferLog); err != nil {
+				t.Fatal(err)
+			}
+			if err := tranferService.apply(&tranferLog, nil); err != nil {
+				t.Fatal(err)
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "100.00" {
+				t.Fatalf("balance of 111-1 is %s after debit and credit of 40, want 100.00", balance)
+			}
+			if pending := pendingTranfersOf(t, store, "111-1"); pending != 0 {
+				t.Fatalf("111-1 has %d PendingTranfers, want 0", pending)
+			}
+		})
+	}
+}
+
+func TestTranferAcrossCurrencies(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			rates, err := fx.NewFileRateProvider(filepath.Join(t.TempDir(), "rates.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := rates.SetRates([]model.ExchangeRate{{From: "THB", To: "JPY", Rate: "4.1"}, {From: "USD", To: "THB", Rate: "35"}}); err != nil {
+				t.Fatal(err)
+			}
+			d.tranferService.(*TranferServiceImplement).rates = rates
+			alice := createTestUser(t, d, "alice")
+			bob := createTestUser(t, d, "bob")
+			createTestBankAccount(t, d, &alice, "111-1", "1000")
+			createTestBankAccount(t, d, &bob, "222-2", "1")
+			for _, bankAccount := range []model.BankAccount{
+				{BankName: "MUFG", AccountNumber: "333-3", Currency: "JPY", Balance: model.Money{Amount: 1, Currency: "JPY"}},
+				{BankName: "Chase", AccountNumber: "444-4", Currency: "USD", Balance: model.Money{Amount: 100, Currency: "USD"}},
+				{BankName: "BNP", AccountNumber: "555-5", Currency: "EUR", Balance: model.Money{Amount: 100, Currency: "EUR"}},
+			} {
+				if _, err := d.bankAccountService.CreateBankAccount(&bankAccount, bob); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, tc := range []struct {
+				to, amount, amountTo, rate, balanceTo string
+			}{
+				//same currency has no rate
+				{"222-2", "10", "10.00 THB", "", "11.00"},
+				//411.025 yen is rounded half to even to whole yen
+				{"333-3", "100.25", "411 JPY", "4.1", "412"},
+				//rate of the other way round is inverted
+				{"444-4", "350", "10.00 USD", "0.0285714286", "11.00"},
+			} {
+				tranferLog, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, tc.amount), From: "111-1", To: tc.to}, alice)
+				if err != nil {
+					t.Fatal(err)
+				}
+				alice = findTestUser(t, d, alice.ID.Hex())
+				stored, err := store.Tranfers.FindByID(tranferLog.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, tranferLog := range []model.TranferLog{*tranferLog, stored} {
+					amountTo := tranferLog.AmountTo.String() + " " + tranferLog.AmountTo.Currency
+					if tranferLog.Amount != testMoney(t, tc.amount) || amountTo != tc.amountTo || tranferLog.Rate != tc.rate {
+						t.Fatalf("tranfer to %s is %s THB to %s at %q, want %s THB to %s at %q", tc.to, tranferLog.Amount, amountTo, tranferLog.Rate, tc.amount, tc.amountTo, tc.rate)
+					}
+				}
+				if balance := balanceOf(t, store, tc.to); balance != tc.balanceTo {
+					t.Fatalf("balance of %s is %s, want %s", tc.to, balance, tc.balanceTo)
+				}
+			}
+
+			if _, err := d.tranferService.Tranfer(&model.Tranfer{Amount: testMoney(t, "10"), From: "111-1", To: "555-5"}, alice); err != fx.ErrRateNotFound {
+				t.Fatalf("tranfer to EUR got %v, want %v", err, fx.ErrRateNotFound)
+			}
+			if balance := balanceOf(t, store, "111-1"); balance != "539.75" {
+				t.Fatalf("balance of 111-1 is %s, want 539.75", balance)
+			}
+		})
+	}
+}
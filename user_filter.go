@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo"
+)
+
+const (
+	//UserPageLimit is default page size of User
+	UserPageLimit = 50
+	//UserPageLimitMax is max page size of User
+	UserPageLimitMax = 500
+)
+
+//ErrUserCursor is returned when cursor is broken or was made for another sort
+var ErrUserCursor = errors.New("cursor is not of this sort, start again without cursor")
+
+//userCursor is what cursor of page of User holds, it is sent as base64 of JSON
+type userCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Value      string `json:"v"`
+	ID         string `json:"i"`
+}
+
+//encodeUserCursor for cursor of page starting after user in order of filter
+func encodeUserCursor(filter model.UserFilter, user model.User) string {
+	b, _ := json.Marshal(userCursor{
+		Sort:       filter.Sort,
+		Descending: filter.Descending,
+		Value:      user.SortValue(filter.Sort),
+		ID:         user.ID.Hex(),
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//decodeUserCursor for UserCursor of cursor, it must be made for Sort and Descending of filter
+func decodeUserCursor(filter model.UserFilter, cursor string) (*model.UserCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrUserCursor
+	}
+	var decoded userCursor
+	if err := json.Unmarshal(b, &decoded); err != nil || !bson.IsObjectIdHex(decoded.ID) {
+		return nil, ErrUserCursor
+	}
+	if decoded.Sort != filter.Sort || decoded.Descending != filter.Descending {
+		return nil, ErrUserCursor
+	}
+	return &model.UserCursor{Value: decoded.Value, ID: bson.ObjectIdHex(decoded.ID)}, nil
+}
+
+//ParseUserFilter for read limit, cursor, name, email, tel, age_min, age_max, bank and sort in query string.
+//sort is one of model.UserSorts, with - in front it is descending
+func ParseUserFilter(c echo.Context) (model.UserFilter, error) {
+	var err error
+	filter := model.UserFilter{
+		NamePrefix: strings.TrimSpace(c.QueryParam("name")),
+		Email:      strings.TrimSpace(c.QueryParam("email")),
+		Tel:        strings.TrimSpace(c.QueryParam("tel")),
+		BankName:   strings.TrimSpace(c.QueryParam("bank")),
+		Sort:       model.UserSortID,
+		Limit:      UserPageLimit,
+	}
+	if sort := c.QueryParam("sort"); sort != "" {
+		filter.Descending = strings.HasPrefix(sort, "-")
+		filter.Sort = strings.TrimPrefix(sort, "-")
+		known := false
+		for _, userSort := range model.UserSorts {
+			known = known || filter.Sort == userSort
+		}
+		if !known {
+			return filter, errors.New("sort must be one of " + strings.Join(model.UserSorts, ", ") + ", with - in front for descending")
+		}
+	}
+	if ageMin := c.QueryParam("age_min"); ageMin != "" {
+		filter.AgeMin, err = strconv.ParseInt(ageMin, 10, 64)
+		if err != nil || filter.AgeMin < 1 {
+			return filter, errors.New("age_min must be positive number")
+		}
+	}
+	if ageMax := c.QueryParam("age_max"); ageMax != "" {
+		filter.AgeMax, err = strconv.ParseInt(ageMax, 10, 64)
+		if err != nil || filter.AgeMax < 1 || filter.AgeMax < filter.AgeMin {
+			return filter, errors.New("age_max must be positive number not below age_min")
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > UserPageLimitMax {
+			return filter, errors.New("limit must be between 1 and " + strconv.Itoa(UserPageLimitMax))
+		}
+	}
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		if filter.After, err = decodeUserCursor(filter, cursor); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+//MapJSONUserPage for MapJSONUserPage, nextCursor is empty on the last page
+func MapJSONUserPage(user interface{}, filter model.UserFilter, nextCursor string) interface{} {
+	dataJSON := map[string]interface{}{
+		"user":        user,
+		"limit":       filter.Limit,
+		"next_cursor": nextCursor,
+	}
+	return dataJSON
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bankaccountapi/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+//createFilterTestUsers for users to filter, each with bank account in bank when bank is not empty
+func createFilterTestUsers(t *testing.T, d *DataObjectAccess) {
+	t.Helper()
+	for i, u := range []struct {
+		username, firstName, lastName string
+		age                           int64
+		bank                          string
+	}{
+		{"ann", "Ann", "Smith", 25, "KBank"},
+		{"anna", "Anna", "Jones", 40, "SCB"},
+		{"bob", "Bob", "Anderson", 25, "KBank"},
+		{"cat", "Cat", "Brown", 60, ""},
+		{"dan", "Dan", "Annis", 33, "KBank"},
+	} {
+		user, err := d.userService.InsertUser(&model.User{
+			FirstName: u.firstName,
+			LastName:  u.lastName,
+			Username:  u.username,
+			Password:  "password of " + u.username,
+			IDcard:    "1100700000000",
+			Age:       u.age,
+			Email:     u.username + "@example.com",
+			Tel:       "081234567" + strconv.Itoa(i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.bank != "" {
+			if _, err := d.bankAccountService.CreateBankAccount(&model.BankAccount{BankName: u.bank, AccountNumber: "100-" + u.username, Balance: testMoney(t, "1")}, *user); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func usernamesOf(users []model.User) string {
+	var usernames []string
+	for _, user := range users {
+		usernames = append(usernames, user.Username)
+	}
+	return strings.Join(usernames, ",")
+}
+
+//parseTestUserFilter for UserFilter of query string like ParseUserFilter reads it from request
+func parseTestUserFilter(query url.Values) (model.UserFilter, error) {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/v1/users?"+query.Encode(), nil), httptest.NewRecorder())
+	return ParseUserFilter(c)
+}
+
+func TestFindUserFilters(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			createFilterTestUsers(t, d)
+			for _, tc := range []struct {
+				query string
+				want  string
+			}{
+				{"", "ann,anna,bob,cat,dan"},
+				//prefix of first or last name, case is kept
+				{"name=Ann", "ann,anna,dan"},
+				{"name=ann", ""},
+				{"email=bob@example.com", "bob"},
+				{"tel=0812345673", "cat"},
+				{"age_min=30&age_max=50", "anna,dan"},
+				{"age_min=40", "anna,cat"},
+				{"bank=KBank", "ann,bob,dan"},
+				{"name=An&bank=KBank&sort=last_name", "bob,dan,ann"},
+				{"sort=-age", "cat,anna,dan,bob,ann"},
+			} {
+				query, err := url.ParseQuery(tc.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				filter, err := parseTestUserFilter(query)
+				if err != nil {
+					t.Fatalf("%s: %v", tc.query, err)
+				}
+				users, _, err := d.userService.FindAllUser(filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := usernamesOf(users); got != tc.want {
+					t.Errorf("%q found %s, want %s", tc.query, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFindUserCursorRoundTrip(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDAO(t, store)
+			createFilterTestUsers(t, d)
+			for _, tc := range []struct {
+				sort string
+				want string
+			}{
+				{"id", "ann,anna,bob,cat,dan"},
+				{"username", "ann,anna,bob,cat,dan"},
+				{"-first_name", "dan,cat,bob,anna,ann"},
+				//ann and bob have the same age and keep order of ID
+				{"age", "ann,bob,dan,anna,cat"},
+				{"-age", "cat,anna,dan,bob,ann"},
+			} {
+				query := url.Values{"sort": {tc.sort}, "limit": {"2"}}
+				var found []model.User
+				pages := 0
+				for {
+					filter, err := parseTestUserFilter(query)
+					if err != nil {
+						t.Fatalf("sort %s page %d: %v", tc.sort, pages+1, err)
+					}
+					users, cursor, err := d.userService.FindAllUser(filter)
+					if err != nil {
+						t.Fatal(err)
+					}
+					found = append(found, users...)
+					pages++
+					if cursor == "" {
+						break
+					}
+					if pages > 3 {
+						t.Fatalf("sort %s has more than 3 pages of 2 of 5 users", tc.sort)
+					}
+					query.Set("cursor", cursor)
+				}
+				if got := usernamesOf(found); got != tc.want || pages != 3 {
+					t.Errorf("sort %s found %s in %d pages, want %s in 3", tc.sort, got, pages, tc.want)
+				}
+			}
+
+			//cursor only goes with the sort it was made for
+			first, err := parseTestUserFilter(url.Values{"sort": {"age"}, "limit": {"2"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, cursor, err := d.userService.FindAllUser(first)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, query := range []url.Values{
+				{"sort": {"-age"}, "cursor": {cursor}},
+				{"sort": {"username"}, "cursor": {cursor}},
+				{"sort": {"age"}, "cursor": {"not-a-cursor"}},
+			} {
+				if _, err := parseTestUserFilter(query); err != ErrUserCursor {
+					t.Errorf("%s got %v, want %v", query.Encode(), err, ErrUserCursor)
+				}
+			}
+		})
+	}
+}